@@ -0,0 +1,192 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// securityAdvisories adds a "Security fixes" section to the release notes, listing the
+// repository's published security advisories patched in the release
+var securityAdvisories bool
+
+// securityAdvisoryIDs are advisories, by GHSA ID, listed in the section even if their
+// patched versions don't name the release
+var securityAdvisoryIDs []string
+
+// securityFixesSection is the heading of the security advisories in the release notes
+const securityFixesSection = "Security fixes"
+
+// advisoryIDExpression matches a Github security advisory ID, in any case, eg: GHSA-abcd-1234-wxyz
+var advisoryIDExpression = regexp.MustCompile(`(?i)^GHSA(-[0-9a-z]{4}){3}$`)
+
+// advisoriesPerPage is the most security advisories Github lists in a page
+const advisoriesPerPage = 100
+
+// securityAdvisory is the subset of a Github repository security advisory response we use
+type securityAdvisory struct {
+	GHSAID   string `json:"ghsa_id"`
+	CVEID    string `json:"cve_id"`
+	HTMLURL  string `json:"html_url"`
+	Summary  string `json:"summary"`
+	Severity string `json:"severity"`
+	// Vulnerabilities are the affected packages, and the versions fixing each
+	Vulnerabilities []struct {
+		PatchedVersions string `json:"patched_versions"`
+	} `json:"vulnerabilities"`
+}
+
+// patchedIn returns true if any of the advisory's patched versions is the release's. Patched
+// versions are a version, or a comma separated list of them, optionally with an operator,
+// eg: ">= 1.2.3"; the release fixes an advisory if it is the first patched version.
+func (a *securityAdvisory) patchedIn(v *version) bool {
+	for _, vuln := range a.Vulnerabilities {
+		for _, s := range strings.Split(vuln.PatchedVersions, ",") {
+			s = strings.TrimLeft(strings.TrimSpace(s), ">= ")
+			patched, err := parseVersion(s)
+			if err != nil {
+				continue
+			}
+			if patched.compare(v) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listSecurityAdvisories retrieves the repository's published security advisories, a page
+// at a time until the last
+func listSecurityAdvisories(auth *UserAuth, gURL *gitURL) ([]securityAdvisory, error) {
+	var advisories []securityAdvisory
+
+	for page := 1; ; page++ {
+		// newGetRequest sends its params as the body, which the filter isn't read from
+		query := url.Values{"state": {"published"}, "per_page": {strconv.Itoa(advisoriesPerPage)}, "page": {strconv.Itoa(page)}}
+		req, err := newGetRequest(githubRepoURL(gURL, "security-advisories?"+query.Encode()), url.Values{})
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range authHeaders(auth) {
+			req.Header.Set(k, v)
+		}
+
+		body, err := makeHTTPRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageAdvisories []securityAdvisory
+		if err = json.Unmarshal(body, &pageAdvisories); err != nil {
+			return nil, err
+		}
+		advisories = append(advisories, pageAdvisories...)
+
+		if len(pageAdvisories) < advisoriesPerPage {
+			return advisories, nil
+		}
+	}
+}
+
+// fixedAdvisories returns the advisories patched in the release, or listed by ID, most
+// severe first. Listed advisories must be among the published ones.
+func fixedAdvisories(advisories []securityAdvisory, tag string, ids []string) ([]securityAdvisory, error) {
+	listed := make(map[string]bool)
+	for _, id := range ids {
+		listed[strings.ToLower(id)] = true
+	}
+
+	// Tags that aren't semantic versions only have the listed advisories
+	v, _ := parseVersion(tag)
+
+	var fixed []securityAdvisory
+	for _, a := range advisories {
+		id := strings.ToLower(a.GHSAID)
+		if listed[id] || (v != nil && a.patchedIn(v)) {
+			fixed = append(fixed, a)
+		}
+		delete(listed, id)
+	}
+
+	var missing []string
+	for id := range listed {
+		missing = append(missing, id)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("security advisories %s are not published", strings.Join(missing, ", "))
+	}
+
+	sort.SliceStable(fixed, func(i, j int) bool {
+		return severityRank(strings.ToLower(fixed[i].Severity)) > severityRank(strings.ToLower(fixed[j].Severity))
+	})
+
+	return fixed, nil
+}
+
+// renderSecurityFixes renders the advisories as a release notes section, or returns
+// nothing if there are none
+func renderSecurityFixes(advisories []securityAdvisory) string {
+	if len(advisories) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", securityFixesSection)
+	for _, a := range advisories {
+		var details []string
+		if a.CVEID != "" {
+			details = append(details, a.CVEID)
+		}
+		if a.Severity != "" {
+			details = append(details, strings.ToLower(a.Severity))
+		}
+
+		fmt.Fprintf(&b, "* [%s](%s)", a.GHSAID, a.HTMLURL)
+		if len(details) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+		}
+		fmt.Fprintf(&b, ": %s\n", a.Summary)
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// securityFixesNotes renders the "Security fixes" section of the release notes for the tag
+func securityFixesNotes(auth *UserAuth, gURL *gitURL, tag string) (string, error) {
+	advisories, err := listSecurityAdvisories(auth, gURL)
+	if err != nil {
+		return "", err
+	}
+
+	fixed, err := fixedAdvisories(advisories, tag, securityAdvisoryIDs)
+	if err != nil {
+		return "", err
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Listing %d security advisories fixed in %s", len(fixed), tag))
+	}
+	return renderSecurityFixes(fixed), nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestFixedAdvisories checks advisories are picked by their first patched version, or by ID
+func TestFixedAdvisories(t *testing.T) {
+	advisories := []securityAdvisory{
+		{GHSAID: "GHSA-aaaa-aaaa-aaaa", Severity: "medium"},
+		{GHSAID: "GHSA-bbbb-bbbb-bbbb", Severity: "critical"},
+		{GHSAID: "GHSA-cccc-cccc-cccc", Severity: "low"},
+		{GHSAID: "GHSA-dddd-dddd-dddd", Severity: "high"},
+	}
+	advisories[0].Vulnerabilities = []struct {
+		PatchedVersions string `json:"patched_versions"`
+	}{{PatchedVersions: "1.2.0"}}
+	advisories[1].Vulnerabilities = []struct {
+		PatchedVersions string `json:"patched_versions"`
+	}{{PatchedVersions: "0.9.8, >= 1.2.0"}}
+	advisories[2].Vulnerabilities = []struct {
+		PatchedVersions string `json:"patched_versions"`
+	}{{PatchedVersions: "1.1.0"}}
+
+	fixed, err := fixedAdvisories(advisories, "v1.2.0", []string{"GHSA-DDDD-dddd-dddd"})
+	Nil(t, err)
+	var ids []string
+	for _, a := range fixed {
+		ids = append(ids, a.GHSAID)
+	}
+	Equal(t, []string{"GHSA-bbbb-bbbb-bbbb", "GHSA-dddd-dddd-dddd", "GHSA-aaaa-aaaa-aaaa"}, ids)
+
+	// Only the listed advisories are fixed in releases that aren't semantic versions
+	fixed, err = fixedAdvisories(advisories, "nightly", []string{"GHSA-cccc-cccc-cccc"})
+	Nil(t, err)
+	Len(t, fixed, 1)
+
+	_, err = fixedAdvisories(advisories, "v1.2.0", []string{"GHSA-eeee-eeee-eeee"})
+	EqualError(t, err, "security advisories ghsa-eeee-eeee-eeee are not published")
+}
+
+// TestRenderSecurityFixes checks each advisory is listed with its CVE and severity, if any
+func TestRenderSecurityFixes(t *testing.T) {
+	Equal(t, "", renderSecurityFixes(nil))
+
+	notes := renderSecurityFixes([]securityAdvisory{
+		{GHSAID: "GHSA-aaaa-aaaa-aaaa", CVEID: "CVE-2021-1234", Severity: "HIGH", Summary: "Path traversal", HTMLURL: "https://github.com/o/r/security/advisories/GHSA-aaaa-aaaa-aaaa"},
+		{GHSAID: "GHSA-bbbb-bbbb-bbbb", Summary: "Token leak", HTMLURL: "https://github.com/o/r/security/advisories/GHSA-bbbb-bbbb-bbbb"},
+	})
+	Equal(t, "## Security fixes\n\n"+
+		"* [GHSA-aaaa-aaaa-aaaa](https://github.com/o/r/security/advisories/GHSA-aaaa-aaaa-aaaa) (CVE-2021-1234, high): Path traversal\n"+
+		"* [GHSA-bbbb-bbbb-bbbb](https://github.com/o/r/security/advisories/GHSA-bbbb-bbbb-bbbb): Token leak", notes)
+}
+
+// TestSecurityFixesNotes checks the published advisories are retrieved for the section
+func TestSecurityFixesNotes(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/clcollins/go-git-release/security-advisories").
+		MatchParam("state", "published").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{
+				"ghsa_id":         "GHSA-aaaa-aaaa-aaaa",
+				"cve_id":          nil,
+				"html_url":        "https://github.com/clcollins/go-git-release/security/advisories/GHSA-aaaa-aaaa-aaaa",
+				"summary":         "Token leak",
+				"severity":        "medium",
+				"vulnerabilities": []map[string]string{{"patched_versions": "v0.2.0"}},
+			},
+		})
+
+	gURL := &gitURL{organization: "clcollins", repository: "go-git-release"}
+	notes, err := securityFixesNotes(&UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "v0.2.0")
+	Nil(t, err)
+	Contains(t, notes, "(medium): Token leak")
+	True(t, gock.IsDone())
+}
+
+// TestAdvisoryIDExpression checks GHSA IDs are accepted in any case, and other IDs refused
+func TestAdvisoryIDExpression(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"GHSA-abcd-1234-wxyz":  true,
+		"ghsa-abcd-1234-wxyz":  true,
+		"GHSA-ABCD-1234-WXYZ":  true,
+		"GHSA-abcd-1234":       false,
+		"CVE-2021-1234":        false,
+		"xGHSA-abcd-1234-wxyz": false,
+	} {
+		Equal(t, valid, advisoryIDExpression.MatchString(id), id)
+	}
+}
+
+// TestListSecurityAdvisories checks every page of the published advisories is retrieved
+func TestListSecurityAdvisories(t *testing.T) {
+	defer gock.Off()
+
+	full := make([]map[string]string, advisoriesPerPage)
+	for i := range full {
+		full[i] = map[string]string{"ghsa_id": fmt.Sprintf("GHSA-%04d-aaaa-aaaa", i)}
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/security-advisories").
+		MatchParam("page", "1").
+		Reply(200).
+		JSON(full)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/security-advisories").
+		MatchParam("page", "2").
+		Reply(200).
+		JSON([]map[string]string{{"ghsa_id": "GHSA-last-aaaa-aaaa"}})
+
+	advisories, err := listSecurityAdvisories(&UserAuth{AccessToken: "secret", TokenType: "token"}, &gitURL{organization: "o", repository: "r"})
+	Nil(t, err)
+	Len(t, advisories, advisoriesPerPage+1)
+	Equal(t, "GHSA-last-aaaa-aaaa", advisories[advisoriesPerPage].GHSAID)
+	True(t, gock.IsDone())
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// aliasTags are extra tags, eg: a major version tag v1, moved to the release commit
+var aliasTags []string
+
+// aliasUpdate is an alias tag to create or move to the release commit
+type aliasUpdate struct {
+	name string
+	// previousRef is the hash the alias ref had when it was checked, or zero if it is new.
+	// The remote must still have it before the alias is force pushed.
+	previousRef plumbing.Hash
+	// previous is the commit the alias pointed at, or zero if it is new
+	previous plumbing.Hash
+}
+
+// validAliasTag returns an error if the alias can't be used as a tag name
+func validAliasTag(alias, tag string) error {
+	if alias == "" || strings.ContainsAny(alias, " ~^:?*[\\") || strings.HasPrefix(alias, "-") {
+		return fmt.Errorf("invalid alias tag %q", alias)
+	}
+	if alias == tag {
+		return fmt.Errorf("alias tag %q is the release tag", alias)
+	}
+	return nil
+}
+
+// planAliasTags works out which alias tags need creating or moving to the target commit.
+// Moving an alias to a commit that doesn't descend from the one it points at, such as
+// when releasing a fix for an older version, must be confirmed.
+func planAliasTags(repo *git.Repository, aliases []string, target plumbing.Hash) ([]aliasUpdate, error) {
+	targetCommit, err := repo.CommitObject(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []aliasUpdate
+	for _, alias := range aliases {
+		ref, err := repo.Tag(alias)
+		if errors.Is(err, git.ErrTagNotFound) {
+			updates = append(updates, aliasUpdate{name: alias})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Annotated alias tags point at a tag object rather than the commit
+		previous := ref.Hash()
+		if t, err := repo.TagObject(ref.Hash()); err == nil {
+			previous = t.Target
+		}
+
+		if previous == target {
+			continue
+		}
+
+		previousCommit, err := repo.CommitObject(previous)
+		if err != nil {
+			return nil, fmt.Errorf("alias tag %s does not point at a commit: %w", alias, err)
+		}
+
+		forward, err := previousCommit.IsAncestor(targetCommit)
+		if err != nil {
+			return nil, err
+		}
+		if !forward {
+			fmt.Printf("Alias tag %s points at %s, which %s does not descend from\n", alias, previous.String()[:7], target.String()[:7])
+			if !confirm(fmt.Sprintf("Move %s anyway?", alias)) {
+				return nil, fmt.Errorf("moving alias tag %s %w", alias, errHalted)
+			}
+		}
+
+		updates = append(updates, aliasUpdate{name: alias, previousRef: ref.Hash(), previous: previous})
+	}
+
+	return updates, nil
+}
+
+// aliasNote is the line added to the release body listing the aliases of the release
+func aliasNote(aliases []string) string {
+	if len(aliases) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Also tagged as: %s", strings.Join(aliases, ", "))
+}
+
+// checkAliasLease returns an error if any of the alias tags changed on the remote since
+// they were planned, so a concurrent release's alias is never overwritten
+func checkAliasLease(repo *git.Repository, updates []aliasUpdate) error {
+	r, err := repo.Remote(remote)
+	if err != nil {
+		return err
+	}
+
+	u, err := remoteURL(repo, remote)
+	if err != nil {
+		return err
+	}
+
+	auth, err := gitAuth(u)
+	if err != nil {
+		return err
+	}
+
+	refs, err := r.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return err
+	}
+
+	current := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for _, ref := range refs {
+		current[ref.Name()] = ref.Hash()
+	}
+
+	for _, u := range updates {
+		if current[plumbing.NewTagReferenceName(u.name)] != u.previousRef {
+			return fmt.Errorf("alias tag %s changed on %s since it was checked; not moving it", u.name, remote)
+		}
+	}
+
+	return nil
+}
+
+// updateAliasTags points the alias tags at the target commit and force pushes them
+func updateAliasTags(repo *git.Repository, updates []aliasUpdate, target plumbing.Hash) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := checkAliasLease(repo, updates)
+	if err != nil {
+		return err
+	}
+
+	var refSpecs []config.RefSpec
+	for _, u := range updates {
+		name := plumbing.NewTagReferenceName(u.name)
+		err = repo.Storer.SetReference(plumbing.NewHashReference(name, target))
+		if err != nil {
+			return err
+		}
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("+%s:%s", name, name)))
+
+		if verbose {
+			if u.previous.IsZero() {
+				noteInfo(fmt.Sprintf("Creating alias tag %s", u.name))
+			} else {
+				noteInfo(fmt.Sprintf("Moving alias tag %s from %s", u.name, u.previous.String()[:7]))
+			}
+		}
+	}
+
+	return pushRefSpecs(repo, refSpecs)
+}
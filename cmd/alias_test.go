@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestPlanAliasTags checks only aliases that are new or behind the release are updated
+func TestPlanAliasTags(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.Commit("first", nil)
+	second := repo.Commit("second", nil)
+	repo.Tag("v1", first)
+	repo.Tag("v1.1", second)
+
+	updates, err := planAliasTags(repo.Repository, []string{"v1", "v1.1", "latest"}, second.Hash)
+	Nil(t, err)
+	Equal(t, []aliasUpdate{
+		{name: "v1", previousRef: first.Hash, previous: first.Hash},
+		{name: "latest"},
+	}, updates)
+}
+
+// TestValidAliasTag checks alias tag names are validated
+func TestValidAliasTag(t *testing.T) {
+	Nil(t, validAliasTag("v1", "v1.4.2"))
+	Error(t, validAliasTag("v1.4.2", "v1.4.2"))
+	Error(t, validAliasTag("v1 latest", "v1.4.2"))
+	Error(t, validAliasTag("", "v1.4.2"))
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// archiveFile is a file to be written into an archive
+type archiveFile struct {
+	// path is the location of the file on disk
+	path string
+	// name is the path of the file inside the archive
+	name string
+}
+
+// archiveTime returns the modification time given to every archive entry, so archives
+// are byte-identical across rebuilds of the same commit: SOURCE_DATE_EPOCH, if set, as
+// reproducible builds define it, or else the time of the checked out commit
+func archiveTime(repo *git.Repository) (time.Time, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	commit, err := headCommit(repo)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return commit.Committer.When.UTC(), nil
+}
+
+// archiveMode returns the permissions of a file in an archive: executable or not,
+// whatever the umask of the build was
+func archiveMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// sortedArchiveFiles returns the files ordered by their names in the archive
+func sortedArchiveFiles(files []archiveFile) []archiveFile {
+	sorted := append([]archiveFile{}, files...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted
+}
+
+// writeTarGz creates a gzipped tarball at dest containing the provided files. The entries
+// are sorted, and have the modTime and no owner, so the tarball only depends on the files'
+// contents.
+func writeTarGz(dest string, files []archiveFile, modTime time.Time) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	gw.ModTime = modTime
+	tw := tar.NewWriter(gw)
+
+	for _, f := range sortedArchiveFiles(files) {
+		if err := addToTar(tw, f, modTime); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// addToTar writes a single file to the tar writer
+func addToTar(tw *tar.Writer, f archiveFile, modTime time.Time) error {
+	in, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     f.name,
+		Size:     info.Size(),
+		Mode:     int64(archiveMode(info.Mode())),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, in)
+	return err
+}
+
+// writeZip creates a zip archive at dest containing the provided files, sorted and with
+// the modTime as for writeTarGz
+func writeZip(dest string, files []archiveFile, modTime time.Time) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+
+	for _, f := range sortedArchiveFiles(files) {
+		if err := addToZip(zw, f, modTime); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// addToZip writes a single file to the zip writer
+func addToZip(zw *zip.Writer, f archiveFile, modTime time.Time) error {
+	in, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:     f.name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	header.SetMode(archiveMode(info.Mode()))
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, in)
+	return err
+}
+
+// archiveConfig configures wrapping the binary artifacts in archives before they are
+// uploaded. It is only configurable in the config file, under "archives".
+type archiveConfig struct {
+	// Enabled turns on archiving
+	Enabled bool `mapstructure:"enabled"`
+	// Files are glob patterns, relative to the repository root, of extra files such as
+	// LICENSE or README.md to add to every archive
+	Files []string `mapstructure:"files"`
+	// KeepBinaries uploads the bare binaries alongside their archives
+	KeepBinaries bool `mapstructure:"keepBinaries"`
+}
+
+// archiveName returns the name of the archive for a binary built for the platform:
+// a .zip for windows, with any .exe extension dropped, and a .tar.gz otherwise
+func archiveName(name string, plat platform) string {
+	if plat.os == "windows" {
+		return strings.TrimSuffix(name, ".exe") + ".zip"
+	}
+	return name + ".tar.gz"
+}
+
+// archiveExtraFiles returns the extra files matching the patterns in repoDir. Every
+// pattern must match something, so a misspelt LICENSE isn't silently left out.
+func archiveExtraFiles(repoDir string, patterns []string) ([]archiveFile, error) {
+	var files []archiveFile
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(repoDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("archive file pattern %q matched no files", pattern)
+		}
+
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+
+			rel, err := filepath.Rel(repoDir, m)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, archiveFile{path: m, name: filepath.ToSlash(rel)})
+		}
+	}
+
+	return files, nil
+}
+
+// archiveArtifacts wraps each binary artifact, with the configured extra files from
+// repoDir, in an archive for its platform, with entries of the modTime. The archives replace the binaries unless
+// the config keeps them; artifacts that aren't binaries are passed through unchanged.
+func archiveArtifacts(artifacts []*artifact, repoDir string, config archiveConfig, modTime time.Time) ([]*artifact, error) {
+	if !config.Enabled {
+		return artifacts, nil
+	}
+
+	extra, err := archiveExtraFiles(repoDir, config.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	var archived []*artifact
+	for _, a := range artifacts {
+		plat, ok := binaryPlatform(a.path)
+		if !ok {
+			archived = append(archived, a)
+			continue
+		}
+
+		name := archiveName(a.name, plat)
+		dest := filepath.Join(filepath.Dir(a.path), name)
+		files := append([]archiveFile{{path: a.path, name: a.name}}, extra...)
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Archiving %s as %s", a.name, name))
+		}
+
+		write := writeTarGz
+		if plat.os == "windows" {
+			write = writeZip
+		}
+		if err = write(dest, files, modTime); err != nil {
+			return nil, fmt.Errorf("failed archiving %s: %w", a.name, err)
+		}
+
+		info, err := os.Stat(dest)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.KeepBinaries {
+			archived = append(archived, a)
+		}
+		archived = append(archived, &artifact{path: dest, name: name, size: info.Size(), plat: plat, unsignedDigest: a.unsignedDigest})
+	}
+
+	return archived, nil
+}
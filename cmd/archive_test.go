@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+)
+
+func TestArchiveName(t *testing.T) {
+	archiveNameTests := []struct {
+		name     string
+		platform platform
+		expected string
+	}{
+		{name: "app-linux-amd64", platform: platform{os: "linux", arch: "amd64"}, expected: "app-linux-amd64.tar.gz"},
+		{name: "app-darwin-arm64", platform: platform{os: "darwin", arch: "arm64"}, expected: "app-darwin-arm64.tar.gz"},
+		{name: "app-windows-amd64.exe", platform: platform{os: "windows", arch: "amd64"}, expected: "app-windows-amd64.zip"},
+	}
+
+	for _, testSpec := range archiveNameTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				Equal(t, testSpec.expected, archiveName(testSpec.name, testSpec.platform))
+			},
+		)
+	}
+}
+
+// tarNames returns the names of the entries in a gzipped tarball
+func tarNames(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	Nil(t, err)
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	Nil(t, err)
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		Nil(t, err)
+		names = append(names, header.Name)
+	}
+}
+
+// TestArchiveArtifacts archives a copy of the test binary, as a real ELF binary
+func TestArchiveArtifacts(t *testing.T) {
+	exe, err := os.Executable()
+	Nil(t, err)
+	if plat, ok := binaryPlatform(exe); !ok || plat.os == "windows" {
+		t.Skip("test binary is not a unix binary")
+	}
+
+	// The archives are written next to the binaries, so the binary is copied to the temporary directory
+	repoDir := t.TempDir()
+	app := filepath.Join(repoDir, "app-linux-amd64")
+	Nil(t, copyFile(exe, app))
+
+	Nil(t, ioutil.WriteFile(filepath.Join(repoDir, "LICENSE"), []byte("license\n"), 0644))
+	notes := filepath.Join(repoDir, "notes.txt")
+	Nil(t, ioutil.WriteFile(notes, []byte("notes\n"), 0644))
+
+	artifacts := func() []*artifact {
+		return []*artifact{
+			{path: app, name: "app-linux-amd64"},
+			{path: notes, name: "notes.txt"},
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		archived, err := archiveArtifacts(artifacts(), repoDir, archiveConfig{}, time.Time{})
+		Nil(t, err)
+		Equal(t, artifacts(), archived)
+	})
+
+	t.Run("replaces binaries", func(t *testing.T) {
+		archived, err := archiveArtifacts(artifacts(), repoDir, archiveConfig{Enabled: true, Files: []string{"LICENSE"}}, time.Time{})
+		Nil(t, err)
+		Len(t, archived, 2)
+		Equal(t, "app-linux-amd64.tar.gz", archived[0].name)
+		Equal(t, "notes.txt", archived[1].name)
+		Equal(t, []string{"LICENSE", "app-linux-amd64"}, tarNames(t, archived[0].path))
+	})
+
+	t.Run("keeps binaries", func(t *testing.T) {
+		archived, err := archiveArtifacts(artifacts(), repoDir, archiveConfig{Enabled: true, KeepBinaries: true}, time.Time{})
+		Nil(t, err)
+		Len(t, archived, 3)
+		Equal(t, "app-linux-amd64", archived[0].name)
+		Equal(t, "app-linux-amd64.tar.gz", archived[1].name)
+	})
+
+	t.Run("missing extra file", func(t *testing.T) {
+		_, err := archiveArtifacts(artifacts(), repoDir, archiveConfig{Enabled: true, Files: []string{"README.md"}}, time.Time{})
+		NotNil(t, err)
+	})
+}
+
+// TestReproducibleArchives checks archives of the same files are byte-identical, whatever
+// the files' times, permissions and order
+func TestReproducibleArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reproducible")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	modTime := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	write := func(build int) []archiveFile {
+		var files []archiveFile
+		// The umask of each build may differ, but not which files are executable
+		modes := map[string]os.FileMode{"LICENSE": 0600 | os.FileMode(build*0044), "app": 0700 | os.FileMode(build*0055)}
+		for _, name := range []string{"LICENSE", "app"} {
+			path := filepath.Join(dir, name)
+			Nil(t, ioutil.WriteFile(path, []byte(name+"\n"), 0600))
+			Nil(t, os.Chmod(path, modes[name]))
+			stamp := time.Now().Add(time.Duration(build) * time.Hour)
+			Nil(t, os.Chtimes(path, stamp, stamp))
+			files = append(files, archiveFile{path: path, name: name})
+		}
+		if build%2 == 1 {
+			files[0], files[1] = files[1], files[0]
+		}
+		return files
+	}
+
+	for _, format := range []struct {
+		ext   string
+		write func(string, []archiveFile, time.Time) error
+	}{{".tar.gz", writeTarGz}, {".zip", writeZip}} {
+		t.Run(format.ext, func(t *testing.T) {
+			var builds [][]byte
+			for build := 0; build < 2; build++ {
+				dest := filepath.Join(dir, "archive"+format.ext)
+				Nil(t, format.write(dest, write(build), modTime))
+				data, err := ioutil.ReadFile(dest)
+				Nil(t, err)
+				builds = append(builds, data)
+			}
+			Equal(t, builds[0], builds[1])
+		})
+	}
+
+	t.Run("zip entries", func(t *testing.T) {
+		dest := filepath.Join(dir, "entries.zip")
+		Nil(t, writeZip(dest, write(0), modTime))
+		zr, err := zip.OpenReader(dest)
+		Nil(t, err)
+		defer zr.Close()
+
+		Equal(t, "LICENSE", zr.File[0].Name)
+		Equal(t, os.FileMode(0644), zr.File[0].Mode().Perm())
+		Equal(t, os.FileMode(0755), zr.File[1].Mode().Perm())
+		True(t, modTime.Equal(zr.File[0].Modified))
+	})
+}
+
+// TestArchiveTime checks SOURCE_DATE_EPOCH is used over the commit's time
+func TestArchiveTime(t *testing.T) {
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	repo := gitfixture.New(t)
+	repo.CommitAt("first", time.Date(2020, 6, 1, 12, 0, 0, 0, time.FixedZone("EDT", -4*60*60)))
+
+	os.Unsetenv("SOURCE_DATE_EPOCH")
+	modTime, err := archiveTime(repo.Repository)
+	Nil(t, err)
+	Equal(t, time.Date(2020, 6, 1, 16, 0, 0, 0, time.UTC), modTime)
+
+	os.Setenv("SOURCE_DATE_EPOCH", "1577836800")
+	modTime, err = archiveTime(repo.Repository)
+	Nil(t, err)
+	Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), modTime)
+
+	os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	_, err = archiveTime(repo.Repository)
+	NotNil(t, err)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// artifact is a file produced by the build that will be attached to the release
+type artifact struct {
+	path string
+	name string
+	size int64
+	// plat is the platform of the binary an archive artifact wraps, as it can't be
+	// detected from the archive itself
+	plat platform
+	// metadata is the asset metadata rendered for the artifact, recorded in its sidecar
+	metadata map[string]string
+	// unsignedDigest is set on artifacts that are signed, or that wrap or describe a signed
+	// artifact: the digest of the signed artifact as it was built, before it was processed
+	unsignedDigest string
+}
+
+// artifactPlatform returns the platform the artifact was built for, if it is a binary or
+// an archive of one
+func artifactPlatform(a *artifact) (platform, bool) {
+	if a.plat.os != "" {
+		return a.plat, true
+	}
+	return binaryPlatform(a.path)
+}
+
+// findArtifacts returns the regular files in dir matching any of the provided glob
+// patterns, sorted by name. Files matched by more than one pattern are only returned once.
+// A pattern ending in a slash, eg: dist/, matches the regular files in the directories it
+// matches, and absolute patterns are not relative to dir.
+func findArtifacts(dir string, patterns []string) ([]*artifact, error) {
+	var found []*artifact
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		glob := pattern
+		if !filepath.IsAbs(glob) {
+			glob = filepath.Join(dir, glob)
+		}
+		if strings.HasSuffix(pattern, "/") {
+			glob = filepath.Join(glob, "*")
+		}
+
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact pattern %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			if seen[match] {
+				continue
+			}
+
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+
+			// Directories and other non-regular files are not uploadable assets
+			if !info.Mode().IsRegular() {
+				continue
+			}
+
+			seen[match] = true
+			found = append(found, &artifact{
+				path: match,
+				name: filepath.Base(match),
+				size: info.Size(),
+			})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].name < found[j].name
+	})
+
+	return found, nil
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestFindArtifacts checks glob and directory patterns match the regular files only
+func TestFindArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, p := range []string{"bin/app", "dist/app-linux-amd64", "dist/app-darwin-arm64", "dist/nested/skipped"} {
+		Nil(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755))
+		Nil(t, ioutil.WriteFile(filepath.Join(dir, p), []byte(p), 0644))
+	}
+
+	artifactTests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{name: "glob", patterns: []string{"bin/*"}, expected: []string{"app"}},
+		{name: "directory", patterns: []string{"dist/"}, expected: []string{"app-darwin-arm64", "app-linux-amd64"}},
+		{name: "directory without slash", patterns: []string{"dist"}, expected: []string{}},
+		{name: "absolute", patterns: []string{filepath.Join(dir, "bin") + "/"}, expected: []string{"app"}},
+		{name: "duplicates", patterns: []string{"dist/*", "dist/"}, expected: []string{"app-darwin-arm64", "app-linux-amd64"}},
+	}
+
+	for _, testSpec := range artifactTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				found, err := findArtifacts(dir, testSpec.patterns)
+				Nil(t, err)
+
+				names := []string{}
+				for _, a := range found {
+					names = append(names, a.name)
+				}
+				Equal(t, testSpec.expected, names)
+			},
+		)
+	}
+}
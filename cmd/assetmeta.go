@@ -0,0 +1,171 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// assetMetadata are templates of the metadata recorded for each asset, by key, eg:
+// target: {{ .OS }}/{{ .Arch }} or goflags: {{ .Env.GOFLAGS }}
+var assetMetadata map[string]string
+
+// assetMetadataEnv are the environment variables the asset metadata templates may read,
+// so tokens and other secrets in the environment can't end up in the sidecars
+var assetMetadataEnv []string
+
+// assetMetadataExt is appended to the name of an asset for the name of its sidecar
+const assetMetadataExt = ".meta.json"
+
+// assetMetadataVars are the values available to the asset metadata templates: the
+// build info, and the asset's name and platform, if it was built for one
+type assetMetadataVars struct {
+	*buildInfo
+	// Project is the name of the repository
+	Project string
+	Tag     string
+	Name    string
+	OS      string
+	Arch    string
+	// Env is the environment of the release, limited to assetMetadataEnv
+	Env map[string]string
+}
+
+// assetSidecar is the metadata sidecar uploaded alongside an asset, for deployment tooling
+type assetSidecar struct {
+	Name     string            `json:"name"`
+	Size     int64             `json:"size"`
+	SHA256   string            `json:"sha256"`
+	OS       string            `json:"os,omitempty"`
+	Arch     string            `json:"arch,omitempty"`
+	Version  string            `json:"version"`
+	Commit   string            `json:"commit"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// parseAssetMetadata parses the templates, by key
+func parseAssetMetadata(templates map[string]string) (map[string]*template.Template, error) {
+	parsed := make(map[string]*template.Template, len(templates))
+	for key, text := range templates {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("asset metadata keys can't be empty")
+		}
+
+		t, err := template.New(key).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset metadata %s: %w", key, err)
+		}
+		parsed[key] = t
+	}
+
+	return parsed, nil
+}
+
+// environ returns the named environment variables that are set as a map, for templates
+func environ(names []string) map[string]string {
+	env := make(map[string]string)
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			env[name] = v
+		}
+	}
+	return env
+}
+
+// renderAssetMetadata renders the templates for the artifact
+func renderAssetMetadata(templates map[string]*template.Template, vars assetMetadataVars) (map[string]string, error) {
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	metadata := make(map[string]string, len(templates))
+	for _, key := range keys {
+		var rendered bytes.Buffer
+		if err := templates[key].Execute(&rendered, vars); err != nil {
+			return nil, fmt.Errorf("failed rendering asset metadata %s: %w", key, err)
+		}
+		metadata[key] = strings.TrimSpace(rendered.String())
+	}
+
+	return metadata, nil
+}
+
+// writeAssetSidecars records the metadata of each artifact on it, and writes it to a
+// sidecar next to the artifact, returned to be uploaded with it. Nothing is written
+// without any asset metadata configured.
+func writeAssetSidecars(artifacts []*artifact, templates map[string]string, project string, b *buildInfo) ([]*artifact, error) {
+	if len(templates) == 0 {
+		return nil, nil
+	}
+
+	parsed, err := parseAssetMetadata(templates)
+	if err != nil {
+		return nil, err
+	}
+
+	env := environ(assetMetadataEnv)
+	var sidecars []*artifact
+	for _, a := range artifacts {
+		vars := assetMetadataVars{buildInfo: b, Project: project, Tag: tag, Name: a.name, Env: env}
+		if plat, ok := artifactPlatform(a); ok {
+			vars.OS, vars.Arch = plat.os, plat.arch
+		}
+
+		a.metadata, err = renderAssetMetadata(parsed, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.name, err)
+		}
+
+		digest, err := fileDigest(a.path)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.MarshalIndent(assetSidecar{
+			Name:     a.name,
+			Size:     a.size,
+			SHA256:   digest,
+			OS:       vars.OS,
+			Arch:     vars.Arch,
+			Version:  b.Version,
+			Commit:   b.Commit,
+			Metadata: a.metadata,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, '\n')
+
+		path := a.path + assetMetadataExt
+		if err = ioutil.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+		sidecars = append(sidecars, &artifact{path: path, name: a.name + assetMetadataExt, size: int64(len(data)), unsignedDigest: a.unsignedDigest})
+	}
+
+	return sidecars, nil
+}
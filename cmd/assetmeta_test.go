@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestWriteAssetSidecars checks each asset's metadata is rendered, recorded on it, and written to its sidecar
+func TestWriteAssetSidecars(t *testing.T) {
+	defer func(t string) { tag = t }(tag)
+	tag = "v1.2.0"
+	defer func(e []string) { assetMetadataEnv = e }(assetMetadataEnv)
+	assetMetadataEnv = []string{"GOFLAGS", "NOT_SET_ANYWHERE"}
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	os.Setenv("GOFLAGS", "-trimpath")
+	defer os.Unsetenv("TEST_SECRET_TOKEN")
+	os.Setenv("TEST_SECRET_TOKEN", "secret")
+
+	dir, err := ioutil.TempDir("", "sidecars")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app-linux-amd64.tar.gz")
+	Nil(t, ioutil.WriteFile(path, []byte("archive"), 0644))
+	a := &artifact{path: path, name: "app-linux-amd64.tar.gz", size: 7, plat: platform{os: "linux", arch: "amd64"}}
+	b := &buildInfo{Version: "v1.2.0+build.7", Commit: "abc1234def", Describe: "v1.2.0"}
+
+	sidecars, err := writeAssetSidecars([]*artifact{a}, map[string]string{
+		"target":   "{{ .OS }}/{{ .Arch }}",
+		"goflags":  "{{ .Env.GOFLAGS }}",
+		"describe": "{{ .Describe }}",
+		"unset":    "{{ .Env.NOT_SET_ANYWHERE }}",
+		"secret":   "{{ .Env.TEST_SECRET_TOKEN }}",
+	}, "app", b)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+
+	// Only the allowed environment variables are available
+	expected := map[string]string{"target": "linux/amd64", "goflags": "-trimpath", "describe": "v1.2.0", "unset": "", "secret": ""}
+	Equal(t, expected, a.metadata)
+	if !Len(t, sidecars, 1) {
+		return
+	}
+	Equal(t, "app-linux-amd64.tar.gz.meta.json", sidecars[0].name)
+
+	data, err := ioutil.ReadFile(sidecars[0].path)
+	Nil(t, err)
+	Equal(t, int64(len(data)), sidecars[0].size)
+	var sidecar assetSidecar
+	Nil(t, json.Unmarshal(data, &sidecar))
+	Equal(t, "v1.2.0+build.7", sidecar.Version)
+	Equal(t, "linux", sidecar.OS)
+	Equal(t, expected, sidecar.Metadata)
+	Len(t, sidecar.SHA256, 64)
+
+	// Without any metadata configured, there are no sidecars
+	sidecars, err = writeAssetSidecars([]*artifact{a}, nil, "app", b)
+	Nil(t, err)
+	Empty(t, sidecars)
+}
+
+// TestParseAssetMetadata checks templates that can't be parsed are rejected
+func TestParseAssetMetadata(t *testing.T) {
+	_, err := parseAssetMetadata(map[string]string{"target": "{{ .OS }}"})
+	Nil(t, err)
+
+	_, err = parseAssetMetadata(map[string]string{"target": "{{ .OS"})
+	NotNil(t, err)
+
+	_, err = parseAssetMetadata(map[string]string{" ": "value"})
+	NotNil(t, err)
+}
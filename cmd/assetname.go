@@ -0,0 +1,244 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// assetNameTemplate renders the uploaded name of each binary artifact, eg:
+// {{.Project}}_{{.Tag}}_{{.OS}}_{{.Arch}}{{.Ext}}
+var assetNameTemplate string
+
+// assetNameVars are the values available to the asset name template
+type assetNameVars struct {
+	// Project is the name of the repository
+	Project string
+	Tag     string
+	Version string
+	// Name is the artifact name without its extension
+	Name string
+	OS   string
+	Arch string
+	// Ext is the artifact's extension: .tar.gz or .zip for archives, .exe for windows
+	// binaries, and empty otherwise
+	Ext string
+}
+
+// assetExt returns the extension of an artifact built for the platform
+func assetExt(name string, plat platform) string {
+	for _, ext := range []string{".tar.gz", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			return ext
+		}
+	}
+	if plat.os == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+// parseAssetNameTemplate parses the template, and checks it renders a usable name
+func parseAssetNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("assetName").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = renderAssetName(tmpl, assetNameVars{
+		Project: "project",
+		Tag:     "v1.0.0",
+		Version: "v1.0.0",
+		Name:    "project",
+		OS:      "linux",
+		Arch:    "amd64",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// renderAssetName renders the template with the vars into an asset name
+func renderAssetName(tmpl *template.Template, vars assetNameVars) (string, error) {
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSpace(rendered.String())
+	if name == "" {
+		return "", fmt.Errorf("rendered an empty asset name")
+	}
+	if strings.ContainsAny(name, "/\\") {
+		return "", fmt.Errorf("asset name %q contains a path separator", name)
+	}
+
+	return name, nil
+}
+
+// renameAssets renders the asset name template for each artifact built for a platform,
+// ie: binaries and their archives, so the asset names are consistent from release to
+// release. Other artifacts, such as debug symbols, keep their names.
+func renameAssets(artifacts []*artifact, text, project string, b *buildInfo) error {
+	if text == "" {
+		return nil
+	}
+
+	tmpl, err := parseAssetNameTemplate(text)
+	if err != nil {
+		return fmt.Errorf("invalid asset name template: %w", err)
+	}
+
+	names := make(map[string]string)
+	for _, a := range artifacts {
+		names[a.name] = a.name
+	}
+
+	for _, a := range artifacts {
+		plat, ok := artifactPlatform(a)
+		if !ok {
+			continue
+		}
+
+		ext := assetExt(a.name, plat)
+		name, err := renderAssetName(tmpl, assetNameVars{
+			Project: project,
+			Tag:     tag,
+			Version: b.Version,
+			Name:    strings.TrimSuffix(a.name, ext),
+			OS:      plat.os,
+			Arch:    plat.arch,
+			Ext:     ext,
+		})
+		if err != nil {
+			return fmt.Errorf("failed naming %s: %w", a.name, err)
+		}
+		if name == a.name {
+			continue
+		}
+
+		if other, ok := names[name]; ok {
+			group := []*artifact{a}
+			for _, dup := range artifacts {
+				if dup.name == name {
+					group = append(group, dup)
+				}
+			}
+			return fmt.Errorf("asset name %s of %s is already used by %s; %s", name, a.name, other, renameSuggestion(group, text))
+		}
+		delete(names, a.name)
+		names[name] = a.name
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Naming %s %s", a.name, name))
+		}
+		a.name = name
+	}
+
+	return nil
+}
+
+// uploadedAssetName returns the name Github keeps an uploaded asset under, as it replaces
+// characters other than letters, digits, "-", "_" and "." with a period, and trims
+// leading and trailing periods
+func uploadedAssetName(name string) string {
+	mapped := strings.Map(func(r rune) rune {
+		if r < 128 && (r == '-' || r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '.'
+	}, name)
+	return strings.Trim(mapped, ".")
+}
+
+// checkAssetNames fails if two artifacts would be uploaded under the same name, eg: the
+// same binary name built for several platforms into different directories, or names
+// Github renames to the same one. The artifacts are listed by their paths in dir.
+func checkAssetNames(artifacts []*artifact, dir, text string) error {
+	byName := make(map[string][]*artifact)
+	var names []string
+	for _, a := range artifacts {
+		name := uploadedAssetName(a.name)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], a)
+	}
+
+	var collisions []string
+	for _, name := range names {
+		group := byName[name]
+		if len(group) < 2 {
+			continue
+		}
+
+		paths := make([]string, 0, len(group))
+		for _, a := range group {
+			p := a.path
+			if rel, err := filepath.Rel(dir, a.path); err == nil && !strings.HasPrefix(rel, "..") {
+				p = rel
+			}
+			paths = append(paths, p)
+		}
+		collisions = append(collisions, fmt.Sprintf(
+			"%s would all be uploaded as %s; %s", strings.Join(paths, ", "), name, renameSuggestion(group, text),
+		))
+	}
+
+	if len(collisions) > 0 {
+		return fmt.Errorf("asset names collide:\n%s", strings.Join(collisions, "\n"))
+	}
+
+	return nil
+}
+
+// renameSuggestion suggests how the asset name template could tell apart the colliding
+// artifacts, from the platforms they were built for
+func renameSuggestion(group []*artifact, text string) string {
+	oses, arches := make(map[string]bool), make(map[string]bool)
+	for _, a := range group {
+		plat, ok := artifactPlatform(a)
+		if !ok {
+			return "give them distinct file names in the build"
+		}
+		oses[plat.os], arches[plat.arch] = true, true
+	}
+
+	if text == "" {
+		return "set --assetNameTemplate to name them by platform, eg: {{.Name}}_{{.OS}}_{{.Arch}}{{.Ext}}"
+	}
+
+	var missing []string
+	if len(oses) > 1 && !strings.Contains(text, ".OS") {
+		missing = append(missing, "{{.OS}}")
+	}
+	if len(arches) > 1 && !strings.Contains(text, ".Arch") {
+		missing = append(missing, "{{.Arch}}")
+	}
+	if len(missing) == 0 {
+		return "give them distinct file names in the build"
+	}
+	return fmt.Sprintf("add %s to --assetNameTemplate", strings.Join(missing, " and "))
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestRenameAssets checks the binary artifacts are named from the template, and colliding or invalid names are refused
+func TestRenameAssets(t *testing.T) {
+	defer func() { tag = "" }()
+	tag = "v1.2.0"
+	b := &buildInfo{Version: "v1.2.0+build.7"}
+
+	artifacts := func() []*artifact {
+		return []*artifact{
+			{path: "bin/app-linux.tar.gz", name: "app-linux.tar.gz", plat: platform{os: "linux", arch: "amd64"}},
+			{path: "bin/app.zip", name: "app.zip", plat: platform{os: "windows", arch: "arm64"}},
+			{path: "bin/app.exe", name: "app.exe", plat: platform{os: "windows", arch: "amd64"}},
+			{path: "bin/notes.txt", name: "notes.txt"},
+		}
+	}
+
+	renameTests := []struct {
+		name     string
+		template string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "no template",
+			expected: []string{"app-linux.tar.gz", "app.zip", "app.exe", "notes.txt"},
+		},
+		{
+			name:     "platform names",
+			template: "{{.Project}}_{{.Tag}}_{{.OS}}_{{.Arch}}{{.Ext}}",
+			expected: []string{"tool_v1.2.0_linux_amd64.tar.gz", "tool_v1.2.0_windows_arm64.zip", "tool_v1.2.0_windows_amd64.exe", "notes.txt"},
+		},
+		{
+			name:     "version and name",
+			template: "{{.Name}}-{{.Version}}{{.Ext}}",
+			expected: []string{"app-linux-v1.2.0+build.7.tar.gz", "app-v1.2.0+build.7.zip", "app-v1.2.0+build.7.exe", "notes.txt"},
+		},
+		{
+			name:     "colliding names",
+			template: "{{.Project}}_{{.OS}}",
+			err:      true,
+		},
+		{
+			name:     "path separator",
+			template: "{{.OS}}/{{.Arch}}",
+			err:      true,
+		},
+		{
+			name:     "unknown field",
+			template: "{{.Platform}}",
+			err:      true,
+		},
+	}
+
+	for _, testSpec := range renameTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				a := artifacts()
+				err := renameAssets(a, testSpec.template, "tool", b)
+				if testSpec.err {
+					NotNil(t, err)
+					return
+				}
+				Nil(t, err)
+
+				var names []string
+				for _, artifact := range a {
+					names = append(names, artifact.name)
+				}
+				Equal(t, testSpec.expected, names)
+			},
+		)
+	}
+}
+
+// TestCheckAssetNames checks artifacts uploaded under the same name fail, with a way to rename them
+func TestCheckAssetNames(t *testing.T) {
+	linux := &artifact{path: "/src/dist/linux/app", name: "app", plat: platform{os: "linux", arch: "amd64"}}
+	darwin := &artifact{path: "/src/dist/darwin/app", name: "app", plat: platform{os: "darwin", arch: "amd64"}}
+	notes := &artifact{path: "/src/notes.txt", name: "notes.txt"}
+
+	Nil(t, checkAssetNames([]*artifact{linux, notes}, "/src", ""))
+
+	err := checkAssetNames([]*artifact{linux, darwin, notes}, "/src", "")
+	EqualError(t, err, "asset names collide:\ndist/linux/app, dist/darwin/app would all be uploaded as app; "+
+		"set --assetNameTemplate to name them by platform, eg: {{.Name}}_{{.OS}}_{{.Arch}}{{.Ext}}")
+
+	err = checkAssetNames([]*artifact{linux, darwin}, "/src", "{{.Name}}_{{.Arch}}")
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "add {{.OS}} to --assetNameTemplate")
+	}
+
+	// Github renames special characters, so these would be uploaded as the same asset
+	err = checkAssetNames([]*artifact{{path: "/src/a b.txt", name: "a b.txt"}, {path: "/src/a+b.txt", name: "a+b.txt"}}, "/src", "")
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "uploaded as a.b.txt; give them distinct file names in the build")
+	}
+	Equal(t, "app.v1.txt", uploadedAssetName(".app v1.txt."))
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// auditWebhookURL is the endpoint release events are sent to
+var auditWebhookURL string
+
+// auditWebhookSecretEnv names the environment variable with the secret events are signed with
+var auditWebhookSecretEnv string
+
+// Release events sent to the audit webhook
+const (
+	auditStarted   = "release.started"
+	auditPublished = "release.published"
+	auditFailed    = "release.failed"
+)
+
+// auditStages names the stage a release failed at, for release.failed events
+var auditStages = []struct {
+	stage error
+	name  string
+}{
+	{errClone, "clone"},
+	{errTag, "tag"},
+	{errBuild, "build"},
+	{errAuth, "auth"},
+	{errRelease, "release"},
+	{errUpload, "upload"},
+}
+
+// auditEvent is the payload of a release event
+type auditEvent struct {
+	Event      string   `json:"event"`
+	Timestamp  string   `json:"timestamp"`
+	Repository string   `json:"repository"`
+	Tag        string   `json:"tag"`
+	Version    string   `json:"version,omitempty"`
+	Commit     string   `json:"commit,omitempty"`
+	Draft      bool     `json:"draft"`
+	ReleaseURL string   `json:"release_url,omitempty"`
+	Assets     []string `json:"assets,omitempty"`
+	Stage      string   `json:"stage,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	// Provenance is the CI run the release is made from, if any
+	Provenance *provenance `json:"provenance,omitempty"`
+	// CorrelationID is sent in each of the run's requests
+	CorrelationID string `json:"correlation_id"`
+}
+
+// newAuditEvent describes the release at the point of the event. p is nil if the
+// release failed before the repository was prepared.
+func newAuditEvent(event string, p *pipeline, releaseErr error) *auditEvent {
+	e := &auditEvent{
+		Event:         event,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Repository:    repositoryURL,
+		Tag:           tag,
+		Draft:         draft,
+		Provenance:    ciProvenance(),
+		CorrelationID: correlationID,
+	}
+
+	if p != nil {
+		e.Repository = p.releaseRepo.raw
+		if p.build != nil {
+			e.Version = p.build.Version
+			e.Commit = p.build.Commit
+		}
+		if p.release != nil && p.release.HTMLURL != nil {
+			e.ReleaseURL = *p.release.HTMLURL
+		}
+		for _, a := range p.artifacts {
+			e.Assets = append(e.Assets, a.name)
+		}
+	}
+
+	if releaseErr != nil {
+		e.Error = releaseErr.Error()
+		for _, s := range auditStages {
+			if errors.Is(releaseErr, s.stage) {
+				e.Stage = s.name
+				break
+			}
+		}
+	}
+
+	return e
+}
+
+// sendAuditEvent posts the event to the audit webhook, if one is configured. The body is
+// signed with the secret the same way Github signs its webhooks.
+func sendAuditEvent(e *auditEvent) error {
+	if auditWebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":    "application/json",
+		"X-Release-Event": e.Event,
+	}
+	if auditWebhookSecretEnv != "" {
+		secret, err := secretFromEnv(auditWebhookSecretEnv)
+		if err != nil {
+			return err
+		}
+		headers["X-Release-Signature-256"] = webhookSignature(secret, body)
+	}
+
+	req, err := newPostRequest(auditWebhookURL, bytes.NewReader(body), headers)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		return &httpError{StatusCode: resp.StatusCode, Status: resp.Status, Body: respBody}
+	}
+
+	return nil
+}
+
+// auditedRelease runs the release, sending the audit events around it. The release
+// doesn't start if the release.started event can't be delivered, so every release is
+// tracked; failing to deliver the outcome is only a warning, as the release is done.
+func auditedRelease(release func() (*pipeline, error)) error {
+	err := sendAuditEvent(newAuditEvent(auditStarted, nil, nil))
+	if err != nil {
+		return fmt.Errorf("failed sending %s audit event: %w", auditStarted, err)
+	}
+
+	p, releaseErr := release()
+
+	event := auditPublished
+	if releaseErr != nil {
+		event = auditFailed
+	}
+
+	err = sendAuditEvent(newAuditEvent(event, p, releaseErr))
+	if err != nil {
+		fmt.Printf("WARNING: failed sending %s audit event: %s\n", event, err)
+	}
+
+	return releaseErr
+}
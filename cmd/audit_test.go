@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestNewAuditEvent checks failed releases report the stage that failed
+func TestNewAuditEvent(t *testing.T) {
+	e := newAuditEvent(auditFailed, nil, stageFailed(errUpload, fmt.Errorf("timeout")))
+	Equal(t, "upload", e.Stage)
+	Equal(t, "failed uploading release assets: timeout", e.Error)
+
+	e = newAuditEvent(auditFailed, nil, errHalted)
+	Equal(t, "", e.Stage)
+
+	url := "https://github.com/o/r/releases/tag/v1.0.0"
+	p := &pipeline{
+		releaseRepo: &gitURL{raw: "git@github.com:o/r.git"},
+		build:       &buildInfo{Version: "v1.0.0", Commit: "abc123"},
+		artifacts:   []*artifact{{name: "app"}},
+		release:     &release{HTMLURL: &url},
+	}
+	e = newAuditEvent(auditPublished, p, nil)
+	Equal(t, "git@github.com:o/r.git", e.Repository)
+	Equal(t, url, e.ReleaseURL)
+	Equal(t, []string{"app"}, e.Assets)
+}
+
+// TestSendAuditEvent checks events are signed, and rejected deliveries are errors
+func TestSendAuditEvent(t *testing.T) {
+	defer gock.Off()
+	defer func(u, env string) { auditWebhookURL, auditWebhookSecretEnv = u, env }(auditWebhookURL, auditWebhookSecretEnv)
+
+	auditWebhookURL = "https://audit.example.com/releases"
+	auditWebhookSecretEnv = "TEST_AUDIT_SECRET"
+	os.Setenv("TEST_AUDIT_SECRET", "secret")
+	defer os.Unsetenv("TEST_AUDIT_SECRET")
+
+	var signed bool
+	gock.New("https://audit.example.com").
+		Post("/releases").
+		MatchHeader("X-Release-Event", auditStarted).
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := ioutil.ReadAll(req.Body)
+			signed = validWebhookSignature("secret", body, req.Header.Get("X-Release-Signature-256"))
+			return true, err
+		}).
+		Reply(204)
+
+	Nil(t, sendAuditEvent(newAuditEvent(auditStarted, nil, nil)))
+	True(t, signed)
+
+	gock.New("https://audit.example.com").
+		Post("/releases").
+		Reply(503)
+
+	True(t, isHTTPStatus(sendAuditEvent(newAuditEvent(auditStarted, nil, nil)), 503))
+}
@@ -0,0 +1,306 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// token is a personal access token to use instead of the device flow
+var token string
+
+// tokenEnvVars are the environment variables a token is read from if --token is not set,
+// in order of precedence
+var tokenEnvVars = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+
+// cachedAuth is the access token from the first successful authentication, so the
+// device flow only runs once per invocation
+var cachedAuth *UserAuth
+
+// tokenRefreshMargin is how long before a token expires it is replaced, so a request
+// made with it doesn't fail part way, eg: with a Github App's hour long token during watch
+const tokenRefreshMargin = 5 * time.Minute
+
+// providedToken returns the token from --token, or the environment, and where it came from
+func providedToken() (string, string) {
+	if token != "" {
+		return token, "--token"
+	}
+	for _, name := range tokenEnvVars {
+		if v := os.Getenv(name); v != "" {
+			return v, name
+		}
+	}
+	return "", ""
+}
+
+// authenticate authorizes this device to act on the user's behalf with the
+// Github device flow, and returns the resulting access token. A token provided
+// with --token, GITHUB_TOKEN or GH_TOKEN is used instead, eg: in CI, then a
+// configured Github App's installation token, then the gh CLI's token, then the
+// device flow token kept from an earlier run until it stops being valid, then the
+// token for the Github host in ~/.netrc or git's credential helpers. In --ci mode,
+// only the provided token or Github App are used.
+func authenticate() (*UserAuth, error) {
+	if cachedAuth != nil && !cachedAuth.expiring(time.Now()) {
+		return cachedAuth, nil
+	}
+	if cachedAuth != nil && verbose {
+		noteInfo(fmt.Sprintf("Refreshing the token expiring at %s", cachedAuth.expiresAt.Format(time.RFC3339)))
+	}
+	cachedAuth = nil
+
+	auth, _, err := existingAuth()
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		cachedAuth = auth
+		return auth, nil
+	}
+
+	// Nobody can authorize the device flow in CI, and the gh CLI and token store belong to a user
+	if ciMode {
+		return nil, ciNoTokenError()
+	}
+
+	store, err := newTokenStore()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find where to store the token: %w", err)
+	}
+
+	auth, err = deviceFlowAuth(store)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedAuth = auth
+	return auth, nil
+}
+
+// expiring returns whether the token expires within tokenRefreshMargin of now. Tokens
+// without a known expiry are used until they are rejected.
+func (a *UserAuth) expiring(now time.Time) bool {
+	return !a.expiresAt.IsZero() && now.Add(tokenRefreshMargin).After(a.expiresAt)
+}
+
+// existingAuth returns the token authenticate uses without running the device flow, and
+// where it came from, or nil if there is none
+func existingAuth() (*UserAuth, string, error) {
+	if t, source := providedToken(); t != "" {
+		if verbose {
+			noteInfo(fmt.Sprintf("Using the token from %s", source))
+		}
+		return &UserAuth{AccessToken: t, TokenType: "token"}, source, nil
+	}
+
+	if appID != 0 {
+		auth, err := appAuth()
+		if err != nil {
+			return nil, "", err
+		}
+		return auth, fmt.Sprintf("Github App %d", appID), nil
+	}
+
+	if ciMode {
+		return nil, "", nil
+	}
+
+	// Tokens aren't reused while recording or replaying HTTP cassettes, as for the token store
+	if ghCredentials && recordHTTP == "" && replayHTTP == "" {
+		auth, err := ghAuth()
+		if err != nil {
+			return nil, "", err
+		}
+		if auth != nil {
+			return auth, "the gh CLI", nil
+		}
+	}
+
+	store, err := newTokenStore()
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot find where to store the token: %w", err)
+	}
+	if store != nil {
+		auth, err := storedAuth(store)
+		if err != nil {
+			return nil, "", err
+		}
+		if auth != nil {
+			return auth, store.String(), nil
+		}
+	}
+
+	// Tokens kept for git over https, eg: by gh auth setup-git or a credential manager
+	if gitCredentials && recordHTTP == "" && replayHTTP == "" {
+		if auth, source := credentialAuth(); auth != nil {
+			return auth, source, nil
+		}
+	}
+
+	return nil, "", nil
+}
+
+// deviceFlowAuth runs the device flow, and keeps the token in the store, if not nil
+func deviceFlowAuth(store tokenStore) (*UserAuth, error) {
+	if verbose {
+		fmt.Println("Authorizing device")
+	}
+
+	// Builds without a default client ID need one to run the device flow
+	if clientID == "" {
+		return nil, fmt.Errorf("no OAuth client ID for the device flow; set --clientID, or provide a token with --token, GITHUB_TOKEN or GH_TOKEN")
+	}
+
+	authResponse, err := requestDeviceAndUserCodes(githubEndpoint.DeviceAuthURL, clientID, oauthScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed requesting device and user codes from github: %w", err)
+	}
+
+	// prompt user to authorize
+	fmt.Printf("Please enter your one-time verification code at %s\n", authResponse.VerificationURI)
+	fmt.Printf("One-time code: %s\n", authResponse.UserCode)
+	// There is no one to authorize a replayed device flow
+	if replayHTTP == "" {
+		openbrowser(authResponse.VerificationURI)
+	}
+
+	// poll for auth status
+	if verbose {
+		noteInfo("Polling for access token")
+	}
+	stop := showAuthorizationWait(time.Now().Add(time.Duration(authResponse.ExpiresIn) * time.Second))
+	userAuthResponse, err := pollForAccessToken(
+		githubEndpoint.TokenURL,
+		clientID,
+		authResponse.DeviceCode,
+		oauthGrantType,
+		authResponse.ExpiresIn,
+		authResponse.Interval,
+	)
+	stop(err == nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed checking for authorization and retrieving access token: %w", err)
+	}
+
+	if store != nil {
+		if err = store.save(userAuthResponse); err != nil {
+			fmt.Printf("WARNING: cannot store the token in %s: %s\n", store, err)
+		}
+	}
+
+	return userAuthResponse, nil
+}
+
+// ghAuth returns the gh CLI's token, if it has one and it is still valid
+func ghAuth() (*UserAuth, error) {
+	t, path, err := ghToken()
+	if err != nil {
+		fmt.Printf("WARNING: cannot read the gh CLI credentials in %s: %s\n", path, err)
+		return nil, nil
+	}
+	if t == "" {
+		return nil, nil
+	}
+
+	auth := &UserAuth{AccessToken: t, TokenType: "token"}
+	valid, err := validateToken(auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed validating the gh CLI token: %w", err)
+	}
+	if !valid {
+		if verbose {
+			noteInfo("The gh CLI token is no longer valid; run gh auth login to refresh it")
+		}
+		return nil, nil
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Using the gh CLI token from %s", path))
+	}
+	return auth, nil
+}
+
+// credentialAuth returns the password for the Github host from the netrc file or git's
+// credential helpers as a token, and where it came from, if Github accepts it. A
+// credential that can't be checked is skipped, so the next source is tried.
+func credentialAuth() (*UserAuth, string) {
+	c := credentialFor(githubHost())
+	if c == nil {
+		return nil, ""
+	}
+
+	// Passwords for git over https may not be API tokens, so are only used if they work
+	auth := &UserAuth{AccessToken: c.password, TokenType: "token"}
+	valid, err := validateToken(auth)
+	if err != nil {
+		fmt.Printf("WARNING: skipping the credential from %s, failed validating it: %s\n", c.source, err)
+		return nil, ""
+	}
+	if !valid {
+		if verbose {
+			noteInfo(fmt.Sprintf("The credential from %s isn't a valid Github token", c.source))
+		}
+		return nil, ""
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Using the token from %s", c.source))
+	}
+	return auth, c.source
+}
+
+// showAuthorizationWait reports how long is left to enter the one-time code until the
+// returned func is called with whether the device was authorized
+func showAuthorizationWait(expires time.Time) func(authorized bool) {
+	p := newProgress()
+	done := make(chan bool)
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			left := time.Until(expires).Round(time.Second)
+			if left < 0 {
+				left = 0
+			}
+			p.update(fmt.Sprintf("Waiting for authorization; the code expires in %s", left))
+
+			select {
+			case authorized := <-done:
+				if authorized {
+					p.done("Device authorized")
+				} else {
+					p.done("")
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func(authorized bool) {
+		done <- authorized
+		<-finished
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// unsetTokenEnv clears the token environment variables for a test, and returns a func restoring them
+func unsetTokenEnv() func() {
+	saved := make(map[string]string)
+	for _, name := range tokenEnvVars {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = v
+		}
+		os.Unsetenv(name)
+	}
+	return func() {
+		for _, name := range tokenEnvVars {
+			os.Unsetenv(name)
+			if v, ok := saved[name]; ok {
+				os.Setenv(name, v)
+			}
+		}
+	}
+}
+
+// TestAuthenticateWithToken checks a provided token is used without the device flow
+func TestAuthenticateWithToken(t *testing.T) {
+	defer unsetTokenEnv()()
+	defer func() { token, cachedAuth = "", nil }()
+
+	tokenTests := []struct {
+		name     string
+		flag     string
+		env      map[string]string
+		expected string
+		source   string
+	}{
+		{name: "flag", flag: "f", env: map[string]string{"GITHUB_TOKEN": "g"}, expected: "f", source: "--token"},
+		{name: "GITHUB_TOKEN", env: map[string]string{"GITHUB_TOKEN": "g", "GH_TOKEN": "h"}, expected: "g", source: "GITHUB_TOKEN"},
+		{name: "GH_TOKEN", env: map[string]string{"GH_TOKEN": "h"}, expected: "h", source: "GH_TOKEN"},
+	}
+
+	for _, testSpec := range tokenTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				defer unsetTokenEnv()()
+				for k, v := range testSpec.env {
+					os.Setenv(k, v)
+				}
+				token, cachedAuth = testSpec.flag, nil
+
+				_, source := providedToken()
+				Equal(t, testSpec.source, source)
+
+				auth, err := authenticate()
+				Nil(t, err)
+				Equal(t, testSpec.expected, auth.AccessToken)
+				Equal(t, "token", auth.TokenType)
+			},
+		)
+	}
+}
+
+// TestAuthenticateWithoutClientID checks the device flow isn't started without an OAuth app
+func TestAuthenticateWithoutClientID(t *testing.T) {
+	defer unsetTokenEnv()()
+	defer func() { clientID, ghCredentials, cacheToken, cachedAuth = "", true, true, nil }()
+	clientID, ghCredentials, cacheToken = "", false, false
+
+	_, err := authenticate()
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "--clientID")
+	}
+}
+
+// TestAuthenticateRefreshesExpiring checks a cached token is replaced before it expires
+func TestAuthenticateRefreshesExpiring(t *testing.T) {
+	defer unsetTokenEnv()()
+	defer func() { token, cachedAuth = "", nil }()
+	token = "fresh"
+
+	now := time.Now()
+	cachedAuth = &UserAuth{AccessToken: "cached", TokenType: "token", expiresAt: now.Add(time.Hour)}
+	auth, err := authenticate()
+	Nil(t, err)
+	Equal(t, "cached", auth.AccessToken)
+
+	cachedAuth.expiresAt = now.Add(time.Minute)
+	auth, err = authenticate()
+	Nil(t, err)
+	Equal(t, "fresh", auth.AccessToken)
+
+	False(t, (&UserAuth{}).expiring(now), "tokens without an expiry are kept")
+}
@@ -0,0 +1,230 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// authCmd groups the commands that manage the Github credentials, apart from releasing
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Log in to, and out of, Github",
+
+	// The auth commands don't need a repository, only the auth settings
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setup(authValidation)
+	},
+}
+
+// authLoginCmd runs the device flow and stores the token for later runs
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Authorize go-git-release with the device flow, and store the token",
+	Long: `login runs the device flow, even if there is already a token, and keeps the token where auth.storage
+says, for later runs to use.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return authLogin()
+	},
+}
+
+// authStatusCmd reports the credentials the release would use
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the Github user and scopes go-git-release is authenticated as",
+	Long: `status finds the token a release would use, without running the device flow, and prints where it came
+from, and the Github user and OAuth scopes it is for. It exits with an error if there is no valid token.`,
+
+	// The status already says what's wrong
+	SilenceUsage: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return authStatus(cmd.OutOrStdout())
+	},
+}
+
+// authLogoutCmd removes the stored token
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored Github token",
+	Long: `logout removes the device flow token kept for later runs. The authorization itself is only revoked from
+the Github settings of the OAuth app.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return authLogout()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authLogoutCmd)
+}
+
+// oauthScopesHeader lists the scopes of the OAuth token a request was made with
+const oauthScopesHeader = "X-OAuth-Scopes"
+
+// authenticatedUser is the Github user a token acts for, and the token's scopes
+type authenticatedUser struct {
+	Login string `json:"login"`
+	// Scopes is nil for fine-grained tokens, which have permissions instead
+	Scopes []string `json:"-"`
+}
+
+// getAuthenticatedUser retrieves the user the token acts for, and its scopes
+func getAuthenticatedUser(auth *UserAuth) (*authenticatedUser, error) {
+	req, err := newGetRequest(githubUserURL(), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	// The scopes are only in the response headers
+	r, err := ctxhttp.Do(context.TODO(), httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return nil, &httpError{StatusCode: r.StatusCode, Status: r.Status, Body: body}
+	}
+
+	var u authenticatedUser
+	if err = json.Unmarshal(body, &u); err != nil {
+		return nil, err
+	}
+	// Tokens without scopes list none; tokens with permissions instead don't list any
+	if r.Header.Values(oauthScopesHeader) != nil {
+		u.Scopes = []string{}
+		for _, s := range strings.Split(r.Header.Get(oauthScopesHeader), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				u.Scopes = append(u.Scopes, s)
+			}
+		}
+	}
+
+	return &u, nil
+}
+
+func authLogin() error {
+	store, err := newTokenStore()
+	if err != nil {
+		return fmt.Errorf("cannot find where to store the token: %w", err)
+	}
+	if store == nil {
+		return fmt.Errorf("the token isn't stored with --cacheToken=false, or while recording or replaying HTTP")
+	}
+
+	auth, err := deviceFlowAuth(store)
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	u, err := getAuthenticatedUser(auth)
+	if err != nil {
+		return fmt.Errorf("failed retrieving the authenticated user: %w", err)
+	}
+
+	fmt.Printf("Logged in to Github as %s; the token is stored in %s\n", u.Login, store)
+	return nil
+}
+
+func authStatus(out io.Writer) error {
+	auth, source, err := existingAuth()
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+	if auth == nil {
+		fmt.Fprintln(out, "Not logged in to Github; log in with: go-git-release auth login")
+		return fmt.Errorf("not logged in")
+	}
+
+	fmt.Fprintf(out, "Token from %s\n", source)
+
+	// Installation tokens, of Github Apps and the Actions job token, act for no user
+	if strings.HasPrefix(auth.AccessToken, installationTokenPrefix) {
+		fmt.Fprintln(out, "\tGithub App installation token, with the App's permissions")
+		return nil
+	}
+
+	u, err := getAuthenticatedUser(auth)
+	if isHTTPStatus(err, 401) {
+		fmt.Fprintln(out, "\tThe token is no longer valid")
+		return fmt.Errorf("invalid token")
+	}
+	if err != nil {
+		return fmt.Errorf("failed retrieving the authenticated user: %w", err)
+	}
+
+	fmt.Fprintf(out, "\tLogged in as: %s\n", u.Login)
+	switch {
+	case u.Scopes == nil:
+		fmt.Fprintln(out, "\tScopes: none listed; the token has permissions instead")
+	case len(u.Scopes) == 0:
+		fmt.Fprintln(out, "\tScopes: none")
+	default:
+		fmt.Fprintf(out, "\tScopes: %s\n", strings.Join(u.Scopes, ", "))
+	}
+
+	return nil
+}
+
+func authLogout() error {
+	store, err := newTokenStore()
+	if err != nil {
+		return fmt.Errorf("cannot find where to store the token: %w", err)
+	}
+	if store == nil {
+		fmt.Println("No token is stored with --cacheToken=false")
+		return nil
+	}
+
+	if err = store.clear(); err != nil {
+		return fmt.Errorf("failed removing the token from %s: %w", store, err)
+	}
+	fmt.Printf("Removed the stored token from %s\n", store)
+
+	// Other credentials are used before the stored token, and aren't ours to remove
+	if t, source := providedToken(); t != "" {
+		fmt.Printf("The token from %s is still used\n", source)
+	} else if ghCredentials {
+		if t, _, _ := ghToken(); t != "" {
+			fmt.Println("The gh CLI's token is still used; log out with gh auth logout, or use --ghCredentials=false")
+		}
+	}
+
+	return nil
+}
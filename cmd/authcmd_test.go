@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestGetAuthenticatedUser checks the user and the token's scopes are read from the response
+func TestGetAuthenticatedUser(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		MatchHeader("Authorization", "token secret").
+		Reply(200).
+		SetHeader(oauthScopesHeader, "repo, read:org").
+		JSON(map[string]string{"login": "someone"})
+
+	u, err := getAuthenticatedUser(&UserAuth{AccessToken: "secret", TokenType: "token"})
+	Nil(t, err)
+	Equal(t, &authenticatedUser{Login: "someone", Scopes: []string{"repo", "read:org"}}, u)
+	True(t, gock.IsDone())
+}
+
+// TestAuthStatus checks the status reports the token's source and user, without the device flow
+func TestAuthStatus(t *testing.T) {
+	defer gock.Off()
+	defer unsetTokenEnv()()
+	defer func(gh, cache bool) { ghCredentials, cacheToken, token = gh, cache, "" }(ghCredentials, cacheToken)
+	ghCredentials, cacheToken = false, false
+
+	var out bytes.Buffer
+	NotNil(t, authStatus(&out))
+	Contains(t, out.String(), "Not logged in")
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		Reply(200).
+		SetHeader(oauthScopesHeader, "repo").
+		JSON(map[string]string{"login": "someone"})
+
+	token = "secret"
+	out.Reset()
+	Nil(t, authStatus(&out))
+	Equal(t, "Token from --token\n\tLogged in as: someone\n\tScopes: repo\n", out.String())
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		Reply(401)
+
+	out.Reset()
+	NotNil(t, authStatus(&out))
+	Contains(t, out.String(), "no longer valid")
+
+	// Installation tokens act for no user, so aren't looked up
+	token = installationTokenPrefix + "secret"
+	out.Reset()
+	Nil(t, authStatus(&out))
+	Contains(t, out.String(), "installation token")
+	True(t, gock.IsDone())
+}
+
+// TestAuthLogout checks the stored token is removed, and that logging out twice is fine
+func TestAuthLogout(t *testing.T) {
+	defer unsetTokenEnv()()
+	defer func(cache bool) { cacheToken = cache }(cacheToken)
+	cacheToken = true
+
+	dir, err := ioutil.TempDir("", "tokens")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token.json")
+	defer func(f func() (string, error)) { tokenCachePath = f }(tokenCachePath)
+	tokenCachePath = func() (string, error) { return path, nil }
+
+	store := &fileTokenStore{path: path}
+	Nil(t, store.save(&UserAuth{AccessToken: "secret", TokenType: "bearer"}))
+
+	Nil(t, authLogout())
+	_, err = os.Stat(path)
+	True(t, os.IsNotExist(err))
+	Nil(t, authLogout())
+}
@@ -0,0 +1,360 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+)
+
+var backfillFrom string
+var backfillTo string
+var backfillBuild bool
+
+// backfillCmd creates releases for historical tags that don't have one
+var backfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Create releases for existing tags that have none",
+	Long: `backfill creates a release for each semantic version tag from --from to --to, inclusive, that doesn't
+already have one, eg: when adopting the tool on a repository with historical tags. The releases are created
+oldest first, with the tag message and notes generated from the pull requests merged since the previous tag.
+Nothing is built unless --build is set, when each tag is checked out, built and its artifacts uploaded as for a
+normal release. Backfilled releases are only made the latest release if they are newer than every existing one.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return backfill()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backfillCmd)
+
+	backfillCmd.Flags().StringVar(&backfillFrom, "from", "", "(optional) oldest tag to backfill (default is the oldest tag)")
+	backfillCmd.Flags().StringVar(&backfillTo, "to", "", "(optional) newest tag to backfill (default is the newest tag)")
+	backfillCmd.Flags().BoolVar(&backfillBuild, "build", false, "build each tag and upload its artifacts to the release")
+}
+
+// versionRange is the versions between from and to, inclusive. A nil bound is open.
+type versionRange struct {
+	from *version
+	to   *version
+}
+
+// parseVersionRange parses the bounds of a range of tags, either of which may be empty
+func parseVersionRange(from, to string) (*versionRange, error) {
+	r := &versionRange{}
+
+	var err error
+	if from != "" {
+		if r.from, err = parseVersion(from); err != nil {
+			return nil, fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if to != "" {
+		if r.to, err = parseVersion(to); err != nil {
+			return nil, fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+
+	if r.from != nil && r.to != nil && r.from.compare(r.to) > 0 {
+		return nil, fmt.Errorf("--from %s is newer than --to %s", from, to)
+	}
+
+	return r, nil
+}
+
+// contains returns true if the version is in the range
+func (r *versionRange) contains(v *version) bool {
+	if r.from != nil && v.compare(r.from) < 0 {
+		return false
+	}
+	if r.to != nil && v.compare(r.to) > 0 {
+		return false
+	}
+	return true
+}
+
+// backfillTags returns the semantic version tags of the repository in the range that have
+// no release, oldest first. Tags that aren't semantic versions are ignored.
+func backfillTags(repo *git.Repository, r *versionRange, releasesList *releases) ([]string, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]*version)
+	var tags []string
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		v, err := parseVersion(name)
+		if err != nil || !r.contains(v) || releaseForTag(releasesList, name) != nil {
+			return nil
+		}
+		versions[name] = v
+		tags = append(tags, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if c := versions[tags[i]].compare(versions[tags[j]]); c != 0 {
+			return c < 0
+		}
+		// eg: v1.0.0 and 1.0.0, or tags differing only in build metadata
+		return tags[i] < tags[j]
+	})
+
+	return tags, nil
+}
+
+// latestReleaseVersion returns the newest version of the published final releases, or
+// nil if there are none
+func latestReleaseVersion(releasesList *releases) *version {
+	var latest *version
+	for _, r := range *releasesList {
+		if r.TagName == nil || (r.Draft != nil && *r.Draft) || (r.Prerelease != nil && *r.Prerelease) {
+			continue
+		}
+		v, err := parseVersion(*r.TagName)
+		if err != nil || v.isPrerelease() {
+			continue
+		}
+		if latest == nil || v.compare(latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// backfillLatest returns the tag to make the latest release: the newest final version
+// backfilled, if it is newer than every existing release, or an empty string if none is
+func backfillLatest(tags []string, releasesList *releases) string {
+	latest := latestReleaseVersion(releasesList)
+	for i := len(tags) - 1; i >= 0; i-- {
+		v, _ := parseVersion(tags[i])
+		if v.isPrerelease() {
+			continue
+		}
+		if latest == nil || v.compare(latest) > 0 {
+			return tags[i]
+		}
+		return ""
+	}
+	return ""
+}
+
+func backfill() error {
+	r, err := parseVersionRange(backfillFrom, backfillTo)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPipeline("")
+	if err != nil {
+		return err
+	}
+
+	// Every branch is cloned, so every tag is available. Tags that are built need the clone
+	// on disk, where the build runs.
+	var cleanup func()
+	p.repo, p.dir, cleanup, err = cloneHistory(p.gURL.raw, "", inMemoryClone && !backfillBuild)
+	if err != nil {
+		return stageFailed(errClone, err)
+	}
+	defer cleanup()
+
+	forge := providerFor(p.releaseRepo)
+	auth, err := forge.authenticate(p.releaseRepo)
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	releases, err := forge.listReleases(p.releaseRepo)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	tags, err := backfillTags(p.repo, r, releases)
+	if err != nil {
+		return fmt.Errorf("failed listing tags: %w", err)
+	}
+	if len(tags) == 0 {
+		fmt.Println("Every tag in the range already has a release")
+		return nil
+	}
+
+	fmt.Printf("Creating releases for %d tags: %s\n", len(tags), strings.Join(tags, ", "))
+	if !confirm("Would you like to continue?") {
+		return fmt.Errorf("backfill %w", errHalted)
+	}
+
+	latest := backfillLatest(tags, releases)
+	defer func(t string) { tag = t }(tag)
+	for _, t := range tags {
+		// The pipeline stages, and the audit events, are of the tag being backfilled
+		tag = t
+		makeLatest := "false"
+		if t == latest {
+			makeLatest = "true"
+		}
+		// --latest marks every backfilled release the same
+		if markLatest != "" {
+			makeLatest = markLatest
+		}
+
+		err = auditedRelease(func() (*pipeline, error) {
+			return p, backfillRelease(p, forge, auth, t, makeLatest)
+		})
+		if err != nil {
+			return fmt.Errorf("failed backfilling %s: %w", t, err)
+		}
+
+		if p.release.HTMLURL != nil {
+			fmt.Printf("Created release %s: %s\n", t, *p.release.HTMLURL)
+		} else {
+			fmt.Printf("Created release %s\n", t)
+		}
+	}
+
+	return nil
+}
+
+// backfillRelease checks out the tag and creates its release, building and uploading its
+// artifacts with --build. makeLatest is passed to Github as make_latest.
+func backfillRelease(p *pipeline, forge provider, auth *UserAuth, t, makeLatest string) error {
+	p.release, p.artifacts = nil, nil
+
+	commit, err := commitForTag(p.repo, t)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		noteInfo(fmt.Sprintf("Checking out Tag %s\n", t))
+	}
+	_, err = checkoutCommitish(p.repo, commit.Hash)
+	if err != nil {
+		return err
+	}
+
+	// The build number is only taken for tags that are built
+	p.build = &buildInfo{
+		Version:     t,
+		Commit:      commit.Hash.String(),
+		ShortCommit: commit.Hash.String()[:7],
+	}
+	if backfillBuild {
+		p.build, err = newBuildInfo(p.repo, p.releaseRepo, t)
+		if err != nil {
+			return fmt.Errorf("failed preparing build info: %w", err)
+		}
+		if err = p.runBuild(); err != nil {
+			return err
+		}
+		if err = p.processArtifacts(); err != nil {
+			return err
+		}
+		// The next tag is built in the same clone, so its artifacts mustn't be mixed up with these
+		defer removeArtifacts(p.artifacts)
+	}
+
+	// The release notes are the tag message, if it is annotated, and the pull requests
+	tagObj, err := getTagFromString(t, p.repo)
+	if err != nil {
+		return err
+	}
+	var releaseBody string
+	if tagObj != nil {
+		releaseBody = strings.TrimSpace(tagObj.Message)
+	}
+
+	notes, err := generateNotesFromPullRequests(auth, p.releaseRepo, p.repo, t)
+	if err != nil {
+		return fmt.Errorf("failed generating release notes: %w", err)
+	}
+	releaseBody = strings.TrimSpace(releaseBody + "\n\n" + notes)
+
+	if securityAdvisories || len(securityAdvisoryIDs) > 0 {
+		fixes, err := securityFixesNotes(auth, p.releaseRepo, t)
+		if err != nil {
+			return fmt.Errorf("failed retrieving security advisories: %w", err)
+		}
+		releaseBody = strings.TrimSpace(releaseBody + "\n\n" + fixes)
+	}
+
+	if diffStats {
+		stats, err := releaseDiffStat(p.repo, t)
+		if err != nil {
+			return fmt.Errorf("failed computing diff stats: %w", err)
+		}
+		if stats != nil {
+			releaseBody = strings.TrimSpace(releaseBody + "\n\n" + stats.markdown())
+		}
+	}
+
+	v, err := parseVersion(t)
+	if err != nil {
+		return err
+	}
+
+	name, err := renderReleaseName(releaseName, p.releaseRepo.repository, t, p.build)
+	if err != nil {
+		return stageFailed(errRelease, err)
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Creating release %s", name))
+	}
+	p.release, err = forge.createRelease(auth, p.releaseRepo, &newReleaseRequest{
+		TagName:                t,
+		Name:                   name,
+		Body:                   releaseBody,
+		Draft:                  draft,
+		Prerelease:             v.isPrerelease(),
+		MakeLatest:             makeLatest,
+		DiscussionCategoryName: discussionCategory,
+	})
+	if err != nil {
+		return stageFailed(errRelease, err)
+	}
+
+	if len(p.artifacts) > 0 {
+		_, err = uploadAssets(forge, auth, p.release, p.artifacts)
+		if err != nil {
+			return stageFailed(errUpload, err)
+		}
+	}
+
+	return nil
+}
+
+// removeArtifacts deletes the artifacts' files, warning about any that can't be
+func removeArtifacts(artifacts []*artifact) {
+	for _, a := range artifacts {
+		if err := os.Remove(a.path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("WARNING: cannot remove %s: %s\n", a.path, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestParseVersionRange checks the range bounds are validated
+func TestParseVersionRange(t *testing.T) {
+	r, err := parseVersionRange("v1.0.0", "v1.5.0")
+	Nil(t, err)
+	for tag, want := range map[string]bool{
+		"v0.9.0":       false,
+		"v1.0.0-rc.1":  false,
+		"v1.0.0":       true,
+		"v1.5.0-rc.1":  true,
+		"v1.5.0":       true,
+		"v1.5.1":       false,
+		"v1.0.0+build": true,
+	} {
+		v, err := parseVersion(tag)
+		Nil(t, err)
+		Equal(t, want, r.contains(v), tag)
+	}
+
+	r, err = parseVersionRange("", "")
+	Nil(t, err)
+	v, _ := parseVersion("v0.0.1")
+	True(t, r.contains(v))
+
+	_, err = parseVersionRange("v1.5.0", "v1.0.0")
+	Error(t, err)
+	_, err = parseVersionRange("latest", "")
+	Error(t, err)
+}
+
+// TestBackfillTags checks only the semantic version tags in range without a release are
+// backfilled, oldest first
+func TestBackfillTags(t *testing.T) {
+	repo := gitfixture.New(t)
+	for _, name := range []string{"v0.9.0", "v1.0.0", "v1.2.0-rc.1", "v1.2.0", "v1.10.0", "nightly", "v2.0.0"} {
+		repo.Tag(name, repo.Commit(name, nil))
+	}
+
+	released := "v1.2.0"
+	r, err := parseVersionRange("v1.0.0", "v1.10.0")
+	Nil(t, err)
+
+	tags, err := backfillTags(repo.Repository, r, &releases{{TagName: &released}})
+	Nil(t, err)
+	Equal(t, []string{"v1.0.0", "v1.2.0-rc.1", "v1.10.0"}, tags)
+}
+
+// TestBackfillLatest checks a backfilled release is only made the latest if it is newer
+// than every existing release
+func TestBackfillLatest(t *testing.T) {
+	final, pre, isDraft := "v1.5.0", "v2.0.0-rc.1", true
+	existing := &releases{{TagName: &final}, {TagName: &pre, Draft: &isDraft}}
+
+	Equal(t, "", backfillLatest([]string{"v1.0.0", "v1.4.0"}, existing))
+	Equal(t, "v1.6.0", backfillLatest([]string{"v1.0.0", "v1.6.0", "v1.7.0-rc.1"}, existing))
+	Equal(t, "v1.4.0", backfillLatest([]string{"v1.0.0", "v1.4.0"}, &releases{}))
+	Equal(t, "", backfillLatest([]string{"v1.0.0-rc.1"}, &releases{}))
+}
@@ -0,0 +1,356 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// bitbucketHost is the host of Bitbucket Cloud repositories
+const bitbucketHost = "bitbucket.org"
+
+// bitbucketAPIURL is the base URL of the Bitbucket Cloud REST API
+var bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+
+// bitbucketUsername is the Bitbucket user whose app password bitbucketToken is
+var bitbucketUsername string
+
+// bitbucketToken is a repository, project or workspace access token to release on
+// Bitbucket with, or the app password of bitbucketUsername
+var bitbucketToken string
+
+// bitbucketTokenUsername is the username git authenticates with access tokens as
+const bitbucketTokenUsername = "x-token-auth"
+
+// bitbucketRepoURL returns the API URL of path under the repository
+func bitbucketRepoURL(gURL *gitURL, path string) string {
+	return fmt.Sprintf("%s/repositories/%s/%s/%s", strings.TrimSuffix(bitbucketAPIURL, "/"), url.PathEscape(gURL.organization), url.PathEscape(gURL.repository), path)
+}
+
+// bitbucketWebURL returns the web URL of path under the repository
+func bitbucketWebURL(gURL *gitURL, path string) string {
+	return fmt.Sprintf("https://%s/%s/%s/%s", bitbucketHost, gURL.organization, gURL.repository, path)
+}
+
+// bitbucketCredentials returns the username and secret to release on Bitbucket with: an
+// access token in --bitbucketToken or BITBUCKET_TOKEN, which acts as no user, or an app
+// password there too with --bitbucketUsername or BITBUCKET_USERNAME
+func bitbucketCredentials() (string, string, error) {
+	secret := bitbucketToken
+	if secret == "" {
+		secret = os.Getenv("BITBUCKET_TOKEN")
+	}
+	username := bitbucketUsername
+	if username == "" {
+		username = os.Getenv("BITBUCKET_USERNAME")
+	}
+
+	if secret == "" {
+		return "", "", errors.New("no Bitbucket token; set --bitbucketToken or BITBUCKET_TOKEN to an access token, or to an app password with --bitbucketUsername")
+	}
+
+	return username, secret, nil
+}
+
+// bitbucketAuth returns the credentials as the Authorization header authHeaders sends:
+// bearer access tokens, or basic auth with an app password, which may be the one for
+// Bitbucket in the netrc file or git's credential helpers
+func bitbucketAuth() (*UserAuth, error) {
+	username, secret, err := bitbucketCredentials()
+	if err != nil {
+		c := credentialFor(bitbucketHost)
+		if c == nil {
+			return nil, err
+		}
+		if verbose {
+			noteInfo(fmt.Sprintf("Using the Bitbucket credentials from %s", c.source))
+		}
+		// Access tokens for git over https are kept with their placeholder username
+		username, secret = c.username, c.password
+		if username == bitbucketTokenUsername {
+			username = ""
+		}
+	}
+
+	if username == "" {
+		return &UserAuth{AccessToken: secret, TokenType: "Bearer"}, nil
+	}
+
+	basic := base64.StdEncoding.EncodeToString([]byte(username + ":" + secret))
+	return &UserAuth{AccessToken: basic, TokenType: "Basic"}, nil
+}
+
+// bitbucketTag is a tag of the Bitbucket refs API
+type bitbucketTag struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	// Date is when an annotated tag was made; lightweight tags only have their commit's
+	Date   string `json:"date"`
+	Target struct {
+		Hash string `json:"hash"`
+		Date string `json:"date"`
+	} `json:"target"`
+}
+
+// bitbucketTagRequest is the payload creating a tag
+type bitbucketTagRequest struct {
+	Name   string `json:"name"`
+	Target struct {
+		Hash string `json:"hash"`
+	} `json:"target"`
+}
+
+// release converts the tag to the Github release the rest of the tool uses. Bitbucket
+// has no releases, so the tag is the release, its URL is the repository's downloads in
+// the API, where its assets are uploaded, and its page the repository's downloads page.
+func (t *bitbucketTag) release(gURL *gitURL) release {
+	date := t.Date
+	if date == "" {
+		date = t.Target.Date
+	}
+	downloadsURL := bitbucketRepoURL(gURL, "downloads")
+	htmlURL := bitbucketWebURL(gURL, "downloads/")
+	commitish := t.Target.Hash
+	draft, prerelease := false, false
+
+	return release{
+		TagName:         &t.Name,
+		TargetCommitish: &commitish,
+		Name:            &t.Name,
+		Body:            &t.Message,
+		Draft:           &draft,
+		Prerelease:      &prerelease,
+		CreatedAt:       &date,
+		PublishedAt:     &date,
+		URL:             &downloadsURL,
+		HTMLURL:         &htmlURL,
+		UploadURL:       &downloadsURL,
+	}
+}
+
+// getBitbucketTag retrieves the tag, or nil if the repository doesn't have it
+func getBitbucketTag(auth *UserAuth, gURL *gitURL, name string) (*bitbucketTag, error) {
+	req, err := newGetRequest(bitbucketRepoURL(gURL, "refs/tags/"+url.PathEscape(name)), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	body, err := makeHTTPRequest(req)
+	if isHTTPStatus(err, 404) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t bitbucketTag
+	if err = json.Unmarshal(body, &t); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// bitbucketProvider publishes to the Downloads of Bitbucket Cloud repositories. Bitbucket
+// has no releases, so the pushed tag stands for one, and the artifacts are uploaded to the
+// repository's downloads, which all its tags share.
+type bitbucketProvider struct{}
+
+func (b *bitbucketProvider) name() string {
+	return "Bitbucket"
+}
+
+func (b *bitbucketProvider) handles(host string) bool {
+	return host == bitbucketHost
+}
+
+func (b *bitbucketProvider) authenticate(gURL *gitURL) (*UserAuth, error) {
+	if unsupported := githubOnlySettings(); len(unsupported) > 0 {
+		return nil, fmt.Errorf("Bitbucket downloads don't support %s", strings.Join(unsupported, ", "))
+	}
+
+	return bitbucketAuth()
+}
+
+func (b *bitbucketProvider) listReleases(gURL *gitURL) (*releases, error) {
+	return releasesCache.get(releaseCacheKey(gURL), func() (*releases, error) {
+		// Private repositories' tags are only listed with a token
+		auth, _ := bitbucketAuth()
+
+		// The pages link to the next one, until the last
+		query := url.Values{"pagelen": {"100"}, "sort": {"-target.date"}}
+		next := bitbucketRepoURL(gURL, "refs/tags") + "?" + query.Encode()
+		var releasesList releases
+		for next != "" {
+			req, err := newGetRequest(next, url.Values{})
+			if err != nil {
+				return nil, err
+			}
+			if auth != nil {
+				for k, v := range authHeaders(auth) {
+					req.Header.Set(k, v)
+				}
+			}
+
+			body, err := makeHTTPRequest(req)
+			if err != nil {
+				return nil, err
+			}
+
+			var page struct {
+				Values []bitbucketTag `json:"values"`
+				Next   string         `json:"next"`
+			}
+			if err = json.Unmarshal(body, &page); err != nil {
+				return nil, err
+			}
+
+			for _, t := range page.Values {
+				releasesList = append(releasesList, t.release(gURL))
+			}
+			next = page.Next
+		}
+
+		return &releasesList, nil
+	})
+}
+
+// createRelease finds the tag the release stands for, creating it at the commitish if
+// it hasn't been pushed. Tags have nowhere to keep the notes, so they are uploaded to the
+// downloads with the artifacts, as <tag>-RELEASE_NOTES.md.
+func (b *bitbucketProvider) createRelease(auth *UserAuth, gURL *gitURL, request *newReleaseRequest) (*release, error) {
+	if request.Draft {
+		return nil, errors.New("Bitbucket has no draft releases")
+	}
+	if request.Prerelease {
+		return nil, errors.New("Bitbucket has no prereleases")
+	}
+
+	t, err := getBitbucketTag(auth, gURL, request.TagName)
+	if err != nil {
+		return nil, err
+	}
+
+	if t == nil {
+		if request.TargetCommitish == "" {
+			return nil, fmt.Errorf("tag %s isn't on Bitbucket", request.TagName)
+		}
+
+		tagRequest := bitbucketTagRequest{Name: request.TagName}
+		tagRequest.Target.Hash = request.TargetCommitish
+		req, err := newJSONPostRequest(bitbucketRepoURL(gURL, "refs/tags"), &tagRequest, authHeaders(auth))
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := makeHTTPRequest(req)
+		releasesCache.invalidate(releaseCacheKey(gURL))
+		if err != nil {
+			return nil, err
+		}
+
+		t = &bitbucketTag{}
+		if err = json.Unmarshal(body, t); err != nil {
+			return nil, err
+		}
+	}
+
+	r := t.release(gURL)
+	if request.Body != "" {
+		if err = b.uploadNotes(auth, &r, request.Body); err != nil {
+			return nil, fmt.Errorf("failed uploading the release notes: %w", err)
+		}
+		r.Body = &request.Body
+	}
+	return &r, nil
+}
+
+// uploadNotes uploads the release notes to the downloads of the tag's release
+func (b *bitbucketProvider) uploadNotes(auth *UserAuth, r *release, notes string) error {
+	f, err := ioutil.TempFile("", "release-notes")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(notes)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = b.uploadAsset(auth, r, &artifact{path: f.Name(), name: *r.TagName + "-RELEASE_NOTES.md", size: int64(len(notes))})
+	return err
+}
+
+// versionedName returns whether the download name includes the tag, or its version
+// without the "v", so the downloads of other tags can't replace it
+func versionedName(name, tag string) bool {
+	version := strings.TrimPrefix(tag, "v")
+	return strings.Contains(name, tag) || (strings.Contains(version, ".") && strings.Contains(name, version))
+}
+
+// versionDownloadNames prefixes the names of the artifacts that don't include the tag's
+// version with the tag, eg: v1.2.0-SHA256SUMS, as Bitbucket's downloads are shared by
+// all the tags of the repository
+func versionDownloadNames(artifacts []*artifact, tag string) {
+	for _, a := range artifacts {
+		if !versionedName(a.name, tag) {
+			a.name = tag + "-" + a.name
+		}
+	}
+}
+
+// uploadAsset uploads the artifact to the repository's downloads, replacing any download
+// of the same name. Names without the version are refused, as all tags share the downloads.
+func (b *bitbucketProvider) uploadAsset(auth *UserAuth, r *release, a *artifact) (*asset, error) {
+	if r.UploadURL == nil || r.HTMLURL == nil {
+		return nil, errors.New("release has no upload url")
+	}
+	if r.TagName != nil && !versionedName(a.name, *r.TagName) {
+		return nil, fmt.Errorf("download %s doesn't include the version %s, so would be replaced by the downloads of other tags", a.name, *r.TagName)
+	}
+
+	req, err := newMultipartUploadRequest(*r.UploadURL, "files", a, authHeaders(auth))
+	if err != nil {
+		return nil, err
+	}
+
+	// The upload's response is empty, so the download is described from the artifact
+	if _, err = makeHTTPRequest(req); err != nil {
+		return nil, err
+	}
+
+	name := a.name
+	size := int(a.size)
+	apiURL := *r.UploadURL + "/" + url.PathEscape(a.name)
+	download := *r.HTMLURL + url.PathEscape(a.name)
+
+	return &asset{Name: &name, Size: &size, URL: &apiURL, BrowserDownloadURL: &download}, nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// buildInfo describes the build of a release, and is passed to the build as environment variables
+type buildInfo struct {
+	// Version is the tag with any build metadata appended, eg: "v1.2.3+build.42"
+	Version     string
+	BuildNumber string
+	Commit      string
+	ShortCommit string
+	Date        string
+	// Describe is the commit as git describe --tags shows it, eg: "v1.2.3", or
+	// "v1.2.2-4-gabc1234" for a commit after the tag
+	Describe string
+}
+
+// environment returns the build info as environment variables for the build
+func (b *buildInfo) environment() []string {
+	return []string{
+		"VERSION=" + b.Version,
+		"BUILD_NUMBER=" + b.BuildNumber,
+		"GIT_COMMIT=" + b.Commit,
+	}
+}
+
+// newBuildInfo collects the build info for the checked out commit, incrementing the
+// build counter if one is configured, and renders the buildMetadata templates onto the version
+func newBuildInfo(repo *git.Repository, gURL *gitURL, tag string) (*buildInfo, error) {
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &buildInfo{
+		Version:     tag,
+		Commit:      head.Hash.String(),
+		ShortCommit: head.Hash.String()[:7],
+		Date:        time.Now().UTC().Format("20060102"),
+	}
+
+	// The history may not reach a tag, eg: in a shallow clone, so the release isn't failed over it
+	info.Describe, err = describeCommit(repo, head)
+	if err != nil {
+		info.Describe = tag
+		if info.Describe == "" {
+			info.Describe = info.ShortCommit
+		}
+		fmt.Printf("WARNING: cannot describe %s, using %s: %s\n", info.ShortCommit, info.Describe, err)
+	}
+
+	if buildCounter != "" {
+		n, err := nextBuildNumber(buildCounter, gURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed incrementing build counter: %w", err)
+		}
+		info.BuildNumber = strconv.Itoa(n)
+	}
+
+	var metadata []string
+	for _, m := range buildMetadata {
+		t, err := template.New("buildMetadata").Parse(m)
+		if err != nil {
+			return nil, err
+		}
+
+		var rendered bytes.Buffer
+		if err = t.Execute(&rendered, info); err != nil {
+			return nil, err
+		}
+
+		if s := rendered.String(); s != "" {
+			metadata = append(metadata, s)
+		}
+	}
+
+	if len(metadata) > 0 {
+		info.Version = appendBuildMetadata(tag, metadata)
+	}
+
+	return info, nil
+}
+
+// appendBuildMetadata appends the metadata identifiers to the version, after any
+// build metadata it already has, eg: "v1.2.3" and ["build.42", "sha.abc1234"]
+// become "v1.2.3+build.42.sha.abc1234"
+func appendBuildMetadata(version string, metadata []string) string {
+	separator := "+"
+	if strings.Contains(version, "+") {
+		separator = "."
+	}
+	return version + separator + strings.Join(metadata, ".")
+}
+
+// nextBuildNumber increments and returns the build number tracked by the counter source:
+//   - file:<path> - a file containing the last build number
+//   - github-variable:<NAME> - a Github Actions repository variable
+//   - api:<url> - an endpoint that returns the next build number in response to a POST
+func nextBuildNumber(source string, gURL *gitURL) (int, error) {
+	parts := strings.SplitN(source, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, fmt.Errorf("invalid build counter %q", source)
+	}
+
+	switch parts[0] {
+	case "file":
+		return nextFileBuildNumber(parts[1])
+	case "github-variable":
+		return nextGithubVariableBuildNumber(gURL, parts[1])
+	case "api":
+		return nextAPIBuildNumber(parts[1])
+	}
+
+	return 0, fmt.Errorf("unknown build counter type %q", parts[0])
+}
+
+// nextFileBuildNumber increments the build number stored in the file at path.
+// A missing file starts the count at 1.
+func nextFileBuildNumber(path string) (int, error) {
+	path = expandHome(path)
+
+	n := 0
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if err == nil {
+		n, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid build number in %s: %w", path, err)
+		}
+	}
+	n++
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	return n, ioutil.WriteFile(path, []byte(strconv.Itoa(n)+"\n"), 0644)
+}
+
+// actionsVariable is a Github Actions repository variable
+type actionsVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// nextGithubVariableBuildNumber increments the build number stored in a Github Actions
+// repository variable, creating the variable if it does not exist
+func nextGithubVariableBuildNumber(gURL *gitURL, name string) (int, error) {
+	auth, err := authenticate()
+	if err != nil {
+		return 0, err
+	}
+
+	variableURL := githubRepoURL(gURL, "actions/variables/"+url.PathEscape(name))
+
+	req, err := newGetRequest(variableURL, url.Values{})
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	n := 0
+	exists := true
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		if !isHTTPStatus(err, http.StatusNotFound) {
+			return 0, err
+		}
+		exists = false
+	} else {
+		var v actionsVariable
+		if err = json.Unmarshal(body, &v); err != nil {
+			return 0, err
+		}
+		if n, err = strconv.Atoi(strings.TrimSpace(v.Value)); err != nil {
+			return 0, fmt.Errorf("invalid build number in variable %s: %w", name, err)
+		}
+	}
+	n++
+
+	data, err := json.Marshal(actionsVariable{Name: name, Value: strconv.Itoa(n)})
+	if err != nil {
+		return 0, err
+	}
+
+	if exists {
+		req, err = newPatchRequest(variableURL, bytes.NewBuffer(data), authHeaders(auth))
+	} else {
+		req, err = newPostRequest(githubRepoURL(gURL, "actions/variables"), bytes.NewBuffer(data), authHeaders(auth), jsonHeaders())
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = makeHTTPRequest(req); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// nextAPIBuildNumber POSTs to the endpoint, which responds with the next build number
+// either as plain text or as JSON in the form {"build_number": 42}
+func nextAPIBuildNumber(endpoint string) (int, error) {
+	req, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		BuildNumber *int `json:"build_number"`
+	}
+	if json.Unmarshal(body, &response) == nil && response.BuildNumber != nil {
+		return *response.BuildNumber, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(body)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid build number response: %q", body)
+	}
+	return n, nil
+}
+
+// expandHome replaces a leading "~/" in the path with the user's home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestAppendBuildMetadata checks metadata is appended after any existing build metadata
+func TestAppendBuildMetadata(t *testing.T) {
+	metadataTests := []struct {
+		version  string
+		metadata []string
+		expected string
+	}{
+		{version: "v1.2.3", metadata: []string{"build.42"}, expected: "v1.2.3+build.42"},
+		{version: "v1.2.3-rc.1", metadata: []string{"build.42", "sha.abc1234"}, expected: "v1.2.3-rc.1+build.42.sha.abc1234"},
+		{version: "v1.2.3+linux", metadata: []string{"build.42"}, expected: "v1.2.3+linux.build.42"},
+	}
+
+	for _, tt := range metadataTests {
+		t.Run(tt.expected, func(t *testing.T) {
+			Equal(t, tt.expected, appendBuildMetadata(tt.version, tt.metadata))
+		})
+	}
+}
+
+// TestNextFileBuildNumber checks the file counter starts at 1 and increments on each build
+func TestNextFileBuildNumber(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildcounter")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "counter", "build-number")
+
+	n, err := nextFileBuildNumber(path)
+	Nil(t, err)
+	Equal(t, 1, n)
+
+	n, err = nextFileBuildNumber(path)
+	Nil(t, err)
+	Equal(t, 2, n)
+
+	Nil(t, ioutil.WriteFile(path, []byte("not a number"), 0644))
+	_, err = nextFileBuildNumber(path)
+	Error(t, err)
+}
+
+// TestNextBuildNumberInvalid checks malformed counter sources are rejected
+func TestNextBuildNumberInvalid(t *testing.T) {
+	for _, source := range []string{"file", "file:", "redis:counter"} {
+		t.Run(source, func(t *testing.T) {
+			_, err := nextBuildNumber(source, nil)
+			Error(t, err)
+		})
+	}
+}
+
+// TestDescribeCommit checks commits are described by the nearest tag like git describe --tags
+func TestDescribeCommit(t *testing.T) {
+	repo := gitfixture.New(t)
+
+	root := repo.CommitWith("root")
+	described, err := describeCommit(repo.Repository, root)
+	Nil(t, err)
+	Equal(t, root.Hash.String()[:7], described)
+
+	repo.Tag("v1.0.0", root)
+	described, err = describeCommit(repo.Repository, root)
+	Nil(t, err)
+	Equal(t, "v1.0.0", described)
+
+	head := repo.CommitWith("fix", repo.CommitWith("feature", root))
+	described, err = describeCommit(repo.Repository, head)
+	Nil(t, err)
+	Equal(t, "v1.0.0-2-g"+head.Hash.String()[:7], described)
+}
+
+// TestNewBuildInfoShallow checks a commit whose history can't be walked, as in a shallow
+// clone, is described by the tag instead of failing the release
+func TestNewBuildInfoShallow(t *testing.T) {
+	defer func(c string, m []string) { buildCounter, buildMetadata = c, m }(buildCounter, buildMetadata)
+	buildCounter, buildMetadata = "", nil
+
+	repo := gitfixture.New(t)
+	root := repo.CommitWith("root")
+	repo.Tag("v1.0.0", root)
+
+	// The parent of the head isn't in the repository
+	tree, err := repo.Worktree()
+	Nil(t, err)
+	sig := &object.Signature{Name: "test", When: time.Now()}
+	_, err = tree.Commit("shallow", &git.CommitOptions{Author: sig, Committer: sig, Parents: []plumbing.Hash{plumbing.NewHash("0123456789abcdef0123456789abcdef01234567")}})
+	Nil(t, err)
+
+	info, err := newBuildInfo(repo.Repository, nil, "v1.1.0")
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "v1.1.0", info.Describe)
+}
@@ -0,0 +1,251 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// cassette is a recording of HTTP interactions, which can be replayed in place of the
+// network for deterministic tests and debugging. Sensitive values are redacted before
+// interactions are recorded, the same as for --traceHTTP.
+type cassette struct {
+	Interactions []*interaction `json:"interactions"`
+
+	path string
+	used []bool
+	mu   sync.Mutex
+}
+
+// interaction is a single recorded request and its response
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+// recordedRequest identifies the request an interaction is replayed for
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// recordedResponse is replayed to the client
+type recordedResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body"`
+}
+
+// loadCassette reads a cassette from a file
+func loadCassette(path string) (*cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cassette{path: path}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	c.used = make([]bool, len(c.Interactions))
+
+	return c, nil
+}
+
+// save writes the cassette to its file
+func (c *cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(data, '\n'), 0600)
+}
+
+// record adds an interaction and saves the cassette, so the interactions are kept
+// even if the run fails part way
+func (c *cassette) record(i *interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, i)
+	c.used = append(c.used, true)
+	return c.save()
+}
+
+// next returns the first interaction not yet replayed that matches the method and URL
+func (c *cassette) next(method, url string) *interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, recorded := range c.Interactions {
+		if c.used[i] || recorded.Request.Method != method || recorded.Request.URL != url {
+			continue
+		}
+		c.used[i] = true
+		return recorded
+	}
+
+	return nil
+}
+
+// recordingTransport is an http.RoundTripper that records each exchange to a cassette
+type recordingTransport struct {
+	next     http.RoundTripper
+	cassette *cassette
+}
+
+// RoundTrip executes the request with the wrapped transport, and records the exchange
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	recorded := &interaction{
+		Request: recordedRequest{Method: req.Method, URL: sanitizeURL(req.URL)},
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			recorded.Request.Body = recordableRequestBody(body, req.ContentLength)
+			body.Close()
+		}
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	recorded.Response = recordedResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    make(map[string][]string),
+		Body:       recordableBody(data),
+	}
+	for name, values := range resp.Header {
+		if !redactedHeaders[http.CanonicalHeaderKey(name)] {
+			recorded.Response.Headers[name] = values
+		}
+	}
+
+	if err = t.cassette.record(recorded); err != nil {
+		return nil, fmt.Errorf("failed recording HTTP interaction: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replayTransport is an http.RoundTripper that answers requests from a cassette,
+// without using the network
+type replayTransport struct {
+	cassette *cassette
+}
+
+// RoundTrip returns the recorded response for the request
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := sanitizeURL(req.URL)
+
+	recorded := t.cassette.next(req.Method, url)
+	if recorded == nil {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, url, t.cassette.path)
+	}
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	header := make(http.Header)
+	for name, values := range recorded.Response.Headers {
+		header[name] = values
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+		StatusCode:    recorded.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(recorded.Response.Body)),
+		ContentLength: int64(len(recorded.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// recordableBody returns the body for the cassette, with sensitive values redacted.
+// Binary bodies, such as uploaded assets, are summarised.
+func recordableBody(data []byte) string {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return fmt.Sprintf("[binary body: %d bytes]", len(data))
+	}
+	return redactBody(string(data))
+}
+
+// recordableRequestBody returns the request body as it is recorded. Binary bodies, such
+// as streamed asset uploads, are only read far enough to tell they are binary.
+func recordableRequestBody(body io.Reader, length int64) string {
+	prefix, _ := ioutil.ReadAll(io.LimitReader(body, traceBodyLimit))
+	if bytes.IndexByte(prefix, 0) >= 0 {
+		return fmt.Sprintf("[binary body: %d bytes]", length)
+	}
+
+	rest, _ := ioutil.ReadAll(body)
+	return recordableBody(append(prefix, rest...))
+}
+
+// enableHTTPRecord makes httpClient record every exchange to a new cassette at path
+func enableHTTPRecord(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("cassette %s already exists", path)
+	}
+
+	c := &cassette{path: path, Interactions: []*interaction{}}
+	if err := c.save(); err != nil {
+		return err
+	}
+
+	httpClient.Transport = &recordingTransport{next: httpClient.Transport, cassette: c}
+	return nil
+}
+
+// enableHTTPReplay makes httpClient answer every request from the cassette at path
+func enableHTTPReplay(path string) error {
+	c, err := loadCassette(path)
+	if err != nil {
+		return err
+	}
+
+	httpClient.Transport = &replayTransport{cassette: c}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// replayCassette makes httpClient answer requests from the cassette in testdata/cassettes,
+// and returns a func to restore the network transport
+func replayCassette(t *testing.T, name string) func() {
+	previous := httpClient.Transport
+	err := enableHTTPReplay(filepath.Join("testdata", "cassettes", name))
+	if err != nil {
+		t.Fatalf("failed loading cassette %s: %s", name, err)
+	}
+	return func() { httpClient.Transport = previous }
+}
+
+// TestReplayCreateRelease runs listing, creating and uploading to a release against a recorded cassette
+func TestReplayCreateRelease(t *testing.T) {
+	defer replayCassette(t, "create-release.json")()
+
+	gURL := &gitURL{organization: "clcollins", repository: "go-git-release"}
+	auth := &UserAuth{AccessToken: "abc123", TokenType: "bearer"}
+
+	releasesList, err := getReleases(gURL)
+	Nil(t, err)
+	Len(t, *releasesList, 1)
+	Equal(t, "v0.1.0", *(*releasesList)[0].TagName)
+
+	r, err := createRelease(&githubProvider{}, auth, gURL, "v0.2.0", "v0.2.0", "Second release", "", false, false)
+	Nil(t, err)
+	Equal(t, 2, *r.ID)
+
+	dir, err := ioutil.TempDir("", "cassette")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "go-git-release")
+	Nil(t, ioutil.WriteFile(path, []byte("\x7fELF\x00binarydata"), 0755))
+
+	uploaded, err := uploadAssets(&githubProvider{}, auth, r, []*artifact{{path: path, name: "go-git-release", size: 16}})
+	Nil(t, err)
+	Len(t, uploaded, 1)
+	Equal(t, "uploaded", *uploaded[0].State)
+
+	// Every interaction has been replayed, so a repeated request has no response
+	_, err = getReleases(gURL)
+	Error(t, err)
+}
+
+// TestRecordCassette records requests to a server, and checks the cassette replays them without secrets
+func TestRecordCassette(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Private-Token", "glpat-secret")
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"access_token":"gho_secret","token_type":"bearer"}`)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cassette")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recorded.json")
+
+	previous := httpClient.Transport
+	defer func() { httpClient.Transport = previous }()
+
+	Nil(t, enableHTTPRecord(path))
+	Error(t, enableHTTPRecord(path), "an existing cassette is not overwritten")
+
+	params := url.Values{}
+	params.Add("device_code", "device-secret")
+	req, err := newPostRequest(server.URL+"/login/oauth/access_token?client_id=abc", strings.NewReader(params.Encode()))
+	Nil(t, err)
+	req.Header.Set("Authorization", "bearer secret")
+
+	body, err := makeHTTPRequest(req)
+	Nil(t, err)
+	Contains(t, string(body), "gho_secret", "the caller still receives the real response")
+
+	recorded, err := ioutil.ReadFile(path)
+	Nil(t, err)
+	NotContains(t, string(recorded), "secret")
+	Contains(t, string(recorded), "/login/oauth/access_token?client_id=abc")
+
+	Nil(t, enableHTTPReplay(path))
+	req, err = newPostRequest(server.URL+"/login/oauth/access_token?client_id=abc", strings.NewReader(params.Encode()))
+	Nil(t, err)
+	server.Close()
+
+	body, err = makeHTTPRequest(req)
+	Nil(t, err)
+	Contains(t, string(body), `"token_type":"bearer"`)
+}
@@ -0,0 +1,261 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// checksums uploads a checksum file of the artifacts with the release
+var checksums bool
+
+// checksumAlgorithm is the digest used in the checksum file: sha256 or sha512
+var checksumAlgorithm string
+
+// checksumSignCommand signs the checksum file with a detached signature, eg: with gpg. It
+// may reference {{ .Path }}, the checksum file, and {{ .Signature }}, the signature to write.
+var checksumSignCommand string
+
+// signatureExt is the extension of the checksum file's detached signature, eg: SHA256SUMS.sig
+const signatureExt = ".sig"
+
+// signatureExts are the extensions of detached signatures uploaded alongside the assets
+// they sign, eg: app-linux.asc
+var signatureExts = []string{signatureExt, ".asc"}
+
+// checksumSignVars are the values available to the checksum signing command
+type checksumSignVars struct {
+	Path      string
+	Signature string
+}
+
+// checksumManifest maps asset names to their hex encoded digests
+type checksumManifest map[string]string
+
+// parseChecksumManifest parses a checksum file in the format written by sha256sum,
+// one "<digest>  <name>" per line. The name is the rest of the line, so it may contain
+// spaces. A leading "*" on the name (binary mode) is ignored.
+func parseChecksumManifest(data string) (checksumManifest, error) {
+	manifest := make(checksumManifest)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid checksum line: %q", line)
+		}
+		// The digest is followed by a space, then a space in text mode or "*" in binary mode
+		name := line[i+1:]
+		if strings.HasPrefix(name, " ") || strings.HasPrefix(name, "*") {
+			name = name[1:]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid checksum line: %q", line)
+		}
+
+		manifest[name] = strings.ToLower(line[:i])
+	}
+
+	return manifest, scanner.Err()
+}
+
+// hasherForDigest returns a hash matching the length of the hex encoded digest
+func hasherForDigest(digest string) (hash.Hash, error) {
+	switch len(digest) {
+	case sha256.Size * 2:
+		return sha256.New(), nil
+	case sha512.Size * 2:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported digest length %d", len(digest))
+}
+
+// hashReader returns the hex encoded digest of everything read from r
+func hashReader(h hash.Hash, r io.Reader) (string, error) {
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of the file at path
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return hashReader(sha256.New(), f)
+}
+
+// newChecksumHasher returns the hash for the checksum algorithm
+func newChecksumHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+}
+
+// checksumFileName returns the name of the checksum file for the algorithm, eg: SHA256SUMS
+func checksumFileName(algorithm string) string {
+	return strings.ToUpper(algorithm) + "SUMS"
+}
+
+// writeChecksumFile writes the digests of the artifacts to a checksum file in dir, in
+// the format written by sha256sum, and returns it as an artifact to upload
+func writeChecksumFile(dir string, artifacts []*artifact, algorithm string) (*artifact, error) {
+	var b strings.Builder
+	for _, a := range artifacts {
+		h, err := newChecksumHasher(algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := os.Open(a.path)
+		if err != nil {
+			return nil, err
+		}
+		digest, err := hashReader(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&b, "%s  %s\n", digest, a.name)
+	}
+
+	name := checksumFileName(algorithm)
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return nil, err
+	}
+
+	return &artifact{path: path, name: name, size: int64(b.Len())}, nil
+}
+
+// signChecksumFile runs the signing command against the checksum file, and returns the
+// detached signature it wrote as an artifact to upload
+func signChecksumFile(sums *artifact, command string) (*artifact, error) {
+	return signFile(sums, command, signatureExt)
+}
+
+// signFile runs the signing command against the artifact, and returns the detached
+// signature it wrote, named for the artifact with the extension, as an artifact to upload
+func signFile(a *artifact, command, ext string) (*artifact, error) {
+	tmpl, err := template.New("checksumSignCommand").Parse(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum signing command: %w", err)
+	}
+
+	vars := checksumSignVars{Path: a.path, Signature: a.path + ext}
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, vars); err != nil {
+		return nil, fmt.Errorf("invalid checksum signing command: %w", err)
+	}
+
+	args, err := splitCommand(rendered.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("rendered an empty checksum signing command")
+	}
+
+	// A stale signature mustn't be mistaken for the new one
+	if err = os.Remove(vars.Signature); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err = runCommand(args[0], args[1:]...); err != nil {
+		return nil, fmt.Errorf("failed signing %s: %w", a.name, err)
+	}
+
+	info, err := os.Stat(vars.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("the checksum signing command didn't write %s: %w", vars.Signature, err)
+	}
+
+	return &artifact{path: vars.Signature, name: a.name + ext, size: info.Size()}, nil
+}
+
+// isChecksumAsset returns true if the asset is a checksum file, of any algorithm, or its signature
+func isChecksumAsset(name string) bool {
+	for _, algorithm := range []string{"sha256", "sha512"} {
+		sums := checksumFileName(algorithm)
+		if name == sums {
+			return true
+		}
+		for _, ext := range signatureExts {
+			if name == sums+ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// downloadDigest downloads the file at url, hashing it as it is read, and returns
+// the hex encoded digest using the same algorithm as the expected digest
+func downloadDigest(url, expected string) (string, error) {
+	h, err := hasherForDigest(expected)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+
+	r, err := ctxhttp.Do(context.TODO(), httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != 200 {
+		return "", &httpError{StatusCode: r.StatusCode, Status: r.Status}
+	}
+
+	return hashReader(h, r.Body)
+}
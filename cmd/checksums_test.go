@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestWriteChecksumFile checks the checksum file can be read back by verify
+func TestWriteChecksumFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksums")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app")
+	Nil(t, ioutil.WriteFile(path, []byte("hello\n"), 0644))
+	artifacts := []*artifact{{path: path, name: "app"}}
+
+	checksumTests := []struct {
+		algorithm string
+		name      string
+		digest    string
+	}{
+		{algorithm: "sha256", name: "SHA256SUMS", digest: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+		{algorithm: "sha512", name: "SHA512SUMS", digest: "e7c22b994c59d9cf2b48e549b1e24666636045930d3da7c1acb299d1c3b7f931f94aae41edda2c2b207a36e10f8bcb8d45223e54878f5b316e7ce3b6bc019629"},
+	}
+
+	for _, testSpec := range checksumTests {
+		t.Run(
+			testSpec.algorithm,
+			func(t *testing.T) {
+				sums, err := writeChecksumFile(dir, artifacts, testSpec.algorithm)
+				Nil(t, err)
+				Equal(t, testSpec.name, sums.name)
+
+				data, err := ioutil.ReadFile(sums.path)
+				Nil(t, err)
+				manifest, err := parseChecksumManifest(string(data))
+				Nil(t, err)
+				Equal(t, checksumManifest{"app": testSpec.digest}, manifest)
+			},
+		)
+	}
+}
+
+// TestParseChecksumManifest checks the name is the rest of the line, in text or binary mode
+func TestParseChecksumManifest(t *testing.T) {
+	parseChecksumManifestTests := []struct {
+		name     string
+		data     string
+		manifest checksumManifest
+		failure  bool
+	}{
+		{name: "text mode", data: "ABC123  app\n", manifest: checksumManifest{"app": "abc123"}},
+		{name: "binary mode", data: "abc123 *app.exe\n", manifest: checksumManifest{"app.exe": "abc123"}},
+		{name: "single space", data: "abc123 app\n", manifest: checksumManifest{"app": "abc123"}},
+		{name: "spaces in name", data: "abc123  my app v1.tar.gz\n\ndef456  other\n", manifest: checksumManifest{"my app v1.tar.gz": "abc123", "other": "def456"}},
+		{name: "no name", data: "abc123\n", failure: true},
+		{name: "empty name", data: "abc123 *\n", failure: true},
+	}
+
+	for _, testSpec := range parseChecksumManifestTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			manifest, err := parseChecksumManifest(testSpec.data)
+			if testSpec.failure {
+				NotNil(t, err)
+				return
+			}
+			Nil(t, err)
+			Equal(t, testSpec.manifest, manifest)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// ciMode runs unattended, as in CI: only a provided token or Github App is used, nothing is
+// prompted for, no browser is opened, and missing permissions are reported with how to
+// grant them. It is enabled in Github Actions jobs unless --ci=false.
+var ciMode bool
+
+// ciFlag is the --ci flag, to tell if it was set, eg: to false in a Github Actions job
+var ciFlag *pflag.Flag
+
+// acceptedPermissionsHeader lists the permissions Github needed when it forbids a request
+const acceptedPermissionsHeader = "X-Accepted-GitHub-Permissions"
+
+// installationTokenPrefix starts the installation tokens of Github Apps, including the
+// Github Actions job token
+const installationTokenPrefix = "ghs_"
+
+// githubActions returns true if running in a Github Actions job
+func githubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// errCINoToken is returned when there is no token to use in --ci mode
+var errCINoToken = errors.New("no token to authenticate with in --ci mode")
+
+// ciNoTokenError says how to provide a token, as --ci mode never runs the device flow
+func ciNoTokenError() error {
+	if githubActions() {
+		return fmt.Errorf("%w; pass the job's token to the step:\n  env:\n    GITHUB_TOKEN: ${{ secrets.GITHUB_TOKEN }}", errCINoToken)
+	}
+	return fmt.Errorf("%w; set --token, GITHUB_TOKEN or GH_TOKEN, or configure a Github App with --appID", errCINoToken)
+}
+
+// jobPermissions renders the Github permissions, as listed by the X-Accepted-GitHub-Permissions
+// header, eg: "contents=write", as the permissions block of a workflow job. Only the first
+// of the alternative sets, separated by semicolons, is used.
+func jobPermissions(accepted string) string {
+	set := strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0])
+	if set == "" {
+		return ""
+	}
+
+	lines := []string{"permissions:"}
+	for _, p := range strings.Split(set, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", kv[0], kv[1]))
+	}
+	if len(lines) == 1 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ciHint returns how to fix a CI job that failed with err: how to grant the token what a
+// forbidden request needed, or how to go ahead without a prompt that couldn't be answered.
+// It returns an empty string for any other error.
+func ciHint(err error) string {
+	if errors.Is(err, errHalted) {
+		return "Nobody can answer the prompts in --ci mode; use --force to go ahead without them, or --promptDefault yes"
+	}
+
+	var e *httpError
+	if !errors.As(err, &e) || e.StatusCode != 403 || e.RetryAfter > 0 {
+		return ""
+	}
+
+	if permissions := jobPermissions(e.AcceptedPermissions); permissions != "" {
+		return "The token is missing permissions; grant them in the workflow job:\n" + permissions
+	}
+	return "The token is missing permissions; publishing releases needs, eg: in the workflow job:\npermissions:\n  contents: write"
+}
+
+// ciPreflight checks the token can publish releases to the repository, before anything is
+// cloned or built, so a misconfigured job fails in seconds rather than after the build
+func ciPreflight(gURL *gitURL) error {
+	auth, err := authenticate()
+	if err != nil {
+		return err
+	}
+
+	info, err := getRepository(auth, gURL)
+	switch {
+	case isHTTPStatus(err, 401):
+		return fmt.Errorf("the token was rejected; it may have expired or been revoked: %w", err)
+	case isHTTPStatus(err, 404):
+		return fmt.Errorf("the token cannot access %s/%s; the job token only has access to the workflow's repository, so use a personal access token or a Github App for others: %w", gURL.organization, gURL.repository, err)
+	case err != nil:
+		return err
+	}
+
+	// Installation tokens, such as the job token, don't have user permissions to check
+	if strings.HasPrefix(auth.AccessToken, installationTokenPrefix) {
+		return nil
+	}
+	if info.Permissions != nil && !info.Permissions.Push {
+		return fmt.Errorf("the token cannot publish releases to %s/%s; the job token needs:\npermissions:\n  contents: write", gURL.organization, gURL.repository)
+	}
+
+	return checkTokenScopes(auth, info)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestJobPermissions checks the accepted permissions become a workflow permissions block
+func TestJobPermissions(t *testing.T) {
+	jobPermissionsTests := []struct {
+		name     string
+		accepted string
+		expected string
+	}{
+		{name: "none", accepted: "", expected: ""},
+		{name: "one", accepted: "contents=write", expected: "permissions:\n  contents: write"},
+		{name: "several", accepted: "contents=write, pull_requests=read", expected: "permissions:\n  contents: write\n  pull_requests: read"},
+		{name: "alternatives", accepted: "contents=write; administration=write", expected: "permissions:\n  contents: write"},
+		{name: "malformed", accepted: "contents", expected: ""},
+	}
+
+	for _, testSpec := range jobPermissionsTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				Equal(t, testSpec.expected, jobPermissions(testSpec.accepted))
+			},
+		)
+	}
+}
+
+// TestCIHint checks only forbidden requests and halted prompts get a hint
+func TestCIHint(t *testing.T) {
+	forbidden := stageFailed(errRelease, &httpError{StatusCode: 403, AcceptedPermissions: "contents=write"})
+	Contains(t, ciHint(forbidden), "grant them in the workflow job:\npermissions:\n  contents: write")
+	Contains(t, ciHint(&httpError{StatusCode: 403}), "contents: write")
+	Equal(t, "", ciHint(&httpError{StatusCode: 404}))
+	Equal(t, "", ciHint(errors.New("other")))
+	Contains(t, ciHint(fmt.Errorf("tag exists; execution %w", errHalted)), "use --force")
+}
+
+// TestCIAuthenticate checks --ci mode uses a provided token, and otherwise fails rather
+// than running the device flow
+func TestCIAuthenticate(t *testing.T) {
+	defer unsetTokenEnv()()
+	defer func() { ciMode, cachedAuth = false, nil }()
+	ciMode = true
+
+	_, err := authenticate()
+	True(t, errors.Is(err, errCINoToken))
+
+	os.Setenv("GITHUB_TOKEN", "ghs_job")
+	auth, err := authenticate()
+	Nil(t, err)
+	Equal(t, "ghs_job", auth.AccessToken)
+}
+
+// TestCIPreflight checks a token that can't publish releases fails before the build
+func TestCIPreflight(t *testing.T) {
+	defer gock.Off()
+	defer func() { cachedAuth = nil }()
+	gURL, err := parseGitURL("https://github.com/o/r.git")
+	Nil(t, err)
+
+	ciPreflightTests := []struct {
+		name    string
+		token   string
+		status  int
+		push    bool
+		scopes  string
+		failure string
+	}{
+		{name: "can push", token: "ghp_user", status: 200, push: true, scopes: "repo"},
+		{name: "missing scope", token: "ghp_user", status: 200, push: true, scopes: "public_repo", failure: "needs the repo scope"},
+		{name: "cannot push", token: "ghp_user", status: 200, failure: "cannot publish releases"},
+		{name: "job token", token: "ghs_job", status: 200},
+		{name: "rejected", token: "ghs_job", status: 401, failure: "rejected"},
+		{name: "other repository", token: "ghs_job", status: 404, failure: "only has access to the workflow's repository"},
+	}
+
+	for _, testSpec := range ciPreflightTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				cachedAuth = &UserAuth{TokenType: "token", AccessToken: testSpec.token}
+				gock.New("https://api.github.com").
+					Get("/repos/o/r").
+					Reply(testSpec.status).
+					JSON(map[string]interface{}{
+						"full_name":   "o/r",
+						"private":     true,
+						"permissions": map[string]bool{"push": testSpec.push},
+					})
+				if testSpec.scopes != "" {
+					gock.New("https://api.github.com").
+						Get("/user").
+						Reply(200).
+						SetHeader(oauthScopesHeader, testSpec.scopes).
+						JSON(map[string]string{"login": "someone"})
+				}
+
+				err := ciPreflight(gURL)
+				if testSpec.failure == "" {
+					Nil(t, err)
+				} else if NotNil(t, err) {
+					Contains(t, err.Error(), testSpec.failure)
+				}
+			},
+		)
+	}
+}
+
+// TestCIConfirm checks --ci mode answers the default without waiting for input
+func TestCIConfirm(t *testing.T) {
+	defer func() { ciMode, promptDefault = false, "no" }()
+	ciMode = true
+
+	promptDefault = "no"
+	False(t, confirm("Continue?"))
+	promptDefault = "yes"
+	True(t, confirm("Continue?"))
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// codeownersLocations are the paths Github looks for a CODEOWNERS file in, in order
+var codeownersLocations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// codeownersRule is a single line of a CODEOWNERS file
+type codeownersRule struct {
+	pattern string
+	owners  []string
+}
+
+// readCodeowners reads the CODEOWNERS file from the repository checked out in dir.
+// A repository without a CODEOWNERS file returns no rules.
+func readCodeowners(dir string) ([]codeownersRule, error) {
+	for _, location := range codeownersLocations {
+		data, err := ioutil.ReadFile(filepath.Join(dir, location))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseCodeowners(string(data)), nil
+	}
+
+	return nil, nil
+}
+
+// parseCodeowners parses the rules in a CODEOWNERS file, ignoring comments and blank lines
+func parseCodeowners(data string) []codeownersRule {
+	var rules []codeownersRule
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rules = append(rules, codeownersRule{
+			pattern: fields[0],
+			owners:  fields[1:],
+		})
+	}
+
+	return rules
+}
+
+// matches returns true if the rule's pattern matches the repository-relative file path,
+// following the gitignore-style rules Github uses for CODEOWNERS
+func (r codeownersRule) matches(file string) bool {
+	pattern := r.pattern
+
+	if pattern == "*" {
+		return true
+	}
+
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	// A trailing slash, or a trailing "/**", matches everything in the directory
+	directory := strings.HasSuffix(pattern, "/") || strings.HasSuffix(pattern, "/**")
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "**"), "/")
+
+	// Patterns without a slash match at any depth
+	if !anchored && !strings.Contains(pattern, "/") {
+		for _, segment := range strings.Split(file, "/") {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Non-anchored patterns containing a slash are still relative to the root
+	segments := strings.Split(file, "/")
+	patternSegments := strings.Split(pattern, "/")
+	if len(segments) < len(patternSegments) {
+		return false
+	}
+
+	for i, p := range patternSegments {
+		if ok, _ := path.Match(p, segments[i]); !ok {
+			return false
+		}
+	}
+
+	// A pattern ending in a wildcard only matches files directly in the directory,
+	// otherwise a pattern naming a directory also matches everything beneath it
+	last := patternSegments[len(patternSegments)-1]
+	if !directory && strings.Contains(last, "*") {
+		return len(segments) == len(patternSegments)
+	}
+
+	return true
+}
+
+// ownersForPaths returns the sorted, de-duplicated owners of the provided paths.
+// As with Github, the last matching rule in the file takes precedence.
+func ownersForPaths(rules []codeownersRule, paths []string) []string {
+	seen := make(map[string]bool)
+
+	for _, p := range paths {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].matches(p) {
+				for _, owner := range rules[i].owners {
+					seen[owner] = true
+				}
+				break
+			}
+		}
+	}
+
+	owners := make([]string, 0, len(seen))
+	for owner := range seen {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	return owners
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestOwnersForPaths checks changed paths are matched to their CODEOWNERS,
+// with the last matching rule taking precedence
+func TestOwnersForPaths(t *testing.T) {
+	rules := parseCodeowners(`
+# Default owners
+*                 @org/maintainers
+
+*.go              @org/gophers
+/docs/*           @org/docs
+cmd/              @org/cli
+/cmd/release.go   @alice @bob
+`)
+
+	ownerTests := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "README.md", expected: []string{"@org/maintainers"}},
+		{path: "main.go", expected: []string{"@org/gophers"}},
+		{path: "docs/usage.md", expected: []string{"@org/docs"}},
+		{path: "docs/api/usage.md", expected: []string{"@org/maintainers"}},
+		{path: "cmd/root.go", expected: []string{"@org/cli"}},
+		{path: "cmd/release.go", expected: []string{"@alice", "@bob"}},
+	}
+
+	for _, testSpec := range ownerTests {
+		t.Run(
+			testSpec.path,
+			func(t *testing.T) {
+				Equal(t, testSpec.expected, ownersForPaths(rules, []string{testSpec.path}))
+			},
+		)
+	}
+
+	Equal(
+		t,
+		[]string{"@org/cli", "@org/docs", "@org/gophers"},
+		ownersForPaths(rules, []string{"main.go", "docs/usage.md", "cmd/root.go"}),
+	)
+}
@@ -0,0 +1,410 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configCheckAuth runs the device flow during the config check, to check the token
+var configCheckAuth bool
+
+// configOnlyKeys are the settings that can only be set in the config file
+var configOnlyKeys = []string{"gitProtocolHosts", "postProcessors", "archives", "sourceArchive", "notesSections", "mirrors", "auth"}
+
+// configCmd groups the commands that work with the configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the go-git-release configuration",
+
+	// The subcommands report every problem with the settings, rather than exiting at the
+	// first as setup does, so only the HTTP client and git output are set up here
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		loadSettings()
+		configureClients()
+	},
+}
+
+// configCheckCmd reports on problems with the configuration
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the configuration before release day",
+	Long: `check loads the configuration from the config file, environment and flags, and reports on it: unknown
+or invalid settings, secrets that can't be read, files and commands that don't exist, and whether the
+repository can be reached through the Github API. A token from --token, GITHUB_TOKEN or GH_TOKEN is checked
+can publish releases to the repository; with --auth, the device flow is run to check its token. It exits with an error if any check fails.`,
+
+	// The report already says what's wrong
+	SilenceUsage: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := checkConfig()
+		r.print(os.Stdout)
+		if r.failed() {
+			return fmt.Errorf("configuration check failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configCheckCmd)
+
+	configCheckCmd.Flags().BoolVar(&configCheckAuth, "auth", false, "authenticate with the device flow to check the token can publish releases")
+}
+
+// checkStatus is the outcome of a single check
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+// checkResult is the outcome of a single check, and why it didn't pass
+type checkResult struct {
+	status checkStatus
+	name   string
+	err    error
+}
+
+// configReport collects the results of the config checks
+type configReport struct {
+	results []checkResult
+}
+
+// pass records a check that passed
+func (r *configReport) pass(name string) {
+	r.results = append(r.results, checkResult{status: checkPass, name: name})
+}
+
+// warn records a problem that doesn't stop the release
+func (r *configReport) warn(name string, err error) {
+	r.results = append(r.results, checkResult{status: checkWarn, name: name, err: err})
+}
+
+// fail records a problem the release would fail on
+func (r *configReport) fail(name string, err error) {
+	r.results = append(r.results, checkResult{status: checkFail, name: name, err: err})
+}
+
+// check records a pass, or a failure if err isn't nil
+func (r *configReport) check(name string, err error) {
+	if err != nil {
+		r.fail(name, err)
+		return
+	}
+	r.pass(name)
+}
+
+// failed returns true if any check failed
+func (r *configReport) failed() bool {
+	for _, c := range r.results {
+		if c.status == checkFail {
+			return true
+		}
+	}
+	return false
+}
+
+// print writes the report, one line per check, and a summary
+func (r *configReport) print(out io.Writer) {
+	counts := make(map[checkStatus]int)
+	for _, c := range r.results {
+		counts[c.status]++
+
+		status := string(c.status)
+		switch c.status {
+		case checkFail:
+			status = colorize(status, colorRed)
+		case checkPass:
+			status = colorize(status, colorCyan)
+		}
+
+		if c.err != nil {
+			fmt.Fprintf(out, "%s %s: %s\n", status, c.name, c.err)
+		} else {
+			fmt.Fprintf(out, "%s %s\n", status, c.name)
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d checks: %d passed, %d warnings, %d failed\n", len(r.results), counts[checkPass], counts[checkWarn], counts[checkFail])
+}
+
+// checkConfig loads the configuration and runs every check against it
+func checkConfig() *configReport {
+	r := &configReport{}
+
+	if f := viper.ConfigFileUsed(); f == "" {
+		r.warn("config file", fmt.Errorf("no config file found; using flags and environment only"))
+	} else if _, err := os.Stat(f); err != nil {
+		r.warn("config file", fmt.Errorf("%s not found; using flags and environment only", f))
+	} else {
+		r.pass("config file " + f)
+	}
+
+	loadErrs := loadSettings()
+	for _, err := range loadErrs {
+		r.fail("config schema", err)
+	}
+	if len(loadErrs) == 0 {
+		r.pass("config schema")
+	}
+	for _, key := range unknownConfigKeys(viper.AllKeys()) {
+		r.warn("config schema", fmt.Errorf("unknown setting %s", key))
+	}
+
+	validationErrs := initialValidation()
+	for _, err := range validationErrs {
+		r.fail("settings", err)
+	}
+	if len(validationErrs) == 0 {
+		r.pass("settings")
+	}
+
+	checkSecrets(r)
+	checkPaths(r)
+	checkCommands(r)
+
+	// The Github checks need a valid repository
+	if len(validationErrs) == 0 {
+		checkGithub(r)
+	}
+
+	return r
+}
+
+// knownConfigKeys returns the lowercased names of every setting: the flags of every
+// command, and the settings only configurable in the config file
+func knownConfigKeys() map[string]bool {
+	known := make(map[string]bool)
+	add := func(f *pflag.Flag) { known[strings.ToLower(f.Name)] = true }
+
+	rootCmd.PersistentFlags().VisitAll(add)
+	var visit func(c *cobra.Command)
+	visit = func(c *cobra.Command) {
+		c.Flags().VisitAll(add)
+		for _, sub := range c.Commands() {
+			visit(sub)
+		}
+	}
+	visit(rootCmd)
+
+	for _, key := range configOnlyKeys {
+		known[strings.ToLower(key)] = true
+	}
+
+	return known
+}
+
+// unknownConfigKeys returns the keys, as listed by viper, that aren't settings, eg: typos
+func unknownConfigKeys(keys []string) []string {
+	known := knownConfigKeys()
+
+	var unknown []string
+	for _, key := range keys {
+		// Nested keys belong to a config file section, eg: archives.enabled
+		top := strings.SplitN(key, ".", 2)[0]
+		if !known[top] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// checkSecrets checks the secrets in the environment variables named by the settings are set
+func checkSecrets(r *configReport) {
+	if auditWebhookSecretEnv != "" {
+		_, err := secretFromEnv(auditWebhookSecretEnv)
+		r.check("audit webhook secret", err)
+	}
+
+	if appPrivateKeyEnv != "" {
+		_, err := loadAppPrivateKey()
+		r.check("github app private key", err)
+	}
+
+	for _, p := range postProcessors {
+		if p.PasswordEnv == "" {
+			continue
+		}
+		_, err := secretFromEnv(p.PasswordEnv)
+		r.check(fmt.Sprintf("post processor %s password", p.Name), err)
+	}
+}
+
+// checkPaths checks the files referenced by the settings exist
+func checkPaths(r *configReport) {
+	if !isHTTPGitURL(repositoryURL) && privateKey != "" {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			_, err = os.Stat(filepath.Join(home, ".ssh", privateKey))
+		}
+		r.check("private key", err)
+	}
+
+	if appPrivateKey != "" && appPrivateKeyEnv == "" {
+		_, err := loadAppPrivateKey()
+		r.check("github app private key", err)
+	}
+
+	if tagMessageTemplate != "" {
+		source, err := loadTagMessageTemplate(tagMessageTemplate)
+		if err == nil {
+			_, err = template.New("tagMessageTemplate").Parse(source)
+		}
+		r.check("tag message template", err)
+	}
+
+	for _, p := range postProcessors {
+		if p.Certificate == "" {
+			continue
+		}
+		_, err := os.Stat(p.Certificate)
+		r.check(fmt.Sprintf("post processor %s certificate", p.Name), err)
+	}
+
+	for _, m := range mirrors {
+		_, err := m.assetURL("v0.0.0", "asset")
+		r.check(fmt.Sprintf("mirror %s URL", m.Name), err)
+	}
+
+	if uploadURLTemplate != "" {
+		_, err := parseUploadURLTemplate(uploadURLTemplate)
+		r.check("upload URL template", err)
+	}
+}
+
+// checkCommand checks the command line's executable is on the PATH
+func checkCommand(r *configReport, name, command string) {
+	fields, err := splitCommand(command)
+	if err == nil && len(fields) == 0 {
+		err = fmt.Errorf("no command provided")
+	}
+	if err == nil {
+		_, err = exec.LookPath(fields[0])
+	}
+	r.check(name, err)
+}
+
+// checkCommands checks the commands the release runs can be found
+func checkCommands(r *configReport) {
+	if makeTarget != "" {
+		checkCommand(r, "make", "make")
+	}
+	if stripSymbols {
+		checkCommand(r, "strip command", stripCommand)
+	}
+	if symbolHook != "" {
+		checkCommand(r, "symbol hook", symbolHook)
+	}
+	if scan && scanCommand != "" {
+		checkCommand(r, "scan command", scanCommand)
+	}
+	if checksums && checksumSignCommand != "" {
+		checkCommand(r, "checksum signing command", checksumSignCommand)
+	}
+
+	for _, p := range postProcessors {
+		switch p.Type {
+		case processorTypeNotarize:
+			checkCommand(r, fmt.Sprintf("post processor %s", p.Name), "xcrun")
+		case processorTypeAuthenticode:
+			checkCommand(r, fmt.Sprintf("post processor %s", p.Name), "osslsigncode")
+		default:
+			// Templated commands can only be checked once rendered for an artifact
+			if !strings.Contains(p.Command, "{{") {
+				checkCommand(r, fmt.Sprintf("post processor %s", p.Name), p.Command)
+			}
+		}
+	}
+}
+
+// checkGithub checks the release repository can be reached through the API and, with
+// --auth, a provided token or a Github App, that the authenticated user can publish releases to it
+func checkGithub(r *configReport) {
+	repoURL := repositoryURL
+	if upstreamRepositoryURL != "" {
+		repoURL = upstreamRepositoryURL
+	}
+
+	gURL, err := parseGitURL(repoURL)
+	if err != nil {
+		r.fail("github repository", err)
+		return
+	}
+	name := fmt.Sprintf("github repository %s/%s", gURL.organization, gURL.repository)
+
+	// A provided token or Github App is checked without needing --auth, as there's nothing
+	// to prompt for. A provided token is used before the App's installation token.
+	var auth *UserAuth
+	t, source := providedToken()
+	appToken := t == "" && appID != 0
+	if configCheckAuth || t != "" || appToken {
+		name := "github authentication"
+		if t != "" {
+			name += " with the token from " + source
+		} else if appToken {
+			name += fmt.Sprintf(" as Github App %d", appID)
+		}
+		auth, err = authenticate()
+		r.check(name, err)
+		if err != nil {
+			return
+		}
+	}
+
+	info, err := getRepository(auth, gURL)
+	if isHTTPStatus(err, 404) && auth == nil {
+		r.warn(name, fmt.Errorf("not found; private repositories can only be checked with --auth"))
+		return
+	}
+	if err != nil {
+		r.fail(name, err)
+		return
+	}
+
+	// Installation tokens have no user permissions; the App's are checked as it authenticates
+	if auth != nil && !appToken && (info.Permissions == nil || !info.Permissions.Push) {
+		r.fail(name, fmt.Errorf("the authenticated user cannot publish releases"))
+		return
+	}
+	if auth != nil {
+		if err = checkTokenScopes(auth, info); err != nil {
+			r.fail(name, err)
+			return
+		}
+	}
+
+	r.pass(name)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestUnknownConfigKeys checks settings that aren't flags or config file sections are reported, eg: typos
+func TestUnknownConfigKeys(t *testing.T) {
+	keys := []string{"repositoryurl", "archives.enabled", "archives.files", "artifact", "postprocessors", "webhooksecretenv"}
+	Equal(t, []string{"artifact"}, unknownConfigKeys(keys))
+}
+
+// TestConfigReport checks the report lists every check and fails if any of them did
+func TestConfigReport(t *testing.T) {
+	r := &configReport{}
+	r.pass("settings")
+	r.warn("config file", errors.New("not found"))
+	False(t, r.failed())
+
+	r.fail("private key", errors.New("no such file"))
+	True(t, r.failed())
+
+	var out bytes.Buffer
+	r.print(&out)
+	Equal(t, "PASS settings\nWARN config file: not found\nFAIL private key: no such file\n\n"+
+		"3 checks: 1 passed, 1 warnings, 1 failed\n", out.String())
+}
+
+// TestCheckGithub checks the repository is looked up, and the token's permissions with --auth
+func TestCheckGithub(t *testing.T) {
+	defer gock.Off()
+	defer unsetTokenEnv()()
+	defer func() {
+		repositoryURL, configCheckAuth, cachedAuth = "", false, nil
+	}()
+	repositoryURL = "git@github.com:o/r.git"
+
+	checkGithubTests := []struct {
+		name     string
+		auth     bool
+		status   int
+		push     bool
+		scopes   string
+		expected checkStatus
+	}{
+		{name: "public", status: 200, expected: checkPass},
+		{name: "private without auth", status: 404, expected: checkWarn},
+		{name: "can push", auth: true, status: 200, push: true, scopes: "repo", expected: checkPass},
+		{name: "missing scope", auth: true, status: 200, push: true, scopes: "read:org", expected: checkFail},
+		{name: "cannot push", auth: true, status: 200, expected: checkFail},
+		{name: "server error", status: 403, expected: checkFail},
+	}
+
+	for _, testSpec := range checkGithubTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				configCheckAuth = testSpec.auth
+				cachedAuth = &UserAuth{TokenType: "bearer", AccessToken: "token"}
+
+				gock.New("https://api.github.com").
+					Get("/repos/o/r").
+					Reply(testSpec.status).
+					JSON(map[string]interface{}{
+						"full_name":   "o/r",
+						"permissions": map[string]bool{"push": testSpec.push},
+					})
+				if testSpec.scopes != "" {
+					gock.New("https://api.github.com").
+						Get("/user").
+						Reply(200).
+						SetHeader(oauthScopesHeader, testSpec.scopes).
+						JSON(map[string]string{"login": "someone"})
+				}
+
+				r := &configReport{}
+				checkGithub(r)
+				last := r.results[len(r.results)-1]
+				Equal(t, testSpec.expected, last.status, "%v", last.err)
+			},
+		)
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mitchellh/go-homedir"
+)
+
+// gitCredentials reuses the credentials for a host from ~/.netrc, or git's credential helpers
+var gitCredentials bool
+
+// credentialHelperTimeout is how long git's credential helpers are given to answer
+const credentialHelperTimeout = 30 * time.Second
+
+// hostCredential is a username and password for a host, and where it came from
+type hostCredential struct {
+	username string
+	password string
+	source   string
+}
+
+// basicAuth returns the credential for git over https. Tokens are sent with any username.
+func (c *hostCredential) basicAuth() *githttp.BasicAuth {
+	username := c.username
+	if username == "" {
+		username = "x-access-token"
+	}
+	return &githttp.BasicAuth{Username: username, Password: c.password}
+}
+
+// netrcPath returns the path of the user's netrc file, from NETRC as curl finds it, or
+// in the home directory
+func netrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc"), nil
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// parseNetrc returns the login and password of the host's machine entry in the netrc, or
+// of the default entry if it has none. Macro definitions are skipped.
+func parseNetrc(r io.Reader, host string) (string, string, bool) {
+	var login, password, defaultLogin, defaultPassword string
+	var found, foundDefault bool
+
+	// The entry the tokens are read into: the host's, the default, or another machine's
+	var current string
+	inMacro := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A macro definition runs until the next blank line
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				current = "other"
+				if strings.EqualFold(value, host) && !found {
+					current, found = "host", true
+				}
+				i++
+			case "default":
+				current = "other"
+				if !foundDefault {
+					current, foundDefault = "default", true
+				}
+			case "login":
+				if current == "host" {
+					login = value
+				} else if current == "default" {
+					defaultLogin = value
+				}
+				i++
+			case "password":
+				if current == "host" {
+					password = value
+				} else if current == "default" {
+					defaultPassword = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	if found {
+		return login, password, true
+	}
+	return defaultLogin, defaultPassword, foundDefault
+}
+
+// netrcCredential returns the credential for the host from the user's netrc file, or nil
+// if it has none
+func netrcCredential(host string) (*hostCredential, error) {
+	path, err := netrcPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	login, password, ok := parseNetrc(f, host)
+	if !ok || password == "" {
+		return nil, nil
+	}
+	return &hostCredential{username: login, password: password, source: path}, nil
+}
+
+// parseCredentialOutput reads the username and password from the key=value lines
+// git credential fill writes
+func parseCredentialOutput(out []byte) (string, string) {
+	var username, password string
+	for _, line := range strings.Split(string(out), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			username = kv[1]
+		case "password":
+			password = kv[1]
+		}
+	}
+	return username, password
+}
+
+// credentialHelperCredential asks git's credential helpers, eg: osxkeychain or
+// manager-core, for the host's https credential, or returns nil if they have none.
+// Helpers are never allowed to prompt, as a release may run unattended.
+func credentialHelperCredential(host string) (*hostCredential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "-c", "credential.interactive=never", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Without a credential, git fails as it can't prompt for one
+		if strings.Contains(stderr.String(), "terminal prompts disabled") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git credential fill failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	username, password := parseCredentialOutput(out)
+	if password == "" {
+		return nil, nil
+	}
+	return &hostCredential{username: username, password: password, source: "the git credential helper"}, nil
+}
+
+// credentialFor returns the host's credential from the netrc file, or else git's credential
+// helpers, or nil if neither has one or --gitCredentials=false. Credentials that can't be
+// read are warned about, rather than failing the release over them.
+func credentialFor(host string) *hostCredential {
+	if !gitCredentials {
+		return nil
+	}
+
+	c, err := netrcCredential(host)
+	if err != nil {
+		fmt.Printf("WARNING: cannot read the netrc credentials for %s: %s\n", host, err)
+	}
+	if c != nil {
+		return c
+	}
+
+	c, err = credentialHelperCredential(host)
+	if err != nil {
+		fmt.Printf("WARNING: cannot read the git credentials for %s: %s\n", host, err)
+	}
+	return c
+}
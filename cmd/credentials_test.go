@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const testNetrc = `machine gitlab.com login someone password glpat-secret
+default
+	login anonymous
+	password guest
+
+macdef init
+machine github.com login macro password not-this
+
+machine GitHub.com
+	login octocat
+	password ghp_secret
+`
+
+// TestParseNetrc checks the host's entry is found, or the default, and macros are skipped
+func TestParseNetrc(t *testing.T) {
+	parseNetrcTests := []struct {
+		host     string
+		login    string
+		password string
+		found    bool
+	}{
+		{host: "github.com", login: "octocat", password: "ghp_secret", found: true},
+		{host: "gitlab.com", login: "someone", password: "glpat-secret", found: true},
+		{host: "bitbucket.org", login: "anonymous", password: "guest", found: true},
+	}
+
+	for _, testSpec := range parseNetrcTests {
+		login, password, found := parseNetrc(strings.NewReader(testNetrc), testSpec.host)
+		Equal(t, testSpec.login, login, testSpec.host)
+		Equal(t, testSpec.password, password, testSpec.host)
+		Equal(t, testSpec.found, found, testSpec.host)
+	}
+
+	_, _, found := parseNetrc(strings.NewReader("machine gitlab.com password secret\n"), "github.com")
+	False(t, found)
+}
+
+// TestNetrcCredential checks the netrc file is read from NETRC, and a missing one is no error
+func TestNetrcCredential(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netrc")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	saved, ok := os.LookupEnv("NETRC")
+	defer func() {
+		os.Unsetenv("NETRC")
+		if ok {
+			os.Setenv("NETRC", saved)
+		}
+	}()
+
+	path := filepath.Join(dir, "netrc")
+	os.Setenv("NETRC", path)
+	c, err := netrcCredential("github.com")
+	Nil(t, err)
+	Nil(t, c)
+
+	Nil(t, ioutil.WriteFile(path, []byte(testNetrc), 0600))
+	c, err = netrcCredential("github.com")
+	Nil(t, err)
+	Equal(t, &hostCredential{username: "octocat", password: "ghp_secret", source: path}, c)
+}
+
+// TestCredentialHelperCredential checks the credential is read from git credential fill
+func TestCredentialHelperCredential(t *testing.T) {
+	username, password := parseCredentialOutput([]byte("protocol=https\nhost=github.com\nusername=someone\npassword=secret\n"))
+	Equal(t, "someone", username)
+	Equal(t, "secret", password)
+
+	// The helper is configured for this test only, as git reads GIT_CONFIG_* over the config files
+	for k, v := range map[string]string{
+		"GIT_CONFIG_COUNT":   "1",
+		"GIT_CONFIG_KEY_0":   "credential.helper",
+		"GIT_CONFIG_VALUE_0": "!f() { echo username=someone; echo password=gho_helper; }; f",
+	} {
+		saved, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		defer func(k, saved string, ok bool) {
+			os.Unsetenv(k)
+			if ok {
+				os.Setenv(k, saved)
+			}
+		}(k, saved, ok)
+	}
+
+	c, err := credentialHelperCredential("github.com")
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, &hostCredential{username: "someone", password: "gho_helper", source: "the git credential helper"}, c)
+	Equal(t, "someone", c.basicAuth().Username)
+}
+
+// TestProviderCredentials checks the netrc credentials are used for GitLab and Bitbucket,
+// and a Github credential that can't be validated is skipped
+func TestProviderCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netrc")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "netrc")
+	Nil(t, ioutil.WriteFile(path, []byte(testNetrc), 0600))
+	for k, v := range map[string]string{"NETRC": path, "GITLAB_TOKEN": "", "CI_JOB_TOKEN": "", "BITBUCKET_TOKEN": "", "BITBUCKET_USERNAME": ""} {
+		saved, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		defer func(k, saved string, ok bool) {
+			os.Unsetenv(k)
+			if ok {
+				os.Setenv(k, saved)
+			}
+		}(k, saved, ok)
+	}
+	defer func(g bool, gl, bb, bu string) {
+		gitCredentials, gitlabToken, bitbucketToken, bitbucketUsername = g, gl, bb, bu
+	}(gitCredentials, gitlabToken, bitbucketToken, bitbucketUsername)
+	gitCredentials, gitlabToken, bitbucketToken, bitbucketUsername = true, "", "", ""
+
+	auth, err := gitlabAuth()
+	Nil(t, err)
+	Equal(t, &UserAuth{AccessToken: "glpat-secret", TokenType: gitlabPrivateTokenHeader}, auth)
+
+	auth, err = bitbucketAuth()
+	Nil(t, err)
+	Equal(t, &UserAuth{AccessToken: "YW5vbnltb3VzOmd1ZXN0", TokenType: "Basic"}, auth)
+
+	defer gock.Off()
+	gock.New("https://api.github.com").
+		Get("/user").
+		ReplyError(errors.New("connection refused"))
+
+	auth, source := credentialAuth()
+	Nil(t, auth)
+	Empty(t, source)
+	True(t, gock.IsDone())
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// diffStats appends the diff statistics since the previous tag to the release body
+var diffStats bool
+
+// diffStatPackages is how many of the most changed packages are listed
+const diffStatPackages = 5
+
+// diffStat summarizes the changes between two commits, like git diff --shortstat
+type diffStat struct {
+	since      string
+	files      int
+	insertions int
+	deletions  int
+	// packages are the most changed directories, most changed first
+	packages []packageChurn
+}
+
+// packageChurn is the number of lines changed in a directory
+type packageChurn struct {
+	name  string
+	lines int
+}
+
+// computeDiffStat counts the files and lines changed between the from and to commits
+func computeDiffStat(from, to *object.Commit) (*diffStat, error) {
+	patch, err := from.Patch(to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &diffStat{}
+	churn := make(map[string]int)
+	for _, fs := range patch.Stats() {
+		d.files++
+		d.insertions += fs.Addition
+		d.deletions += fs.Deletion
+
+		// Renames are named "old => new"; they count towards the new location
+		name := fs.Name
+		if i := strings.LastIndex(name, " => "); i >= 0 {
+			name = name[i+len(" => "):]
+		}
+		churn[path.Dir(name)] += fs.Addition + fs.Deletion
+	}
+
+	for name, lines := range churn {
+		if lines > 0 {
+			d.packages = append(d.packages, packageChurn{name: name, lines: lines})
+		}
+	}
+	sort.Slice(d.packages, func(i, j int) bool {
+		if d.packages[i].lines != d.packages[j].lines {
+			return d.packages[i].lines > d.packages[j].lines
+		}
+		return d.packages[i].name < d.packages[j].name
+	})
+	if len(d.packages) > diffStatPackages {
+		d.packages = d.packages[:diffStatPackages]
+	}
+
+	return d, nil
+}
+
+// plural returns the count with the singular or plural noun
+func plural(n int, singular, pluralNoun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralNoun)
+}
+
+// shortstat returns the summary line in the form git diff --shortstat prints it
+func (d *diffStat) shortstat() string {
+	return fmt.Sprintf("%s, %s(+), %s(-)",
+		plural(d.files, "file changed", "files changed"),
+		plural(d.insertions, "insertion", "insertions"),
+		plural(d.deletions, "deletion", "deletions"),
+	)
+}
+
+// markdown renders the statistics for the release body
+func (d *diffStat) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Changes since %s\n\n%s\n", d.since, d.shortstat())
+
+	if len(d.packages) > 0 {
+		b.WriteString("\nMost changed packages:\n")
+		for _, p := range d.packages {
+			name := p.name
+			if name == "." {
+				name = "(repository root)"
+			}
+			fmt.Fprintf(&b, "* `%s` (%s)\n", name, plural(p.lines, "line", "lines"))
+		}
+	}
+
+	return b.String()
+}
+
+// releaseDiffStat computes the statistics between the previous tag and the checked out
+// commit, from the local clone. It returns nil for the first release.
+func releaseDiffStat(repo *git.Repository, tag string) (*diffStat, error) {
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	since, sinceCommit, err := changelogBase(repo, head, tag)
+	if err != nil || since == "" {
+		return nil, err
+	}
+
+	d, err := computeDiffStat(sinceCommit, head)
+	if err != nil {
+		return nil, err
+	}
+	d.since = since
+
+	return d, nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestDiffStatMarkdown checks the shortstat line and package list are rendered
+func TestDiffStatMarkdown(t *testing.T) {
+	d := &diffStat{
+		since:      "v1.0.0",
+		files:      3,
+		insertions: 1,
+		deletions:  20,
+		packages: []packageChurn{
+			{name: "cmd", lines: 20},
+			{name: ".", lines: 1},
+		},
+	}
+
+	Equal(t, "### Changes since v1.0.0\n\n"+
+		"3 files changed, 1 insertion(+), 20 deletions(-)\n"+
+		"\nMost changed packages:\n"+
+		"* `cmd` (20 lines)\n"+
+		"* `(repository root)` (1 line)\n", d.markdown())
+}
+
+// TestComputeDiffStat checks lines are counted per file and grouped by directory
+func TestComputeDiffStat(t *testing.T) {
+	repo := gitfixture.New(t)
+	from := repo.Commit("change", map[string]string{"README.md": "one\n", "cmd/run.go": "a\nb\n"})
+	to := repo.Commit("change", map[string]string{"README.md": "one\ntwo\n", "cmd/run.go": "a\nc\nd\n", "cmd/new.go": "x\n"})
+
+	d, err := computeDiffStat(from, to)
+	Nil(t, err)
+	Equal(t, 3, d.files)
+	Equal(t, 4, d.insertions)
+	Equal(t, 1, d.deletions)
+	Equal(t, []packageChurn{{name: "cmd", lines: 4}, {name: ".", lines: 1}}, d.packages)
+}
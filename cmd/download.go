@@ -0,0 +1,221 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// downloadPatterns are the globs the downloaded assets' names match, or all assets if empty
+var downloadPatterns []string
+
+// downloadDir is the directory the assets are downloaded into
+var downloadDir string
+
+// downloadVerify checks the downloaded assets against the release's checksum file
+var downloadVerify bool
+
+// downloadCmd downloads the assets of an existing release
+var downloadCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Download the assets of a release",
+	Long: `download downloads the assets of the release of --tag, or those matching a --pattern, into --dir,
+and verifies them against the SHA256SUMS or SHA512SUMS checksum file uploaded with the release. Assets
+that don't match their checksum are removed again. Drafts and the releases of private repositories are
+downloaded with a token for the repository, if one is already available.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, p := range downloadPatterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+		return download()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+
+	downloadCmd.Flags().StringArrayVar(&downloadPatterns, "pattern", nil, "(optional) glob of the asset names to download, eg: '*.tar.gz'; may be repeated (default is all assets)")
+	downloadCmd.Flags().StringVar(&downloadDir, "dir", ".", "directory to download the assets into")
+	downloadCmd.Flags().BoolVar(&downloadVerify, "verify", true, "verify the assets against the checksum file uploaded with the release")
+}
+
+// matchesDownloadPatterns returns true if the asset name matches any of the patterns,
+// or there are none
+func matchesDownloadPatterns(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func download() error {
+	repoURL := repositoryURL
+	if upstreamRepositoryURL != "" {
+		repoURL = upstreamRepositoryURL
+	}
+	gURL, err := parseGitURL(repoURL)
+	if err != nil {
+		return err
+	}
+	if _, ok := providerFor(gURL).(*githubProvider); !ok {
+		return fmt.Errorf("download only supports Github releases")
+	}
+
+	tag, err = resolveTag(gURL)
+	if err != nil {
+		return err
+	}
+
+	auth, _, err := existingAuth()
+	if err != nil {
+		return err
+	}
+	all, err := repositoryReleases(auth, gURL)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+	r := releaseForTag(all, tag)
+	if r == nil {
+		return fmt.Errorf("no release found for tag %s on %s/%s", tag, gURL.organization, gURL.repository)
+	}
+
+	if err = os.MkdirAll(downloadDir, 0755); err != nil {
+		return err
+	}
+	downloaded, err := downloadAssets(auth, r, downloadDir, downloadPatterns)
+	if err != nil {
+		return err
+	}
+	if len(downloaded) == 0 {
+		return fmt.Errorf("release %s has no assets matching %s", tag, strings.Join(downloadPatterns, ", "))
+	}
+
+	if !downloadVerify {
+		return nil
+	}
+
+	tempDir, err := createTempDir()
+	if err != nil {
+		return fmt.Errorf("cannot create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifest, err := releaseChecksums(auth, r, tempDir)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		fmt.Printf("WARNING: release %s has no checksum file; the assets are not verified\n", tag)
+		return nil
+	}
+
+	return verifyDownloads(downloaded, manifest)
+}
+
+// downloadAssets downloads the release's assets matching the patterns into dir, reporting
+// the progress, and returns them
+func downloadAssets(auth *UserAuth, r *release, dir string, patterns []string) ([]*artifact, error) {
+	var matched []*asset
+	for _, a := range r.Assets {
+		if a.Name != nil && matchesDownloadPatterns(*a.Name, patterns) {
+			matched = append(matched, a)
+		}
+	}
+
+	status := newProgress()
+	var downloaded []*artifact
+	for i, a := range matched {
+		status.update(fmt.Sprintf("Downloading %s (%d of %d assets)", *a.Name, i+1, len(matched)))
+
+		dest := filepath.Join(dir, filepath.Base(*a.Name))
+		if err := downloadReleaseAsset(auth, a, dest); err != nil {
+			status.done("")
+			return downloaded, fmt.Errorf("failed downloading %s: %w", *a.Name, err)
+		}
+
+		info, err := os.Stat(dest)
+		if err != nil {
+			status.done("")
+			return downloaded, err
+		}
+		downloaded = append(downloaded, &artifact{path: dest, name: *a.Name, size: info.Size()})
+	}
+	status.done(fmt.Sprintf("Downloaded %d assets", len(downloaded)))
+
+	return downloaded, nil
+}
+
+// verifyDownloads checks the downloaded assets against the checksum manifest, removing
+// those that don't match. Assets the manifest doesn't list are warned about.
+func verifyDownloads(downloaded []*artifact, manifest checksumManifest) error {
+	var failed []string
+	for _, a := range downloaded {
+		if isChecksumAsset(a.name) {
+			continue
+		}
+
+		digest, ok := manifest[a.name]
+		if !ok {
+			fmt.Printf("WARNING: %s is not in the checksum file; not verified\n", a.name)
+			continue
+		}
+
+		h, err := hasherForDigest(digest)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(a.path)
+		if err != nil {
+			return err
+		}
+		got, err := hashReader(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		if got != digest {
+			failed = append(failed, a.name)
+			if err = os.Remove(a.path); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("%s: OK\n", a.name)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d asset(s) failed verification and were removed: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
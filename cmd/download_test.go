@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestMatchesDownloadPatterns checks assets are picked by any of the globs, or all without one
+func TestMatchesDownloadPatterns(t *testing.T) {
+	True(t, matchesDownloadPatterns("app.tar.gz", nil))
+	True(t, matchesDownloadPatterns("app.tar.gz", []string{"*.zip", "*.tar.gz"}))
+	False(t, matchesDownloadPatterns("app.zip", []string{"*.tar.gz"}))
+}
+
+// TestDownloadAssets checks the matching assets are downloaded, and removed if their checksum doesn't match
+func TestDownloadAssets(t *testing.T) {
+	defer gock.Off()
+
+	dir, err := ioutil.TempDir("", "download")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/assets/1").
+		MatchHeader("Accept", "application/octet-stream").
+		Reply(200).
+		BodyString("linux")
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/assets/2").
+		Reply(200).
+		BodyString("darwin")
+
+	names := []string{"app-linux.tar.gz", "app-darwin.tar.gz", "app.zip"}
+	urls := []string{"https://api.github.com/repos/o/r/releases/assets/1", "https://api.github.com/repos/o/r/releases/assets/2", "https://api.github.com/repos/o/r/releases/assets/3"}
+	r := &release{}
+	for i := range names {
+		r.Assets = append(r.Assets, &asset{Name: &names[i], URL: &urls[i]})
+	}
+
+	downloaded, err := downloadAssets(nil, r, dir, []string{"*.tar.gz"})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Len(t, downloaded, 2)
+	True(t, gock.IsDone())
+
+	linux, err := fileDigest(filepath.Join(dir, "app-linux.tar.gz"))
+	Nil(t, err)
+	err = verifyDownloads(downloaded, checksumManifest{
+		"app-linux.tar.gz":  linux,
+		"app-darwin.tar.gz": "0000000000000000000000000000000000000000000000000000000000000000",
+	})
+	EqualError(t, err, "1 asset(s) failed verification and were removed: app-darwin.tar.gz")
+	FileExists(t, filepath.Join(dir, "app-linux.tar.gz"))
+	NoFileExists(t, filepath.Join(dir, "app-darwin.tar.gz"))
+}
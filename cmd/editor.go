@@ -24,9 +24,6 @@ import (
 	"text/template"
 )
 
-var annotatedTagPrompt string = "\n\n\n# Please enter the tag message for your annotated tag. Lines starting\n" +
-	"# with '#' will be ignored, and an empty message aborts the tagging."
-
 // generateTagMessageFromTemplate returns a string formatted to look like a completed
 // Git tag message, to display to the user
 func generateTagMessageFromTemplate() (*template.Template, error) {
@@ -78,9 +75,9 @@ func getPreferredEditorFromEnvironment() string {
 }
 
 // captureInputFromEditor creates a temp file and populates it with a Git commit-style
-// message prompting the user to enter a message for the annotated tag and captures
+// prompt for the user to enter a message for the annotated tag and captures
 // and returns the output
-func captureInputFromEditor(resolveEditor preferredEditorResolver) ([]byte, error) {
+func captureInputFromEditor(resolveEditor preferredEditorResolver, prompt string) ([]byte, error) {
 	tempFile, err := createTempFile()
 	defer os.Remove(tempFile.Name())
 
@@ -90,7 +87,7 @@ func captureInputFromEditor(resolveEditor preferredEditorResolver) ([]byte, erro
 
 	fileName := tempFile.Name()
 
-	err = ioutil.WriteFile(fileName, []byte(annotatedTagPrompt), 0644)
+	err = ioutil.WriteFile(fileName, []byte(prompt), 0644)
 	if err != nil {
 		return []byte{}, err
 	}
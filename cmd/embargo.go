@@ -0,0 +1,329 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var publicRepositoryURL string
+var publicRepo string
+
+// publicRemote is the name of the remote the embargoed tag is pushed to
+const publicRemote = "public"
+
+// discloseCmd copies an embargoed release from the private repository it was staged in
+// to the public repository
+var discloseCmd = &cobra.Command{
+	Use:   "disclose",
+	Short: "Copy an embargoed release from a private repository to the public one",
+	Long: `disclose is for security fixes released under embargo. The release is made as usual on a private
+repository, eg: a private fork, given by --repositoryURL or --repo, and once the embargo lifts, disclose copies
+it to the public repository given by --publicRepositoryURL or --publicRepo: the tag and the commits it points
+to are pushed, and the release is created with the same name, notes and assets. The public release stays a
+draft until every asset is uploaded, so it is never seen incomplete.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			return fmt.Errorf("tag is required")
+		}
+
+		if publicRepositoryURL != "" && publicRepo != "" {
+			return fmt.Errorf("only one of publicRepositoryURL or publicRepo may be provided")
+		}
+		if publicRepo != "" {
+			u, err := repositoryURLFromShorthand(publicRepo)
+			if err != nil {
+				return err
+			}
+			publicRepositoryURL = u
+		}
+		if publicRepositoryURL == "" {
+			return fmt.Errorf("publicRepositoryURL or publicRepo is required")
+		}
+
+		return disclose()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discloseCmd)
+
+	discloseCmd.Flags().StringVar(&publicRepositoryURL, "publicRepositoryURL", "", "url of the public repository to copy the release to")
+	discloseCmd.Flags().StringVar(&publicRepo, "publicRepo", "", "public repository to copy the release to, as owner/name, instead of a url")
+}
+
+// downloadReleaseAsset downloads the asset through the API, so assets of private
+// repositories and drafts can be downloaded too, into dest. Public assets need no auth.
+// Downloads are retried while Github is unavailable, as other requests are.
+func downloadReleaseAsset(auth *UserAuth, a *asset, dest string) error {
+	if a.URL == nil {
+		return fmt.Errorf("asset has no url")
+	}
+
+	req, err := http.NewRequest("GET", *a.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	if auth != nil {
+		for k, v := range authHeaders(auth) {
+			req.Header.Set(k, v)
+		}
+	}
+
+	_, err = retryUnavailable(req, func(req *http.Request) ([]byte, error) {
+		return nil, downloadTo(req, dest)
+	})
+	return err
+}
+
+// downloadTo sends the request once, and writes the response body to dest
+func downloadTo(req *http.Request, dest string) error {
+	// Github redirects to the storage the asset is in, which isn't sent the Authorization header
+	r, err := ctxhttp.Do(context.TODO(), httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != 200 {
+		return &httpError{StatusCode: r.StatusCode, Status: r.Status, RetryAfter: parseRetryAfter(r.Header.Get("Retry-After"))}
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, r.Body); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// copyRelease recreates the release on the public repository, with the assets
+// downloaded into dir, and publishes it once they are all uploaded
+func copyRelease(auth *UserAuth, public *gitURL, r *release, dir string) (*release, error) {
+	var artifacts []*artifact
+	for _, a := range r.Assets {
+		if a.Name == nil {
+			continue
+		}
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Downloading %s", *a.Name))
+		}
+		path := filepath.Join(dir, *a.Name)
+		if err := downloadReleaseAsset(auth, a, path); err != nil {
+			return nil, fmt.Errorf("failed downloading %s: %w", *a.Name, err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, &artifact{path: path, name: *a.Name, size: info.Size()})
+	}
+
+	request := &newReleaseRequest{TagName: tag, Name: tag, Draft: true}
+	if r.Name != nil && *r.Name != "" {
+		request.Name = *r.Name
+	}
+	latest := markLatest
+	if r.Body != nil {
+		request.Body = unscheduledNotes(*r.Body)
+		if l := scheduledLatest(*r.Body); l != "" && latest == "" {
+			latest = l
+		}
+	}
+	if r.Prerelease != nil {
+		request.Prerelease = *r.Prerelease
+	}
+
+	created, err := postRelease(auth, public, request)
+	if err != nil {
+		return nil, stageFailed(errRelease, err)
+	}
+	if created.ID == nil {
+		return nil, stageFailed(errRelease, fmt.Errorf("release has no ID"))
+	}
+
+	// The draft is deleted if it can't be published, so disclosing can be run again
+	discard := func(err error) error {
+		if deleteErr := deleteRelease(auth, public, *created.ID); deleteErr != nil {
+			fmt.Printf("WARNING: failed deleting the draft release of %s on %s: %s\n", tag, public.raw, deleteErr)
+		}
+		return err
+	}
+
+	if _, err = uploadAssets(providerFor(public), auth, created, artifacts); err != nil {
+		return nil, discard(stageFailed(errUpload, err))
+	}
+
+	published := false
+	// Github ignores make_latest for drafts, so it is sent when the release is published
+	r, err = updateRelease(auth, public, *created.ID, &releaseUpdateRequest{Draft: &published, MakeLatest: latest})
+	if err != nil {
+		return nil, discard(stageFailed(errRelease, fmt.Errorf("failed publishing release: %w", err)))
+	}
+
+	return r, nil
+}
+
+// pushEmbargoedTag clones the private repository into dir, or memory with --inMemory, and
+// pushes the tag, with the commits it points to, to the public repository. It returns the
+// clone, to delete the tag again with unpushEmbargoedTag.
+func pushEmbargoedTag(private, public *gitURL, dir string) (*git.Repository, error) {
+	var repo *git.Repository
+	var err error
+	if inMemoryClone {
+		repo, err = cloneRepoInMemory(private.raw, "")
+	} else {
+		repo, err = cloneRepo(private.raw, dir, "")
+	}
+	if err != nil {
+		return nil, stageFailed(errClone, err)
+	}
+
+	// The embargoed fix is not necessarily on the default branch, so the tag is fetched itself
+	auth, err := gitAuth(private.raw)
+	if err != nil {
+		return nil, err
+	}
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/tags/%s:refs/tags/%s", tag, tag))
+	err = repo.Fetch(&git.FetchOptions{Progress: gitopts.progress, RefSpecs: []config.RefSpec{refSpec}, Auth: auth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("failed fetching tag %s: %w", tag, err)
+	}
+
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: publicRemote, URLs: []string{public.raw}})
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, pushPublic(repo, config.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", tag, tag)))
+}
+
+// unpushEmbargoedTag deletes the tag pushed by pushEmbargoedTag from the public
+// repository, eg: when its release couldn't be published
+func unpushEmbargoedTag(repo *git.Repository) error {
+	return pushPublic(repo, config.RefSpec(fmt.Sprintf(":refs/tags/%s", tag)))
+}
+
+// pushPublic pushes the ref spec to the public repository's remote
+func pushPublic(repo *git.Repository, refSpec config.RefSpec) error {
+	previousRemote := remote
+	defer func() { remote = previousRemote }()
+	remote = publicRemote
+
+	return pushRefSpecs(repo, []config.RefSpec{refSpec})
+}
+
+func disclose() error {
+	private, err := parseGitURL(repositoryURL)
+	if err != nil {
+		return err
+	}
+	public, err := parseGitURL(publicRepositoryURL)
+	if err != nil {
+		return err
+	}
+	// The embargo is kept with Github's security advisories, on private forks
+	for _, gURL := range []*gitURL{private, public} {
+		if _, ok := providerFor(gURL).(*githubProvider); !ok {
+			return fmt.Errorf("disclose only supports Github repositories, not %s", gURL.raw)
+		}
+	}
+
+	auth, err := authenticate()
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	privateReleases, err := listReleases(auth, private)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+	r := releaseForTag((*releases)(&privateReleases), tag)
+	if r == nil {
+		return fmt.Errorf("no release found for tag %s on %s/%s", tag, private.organization, private.repository)
+	}
+
+	publicReleases, err := listReleases(auth, public)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of public releases: %w", err)
+	}
+	if releaseForTag((*releases)(&publicReleases), tag) != nil {
+		return fmt.Errorf("%s/%s already has a release for tag %s", public.organization, public.repository, tag)
+	}
+
+	fmt.Printf("Disclosing %s (%d assets) from %s/%s on %s/%s\n", tag, len(r.Assets), private.organization, private.repository, public.organization, public.repository)
+	if !confirm("Would you like to continue?") {
+		return fmt.Errorf("disclose %w", errHalted)
+	}
+
+	tempDir, err := createTempDir()
+	if err != nil {
+		return fmt.Errorf("cannot create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	repoDir := filepath.Join(tempDir, "repository")
+	assetsDir := filepath.Join(tempDir, "assets")
+	if err = os.Mkdir(assetsDir, 0700); err != nil {
+		return err
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Pushing %s to %s", tag, public.raw))
+	}
+	repo, err := pushEmbargoedTag(private, public, repoDir)
+	if err != nil {
+		return fmt.Errorf("failed pushing tag %s: %w", tag, err)
+	}
+
+	// The tag isn't left public without the release that discloses it
+	published, err := copyRelease(auth, public, r, assetsDir)
+	if err != nil {
+		if unpushErr := unpushEmbargoedTag(repo); unpushErr != nil {
+			fmt.Printf("WARNING: failed deleting tag %s from %s: %s\n", tag, public.raw, unpushErr)
+		}
+		return err
+	}
+
+	summary := &releaseSummary{tag: tag}
+	if published.HTMLURL != nil {
+		summary.releaseURL = *published.HTMLURL
+	}
+	summary.print()
+
+	return nil
+}
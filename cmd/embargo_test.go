@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestCopyRelease checks the embargoed release is recreated on the public repository with
+// its notes and assets, and only published once the assets are uploaded
+func TestCopyRelease(t *testing.T) {
+	defer gock.Off()
+	defer func() { tag = "" }()
+	defer func(l string) { markLatest = l }(markLatest)
+	tag, markLatest = "v1.0.1", "false"
+
+	dir, err := ioutil.TempDir("", "disclose")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	public, err := parseGitURL("https://github.com/o/public.git")
+	Nil(t, err)
+	auth := &UserAuth{TokenType: "token", AccessToken: "token"}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/private/releases/assets/9").
+		MatchHeader("Accept", "application/octet-stream").
+		MatchHeader("Authorization", "token token").
+		Reply(200).
+		BodyString("binary")
+	gock.New("https://api.github.com").
+		Post("/repos/o/public/releases").
+		BodyString(`{"tag_name":"v1.0.1","name":"Security fix","body":"Fixes CVE-2020-0001","draft":true}`).
+		Reply(201).
+		JSON(map[string]interface{}{"id": 1, "upload_url": "https://uploads.github.com/repos/o/public/releases/1/assets{?name,label}"})
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/public/releases/1/assets").
+		MatchParam("name", "app-linux").
+		Reply(201).
+		JSON(map[string]string{"name": "app-linux"})
+	gock.New("https://api.github.com").
+		Patch("/repos/o/public/releases/1").
+		JSON(map[string]interface{}{"draft": false, "make_latest": "false"}).
+		Reply(200).
+		JSON(map[string]interface{}{"id": 1, "html_url": "https://github.com/o/public/releases/tag/v1.0.1"})
+
+	name, body, assetName, assetURL := "Security fix", "Fixes CVE-2020-0001", "app-linux", "https://api.github.com/repos/o/private/releases/assets/9"
+	r := &release{TagName: &tag, Name: &name, Body: &body, Assets: []*asset{{Name: &assetName, URL: &assetURL}}}
+
+	published, err := copyRelease(auth, public, r, dir)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://github.com/o/public/releases/tag/v1.0.1", *published.HTMLURL)
+	True(t, gock.IsDone())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app-linux"))
+	Nil(t, err)
+	Equal(t, "binary", string(data))
+}
+
+// TestCopyReleaseDiscardsDraft checks the draft is deleted if its assets can't be uploaded
+func TestCopyReleaseDiscardsDraft(t *testing.T) {
+	defer gock.Off()
+	defer func() { tag = "" }()
+	tag = "v1.0.1"
+
+	dir, err := ioutil.TempDir("", "disclose")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	public, err := parseGitURL("https://github.com/o/public.git")
+	Nil(t, err)
+	auth := &UserAuth{TokenType: "token", AccessToken: "token"}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/private/releases/assets/9").
+		Reply(200).
+		BodyString("binary")
+	gock.New("https://api.github.com").
+		Post("/repos/o/public/releases").
+		Reply(201).
+		JSON(map[string]interface{}{"id": 1, "upload_url": "https://uploads.github.com/repos/o/public/releases/1/assets{?name,label}"})
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/public/releases/1/assets").
+		Reply(422)
+	gock.New("https://api.github.com").
+		Delete("/repos/o/public/releases/1").
+		Reply(204)
+
+	assetName, assetURL := "app-linux", "https://api.github.com/repos/o/private/releases/assets/9"
+	r := &release{TagName: &tag, Assets: []*asset{{Name: &assetName, URL: &assetURL}}}
+
+	_, err = copyRelease(auth, public, r, dir)
+	True(t, errors.Is(err, errUpload), "%v", err)
+	True(t, gock.IsDone())
+}
+
+// TestDownloadReleaseAssetRetries checks downloads are retried while Github is unavailable
+func TestDownloadReleaseAssetRetries(t *testing.T) {
+	defer gock.Off()
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	sleep = func(time.Duration) {}
+
+	dir, err := ioutil.TempDir("", "download")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/assets/9").
+		Reply(503)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/assets/9").
+		Reply(200).
+		BodyString("binary")
+
+	assetURL := "https://api.github.com/repos/o/r/releases/assets/9"
+	path := filepath.Join(dir, "app")
+	Nil(t, downloadReleaseAsset(nil, &asset{URL: &assetURL}, path))
+	data, err := ioutil.ReadFile(path)
+	Nil(t, err)
+	Equal(t, "binary", string(data))
+	True(t, gock.IsDone())
+}
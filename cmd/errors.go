@@ -0,0 +1,139 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The stages of a release. Errors returned from a failed stage match the stage with
+// errors.Is, and still wrap the underlying cause.
+var (
+	errClone   = errors.New("cannot clone repository")
+	errTag     = errors.New("cannot create tag")
+	errBuild   = errors.New("failed building artifacts")
+	errAuth    = errors.New("failed authenticating with github")
+	errRelease = errors.New("failed creating release")
+	errUpload  = errors.New("failed uploading release assets")
+)
+
+// errEmptyTagMessage is returned when the tag message is empty after cleanup
+var errEmptyTagMessage = errors.New("no tag message, aborting")
+
+// errScanBlocked is returned when the dependency scan has findings that block the release
+var errScanBlocked = errors.New("dependency scan blocked the release")
+
+// errHalted is returned when the user declines to continue at a prompt
+var errHalted = errors.New("halted by user")
+
+// errPlanMismatch is returned when the release no longer matches the plan it applies
+var errPlanMismatch = errors.New("release plan mismatch")
+
+// stageError is a failure of one stage of the release
+type stageError struct {
+	stage error
+	cause error
+}
+
+// stageFailed wraps the cause of a failed stage
+func stageFailed(stage, cause error) error {
+	return &stageError{stage: stage, cause: cause}
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.stage, e.cause)
+}
+
+// Unwrap returns the cause, so it can be inspected with errors.Is and errors.As
+func (e *stageError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches the stage that failed
+func (e *stageError) Is(target error) bool {
+	return target == e.stage
+}
+
+// httpError is returned for responses other than a 200 or a 201
+type httpError struct {
+	StatusCode int
+	Status     string
+	// Body is the response body, which usually describes the error
+	Body []byte
+	// RetryAfter is how long the server asked clients to wait before retrying, if it did
+	RetryAfter time.Duration
+	// AcceptedPermissions are the permissions a forbidden request needed, if Github said
+	AcceptedPermissions string
+}
+
+func (e *httpError) Error() string {
+	return e.Status
+}
+
+// isHTTPStatus returns true if err is, or wraps, an httpError with the status code
+func isHTTPStatus(err error, code int) bool {
+	var e *httpError
+	return errors.As(err, &e) && e.StatusCode == code
+}
+
+// oauthError is an error response from the OAuth2 device flow. Errors with the same
+// code match with errors.Is, eg: errors.Is(err, errSlowDown).
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func (e *oauthError) Error() string {
+	return e.Code
+}
+
+// Is matches oauthErrors with the same code
+func (e *oauthError) Is(target error) bool {
+	t, ok := target.(*oauthError)
+	return ok && t.Code == e.Code
+}
+
+// assetFailure is an asset that failed to upload
+type assetFailure struct {
+	name string
+	err  error
+}
+
+// uploadError reports every asset that failed to upload
+type uploadError struct {
+	failed []assetFailure
+}
+
+func (e *uploadError) Error() string {
+	lines := make([]string, 0, len(e.failed))
+	for _, f := range e.failed {
+		lines = append(lines, fmt.Sprintf("\t%s: %s", f.name, f.err))
+	}
+	return fmt.Sprintf("%d assets failed to upload:\n%s", len(e.failed), strings.Join(lines, "\n"))
+}
+
+// Unwrap returns the first failure, so its cause can still be inspected
+func (e *uploadError) Unwrap() error {
+	if len(e.failed) == 0 {
+		return nil
+	}
+	return e.failed[0].err
+}
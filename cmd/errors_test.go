@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestStageError checks failed stages match both the stage and the cause
+func TestStageError(t *testing.T) {
+	cause := &httpError{StatusCode: 422, Status: "422 Unprocessable Entity"}
+	err := fmt.Errorf("release v1.0: %w", stageFailed(errRelease, cause))
+
+	Equal(t, "release v1.0: failed creating release: 422 Unprocessable Entity", err.Error())
+	True(t, errors.Is(err, errRelease))
+	False(t, errors.Is(err, errClone))
+	True(t, isHTTPStatus(err, 422))
+	False(t, isHTTPStatus(err, 404))
+
+	var e *httpError
+	True(t, errors.As(err, &e))
+	Equal(t, 422, e.StatusCode)
+}
+
+// TestMakeHTTPRequestError checks error responses are returned as an httpError with the body
+func TestMakeHTTPRequestError(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		Reply(404).
+		JSON(map[string]string{"message": "Not Found"})
+
+	req, err := newGetRequest("https://api.github.com/repos/o/r/releases", nil)
+	Nil(t, err)
+
+	body, err := makeHTTPRequest(req)
+	Nil(t, body)
+
+	var e *httpError
+	True(t, errors.As(err, &e))
+	Equal(t, 404, e.StatusCode)
+	JSONEq(t, `{"message":"Not Found"}`, string(e.Body))
+}
+
+// TestOAuthError checks device flow errors match by code
+func TestOAuthError(t *testing.T) {
+	err := fmt.Errorf("polling: %w", &oauthError{Code: "slow_down", Description: "Too many requests"})
+
+	True(t, errors.Is(err, errSlowDown))
+	False(t, errors.Is(err, errAccessDenied))
+}
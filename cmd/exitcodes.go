@@ -0,0 +1,151 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// The exit codes for failures that aren't of a stage of the release
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitFailed  = 2
+)
+
+// exitCode is an exit status of go-git-release, and the error it's returned for
+type exitCode struct {
+	Code        int    `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// err is matched with errors.Is, or nil for the codes that aren't returned for an error
+	err error
+}
+
+// exitCodes are the documented exit statuses. The codes for errors are matched in
+// order, so the more specific errors come before the stages that wrap them.
+var exitCodes = []exitCode{
+	{Code: exitOK, Name: "ok", Description: "the command succeeded"},
+	{Code: exitInvalid, Name: "invalid-settings", Description: "the settings are missing or invalid, or the release differs from the plan it applies; nothing was published", err: errPlanMismatch},
+	{Code: exitFailed, Name: "failed", Description: "the command failed for a reason without its own code"},
+	{Code: 3, Name: "halted", Description: "the release was declined at a prompt", err: errHalted},
+	{Code: 4, Name: "empty-tag-message", Description: "the tag message was empty after cleanup; no tag was created", err: errEmptyTagMessage},
+	{Code: 5, Name: "not-interactive", Description: "a prompt without a default was needed without a terminal, or with --nonInteractive; set its flag instead", err: errNotInteractive},
+	{Code: 10, Name: "clone", Description: "the repository couldn't be cloned", err: errClone},
+	{Code: 11, Name: "tag", Description: "the tag couldn't be created or pushed", err: errTag},
+	{Code: 12, Name: "build", Description: "building the artifacts failed; the tag may already be pushed", err: errBuild},
+	{Code: 13, Name: "auth", Description: "authenticating with Github failed, or the token can't publish releases", err: errAuth},
+	{Code: 14, Name: "release", Description: "the Github release couldn't be created or published", err: errRelease},
+	{Code: 15, Name: "upload", Description: "uploading the release assets failed; the release exists without them", err: errUpload},
+	{Code: 16, Name: "scan-blocked", Description: "the dependency scan had findings at or above --scanFailOn", err: errScanBlocked},
+}
+
+// exitCodeFor returns the exit code for the error
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for _, c := range exitCodes {
+		if c.err != nil && errors.Is(err, c.err) {
+			return c.Code
+		}
+	}
+	return exitFailed
+}
+
+// lookupExitCode returns the documented exit code, if it is one
+func lookupExitCode(code int) (exitCode, bool) {
+	for _, c := range exitCodes {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return exitCode{}, false
+}
+
+// exitCodeHelp renders the exit codes for the --help output
+func exitCodeHelp() string {
+	var b strings.Builder
+	b.WriteString("Exit codes:\n")
+	for _, c := range exitCodes {
+		fmt.Fprintf(&b, "  %-3d %-18s %s\n", c.Code, c.Name, c.Description)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// explainExitOutput is the format explain-exit writes, text or json
+var explainExitOutput string
+
+// explainExitCmd describes the exit codes, so wrapper scripts needn't hard-code them
+var explainExitCmd = &cobra.Command{
+	Use:   "explain-exit [code]",
+	Short: "Explain an exit code, or list them all",
+	Args:  cobra.MaximumNArgs(1),
+
+	// Explaining an exit code needs no settings
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if explainExitOutput != "text" && explainExitOutput != "json" {
+			return fmt.Errorf("unknown --output %s, must be text or json", explainExitOutput)
+		}
+		codes := exitCodes
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("exit code %q is not a number", args[0])
+			}
+			c, ok := lookupExitCode(n)
+			if !ok {
+				return fmt.Errorf("exit code %d is not one of go-git-release's", n)
+			}
+			codes = []exitCode{c}
+		}
+		return writeExitCodes(os.Stdout, codes, explainExitOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainExitCmd)
+	explainExitCmd.Flags().StringVar(&explainExitOutput, "output", "text", "format to write the exit codes in: text or json")
+
+	rootCmd.Long += "\n\n" + exitCodeHelp()
+}
+
+// writeExitCodes writes the exit codes as text, or as a JSON array
+func writeExitCodes(w io.Writer, codes []exitCode, output string) error {
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(codes)
+	}
+	for _, c := range codes {
+		if _, err := fmt.Fprintf(w, "%d %s: %s\n", c.Code, c.Name, c.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestExitCodeFor checks failures exit with the code of their cause, or their stage
+func TestExitCodeFor(t *testing.T) {
+	Equal(t, exitOK, exitCodeFor(nil))
+	Equal(t, exitFailed, exitCodeFor(fmt.Errorf("something else")))
+	Equal(t, 12, exitCodeFor(stageFailed(errBuild, fmt.Errorf("make: *** [build] Error 1"))))
+	Equal(t, 3, exitCodeFor(fmt.Errorf("backfill %w", errHalted)))
+
+	// The more specific cause wins over the stage that wraps it
+	Equal(t, 4, exitCodeFor(stageFailed(errTag, errEmptyTagMessage)))
+
+	seen := map[int]bool{}
+	for _, c := range exitCodes {
+		False(t, seen[c.Code], "exit code %d is listed twice", c.Code)
+		seen[c.Code] = true
+	}
+}
+
+// TestWriteExitCodes checks the exit codes are listed as text, and as JSON for scripts
+func TestWriteExitCodes(t *testing.T) {
+	c, ok := lookupExitCode(15)
+	True(t, ok)
+	_, ok = lookupExitCode(99)
+	False(t, ok)
+
+	var out bytes.Buffer
+	Nil(t, writeExitCodes(&out, []exitCode{c}, "text"))
+	Equal(t, "15 upload: uploading the release assets failed; the release exists without them\n", out.String())
+
+	out.Reset()
+	Nil(t, writeExitCodes(&out, exitCodes, "json"))
+	var listed []map[string]interface{}
+	Nil(t, json.Unmarshal(out.Bytes(), &listed))
+	Len(t, listed, len(exitCodes))
+	Equal(t, map[string]interface{}{"code": float64(16), "name": "scan-blocked", "description": "the dependency scan had findings at or above --scanFailOn"}, listed[len(listed)-1])
+
+	Contains(t, exitCodeHelp(), "13  auth")
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// ghCredentials reuses the token of the gh CLI, if it is logged in to the Github host
+var ghCredentials bool
+
+// ghConfigDir returns the gh CLI's config directory, as gh finds it
+func ghConfigDir() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh"), nil
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI"), nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh"), nil
+}
+
+// ghToken returns the gh CLI's token for the Github host from its hosts.yml, and the path of
+// the file, or an empty token if gh isn't logged in. Recent versions of gh keep the token
+// in the system keychain instead, in which case hosts.yml has none.
+func ghToken() (string, string, error) {
+	dir, err := ghConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	path := filepath.Join(dir, "hosts.yml")
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", path, nil
+	}
+	if err != nil {
+		return "", path, err
+	}
+	defer f.Close()
+
+	// The hosts are keyed by name, which contains the default "." delimiter
+	v := viper.NewWithOptions(viper.KeyDelimiter("::"))
+	v.SetConfigType("yaml")
+	if err = v.ReadConfig(f); err != nil {
+		return "", path, err
+	}
+
+	return v.GetString(githubHost() + "::oauth_token"), path, nil
+}
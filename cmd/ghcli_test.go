@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const testGhHosts = `github.com:
+    user: someone
+    oauth_token: gho_secret
+    git_protocol: ssh
+`
+
+// withGhConfig points the gh config directory at a new directory with the hosts file, or
+// none if hosts is empty, and returns a func removing it
+func withGhConfig(t *testing.T, hosts string) func() {
+	dir, err := ioutil.TempDir("", "gh")
+	Nil(t, err)
+	if hosts != "" {
+		Nil(t, ioutil.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(hosts), 0600))
+	}
+
+	saved, ok := os.LookupEnv("GH_CONFIG_DIR")
+	os.Setenv("GH_CONFIG_DIR", dir)
+	return func() {
+		os.Unsetenv("GH_CONFIG_DIR")
+		if ok {
+			os.Setenv("GH_CONFIG_DIR", saved)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// TestGhToken checks the github.com token is read from the gh CLI's hosts file
+func TestGhToken(t *testing.T) {
+	ghTokenTests := []struct {
+		name     string
+		hosts    string
+		expected string
+		err      bool
+	}{
+		{name: "logged in", hosts: testGhHosts, expected: "gho_secret"},
+		{name: "not logged in"},
+		{name: "token in the keychain", hosts: "github.com:\n    user: someone\n"},
+		{name: "other host", hosts: "github.example.com:\n    oauth_token: other\n"},
+		{name: "corrupt", hosts: "github.com: [", err: true},
+	}
+
+	for _, testSpec := range ghTokenTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				defer withGhConfig(t, testSpec.hosts)()
+
+				tok, path, err := ghToken()
+				Equal(t, os.Getenv("GH_CONFIG_DIR"), filepath.Dir(path))
+				if testSpec.err {
+					Error(t, err)
+					return
+				}
+				Nil(t, err)
+				Equal(t, testSpec.expected, tok)
+			},
+		)
+	}
+}
+
+// TestAuthenticateWithGhToken checks a valid gh CLI token is used without the device flow
+func TestAuthenticateWithGhToken(t *testing.T) {
+	defer gock.Off()
+	defer unsetTokenEnv()()
+	defer withGhConfig(t, testGhHosts)()
+	defer func() { cachedAuth = nil }()
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		MatchHeader("Authorization", "token gho_secret").
+		Reply(200).
+		JSON(map[string]string{"login": "someone"})
+
+	cachedAuth = nil
+	auth, err := authenticate()
+	Nil(t, err)
+	Equal(t, &UserAuth{AccessToken: "gho_secret", TokenType: "token"}, auth)
+	True(t, gock.IsDone())
+
+	// A revoked token falls through to the next source
+	gock.New("https://api.github.com").
+		Get("/user").
+		Reply(401)
+
+	auth, err = ghAuth()
+	Nil(t, err)
+	Nil(t, auth)
+	True(t, gock.IsDone())
+}
@@ -0,0 +1,238 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+// appID is the ID of the Github App to authenticate as, instead of a user
+var appID int64
+
+// appInstallationID is the installation of the App on the repository's account. It is
+// looked up from the repository if not set.
+var appInstallationID int64
+
+// appPrivateKey is the path of the App's private key, as downloaded from Github
+var appPrivateKey string
+
+// appPrivateKeyEnv is the environment variable containing the App's private key, eg: in CI
+var appPrivateKeyEnv string
+
+// appJWTLifetime is how long the App's JWTs are valid; Github allows at most 10 minutes
+const appJWTLifetime = 9 * time.Minute
+
+// githubAppInstallationsURL returns the API endpoint of the App's installations
+func githubAppInstallationsURL() string {
+	return githubAPIURL("app/installations")
+}
+
+// loadAppPrivateKey reads the App's private key from appPrivateKey or appPrivateKeyEnv
+func loadAppPrivateKey() (*rsa.PrivateKey, error) {
+	var data []byte
+	var err error
+	if appPrivateKeyEnv != "" {
+		var key string
+		key, err = secretFromEnv(appPrivateKeyEnv)
+		data = []byte(key)
+	} else {
+		data, err = ioutil.ReadFile(appPrivateKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAppPrivateKey(data)
+}
+
+// parseAppPrivateKey parses a PEM encoded RSA private key, in the PKCS #1 form Github
+// generates, or PKCS #8
+func parseAppPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// appJWT returns a JWT authenticating as the App, signed with its private key. It is
+// issued a minute in the past, allowing for clock drift.
+func appJWT(id int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": id,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// appHeaders returns the Authorization header for requests made as the App
+func appHeaders(jwt string) map[string]string {
+	return map[string]string{
+		"Authorization": "Bearer " + jwt,
+	}
+}
+
+// findAppInstallation returns the ID of the App's installation on the repository
+func findAppInstallation(jwt string, gURL *gitURL) (int64, error) {
+	req, err := newGetRequest(githubRepoURL(gURL, "installation"), url.Values{})
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range appHeaders(jwt) {
+		req.Header.Set(k, v)
+	}
+
+	body, err := makeHTTPRequest(req)
+	if isHTTPStatus(err, 404) {
+		return 0, fmt.Errorf("the Github App is not installed on %s/%s", gURL.organization, gURL.repository)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if err = json.Unmarshal(body, &installation); err != nil {
+		return 0, err
+	}
+
+	return installation.ID, nil
+}
+
+// installationToken is an access token for an installation of the App, valid for an hour
+type installationToken struct {
+	Token       string            `json:"token"`
+	ExpiresAt   string            `json:"expires_at"`
+	Permissions map[string]string `json:"permissions"`
+}
+
+// createInstallationToken exchanges the App's JWT for an installation access token
+func createInstallationToken(jwt string, installationID int64) (*installationToken, error) {
+	tokenURL := fmt.Sprintf("%s/%d/access_tokens", githubAppInstallationsURL(), installationID)
+	req, err := newPostRequest(tokenURL, bytes.NewReader(nil), appHeaders(jwt))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var t installationToken
+	if err = json.Unmarshal(body, &t); err != nil {
+		return nil, err
+	}
+	if t.Token == "" {
+		return nil, errors.New("no token in the response")
+	}
+
+	return &t, nil
+}
+
+// appAuth authenticates as the installation of the Github App on the release repository
+func appAuth() (*UserAuth, error) {
+	key, err := loadAppPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the Github App private key: %w", err)
+	}
+
+	jwt, err := appJWT(appID, key, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign the Github App JWT: %w", err)
+	}
+
+	installationID := appInstallationID
+	if installationID == 0 {
+		repoURL := repositoryURL
+		if upstreamRepositoryURL != "" {
+			repoURL = upstreamRepositoryURL
+		}
+		gURL, err := parseGitURL(repoURL)
+		if err != nil {
+			return nil, err
+		}
+
+		installationID, err = findAppInstallation(jwt, gURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed finding the Github App installation: %w", err)
+		}
+	}
+
+	t, err := createInstallationToken(jwt, installationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating a Github App installation token: %w", err)
+	}
+
+	// Releases are created and their assets uploaded with the contents permission
+	if t.Permissions["contents"] != "write" {
+		return nil, fmt.Errorf("the Github App installation cannot publish releases: it needs the contents write permission")
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Authenticated as installation %d of Github App %d, until %s", installationID, appID, t.ExpiresAt))
+	}
+	auth := &UserAuth{AccessToken: t.Token, TokenType: "token"}
+	if expiresAt, err := time.Parse(time.RFC3339, t.ExpiresAt); err == nil {
+		auth.expiresAt = expiresAt
+	}
+	return auth, nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestAppJWT checks the JWT is signed by the App's key, with its ID as the issuer
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Nil(t, err)
+
+	now := time.Unix(1600000000, 0)
+	jwt, err := appJWT(42, key, now)
+	Nil(t, err)
+
+	parts := strings.Split(jwt, ".")
+	if !Len(t, parts, 3) {
+		return
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	Nil(t, err)
+	Nil(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	Nil(t, err)
+	var claims map[string]int64
+	Nil(t, json.Unmarshal(data, &claims))
+	Equal(t, map[string]int64{"iat": 1599999940, "exp": 1600000540, "iss": 42}, claims)
+}
+
+// TestParseAppPrivateKey checks PKCS #1 and PKCS #8 keys are accepted
+func TestParseAppPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Nil(t, err)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := parseAppPrivateKey(pkcs1)
+	Nil(t, err)
+	True(t, key.Equal(parsed))
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	Nil(t, err)
+	parsed, err = parseAppPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	Nil(t, err)
+	True(t, key.Equal(parsed))
+
+	_, err = parseAppPrivateKey([]byte("not a key"))
+	Error(t, err)
+}
+
+// TestAppAuth checks the installation on the repository is found, and its token used
+func TestAppAuth(t *testing.T) {
+	defer gock.Off()
+	defer func() {
+		appID, appPrivateKeyEnv, repositoryURL = 0, "", ""
+		os.Unsetenv("TEST_APP_KEY")
+	}()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Nil(t, err)
+	os.Setenv("TEST_APP_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+	appID, appPrivateKeyEnv, repositoryURL = 42, "TEST_APP_KEY", "git@github.com:o/r.git"
+
+	appAuthTests := []struct {
+		name        string
+		permissions map[string]string
+		err         bool
+	}{
+		{name: "can publish", permissions: map[string]string{"contents": "write", "metadata": "read"}},
+		{name: "read only", permissions: map[string]string{"contents": "read"}, err: true},
+	}
+
+	for _, testSpec := range appAuthTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				gock.New("https://api.github.com").
+					Get("/repos/o/r/installation").
+					MatchHeader("Authorization", "^Bearer ").
+					Reply(200).
+					JSON(map[string]int{"id": 7})
+				gock.New("https://api.github.com").
+					Post("/app/installations/7/access_tokens").
+					Reply(201).
+					JSON(map[string]interface{}{
+						"token":       "ghs_secret",
+						"expires_at":  "2020-09-13T13:26:40Z",
+						"permissions": testSpec.permissions,
+					})
+
+				auth, err := appAuth()
+				True(t, gock.IsDone())
+				if testSpec.err {
+					Error(t, err)
+					return
+				}
+				Nil(t, err)
+				Equal(t, &UserAuth{AccessToken: "ghs_secret", TokenType: "token", expiresAt: time.Date(2020, 9, 13, 13, 26, 40, 0, time.UTC)}, auth)
+			},
+		)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/spf13/viper"
+)
+
+// generateNotes uses the release notes Github generates, as the web UI's "Generate
+// release notes" button does, instead of the tag message
+var generateNotes bool
+
+// generateNotesRequest is the body of a request for Github's generated release notes
+type generateNotesRequest struct {
+	TagName         string `json:"tag_name"`
+	TargetCommitish string `json:"target_commitish,omitempty"`
+	PreviousTagName string `json:"previous_tag_name,omitempty"`
+}
+
+// generatedNotes are the release notes Github generated
+type generatedNotes struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// githubReleaseConfigPaths are where Github reads the configuration of its generated
+// release notes from, in the repository
+var githubReleaseConfigPaths = []string{".github/release.yml", ".github/release.yaml"}
+
+// githubNotesExclude are the pull requests left out of Github's generated release notes
+type githubNotesExclude struct {
+	Labels  []string `mapstructure:"labels"`
+	Authors []string `mapstructure:"authors"`
+}
+
+// githubNotesCategory is a heading of Github's generated release notes
+type githubNotesCategory struct {
+	Title   string             `mapstructure:"title"`
+	Labels  []string           `mapstructure:"labels"`
+	Exclude githubNotesExclude `mapstructure:"exclude"`
+}
+
+// githubReleaseConfig is Github's generated release notes configuration, as used by the
+// web UI's "Generate release notes" button
+type githubReleaseConfig struct {
+	Changelog struct {
+		Exclude    githubNotesExclude    `mapstructure:"exclude"`
+		Categories []githubNotesCategory `mapstructure:"categories"`
+	} `mapstructure:"changelog"`
+}
+
+// sections returns the categories as release notes sections
+func (c *githubReleaseConfig) sections() []notesSection {
+	var sections []notesSection
+	for _, category := range c.Changelog.Categories {
+		sections = append(sections, notesSection{
+			Title:          category.Title,
+			Labels:         category.Labels,
+			ExcludeLabels:  category.Exclude.Labels,
+			ExcludeAuthors: category.Exclude.Authors,
+		})
+	}
+	return sections
+}
+
+// readGithubReleaseConfig reads the release notes configuration from the repository's
+// worktree, or returns nil if it has none
+func readGithubReleaseConfig(repo *git.Repository) (*githubReleaseConfig, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range githubReleaseConfigPaths {
+		f, err := wt.Filesystem.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		v := viper.New()
+		v.SetConfigType("yaml")
+		err = v.ReadConfig(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var config githubReleaseConfig
+		if err = v.Unmarshal(&config); err != nil {
+			return nil, err
+		}
+
+		if verbose {
+			noteInfo("Using the release notes configuration in " + path)
+		}
+		return &config, nil
+	}
+
+	return nil, nil
+}
+
+// githubGeneratedNotes asks Github to generate the release notes of the tag, for the
+// changes since the previous tag. The commitish is the commit the tag is created on, if it
+// isn't on Github yet.
+func githubGeneratedNotes(auth *UserAuth, gURL *gitURL, tag, previous, commitish string) (string, error) {
+	req, err := newJSONPostRequest(githubRepoURL(gURL, "releases/generate-notes"), &generateNotesRequest{
+		TagName:         tag,
+		TargetCommitish: commitish,
+		PreviousTagName: previous,
+	}, authHeaders(auth))
+	if err != nil {
+		return "", err
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var notes generatedNotes
+	if err = json.Unmarshal(body, &notes); err != nil {
+		return "", err
+	}
+
+	return notes.Body, nil
+}
+
+// generatedReleaseNotes returns Github's generated notes for the release of the tag at the
+// repository's head, since the tag before it
+func generatedReleaseNotes(auth *UserAuth, gURL *gitURL, repo *git.Repository, tag string) (string, error) {
+	head, err := headCommit(repo)
+	if err != nil {
+		return "", err
+	}
+
+	previous, _, err := changelogBase(repo, head, tag)
+	if err != nil {
+		return "", err
+	}
+
+	if verbose {
+		if previous == "" {
+			noteInfo("Generating release notes with Github from all pull requests")
+		} else {
+			noteInfo(fmt.Sprintf("Generating release notes with Github since %s", previous))
+		}
+	}
+
+	return githubGeneratedNotes(auth, gURL, tag, previous, head.Hash.String())
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const githubReleaseConfigYAML = `changelog:
+  exclude:
+    labels:
+      - ignore-for-release
+    authors:
+      - octocat
+  categories:
+    - title: Breaking Changes
+      labels:
+        - breaking-change
+    - title: Everything else
+      labels:
+        - "*"
+      exclude:
+        labels:
+          - dependencies
+`
+
+// TestGithubReleaseConfig checks .github/release.yml categories and exclusions are honored
+func TestGithubReleaseConfig(t *testing.T) {
+	repo := gitfixture.New(t)
+
+	config, err := readGithubReleaseConfig(repo.Repository)
+	Nil(t, err)
+	Nil(t, config)
+
+	repo.Commit("release notes config", map[string]string{".github/release.yml": githubReleaseConfigYAML})
+	config, err = readGithubReleaseConfig(repo.Repository)
+	Nil(t, err)
+	if !NotNil(t, config) {
+		return
+	}
+
+	Equal(t, []notesSection{
+		{Title: "Breaking Changes", Labels: []string{"breaking-change"}},
+		{Title: "Everything else", Labels: []string{"*"}, ExcludeLabels: []string{"dependencies"}},
+	}, config.sections())
+
+	newPR := func(number int, author string, labels ...string) *pullRequest {
+		pr := &pullRequest{Number: number, Title: "Change", HTMLURL: "https://example.org/pr"}
+		pr.User.Login = author
+		for _, l := range labels {
+			pr.Labels = append(pr.Labels, label{Name: l})
+		}
+		return pr
+	}
+
+	prs := []*pullRequest{
+		newPR(1, "someone", "breaking-change"),
+		newPR(2, "someone"),
+		newPR(3, "someone", "dependencies"),
+		newPR(4, "someone", "ignore-for-release"),
+		newPR(5, "OctoCat"),
+	}
+
+	expected := "## Breaking Changes\n\n" +
+		"* Change ([#1](https://example.org/pr)) @someone\n\n" +
+		"## Everything else\n\n" +
+		"* Change ([#2](https://example.org/pr)) @someone\n\n" +
+		"## Other changes\n\n" +
+		"* Change ([#3](https://example.org/pr)) @someone"
+
+	Equal(t, expected, renderNotes(prs, config.sections(), config.Changelog.Exclude.Labels, config.Changelog.Exclude.Authors))
+}
+
+// TestGithubGeneratedNotes checks the notes are generated for the tag since the previous one
+func TestGithubGeneratedNotes(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Post("/repos/o/r/releases/generate-notes").
+		MatchHeader("Authorization", "token secret").
+		JSON(map[string]string{
+			"tag_name":          "v1.1.0",
+			"target_commitish":  "abc123",
+			"previous_tag_name": "v1.0.0",
+		}).
+		Reply(200).
+		JSON(map[string]string{"name": "v1.1.0", "body": "## What's Changed\n* Fix it by @someone in #2"})
+
+	gURL := &gitURL{organization: "o", repository: "r"}
+	notes, err := githubGeneratedNotes(&UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "v1.1.0", "v1.0.0", "abc123")
+	Nil(t, err)
+	Equal(t, "## What's Changed\n* Fix it by @someone in #2", notes)
+	True(t, gock.IsDone())
+}
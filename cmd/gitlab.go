@@ -0,0 +1,400 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// defaultGitlabURL is the GitLab instance releases are published on by default
+const defaultGitlabURL = "https://gitlab.com"
+
+// gitlabURL is the web URL of the GitLab instance, eg: a self-hosted one; its API is at
+// /api/v4 under it
+var gitlabURL = defaultGitlabURL
+
+// gitlabToken is a personal, project or group access token to release on GitLab with
+var gitlabToken string
+
+// gitlabAssetLinkURL renders the URL assets are linked to, where they are hosted elsewhere,
+// instead of uploading them to the project, eg:
+// https://downloads.example.com/{{ .Tag }}/{{ .Name }}
+var gitlabAssetLinkURL string
+
+// The headers GitLab reads tokens from; UserAuth.TokenType is the header of the token
+const (
+	gitlabPrivateTokenHeader = "PRIVATE-TOKEN"
+	gitlabJobTokenHeader     = "JOB-TOKEN"
+)
+
+// gitlabCI returns true if running in a GitLab CI job
+func gitlabCI() bool {
+	return os.Getenv("GITLAB_CI") == "true"
+}
+
+// gitlabHost returns the host of the configured GitLab instance, eg: gitlab.com
+func gitlabHost() string {
+	u, err := url.Parse(gitlabURL)
+	if err != nil || u.Host == "" {
+		return "gitlab.com"
+	}
+	return u.Host
+}
+
+// gitlabBaseURL returns the web URL of the GitLab instance hosting the repository: the
+// configured one, or else the repository's host, eg: a self-hosted gitlab.example.com
+func gitlabBaseURL(gURL *gitURL) string {
+	if gURL.parsedURL == nil || gURL.parsedURL.Host == gitlabHost() {
+		return strings.TrimSuffix(gitlabURL, "/")
+	}
+	return "https://" + gURL.parsedURL.Host
+}
+
+// gitlabProjectURL returns the API URL of path under the repository's project, which is
+// addressed by its URL encoded path, eg: /api/v4/projects/org%2Frepo/releases
+func gitlabProjectURL(gURL *gitURL, path string) string {
+	project := url.PathEscape(gURL.organization + "/" + gURL.repository)
+	return gitlabBaseURL(gURL) + "/api/v4/projects/" + project + "/" + path
+}
+
+// gitlabHeaders returns the header authenticating requests with the token
+func gitlabHeaders(auth *UserAuth) map[string]string {
+	headers := make(map[string]string)
+	if auth != nil {
+		headers[auth.TokenType] = auth.AccessToken
+	}
+	return headers
+}
+
+// gitlabAuth returns the token to release on GitLab with, as gitlabTokenAuth, or else
+// the password for the GitLab host from the netrc file or git's credential helpers
+func gitlabAuth() (*UserAuth, error) {
+	auth, err := gitlabTokenAuth()
+	if err != nil {
+		if c := credentialFor(gitlabHost()); c != nil {
+			if verbose {
+				noteInfo(fmt.Sprintf("Using the GitLab token from %s", c.source))
+			}
+			return &UserAuth{AccessToken: c.password, TokenType: gitlabPrivateTokenHeader}, nil
+		}
+		return nil, err
+	}
+	return auth, nil
+}
+
+// gitlabTokenAuth returns the token given to release on GitLab with: --gitlabToken or
+// GITLAB_TOKEN, or else the CI_JOB_TOKEN of the GitLab CI job
+func gitlabTokenAuth() (*UserAuth, error) {
+	if gitlabToken != "" {
+		return &UserAuth{AccessToken: gitlabToken, TokenType: gitlabPrivateTokenHeader}, nil
+	}
+	if t := os.Getenv("GITLAB_TOKEN"); t != "" {
+		return &UserAuth{AccessToken: t, TokenType: gitlabPrivateTokenHeader}, nil
+	}
+	if t := os.Getenv("CI_JOB_TOKEN"); t != "" {
+		return &UserAuth{AccessToken: t, TokenType: gitlabJobTokenHeader}, nil
+	}
+
+	return nil, errors.New("no GitLab token; set --gitlabToken or GITLAB_TOKEN, or run in a GitLab CI job for its CI_JOB_TOKEN")
+}
+
+// gitlabRelease is a release of the GitLab Releases API
+type gitlabRelease struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+	ReleasedAt  string `json:"released_at"`
+	Assets      struct {
+		Links []gitlabLink `json:"links"`
+	} `json:"assets"`
+	Links struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+// gitlabLink is an asset link of a GitLab release
+type gitlabLink struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+}
+
+// gitlabReleaseRequest is the payload creating a GitLab release
+type gitlabReleaseRequest struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Ref is the commit the tag is created at, if it doesn't exist yet
+	Ref string `json:"ref,omitempty"`
+}
+
+// gitlabLinkRequest is the payload adding an asset link to a GitLab release
+type gitlabLinkRequest struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	LinkType string `json:"link_type"`
+}
+
+// gitlabUpload is a file uploaded to a GitLab project
+type gitlabUpload struct {
+	URL string `json:"url"`
+	// FullPath is the path of the upload on the instance; older versions only have URL,
+	// relative to the project
+	FullPath string `json:"full_path"`
+}
+
+// release converts the GitLab release to the Github release the rest of the tool uses. Its
+// URL is that of the release in the API, and its UploadURL that of the project's uploads.
+func (g *gitlabRelease) release(gURL *gitURL) release {
+	apiURL := gitlabProjectURL(gURL, "releases/"+url.PathEscape(g.TagName))
+	uploadURL := gitlabProjectURL(gURL, "uploads")
+	draft, prerelease := false, false
+
+	r := release{
+		TagName:     &g.TagName,
+		Name:        &g.Name,
+		Body:        &g.Description,
+		Draft:       &draft,
+		Prerelease:  &prerelease,
+		CreatedAt:   &g.CreatedAt,
+		PublishedAt: &g.ReleasedAt,
+		URL:         &apiURL,
+		HTMLURL:     &g.Links.Self,
+		UploadURL:   &uploadURL,
+	}
+	for _, l := range g.Assets.Links {
+		r.Assets = append(r.Assets, l.asset(apiURL))
+	}
+
+	return r
+}
+
+// asset converts the link to the Github asset the rest of the tool uses
+func (l gitlabLink) asset(releaseURL string) *asset {
+	id, name := l.ID, l.Name
+	linkURL := fmt.Sprintf("%s/assets/links/%d", releaseURL, l.ID)
+	download := l.DirectAssetURL
+	if download == "" {
+		download = l.URL
+	}
+
+	return &asset{ID: &id, Name: &name, URL: &linkURL, BrowserDownloadURL: &download}
+}
+
+// gitlabAssetLinkVars are the values available to the asset link URL template
+type gitlabAssetLinkVars struct {
+	Tag  string
+	Name string
+}
+
+// parseGitlabAssetLinkURL parses the asset link URL template
+func parseGitlabAssetLinkURL(text string) (*template.Template, error) {
+	return template.New("gitlabAssetLinkURL").Option("missingkey=error").Parse(text)
+}
+
+// renderGitlabAssetLinkURL renders the URL the artifact of the release is linked to
+func renderGitlabAssetLinkURL(tag, name string) (string, error) {
+	tmpl, err := parseGitlabAssetLinkURL(gitlabAssetLinkURL)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, gitlabAssetLinkVars{Tag: tag, Name: name}); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// uploadToGitlab uploads the artifact to the project's uploads as a multipart form, and
+// returns the URL it can be downloaded from
+func uploadToGitlab(auth *UserAuth, uploadURL string, a *artifact) (string, error) {
+	req, err := newMultipartUploadRequest(uploadURL, "file", a, gitlabHeaders(auth))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := makeHTTPRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var uploaded gitlabUpload
+	if err = json.Unmarshal(resp, &uploaded); err != nil {
+		return "", err
+	}
+
+	// The upload's path is relative to the instance, or to the project on older versions
+	i := strings.Index(uploadURL, "/api/v4/projects/")
+	if i < 0 {
+		return "", fmt.Errorf("cannot parse upload url %s", uploadURL)
+	}
+	base := uploadURL[:i]
+	if uploaded.FullPath != "" {
+		return base + uploaded.FullPath, nil
+	}
+
+	project, err := url.PathUnescape(strings.TrimSuffix(uploadURL[i+len("/api/v4/projects/"):], "/uploads"))
+	if err != nil {
+		return "", err
+	}
+
+	return base + "/" + project + uploaded.URL, nil
+}
+
+// gitlabProvider publishes releases on gitlab.com, or a self-hosted GitLab instance, with
+// the Releases API. Assets are uploaded to the project and linked from the release, or
+// linked where they are hosted with --gitlabAssetLinkURL.
+type gitlabProvider struct{}
+
+func (g *gitlabProvider) name() string {
+	return "GitLab"
+}
+
+// handles claims gitlab.com, the configured instance, and hosts named like one
+func (g *gitlabProvider) handles(host string) bool {
+	return host == "gitlab.com" || host == gitlabHost() || strings.HasPrefix(host, "gitlab.")
+}
+
+func (g *gitlabProvider) authenticate(gURL *gitURL) (*UserAuth, error) {
+	if unsupported := githubOnlySettings(); len(unsupported) > 0 {
+		return nil, fmt.Errorf("GitLab releases don't support %s", strings.Join(unsupported, ", "))
+	}
+
+	return gitlabAuth()
+}
+
+func (g *gitlabProvider) listReleases(gURL *gitURL) (*releases, error) {
+	return releasesCache.get(releaseCacheKey(gURL), func() (*releases, error) {
+		releasesList := releases{}
+
+		// Private projects' releases are only listed with a token
+		auth, _ := gitlabAuth()
+
+		for page := 1; ; page++ {
+			query := url.Values{"per_page": {strconv.Itoa(releasesPerPage)}, "page": {strconv.Itoa(page)}}
+			req, err := newGetRequest(gitlabProjectURL(gURL, "releases")+"?"+query.Encode(), url.Values{})
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range gitlabHeaders(auth) {
+				req.Header.Set(k, v)
+			}
+
+			body, err := makeHTTPRequest(req)
+			if err != nil {
+				return nil, err
+			}
+
+			var list []gitlabRelease
+			if err = json.Unmarshal(body, &list); err != nil {
+				return nil, err
+			}
+			for _, r := range list {
+				releasesList = append(releasesList, r.release(gURL))
+			}
+
+			if len(list) < releasesPerPage {
+				return &releasesList, nil
+			}
+		}
+	})
+}
+
+func (g *gitlabProvider) createRelease(auth *UserAuth, gURL *gitURL, request *newReleaseRequest) (*release, error) {
+	if request.Draft {
+		return nil, errors.New("GitLab has no draft releases")
+	}
+	if request.Prerelease {
+		return nil, errors.New("GitLab has no prereleases")
+	}
+
+	releaseRequest := &gitlabReleaseRequest{
+		TagName:     request.TagName,
+		Name:        request.Name,
+		Description: request.Body,
+		Ref:         request.TargetCommitish,
+	}
+
+	req, err := newJSONPostRequest(gitlabProjectURL(gURL, "releases"), releaseRequest, gitlabHeaders(auth))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeHTTPRequest(req)
+	releasesCache.invalidate(releaseCacheKey(gURL))
+	if err != nil {
+		return nil, err
+	}
+
+	var created gitlabRelease
+	if err = json.Unmarshal(body, &created); err != nil {
+		return nil, err
+	}
+
+	r := created.release(gURL)
+	return &r, nil
+}
+
+func (g *gitlabProvider) uploadAsset(auth *UserAuth, r *release, a *artifact) (*asset, error) {
+	if r.URL == nil || r.TagName == nil {
+		return nil, errors.New("release has no url")
+	}
+
+	var linkURL string
+	var err error
+	if gitlabAssetLinkURL != "" {
+		linkURL, err = renderGitlabAssetLinkURL(*r.TagName, a.name)
+	} else if r.UploadURL == nil {
+		err = errors.New("release has no upload url")
+	} else {
+		linkURL, err = uploadToGitlab(auth, *r.UploadURL, a)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	linkRequest := &gitlabLinkRequest{Name: a.name, URL: linkURL, LinkType: "package"}
+	req, err := newJSONPostRequest(*r.URL+"/assets/links", linkRequest, gitlabHeaders(auth))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var link gitlabLink
+	if err = json.Unmarshal(body, &link); err != nil {
+		return nil, err
+	}
+
+	return link.asset(*r.URL), nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestGitlabAuth checks an access token is preferred to the job token, and each is sent in its header
+func TestGitlabAuth(t *testing.T) {
+	defer func(t string) { gitlabToken = t }(gitlabToken)
+	for _, name := range []string{"GITLAB_TOKEN", "CI_JOB_TOKEN"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	gitlabToken = ""
+
+	_, err := gitlabAuth()
+	NotNil(t, err)
+
+	os.Setenv("CI_JOB_TOKEN", "job")
+	auth, err := gitlabAuth()
+	Nil(t, err)
+	Equal(t, map[string]string{"JOB-TOKEN": "job"}, gitlabHeaders(auth))
+
+	os.Setenv("GITLAB_TOKEN", "pat")
+	auth, err = gitlabAuth()
+	Nil(t, err)
+	Equal(t, map[string]string{"PRIVATE-TOKEN": "pat"}, gitlabHeaders(auth))
+}
+
+// TestGitlabRelease checks the release is created, and the artifacts uploaded to the project and linked
+func TestGitlabRelease(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+
+	dir, err := ioutil.TempDir("", "gitlab")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app-linux")
+	Nil(t, ioutil.WriteFile(path, []byte("binary"), 0644))
+
+	gURL, err := parseGitURL("https://gitlab.com/o/r.git")
+	Nil(t, err)
+	auth := &UserAuth{AccessToken: "pat", TokenType: gitlabPrivateTokenHeader}
+
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/o/r/releases").
+		MatchHeader("Private-Token", "pat").
+		JSON(map[string]string{"tag_name": "v1.0.0", "name": "v1.0.0", "description": "notes"}).
+		Reply(201).
+		JSON(map[string]interface{}{
+			"tag_name":    "v1.0.0",
+			"name":        "v1.0.0",
+			"description": "notes",
+			"_links":      map[string]string{"self": "https://gitlab.com/o/r/-/releases/v1.0.0"},
+		})
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/o/r/uploads").
+		MatchHeader("Content-Type", "multipart/form-data").
+		BodyString("binary").
+		Reply(201).
+		JSON(map[string]string{"url": "/uploads/abc/app-linux"})
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/o/r/releases/v1.0.0/assets/links").
+		JSON(map[string]string{"name": "app-linux", "url": "https://gitlab.com/o/r/uploads/abc/app-linux", "link_type": "package"}).
+		Reply(201).
+		JSON(map[string]interface{}{"id": 7, "name": "app-linux", "url": "https://gitlab.com/o/r/uploads/abc/app-linux"})
+
+	p := &gitlabProvider{}
+	r, err := createRelease(p, auth, gURL, "v1.0.0", "v1.0.0", "notes", "", false, false)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://gitlab.com/o/r/-/releases/v1.0.0", *r.HTMLURL)
+
+	uploaded, err := uploadAssets(p, auth, r, []*artifact{{path: path, name: "app-linux", size: 6}})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://gitlab.com/o/r/uploads/abc/app-linux", *uploaded[0].BrowserDownloadURL)
+	Equal(t, "https://gitlab.com/api/v4/projects/o%2Fr/releases/v1.0.0/assets/links/7", *uploaded[0].URL)
+	True(t, gock.IsDone())
+
+	_, err = createRelease(p, auth, gURL, "v1.0.1", "v1.0.1", "notes", "", true, false)
+	EqualError(t, err, "GitLab has no draft releases")
+}
+
+// TestGitlabAssetLinks checks assets hosted elsewhere are linked without uploading them
+func TestGitlabAssetLinks(t *testing.T) {
+	defer gock.Off()
+	defer func(text string) { gitlabAssetLinkURL = text }(gitlabAssetLinkURL)
+	gitlabAssetLinkURL = "https://downloads.example.com/{{ .Tag }}/{{ .Name }}"
+
+	gock.New("https://gitlab.example.com").
+		Post("/api/v4/projects/o/r/releases/v1.0.0/assets/links").
+		MatchHeader("Job-Token", "job").
+		JSON(map[string]string{"name": "app-linux", "url": "https://downloads.example.com/v1.0.0/app-linux", "link_type": "package"}).
+		Reply(201).
+		JSON(map[string]interface{}{"id": 7, "name": "app-linux", "url": "https://downloads.example.com/v1.0.0/app-linux"})
+
+	gURL, err := parseGitURL("git@gitlab.example.com:o/r.git")
+	Nil(t, err)
+	r := (&gitlabRelease{TagName: "v1.0.0"}).release(gURL)
+
+	a, err := (&gitlabProvider{}).uploadAsset(&UserAuth{AccessToken: "job", TokenType: gitlabJobTokenHeader}, &r, &artifact{name: "app-linux"})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://downloads.example.com/v1.0.0/app-linux", *a.BrowserDownloadURL)
+	True(t, gock.IsDone())
+}
+
+// TestGitlabUnsupported checks the Github only settings are refused on GitLab
+func TestGitlabUnsupported(t *testing.T) {
+	defer func(d, n bool) { draft, notesFromPRs = d, n }(draft, notesFromPRs)
+	draft, notesFromPRs = true, true
+
+	_, err := (&gitlabProvider{}).authenticate(&gitURL{organization: "o", repository: "r"})
+	EqualError(t, err, "GitLab releases don't support draft, notesFromPRs")
+}
+
+// TestGitlabListReleases checks every page of a subgroup project's releases is listed
+func TestGitlabListReleases(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+	defer func(t string) { gitlabToken = t }(gitlabToken)
+	gitlabToken = "pat"
+
+	full := make([]map[string]string, releasesPerPage)
+	for i := range full {
+		full[i] = map[string]string{"tag_name": fmt.Sprintf("v0.%d.0", i)}
+	}
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/group/subgroup/r/releases").
+		MatchParam("page", "1").
+		MatchHeader("Private-Token", "pat").
+		Reply(200).
+		JSON(full)
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/group/subgroup/r/releases").
+		MatchParam("page", "2").
+		Reply(200).
+		JSON([]map[string]string{{"tag_name": "v1.0.0"}})
+
+	gURL, err := parseGitURL("https://gitlab.com/group/subgroup/r.git")
+	Nil(t, err)
+	releasesList, err := (&gitlabProvider{}).listReleases(gURL)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Len(t, *releasesList, releasesPerPage+1)
+	Equal(t, "v1.0.0", *(*releasesList)[releasesPerPage].TagName)
+	True(t, gock.IsDone())
+}
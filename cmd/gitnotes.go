@@ -0,0 +1,302 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitNote records each release in a git note on the released commit, and pushes it
+var gitNote bool
+
+// releaseNotesRef is the notes ref the releases are recorded in
+const releaseNotesRef = plumbing.ReferenceName("refs/notes/releases")
+
+// releaseRecord is what's recorded of a release in its git note
+type releaseRecord struct {
+	Tag        string        `json:"tag"`
+	Repository string        `json:"repository"`
+	Commit     string        `json:"commit"`
+	URL        string        `json:"url,omitempty"`
+	State      string        `json:"state"`
+	ReleasedAt string        `json:"released_at"`
+	Assets     []stagedAsset `json:"assets,omitempty"`
+	// Provenance is the CI run that built the assets, if any
+	Provenance *provenance `json:"provenance,omitempty"`
+}
+
+// newReleaseRecord returns the record of the release of the commit, with the digests of
+// its artifacts
+func newReleaseRecord(gURL *gitURL, r *release, commit string, artifacts []*artifact, prov *provenance) (*releaseRecord, error) {
+	record := &releaseRecord{
+		Tag:        tag,
+		Repository: fmt.Sprintf("%s/%s", gURL.organization, gURL.repository),
+		Commit:     commit,
+		State:      releaseState(r),
+		ReleasedAt: time.Now().UTC().Format(time.RFC3339),
+		Provenance: prov,
+	}
+	if r.HTMLURL != nil {
+		record.URL = *r.HTMLURL
+	}
+
+	for _, a := range artifacts {
+		digest, err := fileDigest(a.path)
+		if err != nil {
+			return nil, err
+		}
+		record.Assets = append(record.Assets, stagedAsset{Name: a.name, Size: a.size, SHA256: digest, Metadata: a.metadata})
+	}
+
+	return record, nil
+}
+
+// mergeReleaseNote adds the record to the note, one JSON object a line, replacing the
+// record of the same tag, eg: from a re-run. A commit may be released under several tags.
+func mergeReleaseNote(note string, record *releaseRecord) (string, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSpace(note), "\n") {
+		var existing releaseRecord
+		if l == "" || (json.Unmarshal([]byte(l), &existing) == nil && existing.Tag == record.Tag) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	lines = append(lines, string(line))
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// addReleaseNote records the release in the note of the commit on refs/notes/releases,
+// committing the notes tree as git notes does. A note already in a fanout directory,
+// eg: ab/cdef..., is moved to the top of the tree, without fanout, which git reads too.
+func addReleaseNote(repo *git.Repository, commit plumbing.Hash, record *releaseRecord, sig *object.Signature) error {
+	var parents []plumbing.Hash
+	var entries []object.TreeEntry
+	var note string
+
+	ref, err := repo.Reference(releaseNotesRef, true)
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return err
+	}
+	if ref != nil {
+		parents = append(parents, ref.Hash())
+
+		notes, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		tree, err := notes.Tree()
+		if err != nil {
+			return err
+		}
+
+		entries, note, _, err = removeNote(repo, tree, "", commit.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	note, err = mergeReleaseNote(note, record)
+	if err != nil {
+		return err
+	}
+
+	blob := repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte(note)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	blobHash, err := repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, object.TreeEntry{Name: commit.String(), Mode: filemode.Regular, Hash: blobHash})
+
+	treeHash, err := storeTree(repo, entries)
+	if err != nil {
+		return err
+	}
+
+	commitObj := repo.Storer.NewEncodedObject()
+	notesCommit := &object.Commit{
+		Author:       *sig,
+		Committer:    *sig,
+		Message:      fmt.Sprintf("Notes added by 'go-git-release' for %s\n", record.Tag),
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	if err = notesCommit.Encode(commitObj); err != nil {
+		return err
+	}
+	commitHash, err := repo.Storer.SetEncodedObject(commitObj)
+	if err != nil {
+		return err
+	}
+
+	return repo.Storer.SetReference(plumbing.NewHashReference(releaseNotesRef, commitHash))
+}
+
+// removeNote returns the entries of the notes tree without the note of the commit, the
+// note, and whether there was one. The fanout directories holding the note, named for the leading hex digits of
+// the commit, are rewritten without it, or dropped once empty.
+func removeNote(repo *git.Repository, tree *object.Tree, prefix, commit string) ([]object.TreeEntry, string, bool, error) {
+	var entries []object.TreeEntry
+	var note string
+	var found bool
+
+	for _, e := range tree.Entries {
+		path := prefix + e.Name
+		switch {
+		case e.Mode == filemode.Dir && len(e.Name) == 2 && strings.HasPrefix(commit, path):
+			sub, err := repo.TreeObject(e.Hash)
+			if err != nil {
+				return nil, "", false, err
+			}
+			subEntries, n, ok, err := removeNote(repo, sub, path, commit)
+			if err != nil {
+				return nil, "", false, err
+			}
+			if !ok {
+				entries = append(entries, e)
+				continue
+			}
+
+			note, found = n, true
+			if len(subEntries) == 0 {
+				continue
+			}
+			if e.Hash, err = storeTree(repo, subEntries); err != nil {
+				return nil, "", false, err
+			}
+			entries = append(entries, e)
+		case e.Mode != filemode.Dir && path == commit:
+			blob, err := repo.BlobObject(e.Hash)
+			if err != nil {
+				return nil, "", false, err
+			}
+			r, err := blob.Reader()
+			if err != nil {
+				return nil, "", false, err
+			}
+			data, err := ioutil.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, "", false, err
+			}
+			note, found = string(data), true
+		default:
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, note, found, nil
+}
+
+// storeTree stores the tree of the entries, sorted as git sorts them, and returns its hash
+func storeTree(repo *git.Repository, entries []object.TreeEntry) (plumbing.Hash, error) {
+	// Git sorts tree entries by name, with directories as if they ended in a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortName(entries[i]) < sortName(entries[j]) })
+
+	treeObj := repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(treeObj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(treeObj)
+}
+
+// fetchReleaseNotes fetches the remote's refs/notes/releases over any local one, so the
+// note is added to those already pushed. A remote without release notes yet is fine.
+func fetchReleaseNotes(repo *git.Repository) error {
+	u, err := remoteURL(repo, remote)
+	if err != nil {
+		return err
+	}
+	auth, err := gitAuth(u)
+	if err != nil {
+		return err
+	}
+
+	refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", releaseNotesRef, releaseNotesRef))
+	err = repo.Fetch(&git.FetchOptions{RemoteName: remote, Progress: gitopts.progress, RefSpecs: []config.RefSpec{refSpec}, Auth: auth})
+
+	var noMatch git.NoMatchingRefSpecError
+	if err == nil || err == git.NoErrAlreadyUpToDate || errors.As(err, &noMatch) {
+		return nil
+	}
+	return err
+}
+
+// recordReleaseNote records the release in the git note of its commit, and pushes the
+// notes ref. The push isn't forced, so notes another release pushed meanwhile aren't lost;
+// as the release is already published by now, failing to push the note is only warned about.
+func recordReleaseNote(repo *git.Repository, commit plumbing.Hash, record *releaseRecord) error {
+	repoConfig, err := repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return err
+	}
+
+	if err = fetchReleaseNotes(repo); err != nil {
+		fmt.Printf("WARNING: not recording the release in %s, failed fetching it: %s\n", releaseNotesRef, err)
+		return nil
+	}
+
+	err = addReleaseNote(repo, commit, record, defaultSignature(repoConfig.User.Name, repoConfig.User.Email))
+	if err != nil {
+		return err
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Pushing the release note of %s to %s", commit.String()[:7], releaseNotesRef))
+	}
+	err = pushRefSpecs(repo, []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", releaseNotesRef, releaseNotesRef))})
+	if err != nil {
+		fmt.Printf("WARNING: failed pushing %s, the release is only recorded locally: %s\n", releaseNotesRef, err)
+	}
+	return nil
+}
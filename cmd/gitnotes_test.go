@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestMergeReleaseNote checks records are added a line each, replacing those of the same tag
+func TestMergeReleaseNote(t *testing.T) {
+	note, err := mergeReleaseNote("", &releaseRecord{Tag: "v1.0.0-rc.1", State: "prerelease"})
+	Nil(t, err)
+	note, err = mergeReleaseNote(note, &releaseRecord{Tag: "v1.0.0", State: "draft"})
+	Nil(t, err)
+	note, err = mergeReleaseNote(note, &releaseRecord{Tag: "v1.0.0", State: "published"})
+	Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(note), "\n")
+	if Len(t, lines, 2) {
+		Contains(t, lines[0], `"tag":"v1.0.0-rc.1"`)
+		Contains(t, lines[1], `"state":"published"`)
+	}
+}
+
+// TestAddReleaseNote checks the notes ref gets a commit per release, with a note per commit
+func TestAddReleaseNote(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.CommitWith("first")
+	second := repo.CommitWith("second", first)
+	sig := &object.Signature{Name: "test"}
+
+	Nil(t, addReleaseNote(repo.Repository, first.Hash, &releaseRecord{Tag: "v0.1.0", Commit: first.Hash.String()}, sig))
+	Nil(t, addReleaseNote(repo.Repository, second.Hash, &releaseRecord{Tag: "v0.2.0", Commit: second.Hash.String()}, sig))
+	Nil(t, addReleaseNote(repo.Repository, second.Hash, &releaseRecord{Tag: "v0.2.0", Commit: second.Hash.String(), State: "published"}, sig))
+
+	ref, err := repo.Reference(releaseNotesRef, true)
+	if !Nil(t, err) {
+		return
+	}
+	notes, err := repo.CommitObject(ref.Hash())
+	Nil(t, err)
+	Equal(t, "Notes added by 'go-git-release' for v0.2.0\n", notes.Message)
+	Len(t, notes.ParentHashes, 1)
+
+	tree, err := notes.Tree()
+	Nil(t, err)
+	Len(t, tree.Entries, 2)
+
+	f, err := tree.File(second.Hash.String())
+	Nil(t, err)
+	contents, err := f.Contents()
+	Nil(t, err)
+	var record releaseRecord
+	Nil(t, json.Unmarshal([]byte(contents), &record))
+	Equal(t, releaseRecord{Tag: "v0.2.0", Commit: second.Hash.String(), State: "published"}, record)
+}
+
+// TestAddReleaseNoteFanout checks a note in a fanout directory is replaced, not duplicated
+func TestAddReleaseNoteFanout(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.CommitWith("first")
+	second := repo.CommitWith("second", first)
+	sig := &object.Signature{Name: "test"}
+	Nil(t, addReleaseNote(repo.Repository, first.Hash, &releaseRecord{Tag: "v0.1.0", Commit: first.Hash.String()}, sig))
+
+	// Move the note into a fanout directory, as git notes does once there are many
+	ref, err := repo.Reference(releaseNotesRef, true)
+	if !Nil(t, err) {
+		return
+	}
+	notes, err := repo.CommitObject(ref.Hash())
+	Nil(t, err)
+	tree, err := notes.Tree()
+	Nil(t, err)
+	name := first.Hash.String()
+	subTree, err := storeTree(repo.Repository, []object.TreeEntry{{Name: name[2:], Mode: filemode.Regular, Hash: tree.Entries[0].Hash}})
+	Nil(t, err)
+	treeHash, err := storeTree(repo.Repository, []object.TreeEntry{{Name: name[:2], Mode: filemode.Dir, Hash: subTree}})
+	Nil(t, err)
+	obj := repo.Storer.NewEncodedObject()
+	Nil(t, (&object.Commit{Author: *sig, Committer: *sig, TreeHash: treeHash}).Encode(obj))
+	fanout, err := repo.Storer.SetEncodedObject(obj)
+	Nil(t, err)
+	Nil(t, repo.Storer.SetReference(plumbing.NewHashReference(releaseNotesRef, fanout)))
+
+	Nil(t, addReleaseNote(repo.Repository, first.Hash, &releaseRecord{Tag: "v0.1.1", Commit: name}, sig))
+	Nil(t, addReleaseNote(repo.Repository, second.Hash, &releaseRecord{Tag: "v0.2.0", Commit: second.Hash.String()}, sig))
+
+	ref, err = repo.Reference(releaseNotesRef, true)
+	Nil(t, err)
+	notes, err = repo.CommitObject(ref.Hash())
+	Nil(t, err)
+	tree, err = notes.Tree()
+	Nil(t, err)
+	if !Len(t, tree.Entries, 2) {
+		return
+	}
+
+	f, err := tree.File(name)
+	Nil(t, err)
+	contents, err := f.Contents()
+	Nil(t, err)
+	Equal(t, 2, strings.Count(contents, "\n"))
+	Contains(t, contents, `"tag":"v0.1.0"`)
+	Contains(t, contents, `"tag":"v0.1.1"`)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// notesSummarizer is the command summarizing the changes of a release into its highlights,
+// eg: an LLM CLI. It reads the changes on stdin and writes the highlights to stdout.
+var notesSummarizer string
+
+// notesSummarizerTimeout is how long the summarizer may run before it is stopped
+var notesSummarizerTimeout time.Duration
+
+// highlightsHeading is the heading of the summarizer's section of the release notes
+const highlightsHeading = "## Highlights"
+
+// summarizerInput lists the changes for the summarizer: the commits since the previous
+// tag, oldest first, and the generated release notes of the pull requests, if any
+func summarizerInput(tag, previous string, commits []*object.Commit, changes string) string {
+	var b strings.Builder
+	if previous == "" {
+		fmt.Fprintf(&b, "Commits in %s:\n", tag)
+	} else {
+		fmt.Fprintf(&b, "Commits in %s since %s:\n", tag, previous)
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%s %s\n", commits[i].Hash.String()[:7], commitSubject(commits[i]))
+	}
+
+	if changes != "" {
+		fmt.Fprintf(&b, "\nPull requests:\n%s\n", changes)
+	}
+
+	return b.String()
+}
+
+// runSummarizer pipes the input to the summarizer command in dir, and returns what it
+// wrote. The tag and previous tag are in its TAG and PREVIOUS_TAG environment variables.
+func runSummarizer(command, dir, tag, previous, input string, timeout time.Duration) (string, error) {
+	fields, err := splitCommand(command)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no summarizer command provided")
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TAG="+tag, "PREVIOUS_TAG="+previous)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("the summarizer didn't finish within %s", timeout)
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// releaseHighlights summarizes the changes of the release of the tag at the repository's
+// head into a Highlights section, or returns "" if the summarizer wrote nothing
+func releaseHighlights(repo *git.Repository, dir, tag, changes string) (string, error) {
+	head, err := headCommit(repo)
+	if err != nil {
+		return "", err
+	}
+
+	previous, since, err := changelogBase(repo, head, tag)
+	if err != nil {
+		return "", err
+	}
+
+	commits, err := commitsBetween(repo, since, head)
+	if err != nil {
+		return "", err
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Summarizing %d commits with %s", len(commits), notesSummarizer))
+	}
+	highlights, err := runSummarizer(notesSummarizer, dir, tag, previous, summarizerInput(tag, previous, commits, changes), notesSummarizerTimeout)
+	if err != nil || highlights == "" {
+		return "", err
+	}
+
+	return highlightsHeading + "\n\n" + highlights, nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestSummarizerInput checks the commits are listed oldest first, with the pull request notes
+func TestSummarizerInput(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.CommitWith("Add the widget (#1)")
+	second := repo.CommitWith("Fix the widget", first)
+
+	input := summarizerInput("v1.1.0", "v1.0.0", []*object.Commit{second, first}, "## Features\n\n* Add the widget (#1)")
+	Equal(t, "Commits in v1.1.0 since v1.0.0:\n"+
+		first.Hash.String()[:7]+" Add the widget (#1)\n"+
+		second.Hash.String()[:7]+" Fix the widget\n"+
+		"\nPull requests:\n## Features\n\n* Add the widget (#1)\n", input)
+
+	True(t, strings.HasPrefix(summarizerInput("v1.0.0", "", nil, ""), "Commits in v1.0.0:\n"))
+}
+
+// TestRunSummarizer checks the changes are piped to the command, and slow commands are stopped
+func TestRunSummarizer(t *testing.T) {
+	out, err := runSummarizer("sh -c 'echo \"$TAG after $PREVIOUS_TAG\"; head -n 1'", "", "v1.1.0", "v1.0.0", "Commits\nabc1234 Fix it\n", time.Minute)
+	Nil(t, err)
+	Equal(t, "v1.1.0 after v1.0.0\nCommits", out)
+
+	_, err = runSummarizer("sleep 5", "", "v1.1.0", "v1.0.0", "", 50*time.Millisecond)
+	EqualError(t, err, "the summarizer didn't finish within 50ms")
+
+	_, err = runSummarizer("false", "", "v1.1.0", "v1.0.0", "", time.Minute)
+	NotNil(t, err)
+}
+
+// TestReleaseHighlights checks the summary is a Highlights section, or nothing if it is empty
+func TestReleaseHighlights(t *testing.T) {
+	defer func(command string, timeout time.Duration) {
+		notesSummarizer, notesSummarizerTimeout = command, timeout
+	}(notesSummarizer, notesSummarizerTimeout)
+	notesSummarizerTimeout = time.Minute
+
+	repo := gitfixture.New(t)
+	root := repo.CommitWith("root")
+	repo.Tag("v1.0.0", root)
+	repo.CommitWith("Add the widget (#1)", root)
+
+	notesSummarizer = "grep -o widget"
+	highlights, err := releaseHighlights(repo.Repository, "", "v1.1.0", "")
+	Nil(t, err)
+	Equal(t, "## Highlights\n\nwidget", highlights)
+
+	notesSummarizer = "true"
+	highlights, err = releaseHighlights(repo.Repository, "", "v1.1.0", "")
+	Nil(t, err)
+	Equal(t, "", highlights)
+}
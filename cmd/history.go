@@ -0,0 +1,303 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// commitForTag returns the commit a tag points at, following annotated tags
+func commitForTag(repo *git.Repository, tag string) (*object.Commit, error) {
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitForHash(repo, ref.Hash())
+}
+
+// commitForHash returns the commit for a hash, which may be the hash of an annotated tag
+func commitForHash(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	tagObj, err := repo.TagObject(hash)
+	if err == nil {
+		return tagObj.Commit()
+	}
+
+	return repo.CommitObject(hash)
+}
+
+// headCommit returns the commit currently checked out in the repository
+func headCommit(repo *git.Repository) (*object.Commit, error) {
+	ref, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.CommitObject(ref.Hash())
+}
+
+// changedPaths returns the sorted paths of the files added, modified or removed
+// between the from and to commits
+func changedPaths(from, to *object.Commit) ([]string, error) {
+	patch, err := from.Patch(to)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, fp := range patch.FilePatches() {
+		before, after := fp.Files()
+		if before != nil {
+			seen[before.Path()] = true
+		}
+		if after != nil {
+			seen[after.Path()] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// tagsByCommit maps commit hashes to the names of the tags pointing at them
+func tagsByCommit(repo *git.Repository) (map[plumbing.Hash][]string, error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	byCommit := make(map[plumbing.Hash][]string)
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		commit, err := commitForHash(repo, ref.Hash())
+		if err != nil {
+			// Tags pointing at trees or blobs can't be part of the history
+			return nil
+		}
+		byCommit[commit.Hash] = append(byCommit[commit.Hash], ref.Name().Short())
+		return nil
+	})
+
+	return byCommit, err
+}
+
+// previousTag walks the history back from the from commit, like git describe, and
+// returns the first tag found other than exclude, and the commit it points at. An
+// empty tag is returned if there is no earlier tag.
+func previousTag(repo *git.Repository, from *object.Commit, exclude string) (string, *object.Commit, error) {
+	byCommit, err := tagsByCommit(repo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: from.Hash})
+	if err != nil {
+		return "", nil, err
+	}
+	defer commits.Close()
+
+	var foundTag string
+	var foundCommit *object.Commit
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		for _, t := range byCommit[c.Hash] {
+			if t != exclude {
+				foundTag = t
+				foundCommit = c
+				return storer.ErrStop
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return foundTag, foundCommit, nil
+}
+
+// changelogBase returns the tag the changes of the release are listed since, and the
+// commit it points at: the tag before the release in the history of head. Releases from
+// the default branch only look along its first parents, so a tag made on a branch merged
+// into it doesn't start the range.
+func changelogBase(repo *git.Repository, head *object.Commit, tag string) (string, *object.Commit, error) {
+	if defaultBranch == "" {
+		return previousTag(repo, head, tag)
+	}
+
+	byCommit, err := tagsByCommit(repo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for c := head; ; {
+		for _, t := range byCommit[c.Hash] {
+			if t != tag {
+				return t, c, nil
+			}
+		}
+		if c.NumParents() == 0 {
+			return "", nil, nil
+		}
+		if c, err = c.Parent(0); err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// describeCommit describes the commit like git describe --tags: the nearest tag, followed
+// by the number of commits since it and the abbreviated commit if it isn't tagged itself.
+// Commits with no tag in their history are described by the abbreviated commit alone.
+func describeCommit(repo *git.Repository, c *object.Commit) (string, error) {
+	short := c.Hash.String()[:7]
+
+	t, tagged, err := previousTag(repo, c, "")
+	if err != nil {
+		return "", err
+	}
+	if t == "" {
+		return short, nil
+	}
+	if tagged.Hash == c.Hash {
+		return t, nil
+	}
+
+	commits, err := commitsBetween(repo, tagged, c)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%d-g%s", t, len(commits), short), nil
+}
+
+// commitsBetween returns the commits reachable from to but not from since, newest
+// first. A nil since returns the whole history of to.
+func commitsBetween(repo *git.Repository, since, to *object.Commit) ([]*object.Commit, error) {
+	commits, _, err := walkCommits(since, to)
+	return commits, err
+}
+
+// walkCommits walks back from to in commit date order, like git rev-list since..to,
+// and returns the commits not reachable from since. Every ancestor of since is excluded,
+// rather than stopping the walk at the commit dates, so commits with skewed clocks can't
+// put since's history into the range. reached is true if since is an ancestor of to.
+func walkCommits(since, to *object.Commit) (commits []*object.Commit, reached bool, err error) {
+	excluded, err := ancestry(since)
+	if err != nil {
+		return nil, false, err
+	}
+
+	queued := make(map[plumbing.Hash]bool)
+	var queue []*object.Commit
+
+	push := func(c *object.Commit) {
+		if queued[c.Hash] {
+			return
+		}
+		queued[c.Hash] = true
+		if excluded[c.Hash] {
+			return
+		}
+		i := sort.Search(len(queue), func(i int) bool {
+			return queue[i].Committer.When.Before(c.Committer.When)
+		})
+		queue = append(queue, nil)
+		copy(queue[i+1:], queue[i:])
+		queue[i] = c
+	}
+
+	reached = since == nil || since.Hash == to.Hash
+	push(to)
+
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		commits = append(commits, c)
+
+		err = c.Parents().ForEach(func(p *object.Commit) error {
+			if since != nil && p.Hash == since.Hash {
+				reached = true
+			}
+			push(p)
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return commits, reached, nil
+}
+
+// ancestry returns the hashes of the commit and every commit reachable from it, or none
+// for a nil commit
+func ancestry(c *object.Commit) (map[plumbing.Hash]bool, error) {
+	hashes := make(map[plumbing.Hash]bool)
+	if c == nil {
+		return hashes, nil
+	}
+
+	err := object.NewCommitPreorderIter(c, nil, nil).ForEach(func(a *object.Commit) error {
+		hashes[a.Hash] = true
+		return nil
+	})
+
+	return hashes, err
+}
+
+// commitSubject returns the first line of a commit message
+func commitSubject(c *object.Commit) string {
+	return strings.TrimSpace(strings.SplitN(c.Message, "\n", 2)[0])
+}
+
+// shortlog summarises the commits by author, in the style of git shortlog
+func shortlog(commits []*object.Commit) string {
+	byAuthor := make(map[string][]string)
+	for i := len(commits) - 1; i >= 0; i-- {
+		author := commits[i].Author.Name
+		byAuthor[author] = append(byAuthor[author], commitSubject(commits[i]))
+	}
+
+	authors := make([]string, 0, len(byAuthor))
+	for author := range byAuthor {
+		authors = append(authors, author)
+	}
+	sort.Strings(authors)
+
+	var b strings.Builder
+	for _, author := range authors {
+		fmt.Fprintf(&b, "%s (%d):\n", author, len(byAuthor[author]))
+		for _, subject := range byAuthor[author] {
+			fmt.Fprintf(&b, "      %s\n", subject)
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
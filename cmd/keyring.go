@@ -0,0 +1,88 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Where the device flow token is kept between runs, set with auth.storage in the config file
+const (
+	tokenStorageFile    = "file"
+	tokenStorageKeyring = "keyring"
+)
+
+// tokenStorage is where the device flow token is kept: in a file, or the OS keyring
+var tokenStorage string
+
+// keyringService identifies the token's entries in the OS keyring, whose accounts are the
+// Github hosts the tokens are for
+const keyringService = "go-git-release"
+
+// errKeyringNotFound is returned by the keyring functions when there is no entry
+var errKeyringNotFound = errors.New("not found in the keyring")
+
+// keyringTokenStore keeps the token in the OS keyring: the macOS Keychain, the Secret
+// Service on Linux and other unixes, or the Windows Credential Manager. The keyringGet,
+// keyringSet and keyringDelete functions are implemented for each platform.
+type keyringTokenStore struct {
+	service string
+	account string
+}
+
+func (s *keyringTokenStore) load() (*UserAuth, error) {
+	secret, err := keyringGet(s.service, s.account)
+	if errors.Is(err, errKeyringNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var auth UserAuth
+	if err = json.Unmarshal([]byte(secret), &auth); err != nil {
+		return nil, err
+	}
+	if auth.AccessToken == "" {
+		return nil, nil
+	}
+
+	return &auth, nil
+}
+
+func (s *keyringTokenStore) save(auth *UserAuth) error {
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+	return keyringSet(s.service, s.account, string(data))
+}
+
+func (s *keyringTokenStore) clear() error {
+	err := keyringDelete(s.service, s.account)
+	if errors.Is(err, errKeyringNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *keyringTokenStore) String() string {
+	return fmt.Sprintf("the %s keyring entry for %s", s.service, s.account)
+}
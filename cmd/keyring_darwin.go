@@ -0,0 +1,72 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityNotFound is the exit status of the security command when there is no such item
+const securityNotFound = 44
+
+// security runs the macOS security command, returning its output
+func security(args ...string) (string, error) {
+	return securityWithInput("", args...)
+}
+
+// securityWithInput runs the macOS security command with the input on its stdin, so
+// secrets it prompts for are never in its arguments, returning its output
+func securityWithInput(input string, args ...string) (string, error) {
+	cmd := exec.Command("security", args...)
+	cmd.Stdin = strings.NewReader(input)
+	out, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == securityNotFound {
+			return "", errKeyringNotFound
+		}
+		return "", fmt.Errorf("security %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// keyringGet reads the secret from the login Keychain
+var keyringGet = func(service, account string) (string, error) {
+	return security("find-generic-password", "-s", service, "-a", account, "-w")
+}
+
+// keyringSet adds the secret to the login Keychain, updating any existing item. With -w
+// last and no value security prompts for the secret, and for it again to confirm it.
+var keyringSet = func(service, account, secret string) error {
+	_, err := securityWithInput(secret+"\n"+secret+"\n", "add-generic-password", "-U", "-s", service, "-a", account, "-w")
+	return err
+}
+
+// keyringDelete removes the secret from the login Keychain
+var keyringDelete = func(service, account string) error {
+	_, err := security("delete-generic-password", "-s", service, "-a", account)
+	return err
+}
@@ -0,0 +1,71 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretTool runs libsecret's secret-tool command with the input, returning its output
+func secretTool(input string, args ...string) (string, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// lookup exits without output or an error message when there is no such secret
+		if len(out) == 0 && stderr.Len() == 0 {
+			return "", errKeyringNotFound
+		}
+		return "", fmt.Errorf("secret-tool %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// keyringGet reads the secret from the Secret Service, eg: GNOME Keyring or KWallet
+var keyringGet = func(service, account string) (string, error) {
+	return secretTool("", "lookup", "service", service, "account", account)
+}
+
+// keyringSet stores the secret in the Secret Service. It is passed on stdin, so it never
+// appears in the process list.
+var keyringSet = func(service, account, secret string) error {
+	_, err := secretTool(secret, "store", "--label", service+" token", "service", service, "account", account)
+	return err
+}
+
+// keyringDelete removes the secret from the Secret Service
+var keyringDelete = func(service, account string) error {
+	_, err := secretTool("", "clear", "service", service, "account", account)
+	return err
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// fakeKeyring replaces the OS keyring functions with a map, until the returned func is called
+func fakeKeyring() (map[string]string, func()) {
+	get, set, del := keyringGet, keyringSet, keyringDelete
+	secrets := make(map[string]string)
+
+	keyringGet = func(service, account string) (string, error) {
+		s, ok := secrets[service+"/"+account]
+		if !ok {
+			return "", errKeyringNotFound
+		}
+		return s, nil
+	}
+	keyringSet = func(service, account, secret string) error {
+		secrets[service+"/"+account] = secret
+		return nil
+	}
+	keyringDelete = func(service, account string) error {
+		if _, ok := secrets[service+"/"+account]; !ok {
+			return errKeyringNotFound
+		}
+		delete(secrets, service+"/"+account)
+		return nil
+	}
+
+	return secrets, func() { keyringGet, keyringSet, keyringDelete = get, set, del }
+}
+
+// TestKeyringTokenStore checks the token is kept as a keyring secret
+func TestKeyringTokenStore(t *testing.T) {
+	secrets, restore := fakeKeyring()
+	defer restore()
+
+	store := &keyringTokenStore{service: keyringService, account: defaultGithubHost}
+
+	auth, err := store.load()
+	Nil(t, err)
+	Nil(t, auth)
+
+	Nil(t, store.save(&UserAuth{AccessToken: "secret", TokenType: "bearer"}))
+	Contains(t, secrets["go-git-release/github.com"], `"secret"`)
+
+	auth, err = store.load()
+	Nil(t, err)
+	Equal(t, &UserAuth{AccessToken: "secret", TokenType: "bearer"}, auth)
+
+	Nil(t, store.clear())
+	Nil(t, store.clear())
+	auth, err = store.load()
+	Nil(t, err)
+	Nil(t, auth)
+}
+
+// TestNewTokenStore checks auth.storage selects where the token is kept
+func TestNewTokenStore(t *testing.T) {
+	defer func() { cacheToken, tokenStorage = false, "" }()
+	cacheToken = true
+
+	tokenStorage = tokenStorageKeyring
+	store, err := newTokenStore()
+	Nil(t, err)
+	IsType(t, &keyringTokenStore{}, store)
+
+	tokenStorage = tokenStorageFile
+	store, err = newTokenStore()
+	Nil(t, err)
+	IsType(t, &fileTokenStore{}, store)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// downloadLink is a stable link to an asset of the latest release
+type downloadLink struct {
+	name string
+	// platform is empty if the asset is not a binary
+	platform string
+	url      string
+}
+
+// String returns the link as a markdown list item
+func (l downloadLink) String() string {
+	if l.platform == "" {
+		return fmt.Sprintf("* [%s](%s)", l.name, l.url)
+	}
+	return fmt.Sprintf("* [%s](%s) (%s)", l.name, l.url, l.platform)
+}
+
+// releaseLinks are the badge and download links written after a release is published
+type releaseLinks struct {
+	badge     string
+	downloads []downloadLink
+}
+
+// newReleaseLinks builds the shields.io latest version badge and the "latest" download
+// link for each artifact. The download links only stay stable if the artifact names
+// don't include the version.
+func newReleaseLinks(gURL *gitURL, artifacts []*artifact) *releaseLinks {
+	repoPath := gURL.organization + "/" + gURL.repository
+	latestURL := githubWebURL(repoPath + "/releases/latest")
+
+	links := &releaseLinks{
+		badge: fmt.Sprintf("[![Latest release](https://img.shields.io/github/v/release/%s)](%s)", repoPath, latestURL),
+	}
+
+	for _, a := range artifacts {
+		l := downloadLink{
+			name: a.name,
+			url:  latestURL + "/download/" + url.PathEscape(a.name),
+		}
+		if p, ok := binaryPlatform(a.path); ok {
+			l.platform = p.String()
+		}
+		links.downloads = append(links.downloads, l)
+	}
+
+	return links
+}
+
+// markdown renders the badge and download links for docs
+func (l *releaseLinks) markdown() string {
+	var b strings.Builder
+	b.WriteString(l.badge)
+	b.WriteString("\n")
+
+	if len(l.downloads) > 0 {
+		b.WriteString("\n")
+		for _, d := range l.downloads {
+			b.WriteString(d.String())
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// writeLinksFile writes the markdown links to path
+func writeLinksFile(path string, l *releaseLinks) error {
+	return ioutil.WriteFile(path, []byte(l.markdown()), 0644)
+}
+
+// generateLinks adds the links to the summary and writes the links file, if either is
+// enabled. Drafts have no public download links, so none are generated for them.
+func generateLinks(summary *releaseSummary, gURL *gitURL, artifacts []*artifact) error {
+	if !showLinks && linksFile == "" {
+		return nil
+	}
+	if summary.state == "draft" {
+		fmt.Printf("WARNING: %s is a draft, so has no public download links; not generating them\n", summary.tag)
+		return nil
+	}
+
+	links := newReleaseLinks(gURL, artifacts)
+
+	if showLinks {
+		summary.links = links
+	}
+
+	if linksFile != "" {
+		if err := writeLinksFile(linksFile, links); err != nil {
+			return fmt.Errorf("failed writing links file: %w", err)
+		}
+		summary.linksFile = linksFile
+	}
+
+	return nil
+}
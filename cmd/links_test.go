@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestReleaseLinksMarkdown checks the badge and latest download links rendered for docs
+func TestReleaseLinksMarkdown(t *testing.T) {
+	gURL := &gitURL{organization: "clcollins", repository: "go-git-release"}
+	links := newReleaseLinks(gURL, []*artifact{
+		{name: "go-git-release-linux-amd64"},
+		{name: "SHA256 SUMS"},
+	})
+
+	expected := "[![Latest release](https://img.shields.io/github/v/release/clcollins/go-git-release)](https://github.com/clcollins/go-git-release/releases/latest)\n" +
+		"\n" +
+		"* [go-git-release-linux-amd64](https://github.com/clcollins/go-git-release/releases/latest/download/go-git-release-linux-amd64)\n" +
+		"* [SHA256 SUMS](https://github.com/clcollins/go-git-release/releases/latest/download/SHA256%20SUMS)\n"
+
+	Equal(t, expected, links.markdown())
+
+	links.downloads[0].platform = "linux/amd64"
+	Equal(t, "* [go-git-release-linux-amd64](https://github.com/clcollins/go-git-release/releases/latest/download/go-git-release-linux-amd64) (linux/amd64)", links.downloads[0].String())
+}
+
+// TestGenerateLinks checks links are generated once the release is public, but not for drafts
+func TestGenerateLinks(t *testing.T) {
+	defer func(s bool, f string) { showLinks, linksFile = s, f }(showLinks, linksFile)
+	showLinks = true
+
+	gURL := &gitURL{organization: "clcollins", repository: "go-git-release"}
+	generateLinksTests := []struct {
+		state    string
+		expected bool
+	}{
+		{state: "published", expected: true},
+		{state: "prerelease", expected: true},
+		{state: "draft", expected: false},
+	}
+	for _, testSpec := range generateLinksTests {
+		t.Run(testSpec.state, func(t *testing.T) {
+			linksFile = filepath.Join(t.TempDir(), "LINKS.md")
+			summary := &releaseSummary{tag: "v1.0.0", state: testSpec.state}
+
+			Nil(t, generateLinks(summary, gURL, []*artifact{{name: "go-git-release-linux-amd64"}}))
+			Equal(t, testSpec.expected, summary.links != nil)
+			_, err := os.Stat(linksFile)
+			Equal(t, testSpec.expected, err == nil)
+		})
+	}
+}
@@ -18,17 +18,80 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"strings"
+	"unicode"
 )
 
-func makeBuild(tempDir string) error {
+func makeBuild(tempDir string, env []string) error {
 
 	cmd := exec.Command("make", makeTarget)
 	cmd.Dir = tempDir
+	cmd.Env = append(os.Environ(), env...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd.Run()
 }
+
+// runCommand runs a command line, such as "strip --strip-unneeded", with the
+// provided arguments appended to it
+func runCommand(command string, args ...string) error {
+	fields, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("no command provided")
+	}
+
+	cmd := exec.Command(fields[0], append(fields[1:], args...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// splitCommand splits a command line into arguments on whitespace, keeping
+// single or double quoted strings together
+func splitCommand(command string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	var quote rune
+	inField := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", command)
+	}
+
+	if inField {
+		fields = append(fields, current.String())
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// inMemoryClone clones the repository into memory, rather than a temporary directory, for
+// the commands that read its history and files but never build it
+var inMemoryClone bool
+
+// cloneRepoInMemory clones the repository into memory, with an in-memory worktree, so
+// nothing is written to disk and there is nothing to clean up
+func cloneRepoInMemory(url, branch string) (*git.Repository, error) {
+	return cloneWith(url, branch, func(o *git.CloneOptions) (*git.Repository, error) {
+		return git.Clone(memory.NewStorage(), memfs.New(), o)
+	})
+}
+
+// cloneHistory clones the repository for a command that doesn't build it: into memory if
+// inMemory is set, otherwise into a new temporary directory. It returns the directory,
+// which is empty in memory, and a func removing it once the command is done.
+func cloneHistory(url, branch string, inMemory bool) (*git.Repository, string, func(), error) {
+	if inMemory {
+		if verbose {
+			noteInfo(fmt.Sprintf("Cloning %s into memory\n", url))
+		}
+		repo, err := cloneRepoInMemory(url, branch)
+		return repo, "", func() {}, err
+	}
+
+	if verbose {
+		noteInfo("Creating temporary directory")
+	}
+	tempDir, err := createTempDir()
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cannot create temporary directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Cloning %s into %s\n", url, tempDir))
+	}
+	repo, err := cloneRepo(url, tempDir, branch)
+	if err != nil {
+		cleanup()
+		return nil, "", nil, err
+	}
+
+	return repo, tempDir, cleanup, nil
+}
+
+// readWorktreeFile reads the file from the repository's worktree, on disk or in memory
+func readWorktreeFile(repo *git.Repository, path string) ([]byte, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := wt.Filesystem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestReadWorktreeFile checks files are read from an in-memory worktree, as from one on disk
+func TestReadWorktreeFile(t *testing.T) {
+	repo := gitfixture.New(t)
+	repo.Commit("changelog", map[string]string{changelogPath: "## v1.0.0\n"})
+
+	data, err := readWorktreeFile(repo.Repository, changelogPath)
+	Nil(t, err)
+	Equal(t, "## v1.0.0\n", string(data))
+
+	_, err = readWorktreeFile(repo.Repository, "missing.md")
+	True(t, os.IsNotExist(err))
+}
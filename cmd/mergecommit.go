@@ -0,0 +1,52 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+)
+
+// releasePullRequest sets the commitish to the merge commit of the --pr pull request,
+// and the branch to its base branch so the clone has the commit, if no branch is set
+func releasePullRequest(gURL *gitURL) error {
+	auth, err := authenticate()
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	pr, err := getPullRequest(auth, gURL, pullRequestNumber)
+	if err != nil {
+		return fmt.Errorf("failed retrieving pull request #%d: %w", pullRequestNumber, err)
+	}
+
+	// Unmerged pull requests have a test merge commit, which is never on the base branch
+	if !pr.Merged || pr.MergeCommitSHA == "" {
+		return fmt.Errorf("pull request #%d of %s/%s is not merged", pullRequestNumber, gURL.organization, gURL.repository)
+	}
+
+	commitish = pr.MergeCommitSHA
+	if branch == "" {
+		branch = pr.Base.Ref
+	} else if branch != pr.Base.Ref {
+		fmt.Printf("WARNING: pull request #%d was merged into %s, not %s\n", pullRequestNumber, pr.Base.Ref, branch)
+	}
+
+	fmt.Printf("Releasing %s, the merge commit of pull request #%d\n", commitish, pullRequestNumber)
+
+	return nil
+}
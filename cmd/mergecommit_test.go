@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestReleasePullRequest checks the merge commit and base branch of a merged pull request are used
+func TestReleasePullRequest(t *testing.T) {
+	defer gock.Off()
+	defer func(c, b string, n int) { commitish, branch, pullRequestNumber, cachedAuth = c, b, n, nil }(commitish, branch, pullRequestNumber)
+	commitish, branch, pullRequestNumber = "", "", 123
+	cachedAuth = &UserAuth{AccessToken: "secret", TokenType: "token"}
+	gURL := &gitURL{organization: "o", repository: "r"}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/pulls/123").
+		Reply(200).
+		JSON(map[string]interface{}{"number": 123, "merged": false, "merge_commit_sha": "0123456789abcdef0123456789abcdef01234567"})
+	err := releasePullRequest(gURL)
+	if NotNil(t, err) {
+		Equal(t, "pull request #123 of o/r is not merged", err.Error())
+	}
+	Equal(t, "", commitish)
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/pulls/123").
+		MatchHeader("Authorization", "token secret").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"number":           123,
+			"merged":           true,
+			"merge_commit_sha": "0123456789abcdef0123456789abcdef01234567",
+			"base":             map[string]string{"ref": "release-1.x"},
+		})
+	Nil(t, releasePullRequest(gURL))
+	Equal(t, "0123456789abcdef0123456789abcdef01234567", commitish)
+	Equal(t, "release-1.x", branch)
+	True(t, gock.IsDone())
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message cleanup modes, matching git's --cleanup option
+const (
+	// cleanupStrip removes comment lines, trailing whitespace and surplus blank lines
+	cleanupStrip = "strip"
+	// cleanupWhitespace is cleanupStrip, but keeps comment lines
+	cleanupWhitespace = "whitespace"
+	// cleanupVerbatim leaves the message unchanged
+	cleanupVerbatim = "verbatim"
+	// cleanupScissors is cleanupWhitespace, after removing everything from the scissors line on
+	cleanupScissors = "scissors"
+)
+
+// defaultCommentChar is used when core.commentChar is not configured
+const defaultCommentChar = "#"
+
+// autoCommentChars are the candidates, in order, when core.commentChar is "auto"
+const autoCommentChars = "#;@!$%^&|:"
+
+// scissorsLine marks the end of the message in the scissors cleanup mode, after the comment char
+const scissorsLine = " ------------------------ >8 ------------------------"
+
+// validCleanupMode returns true if mode is one of the cleanup modes
+func validCleanupMode(mode string) bool {
+	switch mode {
+	case cleanupStrip, cleanupWhitespace, cleanupVerbatim, cleanupScissors:
+		return true
+	}
+	return false
+}
+
+// resolveCommentChar returns the comment char to use for the message. Like git,
+// "auto" picks the first candidate that doesn't start any line of the message.
+func resolveCommentChar(configured, message string) (string, error) {
+	switch {
+	case configured == "":
+		return defaultCommentChar, nil
+	case configured == "auto":
+		for _, c := range autoCommentChars {
+			if !startsAnyLine(message, string(c)) {
+				return string(c), nil
+			}
+		}
+		return "", fmt.Errorf("unable to select a comment character that is not used in the tag message")
+	case len([]rune(configured)) != 1 || strings.TrimSpace(configured) == "":
+		return "", fmt.Errorf("core.commentChar should only be one character")
+	}
+	return configured, nil
+}
+
+// startsAnyLine returns true if any line of s starts with prefix
+func startsAnyLine(s, prefix string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupMessage cleans up a tag message using the mode, the same as git stripspace
+func cleanupMessage(message, mode, commentChar string) (string, error) {
+	switch mode {
+	case cleanupVerbatim:
+		return message, nil
+	case cleanupStrip:
+		return stripSpace(message, commentChar), nil
+	case cleanupWhitespace:
+		return stripSpace(message, ""), nil
+	case cleanupScissors:
+		return stripSpace(cutAtScissors(message, commentChar), ""), nil
+	}
+	return "", fmt.Errorf("invalid cleanup mode %q", mode)
+}
+
+// cutAtScissors removes the scissors line and everything after it
+func cutAtScissors(message, commentChar string) string {
+	scissors := commentChar + scissorsLine + "\n"
+	if strings.HasPrefix(message, scissors) {
+		return ""
+	}
+	if i := strings.Index(message, "\n"+scissors); i >= 0 {
+		return message[:i+1]
+	}
+	return message
+}
+
+// stripSpace removes trailing whitespace from each line, collapses consecutive blank
+// lines, and removes leading and trailing blank lines. Lines starting with the comment
+// char are removed, unless it is empty. A non-empty result always ends with a newline.
+func stripSpace(message, commentChar string) string {
+	var b strings.Builder
+	blank := 0
+
+	for _, line := range strings.SplitAfter(message, "\n") {
+		if line == "" {
+			continue
+		}
+		if commentChar != "" && strings.HasPrefix(line, commentChar) {
+			continue
+		}
+
+		line = strings.TrimRight(line, " \t\n\v\f\r")
+		if line == "" {
+			blank++
+			continue
+		}
+
+		// Blank lines are only kept between content
+		if blank > 0 && b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		blank = 0
+
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// tagMessagePrompt returns the instructions written below the message in the editor
+func tagMessagePrompt(mode, commentChar string) string {
+	prompt := "\n\n\n" +
+		commentChar + " Please enter the tag message for your annotated tag.\n"
+
+	switch mode {
+	case cleanupStrip:
+		prompt += commentChar + " Lines starting with '" + commentChar + "' will be ignored, and an empty message aborts the tagging.\n"
+	case cleanupScissors:
+		prompt += commentChar + scissorsLine + "\n" +
+			commentChar + " Do not modify or remove the line above.\n" +
+			commentChar + " Everything below it will be ignored.\n"
+	default:
+		prompt += commentChar + " Lines starting with '" + commentChar + "' will be kept; you may remove them yourself if you want to.\n"
+	}
+
+	return prompt
+}
@@ -0,0 +1,75 @@
+//go:build go1.18
+// +build go1.18
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzCleanupMessage checks the invariants of each cleanup mode hold for any message
+func FuzzCleanupMessage(f *testing.F) {
+	f.Add("Subject\n\n# comment\nBody  \n\n\n", "#")
+	f.Add("\n\n;x\n\t\r\n# ------------------------ >8 ------------------------\ntail", ";")
+	f.Add("no newline", "#")
+	f.Add("", "#")
+
+	f.Fuzz(func(t *testing.T, message, commentChar string) {
+		if len([]rune(commentChar)) != 1 || strings.TrimSpace(commentChar) == "" {
+			t.Skip()
+		}
+
+		verbatim, err := cleanupMessage(message, cleanupVerbatim, commentChar)
+		if err != nil || verbatim != message {
+			t.Fatalf("verbatim changed the message: %q", verbatim)
+		}
+
+		for _, mode := range []string{cleanupStrip, cleanupWhitespace, cleanupScissors} {
+			cleaned, err := cleanupMessage(message, mode, commentChar)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			again, _ := cleanupMessage(cleaned, mode, commentChar)
+			if again != cleaned {
+				t.Fatalf("%s cleanup is not idempotent: %q became %q", mode, cleaned, again)
+			}
+
+			if cleaned == "" {
+				continue
+			}
+			if !strings.HasSuffix(cleaned, "\n") {
+				t.Fatalf("%s cleanup missing final newline: %q", mode, cleaned)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(cleaned, "\n"), "\n")
+			if lines[0] == "" || lines[len(lines)-1] == "" {
+				t.Fatalf("%s cleanup left leading or trailing blank lines: %q", mode, cleaned)
+			}
+			for i, line := range lines {
+				if strings.TrimRight(line, " \t\n\v\f\r") != line {
+					t.Fatalf("%s cleanup left trailing whitespace: %q", mode, line)
+				}
+				if i > 0 && line == "" && lines[i-1] == "" {
+					t.Fatalf("%s cleanup left consecutive blank lines: %q", mode, cleaned)
+				}
+				if mode == cleanupStrip && strings.HasPrefix(line, commentChar) {
+					t.Fatalf("strip cleanup left a comment: %q", line)
+				}
+			}
+		}
+
+		// Content lines are kept, in order, by the whitespace cleanup
+		cleaned, _ := cleanupMessage(message, cleanupWhitespace, commentChar)
+		var content []string
+		for _, line := range strings.Split(message, "\n") {
+			if line = strings.TrimRight(line, " \t\n\v\f\r"); line != "" {
+				content = append(content, line)
+			}
+		}
+		if strings.Join(content, "\n") != strings.Replace(strings.TrimSuffix(cleaned, "\n"), "\n\n", "\n", -1) {
+			t.Fatalf("whitespace cleanup lost content: %q became %q", message, cleaned)
+		}
+	})
+}
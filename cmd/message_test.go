@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestCleanupMessage checks each cleanup mode against the output of git stripspace
+func TestCleanupMessage(t *testing.T) {
+	message := "\n\n  \nSubject  \n\n\n# a comment\nBody line\t\n\n; not a comment\n\n\n"
+
+	cleanupTests := []struct {
+		name        string
+		message     string
+		mode        string
+		commentChar string
+		expected    string
+	}{
+		{
+			name:        "strip",
+			message:     message,
+			mode:        cleanupStrip,
+			commentChar: "#",
+			expected:    "Subject\n\nBody line\n\n; not a comment\n",
+		},
+		{
+			name:        "strip with a custom comment char",
+			message:     message,
+			mode:        cleanupStrip,
+			commentChar: ";",
+			expected:    "Subject\n\n# a comment\nBody line\n",
+		},
+		{
+			name:        "whitespace keeps comments",
+			message:     message,
+			mode:        cleanupWhitespace,
+			commentChar: "#",
+			expected:    "Subject\n\n# a comment\nBody line\n\n; not a comment\n",
+		},
+		{
+			name:        "verbatim",
+			message:     message,
+			mode:        cleanupVerbatim,
+			commentChar: "#",
+			expected:    message,
+		},
+		{
+			name:        "scissors",
+			message:     "Subject\n# kept\n# ------------------------ >8 ------------------------\nignored\n",
+			mode:        cleanupScissors,
+			commentChar: "#",
+			expected:    "Subject\n# kept\n",
+		},
+		{
+			name:        "scissors on the first line",
+			message:     "# ------------------------ >8 ------------------------\nignored\n",
+			mode:        cleanupScissors,
+			commentChar: "#",
+			expected:    "",
+		},
+		{
+			name:        "no trailing newline",
+			message:     "Subject",
+			mode:        cleanupStrip,
+			commentChar: "#",
+			expected:    "Subject\n",
+		},
+		{
+			name:        "only comments",
+			message:     "# one\n# two",
+			mode:        cleanupStrip,
+			commentChar: "#",
+			expected:    "",
+		},
+	}
+
+	for _, tt := range cleanupTests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleaned, err := cleanupMessage(tt.message, tt.mode, tt.commentChar)
+			Nil(t, err)
+			Equal(t, tt.expected, cleaned)
+		})
+	}
+
+	_, err := cleanupMessage(message, "default", "#")
+	Error(t, err)
+}
+
+// TestResolveCommentChar checks configured and automatically selected comment chars
+func TestResolveCommentChar(t *testing.T) {
+	commentCharTests := []struct {
+		configured string
+		message    string
+		expected   string
+		err        bool
+	}{
+		{configured: "", expected: "#"},
+		{configured: ";", expected: ";"},
+		{configured: "auto", message: "Subject\n", expected: "#"},
+		{configured: "auto", message: "#1 Subject\n;semi\n", expected: "@"},
+		{configured: "auto", message: "#\n;\n@\n!\n$\n%\n^\n&\n|\n:\n", err: true},
+		{configured: "##", err: true},
+		{configured: " ", err: true},
+	}
+
+	for _, tt := range commentCharTests {
+		t.Run(tt.configured+tt.expected, func(t *testing.T) {
+			commentChar, err := resolveCommentChar(tt.configured, tt.message)
+			if tt.err {
+				Error(t, err)
+				return
+			}
+			Nil(t, err)
+			Equal(t, tt.expected, commentChar)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"text/template"
+)
+
+// mirror is a location release assets are copied to, eg: an S3 bucket or CDN.
+// Mirrors are configured in the config file under "mirrors".
+type mirror struct {
+	// Name identifies the mirror in output
+	Name string `mapstructure:"name"`
+	// URL is a template for the download URL of an asset on the mirror,
+	// and may reference {{ .Tag }} and {{ .Name }}
+	URL string `mapstructure:"url"`
+}
+
+// mirrorVars are the values available to a mirror URL template
+type mirrorVars struct {
+	Tag  string
+	Name string
+}
+
+// assetURL renders the mirror's download URL for the named asset of a release
+func (m mirror) assetURL(tag, name string) (string, error) {
+	tmpl, err := template.New(m.Name).Parse(m.URL)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, mirrorVars{Tag: tag, Name: name}); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
+
+// mirrorResult is the outcome of verifying a single asset on a mirror
+type mirrorResult struct {
+	mirror string
+	asset  string
+	url    string
+	err    error
+}
+
+// String returns the result formatted for output
+func (r mirrorResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("FAIL %s: %s (%s): %s", r.mirror, r.asset, r.url, r.err)
+	}
+	return fmt.Sprintf("OK   %s: %s", r.mirror, r.asset)
+}
+
+// sampleAssets returns up to n randomly selected asset names from the manifest,
+// or all of them, sorted, if n is 0 or larger than the manifest
+func sampleAssets(manifest checksumManifest, n int) []string {
+	names := make([]string, 0, len(manifest))
+	for name := range manifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if n <= 0 || n >= len(names) {
+		return names
+	}
+
+	rand.Shuffle(len(names), func(i, j int) {
+		names[i], names[j] = names[j], names[i]
+	})
+	names = names[:n]
+	sort.Strings(names)
+
+	return names
+}
+
+// verifyMirror downloads the sampled assets from the mirror and compares their
+// digests against the manifest
+func verifyMirror(m mirror, tag string, manifest checksumManifest, sample int) []mirrorResult {
+	var results []mirrorResult
+
+	for _, name := range sampleAssets(manifest, sample) {
+		result := mirrorResult{mirror: m.Name, asset: name}
+
+		result.url, result.err = m.assetURL(tag, name)
+		if result.err == nil {
+			if verbose {
+				noteInfo(fmt.Sprintf("Downloading %s", result.url))
+			}
+
+			var digest string
+			digest, result.err = downloadDigest(result.url, manifest[name])
+			if result.err == nil && digest != manifest[name] {
+				result.err = fmt.Errorf("checksum mismatch: expected %s, got %s", manifest[name], digest)
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
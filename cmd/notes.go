@@ -0,0 +1,275 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// notesSection groups the pull requests with any of the labels under a heading in the
+// generated release notes. Sections are configured in the config file under "notesSections",
+// or read from the repository's .github/release.yml.
+type notesSection struct {
+	Title string `mapstructure:"title"`
+	// Labels are the labels of the pull requests in the section; "*" matches any pull request
+	Labels    []string `mapstructure:"labels"`
+	Collapsed bool     `mapstructure:"collapsed"`
+	// ExcludeLabels and ExcludeAuthors keep matching pull requests out of the section
+	ExcludeLabels  []string `mapstructure:"excludeLabels"`
+	ExcludeAuthors []string `mapstructure:"excludeAuthors"`
+}
+
+// matches returns true if the pull request belongs in the section
+func (s notesSection) matches(pr *pullRequest) bool {
+	if pr.hasLabel(s.ExcludeLabels) || pr.byAuthor(s.ExcludeAuthors) {
+		return false
+	}
+	for _, l := range s.Labels {
+		if l == "*" {
+			return true
+		}
+	}
+	return pr.hasLabel(s.Labels)
+}
+
+// defaultNotesSections are used when no sections are configured
+var defaultNotesSections = []notesSection{
+	{Title: "Features", Labels: []string{"enhancement", "feature"}},
+	{Title: "Fixes", Labels: []string{"bug", "fix"}},
+	{Title: "Dependencies", Labels: []string{"dependencies"}, Collapsed: true},
+}
+
+// otherNotesSection is the heading of the pull requests that don't match any section
+const otherNotesSection = "Other changes"
+
+// pullRequest is the subset of a Github pull request response we use
+type pullRequest struct {
+	Number  int     `json:"number"`
+	Title   string  `json:"title"`
+	HTMLURL string  `json:"html_url"`
+	Labels  []label `json:"labels"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	// Merged and MergeCommitSHA are the pull request's merge, as it was merged into the
+	// base branch, or its squash or rebase commit
+	Merged         bool   `json:"merged"`
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	Base           struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// label is a Github issue or pull request label
+type label struct {
+	Name string `json:"name"`
+}
+
+// hasLabel returns true if the pull request has any of the labels
+func (pr *pullRequest) hasLabel(labels []string) bool {
+	for _, l := range pr.Labels {
+		for _, name := range labels {
+			if strings.EqualFold(l.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// byAuthor returns true if the pull request was opened by any of the authors
+func (pr *pullRequest) byAuthor(authors []string) bool {
+	for _, a := range authors {
+		if strings.EqualFold(pr.User.Login, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// pullRequestExpression matches the pull request number in merge commit subjects,
+// eg: "Merge pull request #12 from ..." or squash merges "Fix the thing (#12)"
+var pullRequestExpression = regexp.MustCompile(`(?:^Merge pull request #(\d+)|\(#(\d+)\)$)`)
+
+// pullRequestNumbers returns the unique pull request numbers referenced by the
+// commit subjects, in the order the commits are provided
+func pullRequestNumbers(commits []*object.Commit) []int {
+	var numbers []int
+	seen := make(map[int]bool)
+
+	for _, c := range commits {
+		matches := pullRequestExpression.FindStringSubmatch(commitSubject(c))
+		if matches == nil {
+			continue
+		}
+
+		digits := matches[1]
+		if digits == "" {
+			digits = matches[2]
+		}
+
+		n, err := strconv.Atoi(digits)
+		if err != nil || seen[n] {
+			continue
+		}
+
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+
+	return numbers
+}
+
+// getPullRequest retrieves a pull request by number
+func getPullRequest(auth *UserAuth, gURL *gitURL, number int) (*pullRequest, error) {
+	req, err := newGetRequest(githubRepoURL(gURL, fmt.Sprintf("pulls/%d", number)), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var pr pullRequest
+	if err = json.Unmarshal(body, &pr); err != nil {
+		return nil, err
+	}
+
+	return &pr, nil
+}
+
+// renderNotes groups the pull requests into the sections, in the order the sections
+// are listed, and renders them as markdown. Pull requests with an excluded label or
+// author are left out, and those matching no section are listed under otherNotesSection.
+func renderNotes(prs []*pullRequest, sections []notesSection, excludeLabels, excludeAuthors []string) string {
+	grouped := make([][]*pullRequest, len(sections))
+	var other []*pullRequest
+
+	for _, pr := range prs {
+		if pr.hasLabel(excludeLabels) || pr.byAuthor(excludeAuthors) {
+			continue
+		}
+
+		matched := false
+		for i, s := range sections {
+			if s.matches(pr) {
+				grouped[i] = append(grouped[i], pr)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			other = append(other, pr)
+		}
+	}
+
+	var b strings.Builder
+	for i, s := range sections {
+		writeNotesSection(&b, s, grouped[i])
+	}
+	writeNotesSection(&b, notesSection{Title: otherNotesSection}, other)
+
+	return strings.TrimSpace(b.String())
+}
+
+// writeNotesSection writes a section of the release notes, if it has any pull requests
+func writeNotesSection(b *strings.Builder, s notesSection, prs []*pullRequest) {
+	if len(prs) == 0 {
+		return
+	}
+
+	if s.Collapsed {
+		fmt.Fprintf(b, "<details>\n<summary>%s</summary>\n\n", s.Title)
+	} else {
+		fmt.Fprintf(b, "## %s\n\n", s.Title)
+	}
+
+	for _, pr := range prs {
+		fmt.Fprintf(b, "* %s ([#%d](%s)) @%s\n", pr.Title, pr.Number, pr.HTMLURL, pr.User.Login)
+	}
+
+	if s.Collapsed {
+		b.WriteString("\n</details>\n")
+	}
+	b.WriteString("\n")
+}
+
+// generateNotesFromPullRequests renders release notes from the pull requests merged
+// between the previous tag, or --since, and the checked out commit
+func generateNotesFromPullRequests(auth *UserAuth, gURL *gitURL, repo *git.Repository, tag string) (string, error) {
+	head, err := headCommit(repo)
+	if err != nil {
+		return "", err
+	}
+
+	prevTag, prevCommit, err := notesBase(repo, head, tag)
+	if err != nil {
+		return "", err
+	}
+
+	if verbose {
+		if prevTag == "" {
+			noteInfo("Generating release notes from all pull requests")
+		} else {
+			noteInfo(fmt.Sprintf("Generating release notes from pull requests since %s", prevTag))
+		}
+	}
+
+	prs, err := notesPullRequests(auth, gURL, repo, prevCommit, head)
+	if err != nil {
+		return "", err
+	}
+
+	// The notes configuration Github uses for its own generated notes is honored too, so
+	// both agree; sections in the config file take precedence over its categories
+	sections := notesSections
+	excludeLabels := append([]string{}, notesExcludeLabels...)
+	var excludeAuthors []string
+
+	ghConfig, err := readGithubReleaseConfig(repo)
+	if err != nil {
+		return "", fmt.Errorf("failed reading Github release notes configuration: %w", err)
+	}
+	if ghConfig != nil {
+		if len(sections) == 0 {
+			sections = ghConfig.sections()
+		}
+		excludeLabels = append(excludeLabels, ghConfig.Changelog.Exclude.Labels...)
+		excludeAuthors = ghConfig.Changelog.Exclude.Authors
+	}
+
+	if len(sections) == 0 {
+		sections = defaultNotesSections
+	}
+
+	return renderNotes(prs, sections, excludeLabels, excludeAuthors), nil
+}
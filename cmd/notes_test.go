@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestPullRequestNumbers checks pull request numbers are found in merge and squash commit subjects
+func TestPullRequestNumbers(t *testing.T) {
+	commits := []*object.Commit{
+		{Message: "Merge pull request #12 from someone/branch\n\nAdd a feature"},
+		{Message: "Fix a bug (#15)"},
+		{Message: "Fix a bug (#15)"},
+		{Message: "Regular commit mentioning #20"},
+	}
+
+	Equal(t, []int{12, 15}, pullRequestNumbers(commits))
+}
+
+// TestRenderNotes checks pull requests are grouped by label into sections
+func TestRenderNotes(t *testing.T) {
+	newPR := func(number int, title string, labels ...string) *pullRequest {
+		pr := &pullRequest{Number: number, Title: title, HTMLURL: "https://example.org/pr"}
+		pr.User.Login = "someone"
+		for _, l := range labels {
+			pr.Labels = append(pr.Labels, label{Name: l})
+		}
+		return pr
+	}
+
+	prs := []*pullRequest{
+		newPR(1, "Add a feature", "enhancement"),
+		newPR(2, "Fix a bug", "bug"),
+		newPR(3, "Bump a dependency", "dependencies"),
+		newPR(4, "Update CI", "skip-changelog"),
+		newPR(5, "Tidy up"),
+	}
+
+	expected := "## Features\n\n" +
+		"* Add a feature ([#1](https://example.org/pr)) @someone\n\n" +
+		"## Fixes\n\n" +
+		"* Fix a bug ([#2](https://example.org/pr)) @someone\n\n" +
+		"<details>\n<summary>Dependencies</summary>\n\n" +
+		"* Bump a dependency ([#3](https://example.org/pr)) @someone\n\n" +
+		"</details>\n\n" +
+		"## Other changes\n\n" +
+		"* Tidy up ([#5](https://example.org/pr)) @someone"
+
+	Equal(t, expected, renderNotes(prs, defaultNotesSections, []string{"skip-changelog"}, nil))
+}
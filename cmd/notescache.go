@@ -0,0 +1,209 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// notesCache keeps the pull requests found for the generated release notes between runs,
+// so later runs only walk the commits made since
+var notesCache bool
+
+// notesSince is the tag or commit the release notes are generated since, rather than
+// the previous tag
+var notesSince string
+
+// notesCachePath returns the path of the notes cache file in the user's cache directory
+// for the repository and the hash of the commit the notes are generated since, so notes
+// for different ranges are cached separately
+var notesCachePath = func(gURL *gitURL, since string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	if since == "" {
+		since = "all"
+	}
+	return filepath.Join(dir, "go-git-release", "notes", releaseCacheKey(gURL)+"-"+since+".json"), nil
+}
+
+// notesCacheEntry is the last processed commit of a repository, and the pull requests
+// merged between the commit the notes were generated since and it
+type notesCacheEntry struct {
+	// Since is the hash of the commit the notes were generated since, empty for the
+	// whole history
+	Since        string         `json:"since"`
+	Commit       string         `json:"commit"`
+	PullRequests []*pullRequest `json:"pullRequests"`
+}
+
+// loadNotesCache returns the repository's notes cache entry for the notes since the
+// commit, or nil if there isn't one
+func loadNotesCache(gURL *gitURL, since string) (*notesCacheEntry, error) {
+	path, err := notesCachePath(gURL, since)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry notesCacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid notes cache %s: %w", path, err)
+	}
+
+	return &entry, nil
+}
+
+// saveNotesCache replaces the repository's notes cache entry for the entry's since commit
+func saveNotesCache(gURL *gitURL, entry *notesCacheEntry) error {
+	path, err := notesCachePath(gURL, entry.Since)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// notesBase returns the tag or commit the release notes are generated since, and the
+// commit it points at: --since if set, or else the tag before the release
+func notesBase(repo *git.Repository, head *object.Commit, tag string) (string, *object.Commit, error) {
+	if notesSince == "" {
+		return changelogBase(repo, head, tag)
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(notesSince))
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot find --since %s: %w", notesSince, err)
+	}
+
+	c, err := commitForHash(repo, *hash)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot find --since %s: %w", notesSince, err)
+	}
+
+	return notesSince, c, nil
+}
+
+// cachedNotesCommits returns the commits made since the notes cache's last processed
+// commit, and the pull requests it found, if it is for the same since commit. It misses
+// if that commit is no longer in the history, eg: after a force push.
+func cachedNotesCommits(repo *git.Repository, gURL *gitURL, since string, head *object.Commit) ([]*object.Commit, []*pullRequest, bool) {
+	entry, err := loadNotesCache(gURL, since)
+	if err != nil {
+		fmt.Printf("WARNING: cannot read the notes cache: %s\n", err)
+		return nil, nil, false
+	}
+	if entry == nil || entry.Since != since {
+		return nil, nil, false
+	}
+
+	last, err := repo.CommitObject(plumbing.NewHash(entry.Commit))
+	if err != nil {
+		return nil, nil, false
+	}
+
+	commits, reached, err := walkCommits(last, head)
+	if err != nil || !reached {
+		return nil, nil, false
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Reusing the notes cache, walking the %d commits since %s", len(commits), last.Hash.String()[:7]))
+	}
+
+	return commits, entry.PullRequests, true
+}
+
+// notesPullRequests returns the pull requests merged between the since and head commits,
+// newest first. With the notes cache, the pull requests found by the last run are reused
+// and only the commits after its last processed commit are walked. Cassettes are recorded
+// and replayed without the cache, so the requests are the same every time.
+func notesPullRequests(auth *UserAuth, gURL *gitURL, repo *git.Repository, since, head *object.Commit) ([]*pullRequest, error) {
+	useCache := notesCache && recordHTTP == "" && replayHTTP == ""
+
+	var sinceHash string
+	if since != nil {
+		sinceHash = since.Hash.String()
+	}
+
+	var commits []*object.Commit
+	var cached []*pullRequest
+	hit := false
+	if useCache {
+		commits, cached, hit = cachedNotesCommits(repo, gURL, sinceHash, head)
+	}
+	if !hit {
+		var err error
+		commits, err = commitsBetween(repo, since, head)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	known := make(map[int]bool)
+	for _, pr := range cached {
+		known[pr.Number] = true
+	}
+
+	var prs []*pullRequest
+	for _, n := range pullRequestNumbers(commits) {
+		if known[n] {
+			continue
+		}
+		pr, err := getPullRequest(auth, gURL, n)
+		if err != nil {
+			return nil, fmt.Errorf("failed retrieving pull request #%d: %w", n, err)
+		}
+		prs = append(prs, pr)
+	}
+	prs = append(prs, cached...)
+
+	if useCache {
+		entry := &notesCacheEntry{Since: sinceHash, Commit: head.Hash.String(), PullRequests: prs}
+		if err := saveNotesCache(gURL, entry); err != nil {
+			fmt.Printf("WARNING: cannot write the notes cache: %s\n", err)
+		}
+	}
+
+	return prs, nil
+}
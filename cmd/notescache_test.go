@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// subjects returns the commits' subjects, in order
+func subjects(commits []*object.Commit) []string {
+	var s []string
+	for _, c := range commits {
+		s = append(s, commitSubject(c))
+	}
+	return s
+}
+
+// TestWalkCommits checks branches merged since are walked, but not the history before
+func TestWalkCommits(t *testing.T) {
+	repo := gitfixture.New(t)
+
+	root := repo.CommitWith("root")
+	tagged := repo.CommitWith("tagged", root)
+	branch := repo.CommitWith("branch", root)
+	main := repo.CommitWith("main", tagged)
+	merge := repo.CommitWith("Merge pull request #2", main, branch)
+
+	commits, reached, err := walkCommits(tagged, merge)
+	Nil(t, err)
+	True(t, reached)
+	Equal(t, []string{"Merge pull request #2", "main", "branch"}, subjects(commits))
+
+	commits, reached, err = walkCommits(merge, merge)
+	Nil(t, err)
+	True(t, reached)
+	Empty(t, commits)
+
+	// The branch isn't an ancestor of main, so everything on main is new to it
+	commits, reached, err = walkCommits(branch, main)
+	Nil(t, err)
+	False(t, reached)
+	Equal(t, []string{"main", "tagged"}, subjects(commits))
+
+	commits, reached, err = walkCommits(nil, main)
+	Nil(t, err)
+	True(t, reached)
+	Equal(t, []string{"main", "tagged", "root"}, subjects(commits))
+}
+
+// TestChangelogBase checks releases from the default branch list their changes since the
+// previous tag on it, not one on a branch merged into it
+func TestChangelogBase(t *testing.T) {
+	defer func(b string) { defaultBranch = b }(defaultBranch)
+
+	repo := gitfixture.New(t)
+	root := repo.CommitWith("root")
+	feature := repo.CommitWith("feature", root)
+	repo.Tag("v1.1.0-beta.1", feature)
+	main := repo.CommitWith("main", root)
+	merge := repo.CommitWith("Merge pull request #4", main, feature)
+	repo.Tag("v1.1.0", merge)
+
+	changelogBaseTests := []struct {
+		name          string
+		defaultBranch string
+		expected      string
+	}{
+		{name: "any branch", expected: "v1.1.0-beta.1"},
+		{name: "default branch", defaultBranch: "main", expected: ""},
+	}
+	for _, testSpec := range changelogBaseTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			defaultBranch = testSpec.defaultBranch
+
+			previous, _, err := changelogBase(repo.Repository, merge, "v1.1.0")
+			Nil(t, err)
+			Equal(t, testSpec.expected, previous)
+		})
+	}
+}
+
+// TestWalkCommitsClockSkew checks the history of since is excluded even when its commit
+// dates are out of order, eg: since was committed on a clock running behind
+func TestWalkCommitsClockSkew(t *testing.T) {
+	repo := gitfixture.New(t)
+
+	root := repo.CommitWith("root")
+	since := repo.CommitAt("since", root.Committer.When.Add(-time.Hour), root)
+	feature := repo.CommitWith("feature", root)
+	merge := repo.CommitWith("Merge pull request #3", since, feature)
+
+	commits, reached, err := walkCommits(since, merge)
+	Nil(t, err)
+	True(t, reached)
+	Equal(t, []string{"Merge pull request #3", "feature"}, subjects(commits))
+}
+
+// TestNotesPullRequests checks the cached pull requests are reused, and only the pull
+// requests merged since the last processed commit are retrieved
+func TestNotesPullRequests(t *testing.T) {
+	defer gock.Off()
+	defer func(cache bool) { notesCache = cache }(notesCache)
+	notesCache = true
+
+	dir, err := ioutil.TempDir("", "notes")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(f func(*gitURL, string) (string, error)) { notesCachePath = f }(notesCachePath)
+	notesCachePath = func(_ *gitURL, since string) (string, error) { return filepath.Join(dir, since+".json"), nil }
+
+	repo := gitfixture.New(t)
+	tagged := repo.CommitWith("v1.0.0")
+	first := repo.CommitWith("Add a flag (#1)", tagged)
+
+	gURL := &gitURL{organization: "o", repository: "r"}
+	auth := &UserAuth{AccessToken: "secret", TokenType: "token"}
+	for _, n := range []int{1, 2} {
+		gock.New("https://api.github.com").
+			Get(fmt.Sprintf("/repos/o/r/pulls/%d", n)).
+			Times(1).
+			Reply(200).
+			JSON(map[string]interface{}{"number": n, "title": fmt.Sprintf("PR %d", n)})
+	}
+
+	prs, err := notesPullRequests(auth, gURL, repo.Repository, tagged, first)
+	Nil(t, err)
+	Len(t, prs, 1)
+
+	// Pull request #1 comes from the cache, so is only retrieved once
+	second := repo.CommitWith("Fix the flag (#2)", first)
+	prs, err = notesPullRequests(auth, gURL, repo.Repository, tagged, second)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, 2, prs[0].Number)
+	Equal(t, 1, prs[1].Number)
+	True(t, gock.IsDone())
+
+	entry, err := loadNotesCache(gURL, tagged.Hash.String())
+	Nil(t, err)
+	Equal(t, &notesCacheEntry{Since: tagged.Hash.String(), Commit: second.Hash.String(), PullRequests: prs}, entry)
+
+	// Notes since another commit don't reuse them, or replace them
+	_, _, hit := cachedNotesCommits(repo.Repository, gURL, first.Hash.String(), second)
+	False(t, hit)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/pulls/2").
+		Reply(200).
+		JSON(map[string]interface{}{"number": 2, "title": "PR 2"})
+	prs, err = notesPullRequests(auth, gURL, repo.Repository, first, second)
+	Nil(t, err)
+	Len(t, prs, 1)
+	_, _, hit = cachedNotesCommits(repo.Repository, gURL, tagged.Hash.String(), second)
+	True(t, hit)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// notesFile is the file the release notes are read from instead of the tag message, or
+// "-" for stdin
+var notesFile string
+
+// stdinNotesFile is the notesFile reading the release notes from stdin
+const stdinNotesFile = "-"
+
+// readNotesFile returns the release notes in the file, or stdin for "-", or "" if no file
+// is provided. Notes that are empty are an error, rather than a release with no notes.
+func readNotesFile(path string, stdin io.Reader) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	var data []byte
+	var err error
+	if path == stdinNotesFile {
+		data, err = ioutil.ReadAll(stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed reading release notes: %w", err)
+	}
+
+	notes := strings.TrimSpace(string(data))
+	if notes == "" {
+		return "", fmt.Errorf("the release notes in %s are empty", notesFileName(path))
+	}
+
+	return notes, nil
+}
+
+// notesFileName returns the name of the notes file for messages
+func notesFileName(path string) string {
+	if path == stdinNotesFile {
+		return "stdin"
+	}
+	return path
+}
+
+// validateNotesFile checks the notes file exists, before anything is cloned or built. The
+// notes are only read from stdin with --force, or in non-interactive mode, as the prompts
+// would have nothing left to read their answers from.
+func validateNotesFile(path string) error {
+	if path == stdinNotesFile && !force && !nonInteractiveMode() {
+		return fmt.Errorf("the release notes can only be read from stdin with --force, as the prompts read their answers from it")
+	}
+	if path == "" || path == stdinNotesFile {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestReadNotesFile checks the notes are read from the file or stdin, and empty notes are rejected
+func TestReadNotesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notes")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "NOTES.md")
+	Nil(t, ioutil.WriteFile(path, []byte("## Changes\n\n* Fixed it\n\n"), 0644))
+
+	notes, err := readNotesFile(path, strings.NewReader("ignored"))
+	Nil(t, err)
+	Equal(t, "## Changes\n\n* Fixed it", notes)
+
+	notes, err = readNotesFile("-", strings.NewReader("Piped notes\n"))
+	Nil(t, err)
+	Equal(t, "Piped notes", notes)
+
+	notes, err = readNotesFile("", strings.NewReader("ignored"))
+	Nil(t, err)
+	Equal(t, "", notes)
+
+	_, err = readNotesFile("-", strings.NewReader("\n"))
+	EqualError(t, err, "the release notes in stdin are empty")
+
+	_, err = readNotesFile(filepath.Join(dir, "missing.md"), nil)
+	NotNil(t, err)
+}
+
+// TestValidateNotesFile checks missing notes files, and notes on stdin the prompts need, are
+// found before the release starts
+func TestValidateNotesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notes")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "NOTES.md")
+	Nil(t, ioutil.WriteFile(path, []byte("notes"), 0644))
+
+	defer func(f, n bool) { force, nonInteractive = f, n }(force, nonInteractive)
+	force, nonInteractive = false, false
+
+	Nil(t, validateNotesFile(""))
+	NotNil(t, validateNotesFile("-"), "the prompts need stdin")
+	force = true
+	Nil(t, validateNotesFile("-"))
+	Nil(t, validateNotesFile(path))
+	NotNil(t, validateNotesFile(dir))
+	NotNil(t, validateNotesFile(filepath.Join(dir, "missing.md")))
+}
@@ -0,0 +1,306 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var changelogPath string
+var syncNotesDryRun bool
+
+// syncNotesCmd updates the notes of releases that have drifted from the CHANGELOG
+var syncNotesCmd = &cobra.Command{
+	Use:   "sync-notes",
+	Short: "Update release notes that differ from the CHANGELOG",
+	Long: `sync-notes compares the notes of each release with the section for its version in the CHANGELOG of the
+repository's default branch, and updates the releases whose notes have drifted, so both stay consistent. The
+differences are shown before anything is changed; with --dryRun, nothing is, and drafts aren't compared. Only the
+notes section of a release is replaced, leaving the rest of its body, eg: the security fixes and the CI footer;
+the whole body of a release made without the section's markers is. Releases with no CHANGELOG section are left
+as they are.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return syncNotes()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncNotesCmd)
+
+	syncNotesCmd.Flags().StringVar(&changelogPath, "changelog", "CHANGELOG.md", "path of the changelog, relative to the repository root")
+	syncNotesCmd.Flags().BoolVar(&syncNotesDryRun, "dryRun", false, "show the differences without updating any release")
+}
+
+// changelogHeading matches a markdown heading for a version, eg: "## [1.2.0] - 2020-06-01" or "# v1.2.0"
+var changelogHeading = regexp.MustCompile(`^(#{1,6})\s+\[?(v?\d+\.\d+\.\d+(?:-[0-9A-Za-z\-\.]+)?(?:\+[0-9A-Za-z\-\.]+)?)\]?`)
+
+// anyHeading matches any markdown heading, capturing its level
+var anyHeading = regexp.MustCompile(`^(#{1,6})\s`)
+
+// linkReference matches a markdown link reference definition, eg: "[1.2.0]: https://..."
+var linkReference = regexp.MustCompile(`^\[[^\]]+\]:\s*\S+`)
+
+// changelogKey is the key of a version's changelog section, ignoring the "v" prefix and
+// build metadata, so v1.2.0 and 1.2.0 match
+func changelogKey(v *version) string {
+	if v.prerelease != "" {
+		return v.core() + "-" + v.prerelease
+	}
+	return v.core()
+}
+
+// parseChangelog returns the sections of a markdown changelog, keyed by changelogKey. A
+// section runs from its version heading to the next heading of the same or a higher
+// level; link reference definitions are left out.
+func parseChangelog(text string) map[string]string {
+	sections := make(map[string]string)
+
+	var key string
+	var level int
+	var body []string
+	flush := func() {
+		if key != "" {
+			sections[key] = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		key, body = "", nil
+	}
+
+	for _, line := range strings.Split(normalizeNotes(text), "\n") {
+		if m := anyHeading.FindStringSubmatch(line); m != nil && (key == "" || len(m[1]) <= level) {
+			flush()
+			if m := changelogHeading.FindStringSubmatch(line); m != nil {
+				if v, err := parseVersion(m[2]); err == nil {
+					key, level = changelogKey(v), len(m[1])
+				}
+			}
+			continue
+		}
+
+		if key != "" && !linkReference.MatchString(line) {
+			body = append(body, line)
+		}
+	}
+	flush()
+
+	return sections
+}
+
+// The markers around the notes section of a release body, which sync-notes replaces,
+// leaving the rest, eg: the security fixes and the CI footer, as it is
+const (
+	notesStartMarker = "<!-- go-git-release notes -->"
+	notesEndMarker   = "<!-- go-git-release notes end -->"
+)
+
+// markNotes wraps the notes in the markers of the section sync-notes replaces
+func markNotes(notes string) string {
+	return notesStartMarker + "\n" + strings.TrimSpace(notes) + "\n" + notesEndMarker
+}
+
+// splitNotes returns the parts of the body before, within and after its notes section
+// markers. A body without them, from a release made before they were added, is all notes.
+func splitNotes(body string) (string, string, string) {
+	start := strings.Index(body, notesStartMarker)
+	end := strings.Index(body, notesEndMarker)
+	if start < 0 || end < start {
+		return "", body, ""
+	}
+	return body[:start], body[start+len(notesStartMarker) : end], body[end+len(notesEndMarker):]
+}
+
+// normalizeNotes returns the notes with unix line endings and no surrounding whitespace,
+// as Github may return the notes with CRLF line endings
+func normalizeNotes(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+// lineDiff returns the lines of from and to, prefixed with "-" if only in from, "+" if
+// only in to, or " " if in both
+func lineDiff(from, to string) []string {
+	a, b := strings.Split(from, "\n"), strings.Split(to, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+
+	return diff
+}
+
+// notesDrift is a release whose notes differ from its changelog section
+type notesDrift struct {
+	release *release
+	tag     string
+	body    string
+	diff    []string
+}
+
+// driftedReleases returns the releases whose notes differ from their changelog sections
+func driftedReleases(releasesList *releases, sections map[string]string) []notesDrift {
+	var drifted []notesDrift
+	for i, r := range *releasesList {
+		if r.TagName == nil || r.ID == nil {
+			continue
+		}
+		v, err := parseVersion(*r.TagName)
+		if err != nil {
+			continue
+		}
+		section, ok := sections[changelogKey(v)]
+		if !ok {
+			if verbose {
+				noteInfo(fmt.Sprintf("No CHANGELOG section for %s", *r.TagName))
+			}
+			continue
+		}
+
+		var body string
+		if r.Body != nil {
+			body = normalizeNotes(*r.Body)
+		}
+		before, notes, after := splitNotes(body)
+		notes = normalizeNotes(notes)
+		if notes == section {
+			continue
+		}
+
+		drifted = append(drifted, notesDrift{
+			release: &(*releasesList)[i],
+			tag:     *r.TagName,
+			body:    before + markNotes(section) + after,
+			diff:    lineDiff(notes, section),
+		})
+	}
+	return drifted
+}
+
+func syncNotes() error {
+	p, err := newPipeline("")
+	if err != nil {
+		return err
+	}
+	if _, ok := providerFor(p.releaseRepo).(*githubProvider); !ok {
+		return fmt.Errorf("sync-notes only supports Github releases")
+	}
+
+	// The CHANGELOG is maintained on the default branch, or --branch
+	repo, _, cleanup, err := cloneHistory(p.gURL.raw, cloneBranch(p.gURL), inMemoryClone)
+	if err != nil {
+		return stageFailed(errClone, err)
+	}
+	defer cleanup()
+
+	data, err := readWorktreeFile(repo, changelogPath)
+	if err != nil {
+		return fmt.Errorf("failed reading changelog: %w", err)
+	}
+	sections := parseChangelog(string(data))
+
+	// Updating needs authorization, and drafts are only listed for an authenticated user,
+	// so a dry run, which doesn't authenticate, leaves them out
+	var auth *UserAuth
+	var list *releases
+	if syncNotesDryRun {
+		list, err = getReleases(p.releaseRepo)
+	} else {
+		auth, err = authenticate()
+		if err != nil {
+			return stageFailed(errAuth, err)
+		}
+		var all []release
+		all, err = listReleases(auth, p.releaseRepo)
+		list = (*releases)(&all)
+	}
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	drifted := driftedReleases(list, sections)
+	if len(drifted) == 0 {
+		fmt.Printf("The notes of every release match %s\n", changelogPath)
+		return nil
+	}
+
+	for _, d := range drifted {
+		fmt.Printf("--- %s release notes\n+++ %s section\n", d.tag, changelogPath)
+		for _, line := range d.diff {
+			fmt.Println(line)
+		}
+		fmt.Println()
+	}
+
+	if syncNotesDryRun {
+		fmt.Printf("%d releases differ from %s\n", len(drifted), changelogPath)
+		return nil
+	}
+
+	if !confirm(fmt.Sprintf("Update the notes of %d releases?", len(drifted))) {
+		return fmt.Errorf("sync-notes %w", errHalted)
+	}
+
+	for _, d := range drifted {
+		body := d.body
+		_, err = updateRelease(auth, p.releaseRepo, *d.release.ID, &releaseUpdateRequest{Body: &body})
+		if err != nil {
+			return fmt.Errorf("failed updating the notes of %s: %w", d.tag, err)
+		}
+		fmt.Printf("Updated the notes of %s\n", d.tag)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+const testChangelog = `# Changelog
+
+## [Unreleased]
+
+- Not released yet
+
+## [1.2.0] - 2020-06-01
+
+### Added
+
+- A feature
+
+## v1.1.0-rc.1
+
+- A fix
+
+[1.2.0]: https://github.com/example/project/compare/v1.1.0...v1.2.0
+`
+
+// TestParseChangelog checks each version's section is found, without its link references
+func TestParseChangelog(t *testing.T) {
+	Equal(t, map[string]string{
+		"1.2.0":      "### Added\n\n- A feature",
+		"1.1.0-rc.1": "- A fix",
+	}, parseChangelog(testChangelog))
+}
+
+// TestLineDiff checks the diff marks removed, added and unchanged lines
+func TestLineDiff(t *testing.T) {
+	Equal(t, []string{" a", "-b", "+B", " c", "+d"}, lineDiff("a\nb\nc", "a\nB\nc\nd"))
+	Equal(t, []string{" same"}, lineDiff("same", "same"))
+}
+
+// TestDriftedReleases checks only releases whose notes differ from their section are updated
+func TestDriftedReleases(t *testing.T) {
+	sections := parseChangelog(testChangelog)
+
+	matching, drifted, missing := "v1.2.0", "1.1.0-rc.1", "v1.0.0"
+	matchingBody, driftedBody := "### Added\r\n\r\n- A feature\r\n", "- A fix, maybe"
+	ids := []int{1, 2, 3}
+
+	result := driftedReleases(&releases{
+		{TagName: &matching, Body: &matchingBody, ID: &ids[0]},
+		{TagName: &drifted, Body: &driftedBody, ID: &ids[1]},
+		{TagName: &missing, ID: &ids[2]},
+	}, sections)
+
+	if Len(t, result, 1) {
+		Equal(t, "1.1.0-rc.1", result[0].tag)
+		Equal(t, markNotes("- A fix"), result[0].body)
+		Equal(t, 2, *result[0].release.ID)
+		Equal(t, []string{"-- A fix, maybe", "+- A fix"}, result[0].diff)
+	}
+}
+
+// TestDriftedReleasesMarkedNotes checks only the marked notes section is compared and
+// replaced, leaving the rest of the body
+func TestDriftedReleasesMarkedNotes(t *testing.T) {
+	sections := parseChangelog(testChangelog)
+
+	matching, drifted := "v1.2.0", "1.1.0-rc.1"
+	matchingBody := markNotes("### Added\n\n- A feature") + "\n\n---\nBuilt by CI"
+	driftedBody := "Intro\n\n" + markNotes("- A fix, maybe") + "\n\n---\nBuilt by CI"
+	ids := []int{1, 2}
+
+	result := driftedReleases(&releases{
+		{TagName: &matching, Body: &matchingBody, ID: &ids[0]},
+		{TagName: &drifted, Body: &driftedBody, ID: &ids[1]},
+	}, sections)
+
+	if Len(t, result, 1) {
+		Equal(t, "Intro\n\n"+markNotes("- A fix")+"\n\n---\nBuilt by CI", result[0].body)
+		Equal(t, []string{"-- A fix, maybe", "+- A fix"}, result[0].diff)
+	}
+}
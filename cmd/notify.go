@@ -0,0 +1,140 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// issue is the subset of a Github issue or issue comment response we use
+type issue struct {
+	Number  *int    `json:"number,omitempty"`
+	HTMLURL *string `json:"html_url,omitempty"`
+}
+
+// notificationRequest is the payload for creating an issue or an issue comment
+type notificationRequest struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body"`
+}
+
+// releaseOwners returns the configured notifyTeams plus, if notifyCodeowners is set,
+// the CODEOWNERS of the paths changed since the previous release
+func releaseOwners(repo *git.Repository, dir string, previous *release) ([]string, error) {
+	mentions := append([]string{}, notifyTeams...)
+
+	if !notifyCodeowners {
+		return mentions, nil
+	}
+
+	if previous == nil || previous.TagName == nil {
+		if verbose {
+			noteInfo("No previous release; skipping CODEOWNERS notification")
+		}
+		return mentions, nil
+	}
+
+	rules, err := readCodeowners(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading CODEOWNERS: %w", err)
+	}
+	if len(rules) == 0 {
+		return mentions, nil
+	}
+
+	from, err := commitForTag(repo, *previous.TagName)
+	if err != nil {
+		return nil, fmt.Errorf("failed resolving previous tag %s: %w", *previous.TagName, err)
+	}
+
+	to, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := changedPaths(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed finding changed paths: %w", err)
+	}
+
+	for _, owner := range ownersForPaths(rules, paths) {
+		mentions = appendUnique(mentions, owner)
+	}
+
+	return mentions, nil
+}
+
+// notificationBody formats the message mentioning the owners about the release
+func notificationBody(tag, releaseURL string, mentions []string) string {
+	return fmt.Sprintf(
+		"%s\n\nRelease [%s](%s) has been published.",
+		strings.Join(mentions, " "),
+		tag,
+		releaseURL,
+	)
+}
+
+// notifyOwners mentions the owners in a comment on notifyIssue, or in a new issue
+// if notifyIssue is not set, and returns the URL of the comment or issue
+func notifyOwners(auth *UserAuth, gURL *gitURL, tag, releaseURL string, mentions []string) (string, error) {
+	payload := notificationRequest{
+		Body: notificationBody(tag, releaseURL, mentions),
+	}
+
+	endpoint := githubRepoURL(gURL, "issues")
+	if notifyIssue > 0 {
+		endpoint = githubRepoURL(gURL, fmt.Sprintf("issues/%d/comments", notifyIssue))
+	} else {
+		payload.Title = fmt.Sprintf("Release %s published", tag)
+	}
+
+	req, err := newJSONPostRequest(endpoint, payload, authHeaders(auth))
+	if err != nil {
+		return "", err
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var created issue
+	if err = json.Unmarshal(body, &created); err != nil {
+		return "", err
+	}
+
+	if created.HTMLURL == nil {
+		return "", nil
+	}
+
+	return *created.HTMLURL, nil
+}
+
+// appendUnique appends s to the slice if it is not already present
+func appendUnique(slice []string, s string) []string {
+	for _, existing := range slice {
+		if existing == s {
+			return slice
+		}
+	}
+	return append(slice, s)
+}
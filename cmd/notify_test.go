@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestReleaseOwners checks the CODEOWNERS of the paths changed since the previous release are mentioned along with the teams
+func TestReleaseOwners(t *testing.T) {
+	defer func(teams []string, codeowners bool) { notifyTeams, notifyCodeowners = teams, codeowners }(notifyTeams, notifyCodeowners)
+
+	repo := gitfixture.New(t)
+	repo.Tag("v1.0.0", repo.Commit("first", map[string]string{"README.md": "first\n", "docs/usage.md": "usage\n"}))
+	repo.Commit("second", map[string]string{"cmd/root.go": "package cmd\n"})
+
+	dir := t.TempDir()
+	Nil(t, ioutil.WriteFile(filepath.Join(dir, "CODEOWNERS"), []byte("*  @org/maintainers\ncmd/  @org/cli\n/docs/  @org/docs\n"), 0644))
+
+	previous, missing := "v1.0.0", "v0.9.0"
+	releaseOwnersTests := []struct {
+		name       string
+		teams      []string
+		codeowners bool
+		previous   *release
+		expected   []string
+		err        bool
+	}{
+		{name: "teams only", teams: []string{"@org/release"}, previous: &release{TagName: &previous}, expected: []string{"@org/release"}},
+		{name: "changed paths", teams: []string{"@org/release", "@org/cli"}, codeowners: true, previous: &release{TagName: &previous}, expected: []string{"@org/release", "@org/cli"}},
+		{name: "no previous release", teams: []string{"@org/release"}, codeowners: true, expected: []string{"@org/release"}},
+		{name: "previous tag missing", codeowners: true, previous: &release{TagName: &missing}, err: true},
+	}
+	for _, testSpec := range releaseOwnersTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			notifyTeams, notifyCodeowners = testSpec.teams, testSpec.codeowners
+
+			mentions, err := releaseOwners(repo.Repository, dir, testSpec.previous)
+			if testSpec.err {
+				NotNil(t, err)
+				return
+			}
+			Nil(t, err)
+			Equal(t, testSpec.expected, mentions)
+		})
+	}
+}
+
+// TestNotifyOwners checks the owners are mentioned in a comment on the issue, or in a new issue
+func TestNotifyOwners(t *testing.T) {
+	defer gock.Off()
+	defer func(i int) { notifyIssue = i }(notifyIssue)
+
+	gURL, err := parseGitURL("https://github.com/o/r.git")
+	Nil(t, err)
+	body := "@org/cli\n\nRelease [v1.1.0](https://github.com/o/r/releases/tag/v1.1.0) has been published."
+
+	notifyOwnersTests := []struct {
+		name    string
+		issue   int
+		path    string
+		request map[string]string
+	}{
+		{name: "new issue", path: "/repos/o/r/issues", request: map[string]string{"title": "Release v1.1.0 published", "body": body}},
+		{name: "issue comment", issue: 12, path: "/repos/o/r/issues/12/comments", request: map[string]string{"body": body}},
+	}
+	for _, testSpec := range notifyOwnersTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			notifyIssue = testSpec.issue
+
+			gock.New("https://api.github.com").
+				Post(testSpec.path).
+				MatchHeader("Authorization", "token secret").
+				JSON(testSpec.request).
+				Reply(201).
+				JSON(map[string]interface{}{"number": 13, "html_url": "https://github.com/o/r/issues/13"})
+
+			url, err := notifyOwners(&UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "v1.1.0", "https://github.com/o/r/releases/tag/v1.1.0", []string{"@org/cli"})
+			Nil(t, err)
+			Equal(t, "https://github.com/o/r/issues/13", url)
+			True(t, gock.IsDone())
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// openCmd opens the page for a release in the browser
+var openCmd = &cobra.Command{
+	Use:   "open",
+	Short: "Open a release in the browser",
+	Long: `open opens the Github page for the release with the provided tag in the browser. If --tag is omitted,
+the release can be picked from a list.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return openRelease()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(openCmd)
+}
+
+func openRelease() error {
+	gURL, err := parseGitURL(repositoryURL)
+	if err != nil {
+		return err
+	}
+
+	tag, err = resolveTag(gURL)
+	if err != nil {
+		return err
+	}
+
+	releasesList, err := getReleases(gURL)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	for _, r := range *releasesList {
+		if r.TagName != nil && *r.TagName == tag && r.HTMLURL != nil {
+			fmt.Println(*r.HTMLURL)
+			openbrowser(*r.HTMLURL)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no release found for tag %s", tag)
+}
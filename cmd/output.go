@@ -0,0 +1,173 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// noColor disables colored output. It is also disabled by the NO_COLOR environment variable.
+var noColor bool
+
+// plainOutput is the screen reader friendly mode: no color, and progress reported with
+// periodic status lines instead of lines redrawn in place
+var plainOutput bool
+
+// plainStatusInterval is the least time between the status lines of a progress in plain mode
+var plainStatusInterval = 10 * time.Second
+
+// ANSI color codes
+const (
+	colorRed  = "31"
+	colorCyan = "36"
+)
+
+// isTerminal returns true if f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled returns true if output to stdout may be colored
+func colorEnabled() bool {
+	if noColor || plainOutput || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// colorize wraps s in the ANSI color code, if color is enabled
+func colorize(s, code string) string {
+	if !colorEnabled() {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+// promptChoices is the hint shown after a yes or no question
+func promptChoices() string {
+	if plainOutput {
+		return "(yes or no)"
+	}
+	return "[y/n]"
+}
+
+// progress reports the status of a long running step. On a terminal, the status is
+// redrawn in place, unless verbose output would be interleaved with it. In plain mode,
+// it is written as a line at most every plainStatusInterval; otherwise, eg: when the
+// output is a log, only the outcome is.
+type progress struct {
+	mu       sync.Mutex
+	out      io.Writer
+	redraw   bool
+	plain    bool
+	interval time.Duration
+	last     time.Time
+}
+
+// newProgress returns a progress writing to stdout in the current output mode
+func newProgress() *progress {
+	return &progress{
+		out:      os.Stdout,
+		redraw:   !plainOutput && !verbose && isTerminal(os.Stdout),
+		plain:    plainOutput,
+		interval: plainStatusInterval,
+	}
+}
+
+// update reports the current status
+func (p *progress) update(status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.redraw:
+		fmt.Fprintf(p.out, "\r\033[K%s", status)
+	case p.plain:
+		now := time.Now()
+		if !p.last.IsZero() && now.Sub(p.last) < p.interval {
+			return
+		}
+		p.last = now
+		fmt.Fprintln(p.out, status)
+	}
+}
+
+// done reports the outcome, ending the progress
+func (p *progress) done(outcome string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.redraw {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	if outcome != "" {
+		fmt.Fprintln(p.out, outcome)
+	}
+	// Any later status starts afresh
+	p.last = time.Time{}
+}
+
+// progressWriter adapts output that redraws its status with carriage returns, such as
+// git's progress, to a progress. Completed lines are always written.
+type progressWriter struct {
+	p   *progress
+	buf []byte
+}
+
+// newProgressWriter returns out unchanged unless in plain mode, where git's redrawn
+// progress lines are turned into periodic status lines
+func newProgressWriter(out io.Writer) io.Writer {
+	if !plainOutput || out == nil {
+		return out
+	}
+	p := newProgress()
+	p.out = out
+	return &progressWriter{p: p}
+}
+
+func (w *progressWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			return len(data), nil
+		}
+
+		line := string(w.buf[:i])
+		end := w.buf[i]
+		w.buf = w.buf[i+1:]
+
+		if line == "" {
+			continue
+		}
+		if end == '\n' {
+			w.p.done(line)
+		} else {
+			w.p.update(line)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestProgressPlain checks plain status lines are rate limited, but outcomes never are
+func TestProgressPlain(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{out: &out, plain: true, interval: time.Hour}
+
+	p.update("1 of 3")
+	p.update("2 of 3")
+	p.done("finished")
+	p.update("again")
+
+	Equal(t, "1 of 3\nfinished\nagain\n", out.String())
+}
+
+// TestProgressRedraw checks terminal status lines are redrawn and cleared
+func TestProgressRedraw(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{out: &out, redraw: true}
+
+	p.update("1 of 2")
+	p.update("2 of 2")
+	p.done("")
+
+	Equal(t, "\r\033[K1 of 2\r\033[K2 of 2\r\033[K", out.String())
+}
+
+// TestProgressWriter checks git's redrawn progress becomes periodic status lines
+func TestProgressWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &progressWriter{p: &progress{out: &out, plain: true, interval: time.Hour}}
+
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(w, "Counting objects:  %d%% (%d/3)\r", i*33, i)
+	}
+	fmt.Fprint(w, "Counting objects: 100% (3/3), done.\n")
+	fmt.Fprint(w, "Compressing objects:  50% (1/2)\rCompr")
+	fmt.Fprint(w, "essing objects: 100% (2/2), done.\n")
+
+	Equal(t, "Counting objects:  33% (1/3)\n"+
+		"Counting objects: 100% (3/3), done.\n"+
+		"Compressing objects:  50% (1/2)\n"+
+		"Compressing objects: 100% (2/2), done.\n", out.String())
+}
+
+// TestPlainReleaseLabel checks plain picker labels aren't padded into columns
+func TestPlainReleaseLabel(t *testing.T) {
+	defer func() { plainOutput = false }()
+
+	tagName, date, isDraft := "v1.0.0", "2020-06-01T00:00:00Z", true
+	r := release{TagName: &tagName, CreatedAt: &date, Draft: &isDraft}
+
+	plainOutput = true
+	Equal(t, "v1.0.0, 2020-06-01T00:00:00Z, draft", releaseLabel(r))
+	Equal(t, "(yes or no)", promptChoices())
+}
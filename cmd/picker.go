@@ -0,0 +1,164 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errNoTag is returned when a tag is required but was not provided, and cannot be prompted for
+var errNoTag = errors.New("tag is required; use --tag to select a release")
+
+// isInteractive returns true if the user can be prompted for input; ie: stdin is a
+// terminal, the force flag isn't set and the run isn't non-interactive, eg: with --ci
+func isInteractive() bool {
+	if force || nonInteractiveMode() {
+		return false
+	}
+
+	return isTerminal(os.Stdin)
+}
+
+// fuzzyMatch returns true if all the characters of the query appear in s, in order,
+// ignoring case
+func fuzzyMatch(query, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(query) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+// releaseLabel formats a release for display in the picker
+func releaseLabel(r release) string {
+	var tagName, date string
+	if r.TagName != nil {
+		tagName = *r.TagName
+	}
+
+	switch {
+	case r.PublishedAt != nil:
+		date = *r.PublishedAt
+	case r.CreatedAt != nil:
+		date = *r.CreatedAt
+	}
+
+	var states []string
+	if r.Draft != nil && *r.Draft {
+		states = append(states, "draft")
+	}
+	if r.Prerelease != nil && *r.Prerelease {
+		states = append(states, "prerelease")
+	}
+
+	// Screen readers read out the padding, so plain labels are a list instead of columns
+	if plainOutput {
+		return strings.Join(append([]string{tagName, date}, states...), ", ")
+	}
+
+	label := fmt.Sprintf("%-20s %-22s", tagName, date)
+	if len(states) > 0 {
+		label += " (" + strings.Join(states, ", ") + ")"
+	}
+	return label
+}
+
+// pickRelease prompts the user to choose one of the releases, and returns its tag.
+// Typing text filters the list with a fuzzy match; typing a number selects that
+// release. An error is returned if the user cannot be prompted.
+func pickRelease(releasesList *releases) (string, error) {
+	if !isInteractive() {
+		return "", errNoTag
+	}
+
+	if releasesList == nil || len(*releasesList) == 0 {
+		return "", fmt.Errorf("no releases found")
+	}
+
+	return pickReleaseFrom(*releasesList, os.Stdin, os.Stdout)
+}
+
+// resolveTag returns the tag provided with --tag, or prompts the user to pick one
+// of the repository's releases if it was omitted
+func resolveTag(gURL *gitURL) (string, error) {
+	if tag != "" {
+		return tag, nil
+	}
+
+	if !isInteractive() {
+		return "", errNoTag
+	}
+
+	releasesList, err := getReleases(gURL)
+	if err != nil {
+		return "", fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	return pickRelease(releasesList)
+}
+
+// pickReleaseFrom runs the release picker reading choices from in and writing to out
+func pickReleaseFrom(all []release, in io.Reader, out io.Writer) (string, error) {
+	reader := bufio.NewReader(in)
+	filter := ""
+
+	for {
+		var shown []release
+		for _, r := range all {
+			if r.TagName != nil && fuzzyMatch(filter, releaseLabel(r)) {
+				shown = append(shown, r)
+			}
+		}
+
+		fmt.Fprintln(out)
+		for i, r := range shown {
+			fmt.Fprintf(out, "%3d) %s\n", i+1, releaseLabel(r))
+		}
+		if len(shown) == 0 {
+			fmt.Fprintf(out, "No releases match %q\n", filter)
+		}
+
+		fmt.Fprint(out, "Select a release by number, type to filter, or empty to clear the filter: ")
+
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		response = strings.TrimSpace(response)
+
+		if n, err := strconv.Atoi(response); err == nil {
+			if n < 1 || n > len(shown) {
+				fmt.Fprintf(out, "%d is not a listed release\n", n)
+				continue
+			}
+			return *shown[n-1].TagName, nil
+		}
+
+		filter = response
+	}
+}
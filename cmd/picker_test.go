@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestFuzzyMatch checks queries match when their characters appear in order
+func TestFuzzyMatch(t *testing.T) {
+	True(t, fuzzyMatch("", "v1.0.0"))
+	True(t, fuzzyMatch("v1", "v1.0.0"))
+	True(t, fuzzyMatch("RC", "v1.0.0-rc.1"))
+	True(t, fuzzyMatch("v1rc", "v1.0.0-rc.1"))
+	False(t, fuzzyMatch("rcv1", "v1.0.0-rc.1"))
+	False(t, fuzzyMatch("v2", "v1.0.0"))
+}
+
+// TestPickReleaseFrom checks releases can be filtered and selected by number
+func TestPickReleaseFrom(t *testing.T) {
+	tags := []string{"v1.1.0", "v1.1.0-rc.1", "v1.0.0"}
+	var all []release
+	for i := range tags {
+		all = append(all, release{TagName: &tags[i]})
+	}
+
+	picked, err := pickReleaseFrom(all, strings.NewReader("2\n"), ioutil.Discard)
+	Nil(t, err)
+	Equal(t, "v1.1.0-rc.1", picked)
+
+	// Filtering renumbers the list; out of range selections are re-prompted
+	picked, err = pickReleaseFrom(all, strings.NewReader("v100\n5\n1\n"), ioutil.Discard)
+	Nil(t, err)
+	Equal(t, "v1.0.0", picked)
+
+	// Running out of input without a selection is an error
+	_, err = pickReleaseFrom(all, strings.NewReader("v9\n"), ioutil.Discard)
+	Error(t, err)
+}
@@ -0,0 +1,439 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// releasePlan records what a release would do, for review before it is applied.
+// Planning runs the clone, build and artifact processing, but pushes, signs and
+// publishes nothing.
+type releasePlan struct {
+	Tag               string `json:"tag"`
+	Repository        string `json:"repository"`
+	ReleaseRepository string `json:"release_repository"`
+	Commit            string `json:"commit"`
+	Version           string `json:"version"`
+	// TagMessage is the message of the tag the plan creates, reused when it is applied
+	TagMessage  string `json:"tag_message,omitempty"`
+	ReleaseName string `json:"release_name"`
+	// Notes are the body of the release, which applying the plan publishes
+	Notes      string `json:"notes"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	// ReleaseID is the existing release the plan updates or uploads to, if any
+	ReleaseID  int                `json:"release_id,omitempty"`
+	Stages     []string           `json:"stages"`
+	Mutations  []planMutation     `json:"mutations"`
+	Artifacts  []plannedArtifact  `json:"artifacts"`
+	TotalSize  int64              `json:"total_size"`
+	Signatures []plannedSignature `json:"signatures,omitempty"`
+	PlannedAt  time.Time          `json:"planned_at"`
+}
+
+// planMutation is a change the release makes to a remote or the forge
+type planMutation struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// plannedArtifact is an asset the release uploads. Signed artifacts, and archives and
+// sidecars of them, have the digest of the signed artifact as it was built, as it's
+// only signed when the plan is applied.
+type plannedArtifact struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Signed bool   `json:"signed,omitempty"`
+}
+
+// plannedSignature is a signature the release makes when the plan is applied
+type plannedSignature struct {
+	Artifact string `json:"artifact"`
+	// Signer is the post processor signing the artifact, or "checksumSignCommand"
+	Signer string `json:"signer"`
+	Type   string `json:"type"`
+	// Signature is the detached signature uploaded with the artifact, if any
+	Signature string `json:"signature,omitempty"`
+}
+
+// readReleasePlan reads a plan written by writeReleasePlan
+func readReleasePlan(path string) (*releasePlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan releasePlan
+	if err = json.Unmarshal(data, &plan); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// writeReleasePlan writes the plan as indented JSON, to be archived or reviewed
+func writeReleasePlan(path string, plan *releasePlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// signingProcessor returns true if the post processor signs the artifacts it matches
+func signingProcessor(p postProcessor) bool {
+	return p.Type == processorTypeNotarize || p.Type == processorTypeAuthenticode
+}
+
+// recordUnsignedDigests records the digest of each artifact the signing post processors
+// match, before any post processor runs, so planned and applied releases compare equal
+func recordUnsignedDigests(artifacts []*artifact, processors []postProcessor) error {
+	for _, p := range processors {
+		if !signingProcessor(p) {
+			continue
+		}
+		for _, a := range artifacts {
+			ok, err := p.matches(a)
+			if err != nil {
+				return fmt.Errorf("post processor %q: %w", p.Name, err)
+			}
+			if !ok || a.unsignedDigest != "" {
+				continue
+			}
+			if a.unsignedDigest, err = fileDigest(a.path); err != nil {
+				return fmt.Errorf("failed hashing %s: %w", a.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deferSigning returns the post processors to run while planning, without the signing
+// ones, and records the signatures they would make instead
+func (plan *releasePlan) deferSigning(artifacts []*artifact, processors []postProcessor) ([]postProcessor, error) {
+	var run []postProcessor
+	for _, p := range processors {
+		if !signingProcessor(p) {
+			run = append(run, p)
+			continue
+		}
+
+		for _, a := range artifacts {
+			ok, err := p.matches(a)
+			if err != nil {
+				return nil, fmt.Errorf("post processor %q: %w", p.Name, err)
+			}
+			if ok {
+				plan.Signatures = append(plan.Signatures, plannedSignature{Artifact: a.name, Signer: p.Name, Type: p.Type})
+			}
+		}
+	}
+
+	return run, nil
+}
+
+// deferChecksumSigning records the checksum file's signature, which is made when the
+// plan is applied
+func (plan *releasePlan) deferChecksumSigning(sums *artifact) {
+	plan.Signatures = append(plan.Signatures, plannedSignature{
+		Artifact:  sums.name,
+		Signer:    "checksumSignCommand",
+		Type:      "detached",
+		Signature: sums.name + signatureExt,
+	})
+}
+
+// planArtifacts records the artifacts to upload, with their digests
+func (plan *releasePlan) planArtifacts(artifacts []*artifact) error {
+	plan.Artifacts, plan.TotalSize = nil, 0
+	for _, a := range artifacts {
+		digest, err := plannedDigest(a)
+		if err != nil {
+			return err
+		}
+		plan.Artifacts = append(plan.Artifacts, plannedArtifact{Name: a.name, Size: a.size, SHA256: digest, Signed: a.unsignedDigest != ""})
+		plan.TotalSize += a.size
+	}
+
+	return nil
+}
+
+// plannedDigest returns the digest the plan records for the artifact: of the signed
+// artifact before it was signed, or else of the artifact itself
+func plannedDigest(a *artifact) (string, error) {
+	if a.unsignedDigest != "" {
+		return a.unsignedDigest, nil
+	}
+
+	digest, err := fileDigest(a.path)
+	if err != nil {
+		return "", fmt.Errorf("failed hashing %s: %w", a.name, err)
+	}
+	return digest, nil
+}
+
+// planStages lists the stages of the pipeline the release runs
+func planStages(tagged bool) []string {
+	stages := []string{"clone", "checkout tag"}
+	if tagged {
+		stages = []string{"clone", "create tag"}
+	}
+
+	return append(stages, "build", "process artifacts", "publish")
+}
+
+// planMutations lists the changes publishing the artifacts makes, in the order they
+// are made
+func planMutations(p *pipeline, existing *release, artifacts []*artifact) []planMutation {
+	repository := fmt.Sprintf("%s/%s", p.releaseRepo.organization, p.releaseRepo.repository)
+
+	var mutations []planMutation
+	if p.tagged {
+		mutations = append(mutations, planMutation{Action: "push tag", Target: remote, Detail: tag})
+	}
+
+	scheduled := !publishTime.IsZero()
+	switch {
+	case existing != nil && updateExisting:
+		detail := tag
+		if prereleaseSet {
+			detail = fmt.Sprintf("%s (prerelease: %t)", tag, prerelease)
+		}
+		mutations = append(mutations, planMutation{Action: "update release", Target: repository, Detail: detail})
+	case existing == nil:
+		mutations = append(mutations, planMutation{Action: "create release", Target: repository, Detail: fmt.Sprintf("%s (draft: %t, prerelease: %t)", tag, draft || scheduled, prerelease)})
+	}
+
+	// The artifacts are uploaded first, and only then are the existing assets they replace
+	// deleted, and with --update, those no longer built
+	for _, a := range artifacts {
+		mutations = append(mutations, planMutation{Action: "upload asset", Target: repository, Detail: fmt.Sprintf("%s (%s)", a.name, formatSize(a.size))})
+	}
+
+	if existing != nil {
+		built := make(map[string]bool)
+		for _, a := range artifacts {
+			built[a.name] = true
+		}
+		for _, a := range existing.Assets {
+			if a.Name == nil {
+				continue
+			}
+			if built[*a.Name] {
+				mutations = append(mutations, planMutation{Action: "delete asset", Target: repository, Detail: *a.Name + " (replaced)"})
+			} else if updateExisting {
+				mutations = append(mutations, planMutation{Action: "delete asset", Target: repository, Detail: *a.Name + " (no longer built)"})
+			}
+		}
+	}
+
+	if scheduled && publishWait {
+		mutations = append(mutations, planMutation{Action: "publish release", Target: repository, Detail: fmt.Sprintf("%s at %s", tag, publishTime.Format(time.RFC3339))})
+	}
+
+	published := !draft && (!scheduled || publishWait)
+	if published {
+		for _, alias := range aliasTags {
+			mutations = append(mutations, planMutation{Action: "move tag", Target: remote, Detail: fmt.Sprintf("%s to %s", alias, p.build.ShortCommit)})
+		}
+		if gitNote {
+			mutations = append(mutations, planMutation{Action: "push note", Target: remote, Detail: string(releaseNotesRef)})
+		}
+		if len(notifyTeams) > 0 || notifyCodeowners {
+			mutations = append(mutations, planMutation{Action: "notify owners", Target: repository, Detail: strings.Join(notifyTeams, ", ")})
+		}
+	}
+
+	return mutations
+}
+
+// planRelease runs the release up to publishing, and writes the plan of what publishing
+// would change instead
+func (p *pipeline) planRelease(plan *releasePlan) error {
+	forge := providerFor(p.releaseRepo)
+	auth, err := forge.authenticate(p.releaseRepo)
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	plan.Tag, plan.TagMessage = tag, tagMessage
+	plan.Repository, plan.ReleaseRepository = p.gURL.raw, p.releaseRepo.raw
+	plan.Commit, plan.Version = p.build.Commit, p.build.Version
+	plan.Draft, plan.Prerelease = draft || !publishTime.IsZero(), prerelease
+	plan.Stages = planStages(p.tagged)
+	plan.PlannedAt = time.Now().UTC()
+
+	if err = plan.planArtifacts(p.artifacts); err != nil {
+		return err
+	}
+
+	plan.Notes, _, err = p.releaseNotes(auth, !publishTime.IsZero())
+	if err != nil {
+		return err
+	}
+
+	name, err := renderReleaseName(releaseName, p.gURL.repository, tag, p.build)
+	if err != nil {
+		return stageFailed(errRelease, err)
+	}
+	plan.ReleaseName = name
+
+	existing, err := p.existingRelease()
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != nil {
+		plan.ReleaseID = *existing.ID
+	}
+	plan.Mutations = planMutations(p, existing, p.artifacts)
+
+	if err = writeReleasePlan(planPath, plan); err != nil {
+		return fmt.Errorf("failed writing release plan: %w", err)
+	}
+
+	fmt.Printf("Planned release %s of %s at %s\n", tag, p.releaseRepo.raw, p.build.ShortCommit)
+	for _, m := range plan.Mutations {
+		fmt.Printf("\t%s %s: %s\n", m.Action, m.Target, m.Detail)
+	}
+	for _, s := range plan.Signatures {
+		fmt.Printf("\tsign %s with %s\n", s.Artifact, s.Signer)
+	}
+	fmt.Printf("Wrote the plan to %s; release it with --apply %s\n", planPath, planPath)
+
+	return nil
+}
+
+// existingRelease returns the release of the tag the run would reuse, failing as
+// publishing does if it can reuse none
+func (p *pipeline) existingRelease() (*release, error) {
+	forge := providerFor(p.releaseRepo)
+	auth, err := forge.authenticate(p.releaseRepo)
+	if err != nil {
+		return nil, stageFailed(errAuth, err)
+	}
+
+	releases, err := forge.listReleases(p.releaseRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	existing, err := findRelease(forge, auth, p.releaseRepo, releases, tag)
+	if err != nil {
+		return nil, err
+	}
+	_, tagsAreReleases := forge.(*bitbucketProvider)
+	if existing != nil && !replaceAssets && !updateExisting && !tagsAreReleases {
+		return nil, fmt.Errorf("release with tag \"%s\" already exists", tag)
+	}
+	if !replaceAssets && !updateExisting {
+		return nil, nil
+	}
+
+	return existing, nil
+}
+
+// checkPlan fails if the release no longer matches the plan: its commit, the assets it
+// uploads and their digests, or the release it reuses. Signed artifacts are compared as
+// they were built, before they were signed.
+func (p *pipeline) checkPlan(plan *releasePlan) error {
+	if plan.Tag != tag || plan.ReleaseRepository != p.releaseRepo.raw {
+		return fmt.Errorf("%w: the plan is of %s on %s, not %s on %s", errPlanMismatch, plan.Tag, plan.ReleaseRepository, tag, p.releaseRepo.raw)
+	}
+	if plan.Commit != p.build.Commit {
+		return fmt.Errorf("%w: the plan is of commit %s, but %s is at %s", errPlanMismatch, plan.Commit, tag, p.build.Commit)
+	}
+
+	planned := make(map[string]plannedArtifact)
+	for _, a := range plan.Artifacts {
+		planned[a.Name] = a
+	}
+	signatures := make(map[string]bool)
+	for _, s := range plan.Signatures {
+		if s.Signature != "" {
+			signatures[s.Signature] = true
+		}
+	}
+
+	var added, changed []string
+	for _, a := range p.artifacts {
+		pa, ok := planned[a.name]
+		if !ok {
+			if !signatures[a.name] {
+				added = append(added, a.name)
+			}
+			continue
+		}
+		delete(planned, a.name)
+
+		// The checksums are of the signed artifacts, which are compared themselves
+		if isChecksumAsset(a.name) {
+			continue
+		}
+		digest, err := plannedDigest(a)
+		if err != nil {
+			return err
+		}
+		if digest != pa.SHA256 || (a.unsignedDigest != "") != pa.Signed {
+			changed = append(changed, a.name)
+		}
+	}
+
+	var missing []string
+	for name := range planned {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	if len(added) > 0 || len(missing) > 0 {
+		return fmt.Errorf("%w: the assets differ from the plan: added %s, missing %s", errPlanMismatch, listOrNone(added), listOrNone(missing))
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: built differently from the plan: %s", errPlanMismatch, strings.Join(changed, ", "))
+	}
+
+	existing, err := p.existingRelease()
+	if err != nil {
+		return err
+	}
+	var id int
+	if existing != nil && existing.ID != nil {
+		id = *existing.ID
+	}
+	if id != plan.ReleaseID {
+		return fmt.Errorf("%w: the release of %s has changed since it was planned", errPlanMismatch, tag)
+	}
+
+	return nil
+}
+
+// listOrNone joins the names, or returns "none"
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestPlanMutations checks the changes are listed in the order publishing makes them
+func TestPlanMutations(t *testing.T) {
+	defer func(r string, update bool, aliases []string) { remote, updateExisting, aliasTags = r, update, aliases }(remote, updateExisting, aliasTags)
+	defer func(tg string) { tag = tg }(tag)
+	remote, tag, updateExisting, aliasTags = "origin", "v1.2.0", true, []string{"v1"}
+
+	p := &pipeline{releaseRepo: &gitURL{organization: "o", repository: "r"}, build: &buildInfo{ShortCommit: "abc1234"}, tagged: true}
+	app, old := "app-linux", "app-freebsd"
+	existing := &release{Assets: []*asset{{Name: &app}, {Name: &old}}}
+
+	mutations := planMutations(p, existing, []*artifact{{name: "app-linux", size: 2048}})
+	Equal(t, []planMutation{
+		{Action: "push tag", Target: "origin", Detail: "v1.2.0"},
+		{Action: "update release", Target: "o/r", Detail: "v1.2.0"},
+		{Action: "upload asset", Target: "o/r", Detail: "app-linux (2.0KB)"},
+		{Action: "delete asset", Target: "o/r", Detail: "app-linux (replaced)"},
+		{Action: "delete asset", Target: "o/r", Detail: "app-freebsd (no longer built)"},
+		{Action: "move tag", Target: "origin", Detail: "v1 to abc1234"},
+	}, mutations)
+}
+
+// TestDeferSigning checks signing post processors are recorded, rather than run, while planning.
+// The test binary itself is signed, as a real binary.
+func TestDeferSigning(t *testing.T) {
+	exe, err := os.Executable()
+	Nil(t, err)
+	plat, ok := binaryPlatform(exe)
+	if !ok {
+		t.Skip("test binary is not a recognized binary")
+	}
+
+	plan := &releasePlan{}
+	processors := []postProcessor{
+		{Name: "upx", Command: "upx"},
+		{Name: "sign", Type: processorTypeAuthenticode, Platforms: []string{plat.os}},
+	}
+
+	artifacts := []*artifact{{name: "app", path: exe}, {name: "README.md", path: "README.md"}}
+	run, err := plan.deferSigning(artifacts, processors)
+	Nil(t, err)
+	Equal(t, processors[:1], run)
+	Equal(t, []plannedSignature{{Artifact: "app", Signer: "sign", Type: processorTypeAuthenticode}}, plan.Signatures)
+
+	plan.deferChecksumSigning(&artifact{name: "SHA256SUMS"})
+	Equal(t, "SHA256SUMS.sig", plan.Signatures[len(plan.Signatures)-1].Signature)
+
+	// Only the signed artifact is compared by its digest as built
+	Nil(t, recordUnsignedDigests(artifacts, processors))
+	digest, err := fileDigest(exe)
+	Nil(t, err)
+	Equal(t, digest, artifacts[0].unsignedDigest)
+	Empty(t, artifacts[1].unsignedDigest)
+}
+
+// TestCheckPlan checks a plan is only applied to the commit, assets and release it planned
+func TestCheckPlan(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+	defer func(tg string, ci, replace bool) { tag, ciMode, replaceAssets, cachedAuth = tg, ci, replace, nil }(tag, ciMode, replaceAssets)
+	tag, ciMode, replaceAssets = "v1.2.0", true, true
+	cachedAuth = &UserAuth{AccessToken: "secret", TokenType: "token"}
+
+	dir, err := ioutil.TempDir("", "plan")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app-linux")
+	Nil(t, ioutil.WriteFile(path, []byte("binary"), 0644))
+	artifacts := []*artifact{{path: path, name: "app-linux", size: 6}}
+
+	gURL, err := parseGitURL("https://github.com/o/r.git")
+	Nil(t, err)
+	p := &pipeline{gURL: gURL, releaseRepo: gURL, artifacts: artifacts, build: &buildInfo{Commit: "abc"}, tagged: true}
+
+	plan := &releasePlan{}
+	Nil(t, plan.planArtifacts(artifacts))
+	plan.Tag, plan.ReleaseRepository, plan.Commit = "v1.2.0", gURL.raw, "def"
+
+	err = p.checkPlan(plan)
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "the plan is of commit def")
+	}
+
+	plan.Commit = "abc"
+	plan.Artifacts = append(plan.Artifacts, plannedArtifact{Name: "app-darwin"})
+	err = p.checkPlan(plan)
+	if NotNil(t, err) {
+		Equal(t, "release plan mismatch: the assets differ from the plan: added none, missing app-darwin", err.Error())
+	}
+	plan.Artifacts = plan.Artifacts[:1]
+
+	// An artifact built differently is refused, as settings are
+	Nil(t, ioutil.WriteFile(path, []byte("rebuilt"), 0644))
+	err = p.checkPlan(plan)
+	if NotNil(t, err) {
+		Equal(t, "release plan mismatch: built differently from the plan: app-linux", err.Error())
+		Equal(t, exitInvalid, exitCodeFor(err))
+	}
+
+	// A signed artifact is compared as it was built, before it was signed
+	unsigned, err := fileDigest(path)
+	Nil(t, err)
+	Nil(t, plan.planArtifacts(artifacts))
+	artifacts[0].unsignedDigest = unsigned
+	err = p.checkPlan(plan)
+	if NotNil(t, err, "an artifact signed only when applied is planned as signed") {
+		Contains(t, err.Error(), "built differently from the plan: app-linux")
+	}
+	Nil(t, plan.planArtifacts(artifacts))
+	True(t, plan.Artifacts[0].Signed)
+	Nil(t, ioutil.WriteFile(path, []byte("rebuilt and signed"), 0644))
+
+	// The release was created after the plan, eg: by another run
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		Reply(200).
+		JSON([]map[string]interface{}{})
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/tags/v1.2.0").
+		Times(2).
+		Reply(200).
+		JSON(map[string]interface{}{"id": 7, "tag_name": "v1.2.0", "name": "v1.2.0"})
+
+	err = p.checkPlan(plan)
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "has changed since it was planned")
+	}
+
+	plan.ReleaseID = 7
+	Nil(t, p.checkPlan(plan))
+	True(t, gock.IsDone())
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"strings"
+)
+
+// platform is the operating system and architecture a binary artifact was built for,
+// using the GOOS/GOARCH naming
+type platform struct {
+	os   string
+	arch string
+}
+
+// String returns the platform as "os/arch"
+func (p platform) String() string {
+	return p.os + "/" + p.arch
+}
+
+// matches returns true if the platform matches a specification of either "os" or "os/arch"
+func (p platform) matches(spec string) bool {
+	parts := strings.SplitN(strings.ToLower(spec), "/", 2)
+	if parts[0] != p.os {
+		return false
+	}
+	return len(parts) == 1 || parts[1] == p.arch
+}
+
+var elfArches = map[elf.Machine]string{
+	elf.EM_386:     "386",
+	elf.EM_X86_64:  "amd64",
+	elf.EM_ARM:     "arm",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_PPC64:   "ppc64",
+	elf.EM_S390:    "s390x",
+}
+
+// elfArch returns the architecture of an ELF binary for the machine. ppc64 binaries are
+// big endian, and ppc64le ones little endian, with the same machine.
+func elfArch(machine elf.Machine, data elf.Data) string {
+	if machine == elf.EM_PPC64 && data == elf.ELFDATA2LSB {
+		return "ppc64le"
+	}
+	return elfArches[machine]
+}
+
+var machoArches = map[macho.Cpu]string{
+	macho.Cpu386:   "386",
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm:   "arm",
+	macho.CpuArm64: "arm64",
+}
+
+var peArches = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+// binaryPlatform inspects the file at path and returns the platform it was built for.
+// The returned bool is false if the file is not an ELF, Mach-O or PE executable.
+func binaryPlatform(path string) (platform, bool) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return platform{os: "linux", arch: elfArch(f.Machine, f.Data)}, true
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return platform{os: "darwin", arch: machoArches[f.Cpu]}, true
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return platform{os: "windows", arch: peArches[f.Machine]}, true
+	}
+	return platform{}, false
+}
+
+// isBinary returns true if the file at path is an ELF, Mach-O or PE executable
+func isBinary(path string) bool {
+	_, ok := binaryPlatform(path)
+	return ok
+}
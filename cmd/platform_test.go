@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"debug/elf"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestElfArch checks ELF machines map to GOARCH names, with ppc64 told apart from ppc64le by endianness
+func TestElfArch(t *testing.T) {
+	elfArchTests := []struct {
+		machine  elf.Machine
+		data     elf.Data
+		expected string
+	}{
+		{machine: elf.EM_386, data: elf.ELFDATA2LSB, expected: "386"},
+		{machine: elf.EM_X86_64, data: elf.ELFDATA2LSB, expected: "amd64"},
+		{machine: elf.EM_ARM, data: elf.ELFDATA2LSB, expected: "arm"},
+		{machine: elf.EM_AARCH64, data: elf.ELFDATA2LSB, expected: "arm64"},
+		{machine: elf.EM_PPC64, data: elf.ELFDATA2MSB, expected: "ppc64"},
+		{machine: elf.EM_PPC64, data: elf.ELFDATA2LSB, expected: "ppc64le"},
+		{machine: elf.EM_S390, data: elf.ELFDATA2MSB, expected: "s390x"},
+		{machine: elf.EM_MIPS, data: elf.ELFDATA2MSB, expected: ""},
+	}
+	for _, testSpec := range elfArchTests {
+		t.Run(testSpec.machine.String()+"/"+testSpec.data.String(), func(t *testing.T) {
+			Equal(t, testSpec.expected, elfArch(testSpec.machine, testSpec.data))
+		})
+	}
+}
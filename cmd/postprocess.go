@@ -0,0 +1,203 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// postProcessor is a command run against matching artifacts after the build,
+// eg: upx compression or code signing. Processors are configured in the config file
+// under "postProcessors", and are run in the order they are listed.
+type postProcessor struct {
+	// Name identifies the processor in output
+	Name string `mapstructure:"name"`
+	// Type is one of "command" (the default), "notarize" or "authenticode"
+	Type string `mapstructure:"type"`
+	// Command is the command line to run. It may reference {{ .Path }}, {{ .Name }},
+	// {{ .OS }} and {{ .Arch }}; if it does not, the artifact path is appended to it.
+	Command string `mapstructure:"command"`
+	// Platforms limits the processor to binaries for the listed "os" or "os/arch" platforms
+	Platforms []string `mapstructure:"platforms"`
+	// Pattern limits the processor to artifacts with names matching the glob pattern
+	Pattern string `mapstructure:"pattern"`
+
+	// Signing options for the "notarize" and "authenticode" types
+	KeychainProfile string `mapstructure:"keychainProfile"`
+	AppleID         string `mapstructure:"appleID"`
+	TeamID          string `mapstructure:"teamID"`
+	PasswordEnv     string `mapstructure:"passwordEnv"`
+	Certificate     string `mapstructure:"certificate"`
+	PKCS11Module    string `mapstructure:"pkcs11Module"`
+	Key             string `mapstructure:"key"`
+	TimestampURL    string `mapstructure:"timestampURL"`
+}
+
+// platforms returns the platforms the processor is limited to. Signing processors
+// only apply to binaries for their own platform unless configured otherwise.
+func (p postProcessor) platforms() []string {
+	if len(p.Platforms) > 0 {
+		return p.Platforms
+	}
+
+	switch p.Type {
+	case processorTypeNotarize:
+		return []string{"darwin"}
+	case processorTypeAuthenticode:
+		return []string{"windows"}
+	}
+
+	return nil
+}
+
+// postProcessorVars are the values available to a postProcessor command template
+type postProcessorVars struct {
+	Path string
+	Name string
+	OS   string
+	Arch string
+}
+
+// matches returns true if the processor should be run against the artifact
+func (p postProcessor) matches(a *artifact) (bool, error) {
+	if p.Pattern != "" {
+		ok, err := filepath.Match(p.Pattern, a.name)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", p.Pattern, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	platforms := p.platforms()
+	if len(platforms) == 0 {
+		return true, nil
+	}
+
+	plat, ok := binaryPlatform(a.path)
+	if !ok {
+		return false, nil
+	}
+
+	for _, spec := range platforms {
+		if plat.matches(spec) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// commandArgs renders the processor's command for the artifact and splits it into arguments
+func (p postProcessor) commandArgs(a *artifact) ([]string, error) {
+	if !strings.Contains(p.Command, "{{") {
+		fields, err := splitCommand(p.Command)
+		if err != nil {
+			return nil, err
+		}
+		return append(fields, a.path), nil
+	}
+
+	tmpl, err := template.New(p.Name).Parse(p.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	plat, _ := binaryPlatform(a.path)
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, postProcessorVars{
+		Path: a.path,
+		Name: a.name,
+		OS:   plat.os,
+		Arch: plat.arch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return splitCommand(rendered.String())
+}
+
+// run runs the processor against a single artifact
+func (p postProcessor) run(a *artifact) error {
+	switch p.Type {
+	case processorTypeNotarize:
+		return notarize(p, a)
+	case processorTypeAuthenticode:
+		return authenticodeSign(p, a)
+	}
+
+	args, err := p.commandArgs(a)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("rendered an empty command")
+	}
+
+	return runCommand(args[0], args[1:]...)
+}
+
+// runPostProcessors runs each configured postProcessor, in order, against the
+// artifacts it matches, and updates the artifact sizes afterwards
+func runPostProcessors(artifacts []*artifact, processors []postProcessor) error {
+	for _, p := range processors {
+		switch p.Type {
+		case "", processorTypeCommand:
+			if p.Command == "" {
+				return fmt.Errorf("post processor %q has no command", p.Name)
+			}
+		case processorTypeNotarize, processorTypeAuthenticode:
+		default:
+			return fmt.Errorf("post processor %q has unknown type %q", p.Name, p.Type)
+		}
+
+		for _, a := range artifacts {
+			ok, err := p.matches(a)
+			if err != nil {
+				return fmt.Errorf("post processor %q: %w", p.Name, err)
+			}
+			if !ok {
+				continue
+			}
+
+			if verbose {
+				noteInfo(fmt.Sprintf("Running post processor %s on %s", p.Name, a.name))
+			}
+
+			if err = p.run(a); err != nil {
+				return fmt.Errorf("post processor %q failed on %s: %w", p.Name, a.name, err)
+			}
+
+			info, err := os.Stat(a.path)
+			if err != nil {
+				return err
+			}
+			a.size = info.Size()
+		}
+	}
+
+	return nil
+}
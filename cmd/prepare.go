@@ -0,0 +1,277 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/spf13/cobra"
+)
+
+// preparedStatePath is where prepare records the release for finish
+var preparedStatePath string
+
+// workDir is the directory prepare clones the repository into
+var workDir string
+
+// preparedRelease records a release prepared for an external build, so it can be
+// finished, possibly on another machine, once the artifacts are built
+type preparedRelease struct {
+	Tag               string `json:"tag"`
+	Repository        string `json:"repository"`
+	ReleaseRepository string `json:"release_repository"`
+	Commit            string `json:"commit"`
+	Version           string `json:"version"`
+	BuildNumber       string `json:"build_number,omitempty"`
+	// Dir is the clone prepare left behind, reused by finish if it still exists
+	Dir string `json:"dir"`
+}
+
+// prepareCmd clones and tags the repository, stopping before the build
+var prepareCmd = &cobra.Command{
+	Use:   "prepare",
+	Short: "Clone and tag the repository, ready for an external build",
+	Long: `prepare runs the release pipeline up to the build: it clones the repository, creates or checks out the
+tag and works out the version. The clone is kept, and the release is recorded in a state file for the finish
+subcommand. Build the artifacts however you like, then run finish with --artifacts pointing at them.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			return fmt.Errorf("tag is required")
+		}
+
+		if preparedStatePath == "" {
+			preparedStatePath = defaultPreparedStatePath(tag)
+		}
+
+		return prepare()
+	},
+}
+
+// finishCmd publishes a prepared release with artifacts built outside the tool
+var finishCmd = &cobra.Command{
+	Use:   "finish",
+	Short: "Publish a prepared release with externally built artifacts",
+	Long: `finish completes a release started with the prepare subcommand. The artifacts are found with the
+--artifacts patterns relative to the current directory, eg: --artifacts dist/ for every file in dist, then
+post processed, checked and uploaded to the release as when the tool runs the build itself.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			return fmt.Errorf("tag is required")
+		}
+
+		if preparedStatePath == "" {
+			preparedStatePath = defaultPreparedStatePath(tag)
+		}
+
+		return finish()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(prepareCmd)
+	rootCmd.AddCommand(finishCmd)
+
+	for _, c := range []*cobra.Command{prepareCmd, finishCmd} {
+		c.Flags().StringVar(&preparedStatePath, "preparedState", "", "path of the prepared release state file (default is <tag>.prepared.json)")
+	}
+
+	prepareCmd.Flags().StringVar(&workDir, "workDir", "", "(optional) directory to clone into (default is a new temporary directory)")
+}
+
+// defaultPreparedStatePath returns the prepared release state path for a tag, in the
+// current directory. Path separators in the tag, eg: release/v1, become dashes.
+func defaultPreparedStatePath(tag string) string {
+	return fmt.Sprintf("%s.prepared.json", strings.NewReplacer("/", "-", "\\", "-").Replace(tag))
+}
+
+// writePreparedRelease records the prepared release
+func writePreparedRelease(path string, p *pipeline) error {
+	state := preparedRelease{
+		Tag:               tag,
+		Repository:        p.gURL.raw,
+		ReleaseRepository: p.releaseRepo.raw,
+		Commit:            p.build.Commit,
+		Version:           p.build.Version,
+		BuildNumber:       p.build.BuildNumber,
+		Dir:               p.dir,
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// readPreparedRelease reads a state file written by writePreparedRelease
+func readPreparedRelease(path string) (*preparedRelease, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var state preparedRelease
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func prepare() error {
+	dir := workDir
+	if dir == "" {
+		var err error
+		dir, err = createTempDir()
+		if err != nil {
+			return fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create work directory: %w", err)
+	}
+
+	p, err := preparePipeline(dir)
+	if err != nil {
+		return err
+	}
+
+	err = writePreparedRelease(preparedStatePath, p)
+	if err != nil {
+		return fmt.Errorf("failed writing prepared release state: %w", err)
+	}
+
+	fmt.Printf("Prepared %s in %s\n", tag, dir)
+	fmt.Println("Build the artifacts with this environment:")
+	for _, e := range p.build.environment() {
+		fmt.Printf("\t%s\n", e)
+	}
+	fmt.Printf("then run: go-git-release finish --tag %s --artifacts <path>\n", tag)
+
+	return nil
+}
+
+// resumePipeline checks out the prepared tag again, in the clone left by prepare if it
+// still exists, so the release notes and owners can be worked out from the history
+func resumePipeline(state *preparedRelease) (*pipeline, func(), error) {
+	p, err := newPipeline(state.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {}
+	repo, err := git.PlainOpen(state.Dir)
+	if err != nil {
+		if verbose {
+			noteInfo(fmt.Sprintf("Prepared clone %s is not available, cloning again", state.Dir))
+		}
+
+		p.dir, err = createTempDir()
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot create temporary directory: %w", err)
+		}
+		cleanup = func() { os.RemoveAll(p.dir) }
+
+		repo, err = cloneRepo(p.gURL.raw, p.dir, branch)
+		if err != nil {
+			cleanup()
+			return nil, nil, stageFailed(errClone, err)
+		}
+	}
+
+	tagObj, err := getTagFromString(tag, repo)
+	if err == nil && tagObj == nil {
+		err = fmt.Errorf("tag %s not found; was it pushed by prepare?", tag)
+	}
+	if err == nil && tagObj.Target.String() != state.Commit {
+		err = fmt.Errorf("tag %s points at %s, but %s was prepared", tag, tagObj.Target, state.Commit)
+	}
+	if err == nil {
+		_, err = checkoutCommitish(repo, tagObj.Target)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	p.repo = repo
+
+	// The build number was already taken by prepare, so the counter isn't incremented again
+	p.build = &buildInfo{
+		Version:     state.Version,
+		BuildNumber: state.BuildNumber,
+		Commit:      state.Commit,
+		ShortCommit: state.Commit[:7],
+	}
+
+	return p, cleanup, nil
+}
+
+func finish() error {
+	state, err := readPreparedRelease(preparedStatePath)
+	if err != nil {
+		return fmt.Errorf("failed reading prepared release state: %w", err)
+	}
+
+	if state.Tag != tag {
+		return fmt.Errorf("prepared release state %s is for tag %s, not %s", preparedStatePath, state.Tag, tag)
+	}
+
+	if len(state.Commit) < 7 {
+		return fmt.Errorf("prepared release state %s has no commit", preparedStatePath)
+	}
+
+	// Release from the repositories that were prepared
+	repositoryURL = state.Repository
+	if state.ReleaseRepository != state.Repository {
+		upstreamRepositoryURL = state.ReleaseRepository
+	}
+
+	p, cleanup, err := resumePipeline(state)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return auditedRelease(func() (*pipeline, error) {
+		// The artifacts were built outside the tool, so they're found relative to where it runs
+		if verbose {
+			noteInfo("Finding build artifacts")
+		}
+		p.artifacts, err = findArtifacts(".", artifactPatterns)
+		if err != nil {
+			return p, fmt.Errorf("failed finding build artifacts: %w", err)
+		}
+		if len(p.artifacts) == 0 {
+			return p, fmt.Errorf("no artifacts found matching %v", artifactPatterns)
+		}
+
+		err = p.processArtifacts()
+		if err != nil {
+			return p, err
+		}
+
+		return p, p.publishResuming()
+	})
+}
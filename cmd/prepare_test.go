@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestDefaultPreparedStatePath checks the state is recorded in the current directory, whatever the tag contains
+func TestDefaultPreparedStatePath(t *testing.T) {
+	defaultPreparedStatePathTests := []struct {
+		tag      string
+		expected string
+	}{
+		{tag: "v1.0.0", expected: "v1.0.0.prepared.json"},
+		{tag: "release/v1", expected: "release-v1.prepared.json"},
+		{tag: "team/api/v2.1.0", expected: "team-api-v2.1.0.prepared.json"},
+		{tag: "windows\\v1", expected: "windows-v1.prepared.json"},
+	}
+	for _, testSpec := range defaultPreparedStatePathTests {
+		t.Run(testSpec.tag, func(t *testing.T) {
+			Equal(t, testSpec.expected, defaultPreparedStatePath(testSpec.tag))
+		})
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nonInteractive never prompts: questions are answered with their defaults, eg: yes or no
+// ones with --promptDefault, and those without one fail. --ci is non-interactive too.
+var nonInteractive bool
+
+// errNoAnswer is returned when a prompt without a default gets no answer
+var errNoAnswer = errors.New("no answer")
+
+// errNotInteractive is returned when a prompt without a default can't be asked
+var errNotInteractive = errors.New("cannot prompt for input when not running interactively")
+
+// nonInteractiveMode returns true if nobody may be prompted
+func nonInteractiveMode() bool {
+	return nonInteractive || ciMode
+}
+
+// promptOptions are the default and validation of a prompt
+type promptOptions struct {
+	// Default is the answer to an empty line, or when there is no answer in time or no
+	// input. Prompts without one ask until answered.
+	Default string
+	// Validate checks the answer, which is asked for again if it fails
+	Validate func(answer string) error
+}
+
+// prompter asks questions, reading the answers from lines and writing the questions to
+// out. Answers not given within timeout take the default; a zero timeout waits
+// indefinitely. echo turns the terminal's echo off while a secret is typed.
+type prompter struct {
+	lines   <-chan string
+	out     io.Writer
+	timeout time.Duration
+	echo    func(on bool) error
+}
+
+// stdinPrompter returns the prompter asking on the terminal
+func stdinPrompter() *prompter {
+	return &prompter{lines: stdinLines(), out: os.Stdout, timeout: promptTimeout, echo: setEcho}
+}
+
+// ask prompts with the label until the answer is valid
+func (p *prompter) ask(label string, opts promptOptions) (string, error) {
+	var expired <-chan time.Time
+	if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		fmt.Fprintf(p.out, "%s: \n", label)
+
+		select {
+		case response, ok := <-p.lines:
+			if !ok {
+				return p.fallback("No input available", opts)
+			}
+
+			response = strings.TrimSpace(response)
+			if response == "" {
+				if opts.Default == "" {
+					continue
+				}
+				response = opts.Default
+			}
+
+			if opts.Validate != nil {
+				if err := opts.Validate(response); err != nil {
+					fmt.Fprintln(p.out, err)
+					continue
+				}
+			}
+			return response, nil
+		case <-expired:
+			return p.fallback(fmt.Sprintf("No answer after %s", p.timeout), opts)
+		}
+	}
+}
+
+// fallback answers the default when there is no answer, saying why
+func (p *prompter) fallback(reason string, opts promptOptions) (string, error) {
+	if opts.Default == "" {
+		fmt.Fprintln(p.out, reason)
+		return "", errNoAnswer
+	}
+
+	fmt.Fprintf(p.out, "%s; answering %s\n", reason, opts.Default)
+	return opts.Default, nil
+}
+
+// confirm prompts for yes or no; an empty line or no answer is the default answer
+func (p *prompter) confirm(s string, defaultAnswer bool) bool {
+	answer := "no"
+	if defaultAnswer {
+		answer = "yes"
+	}
+
+	response, err := p.ask(s+" "+promptChoices(), promptOptions{Default: answer, Validate: yesOrNo})
+	if err != nil {
+		return defaultAnswer
+	}
+
+	return isYes(response)
+}
+
+// input prompts for a line of input, showing the default, if any
+func (p *prompter) input(message string, opts promptOptions) (string, error) {
+	label := message
+	if opts.Default != "" {
+		label = fmt.Sprintf("%s (default %s)", message, opts.Default)
+	}
+
+	return p.ask(label, opts)
+}
+
+// secret prompts for a line of input without echoing it, eg: a passphrase
+func (p *prompter) secret(message string) (string, error) {
+	if err := p.echo(false); err != nil {
+		return "", fmt.Errorf("cannot hide the input: %w", err)
+	}
+	defer p.echo(true)
+
+	answer, err := p.ask(message, promptOptions{})
+	// The newline typed wasn't echoed either
+	fmt.Fprintln(p.out)
+
+	return answer, err
+}
+
+// yesOrNo checks the answer is yes or no
+func yesOrNo(answer string) error {
+	switch strings.ToLower(answer) {
+	case "y", "yes", "n", "no":
+		return nil
+	}
+	return errors.New("answer yes or no")
+}
+
+// isYes returns true if a yes or no answer is yes
+func isYes(answer string) bool {
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// validReleaseTag checks a tag typed at the prompt is a semantic version, as a typo in it
+// is released otherwise
+func validReleaseTag(tag string) error {
+	_, err := parseVersion(tag)
+	return err
+}
+
+// confirm prompts the user for yes or no, with a message from the provided string
+// Immediately returns true (yes) if the "force" flag is set, or --promptDefault in
+// non-interactive mode
+func confirm(s string) bool {
+	// If the force flag is set, assume true
+	if force {
+		return true
+	}
+
+	// There is nobody to answer, so don't wait for the timeout
+	if nonInteractiveMode() {
+		fmt.Printf("%s %s: \n", s, promptChoices())
+		fmt.Printf("Not prompting in non-interactive mode; answering %s\n", promptDefault)
+		if ciMode && promptDefault != "yes" {
+			fmt.Println("WARNING: the run stops here in --ci mode; use --force to answer yes")
+		}
+		return promptDefault == "yes"
+	}
+
+	return stdinPrompter().confirm(s, promptDefault == "yes")
+}
+
+// promptInput prompts the user for a line of input. In non-interactive mode, the default
+// is answered without asking, and prompts without one fail.
+func promptInput(message string, opts promptOptions) (string, error) {
+	if nonInteractiveMode() {
+		if opts.Default == "" {
+			return "", fmt.Errorf("%s: %w", message, errNotInteractive)
+		}
+		fmt.Printf("Not prompting in non-interactive mode; %s is %s\n", strings.ToLower(message), opts.Default)
+		return opts.Default, nil
+	}
+
+	return stdinPrompter().input(message, opts)
+}
+
+// promptSecret prompts the user for a secret, eg: a key passphrase, without echoing it.
+// Secrets have no default, so this fails unless stdin is a terminal someone can type in.
+func promptSecret(message string) (string, error) {
+	if !isInteractive() {
+		return "", fmt.Errorf("%s: %w", message, errNotInteractive)
+	}
+
+	return stdinPrompter().secret(message)
+}
+
+var stdinOnce sync.Once
+var stdinChannel chan string
+
+// stdinLines returns a channel of the lines read from stdin, which is closed at the
+// end of the input. The reader is shared by every prompt, as a read blocked on stdin
+// can't be abandoned when a prompt times out.
+func stdinLines() <-chan string {
+	stdinOnce.Do(func() {
+		stdinChannel = make(chan string)
+		go func() {
+			defer close(stdinChannel)
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				line, err := reader.ReadString('\n')
+				if line != "" {
+					stdinChannel <- line
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+
+	return stdinChannel
+}
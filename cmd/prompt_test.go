@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// testPrompter returns a prompter reading the lines, without a terminal to hide secrets on
+func testPrompter(lines <-chan string, timeout time.Duration) *prompter {
+	return &prompter{lines: lines, out: ioutil.Discard, timeout: timeout, echo: func(bool) error { return nil }}
+}
+
+// TestPrompterConfirm checks answers are read until a valid one, and the default is used on an empty line, timeout or closed input
+func TestPrompterConfirm(t *testing.T) {
+	lines := make(chan string, 3)
+	lines <- "maybe\n"
+	lines <- "Y\n"
+	True(t, testPrompter(lines, time.Second).confirm("Continue?", false))
+
+	lines <- "no\n"
+	False(t, testPrompter(lines, 0).confirm("Continue?", true))
+
+	lines <- "\n"
+	True(t, testPrompter(lines, 0).confirm("Continue?", true))
+
+	// Nobody answers
+	False(t, testPrompter(make(chan string), 10*time.Millisecond).confirm("Continue?", false))
+	True(t, testPrompter(make(chan string), 10*time.Millisecond).confirm("Continue?", true))
+
+	// The input has ended
+	closed := make(chan string)
+	close(closed)
+	False(t, testPrompter(closed, 0).confirm("Continue?", false))
+}
+
+// TestPrompterInput checks invalid answers are asked again, and prompts without a default need an answer
+func TestPrompterInput(t *testing.T) {
+	semver := func(answer string) error {
+		_, err := parseVersion(answer)
+		return err
+	}
+
+	lines := make(chan string, 4)
+	lines <- "next\n"
+	lines <- "\n"
+	lines <- "v1.2.0\n"
+	answer, err := testPrompter(lines, 0).input("Tag", promptOptions{Validate: semver})
+	Nil(t, err)
+	Equal(t, "v1.2.0", answer)
+
+	lines <- "\n"
+	answer, err = testPrompter(lines, 0).input("Tag", promptOptions{Default: "v1.0.0", Validate: semver})
+	Nil(t, err)
+	Equal(t, "v1.0.0", answer)
+
+	_, err = testPrompter(make(chan string), 10*time.Millisecond).input("Tag", promptOptions{})
+	True(t, errors.Is(err, errNoAnswer))
+}
+
+// TestPrompterSecret checks echo is off while the secret is typed
+func TestPrompterSecret(t *testing.T) {
+	var echoed []bool
+	lines := make(chan string, 1)
+	lines <- "hunter2\n"
+
+	p := testPrompter(lines, 0)
+	p.echo = func(on bool) error {
+		echoed = append(echoed, on)
+		return nil
+	}
+	secret, err := p.secret("Passphrase")
+	Nil(t, err)
+	Equal(t, "hunter2", secret)
+	Equal(t, []bool{false, true}, echoed)
+
+	p.echo = func(bool) error { return errors.New("not a terminal") }
+	_, err = p.secret("Passphrase")
+	True(t, err != nil && strings.Contains(err.Error(), "cannot hide the input"))
+}
+
+// TestNonInteractivePrompts checks prompts answer their defaults without asking, and fail without one
+func TestNonInteractivePrompts(t *testing.T) {
+	defer func(n bool) { nonInteractive = n }(nonInteractive)
+	nonInteractive = true
+
+	answer, err := promptInput("Tag", promptOptions{Default: "v1.0.0"})
+	Nil(t, err)
+	Equal(t, "v1.0.0", answer)
+
+	_, err = promptInput("Tag", promptOptions{})
+	True(t, errors.Is(err, errNotInteractive))
+
+	_, err = promptSecret("Passphrase")
+	True(t, errors.Is(err, errNotInteractive))
+}
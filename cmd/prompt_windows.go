@@ -0,0 +1,46 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"syscall"
+)
+
+// enableEchoInput is the console mode flag echoing what is typed
+const enableEchoInput = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// setEcho turns the console's echo of what is typed on or off
+func setEcho(on bool) error {
+	var mode uint32
+	if err := syscall.GetConsoleMode(syscall.Stdin, &mode); err != nil {
+		return err
+	}
+
+	if on {
+		mode |= enableEchoInput
+	} else {
+		mode &^= enableEchoInput
+	}
+
+	if r, _, err := procSetConsoleMode.Call(uintptr(syscall.Stdin), uintptr(mode)); r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// Git protocols used to clone and push
+const (
+	protocolSSH   = "ssh"
+	protocolHTTPS = "https"
+)
+
+// gitProtocol is the protocol of the clone URLs built from owner/name shorthands
+var gitProtocol string
+
+// gitProtocolHosts overrides gitProtocol for individual hosts, eg: github.example.com: https
+var gitProtocolHosts map[string]string
+
+// validGitProtocol returns true if the protocol is supported
+func validGitProtocol(protocol string) bool {
+	return protocol == protocolSSH || protocol == protocolHTTPS
+}
+
+// gitProtocolFor returns the protocol to use for the host, preferring any per-host override
+func gitProtocolFor(host string) string {
+	if p, ok := gitProtocolHosts[strings.ToLower(host)]; ok {
+		return p
+	}
+	return gitProtocol
+}
+
+// cloneURLFor returns the clone URL of the repository on the host, in the preferred protocol
+func cloneURLFor(host, organization, repository string) string {
+	if gitProtocolFor(host) == protocolHTTPS {
+		return fmt.Sprintf("https://%s/%s/%s.git", host, organization, repository)
+	}
+	return fmt.Sprintf("git@%s:%s/%s.git", host, organization, repository)
+}
+
+// isHTTPGitURL returns true if the git URL is cloned over http(s) rather than ssh
+func isHTTPGitURL(u string) bool {
+	return strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://")
+}
+
+// isLocalGitURL returns true if the git URL is a repository on the local filesystem, eg:
+// a mirror, which git reads and writes directly
+func isLocalGitURL(u string) bool {
+	ep, err := transport.NewEndpoint(u)
+	return err == nil && ep.Protocol == "file"
+}
+
+// gitAuth returns the credentials to clone from or push to the URL. SSH URLs use the
+// ssh agent, and https URLs the Github, GitLab or Bitbucket token the release is made with,
+// or else the host's credential from ~/.netrc or git's credential helpers. Local
+// repositories need none.
+func gitAuth(u string) (transport.AuthMethod, error) {
+	if isLocalGitURL(u) {
+		return nil, nil
+	}
+
+	if !isHTTPGitURL(u) {
+		return sshAuth()
+	}
+
+	if parsed, err := url.Parse(u); err == nil && (&gitlabProvider{}).handles(parsed.Host) {
+		auth, err := gitlabTokenAuth()
+		if err != nil {
+			if c := credentialFor(parsed.Host); c != nil {
+				return c.basicAuth(), nil
+			}
+			return nil, stageFailed(errAuth, err)
+		}
+
+		// GitLab takes any username with an access token, but only this one with the job token
+		username := "oauth2"
+		if auth.TokenType == gitlabJobTokenHeader {
+			username = "gitlab-ci-token"
+		}
+		return &githttp.BasicAuth{Username: username, Password: auth.AccessToken}, nil
+	}
+
+	if parsed, err := url.Parse(u); err == nil && (&bitbucketProvider{}).handles(parsed.Host) {
+		username, secret, err := bitbucketCredentials()
+		if err != nil {
+			if c := credentialFor(parsed.Host); c != nil {
+				return c.basicAuth(), nil
+			}
+			return nil, stageFailed(errAuth, err)
+		}
+
+		if username == "" {
+			username = bitbucketTokenUsername
+		}
+		return &githttp.BasicAuth{Username: username, Password: secret}, nil
+	}
+
+	auth, err := authenticate()
+	if err != nil {
+		return nil, stageFailed(errAuth, err)
+	}
+
+	return &githttp.BasicAuth{Username: "x-access-token", Password: auth.AccessToken}, nil
+}
+
+// sshAuth authenticates git over ssh with the ssh agent, or without one running, with the
+// first of the default private keys that exists, unlocked at the prompt if it's encrypted
+func sshAuth() (transport.AuthMethod, error) {
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		for _, name := range sshKeyNames {
+			key, err := publicKey(name)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed loading ssh key %s: %w", name, err)
+			}
+			return key, nil
+		}
+	}
+
+	return ssh.NewSSHAgentAuth("git")
+}
+
+// remoteFinder looks up a repository's remotes, as a *git.Repository does
+type remoteFinder interface {
+	Remote(name string) (*git.Remote, error)
+}
+
+// remoteURL returns the first URL of the named remote
+func remoteURL(repo remoteFinder, name string) (string, error) {
+	r, err := repo.Remote(name)
+	if err != nil {
+		return "", err
+	}
+
+	urls := r.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %s has no URL", name)
+	}
+
+	return urls[0], nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestRepositoryURLFromShorthand checks shorthands use the preferred protocol for the host
+func TestRepositoryURLFromShorthand(t *testing.T) {
+	defer func(p string, hosts map[string]string) { gitProtocol, gitProtocolHosts = p, hosts }(gitProtocol, gitProtocolHosts)
+
+	protocolTests := []struct {
+		name     string
+		protocol string
+		hosts    map[string]string
+		expected string
+	}{
+		{name: "ssh", protocol: protocolSSH, expected: "git@github.com:clcollins/go-git-release.git"},
+		{name: "https", protocol: protocolHTTPS, expected: "https://github.com/clcollins/go-git-release.git"},
+		{name: "host override", protocol: protocolSSH, hosts: map[string]string{"github.com": protocolHTTPS}, expected: "https://github.com/clcollins/go-git-release.git"},
+		{name: "other host override", protocol: protocolSSH, hosts: map[string]string{"github.example.com": protocolHTTPS}, expected: "git@github.com:clcollins/go-git-release.git"},
+	}
+
+	for _, testSpec := range protocolTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				gitProtocol, gitProtocolHosts = testSpec.protocol, testSpec.hosts
+
+				u, err := repositoryURLFromShorthand("clcollins/go-git-release")
+				Nil(t, err)
+				Equal(t, testSpec.expected, u)
+
+				// Both forms refer to the same repository for API requests
+				gURL, err := parseGitURL(u)
+				Nil(t, err)
+				Equal(t, "clcollins", gURL.organization)
+				Equal(t, "go-git-release", gURL.repository)
+				Equal(t, testSpec.protocol == protocolHTTPS || testSpec.hosts["github.com"] == protocolHTTPS, isHTTPGitURL(u))
+			},
+		)
+	}
+}
+
+// TestRepositoryURLFromShorthandProvider checks shorthands are on the configured forge
+func TestRepositoryURLFromShorthandProvider(t *testing.T) {
+	defer func(p, proto string) { shorthandProvider, gitProtocol = p, proto }(shorthandProvider, gitProtocol)
+	gitProtocol = protocolHTTPS
+
+	shorthandProvider = "bitbucket"
+	u, err := repositoryURLFromShorthand("o/r")
+	Nil(t, err)
+	Equal(t, "https://bitbucket.org/o/r.git", u)
+
+	shorthandProvider = "gitlab"
+	u, err = repositoryURLFromShorthand("o/r")
+	Nil(t, err)
+	Equal(t, "https://"+gitlabHost()+"/o/r.git", u)
+}
+
+// TestSSHAuth checks the default keys are used without an ssh agent, and a passphrase is
+// only asked for encrypted ones
+func TestSSHAuth(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	defer func(n bool) { nonInteractive = n }(nonInteractive)
+	os.Unsetenv("SSH_AUTH_SOCK")
+	nonInteractive = true
+
+	home := t.TempDir()
+	os.Setenv("HOME", home)
+	Nil(t, os.Mkdir(filepath.Join(home, ".ssh"), 0700))
+
+	// An encrypted key needs its passphrase, which nobody can type in tests
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	Nil(t, err)
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), []byte("secret"), x509.PEMCipherAES256)
+	Nil(t, err)
+	Nil(t, ioutil.WriteFile(filepath.Join(home, ".ssh", "id_rsa"), pem.EncodeToMemory(block), 0600))
+
+	_, err = sshAuth()
+	True(t, errors.Is(err, errNotInteractive), "%v", err)
+
+	// An unencrypted key is preferred, and used as it is
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	Nil(t, err)
+	Nil(t, ioutil.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	auth, err := sshAuth()
+	if Nil(t, err, "%v", err) {
+		IsType(t, &ssh.PublicKeys{}, auth)
+	}
+}
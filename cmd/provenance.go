@@ -0,0 +1,125 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// recordProvenance links the release to the CI run that built it, when running in CI
+var recordProvenance bool
+
+// provenance identifies the CI run that built the release
+type provenance struct {
+	System    string `json:"system"`
+	Workflow  string `json:"workflow,omitempty"`
+	RunNumber string `json:"run_number,omitempty"`
+	RunURL    string `json:"run_url,omitempty"`
+	Job       string `json:"job,omitempty"`
+	Trigger   string `json:"trigger,omitempty"`
+	Runner    string `json:"runner,omitempty"`
+}
+
+// detectProvenance returns the CI run described by the environment, or nil if it is
+// not a CI system we know. Github Actions, GitLab CI and Jenkins are recognized.
+func detectProvenance(getenv func(string) string) *provenance {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		p := &provenance{
+			System:    "Github Actions",
+			Workflow:  getenv("GITHUB_WORKFLOW"),
+			RunNumber: getenv("GITHUB_RUN_NUMBER"),
+			Job:       getenv("GITHUB_JOB"),
+			Trigger:   getenv("GITHUB_EVENT_NAME"),
+			Runner:    strings.TrimSpace(strings.Join([]string{getenv("RUNNER_NAME"), getenv("RUNNER_OS"), getenv("RUNNER_ARCH")}, " ")),
+		}
+		if server, repository, id := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID"); server != "" && repository != "" && id != "" {
+			p.RunURL = fmt.Sprintf("%s/%s/actions/runs/%s", server, repository, id)
+			if attempt := getenv("GITHUB_RUN_ATTEMPT"); attempt != "" && attempt != "1" {
+				p.RunURL += "/attempts/" + attempt
+			}
+		}
+		return p
+
+	case getenv("GITLAB_CI") == "true":
+		return &provenance{
+			System:    "GitLab CI",
+			Workflow:  getenv("CI_PROJECT_PATH"),
+			RunNumber: getenv("CI_PIPELINE_IID"),
+			RunURL:    getenv("CI_JOB_URL"),
+			Job:       getenv("CI_JOB_NAME"),
+			Trigger:   getenv("CI_PIPELINE_SOURCE"),
+			Runner:    getenv("CI_RUNNER_DESCRIPTION"),
+		}
+
+	case getenv("JENKINS_URL") != "":
+		return &provenance{
+			System:    "Jenkins",
+			Workflow:  getenv("JOB_NAME"),
+			RunNumber: getenv("BUILD_NUMBER"),
+			RunURL:    getenv("BUILD_URL"),
+			Runner:    getenv("NODE_NAME"),
+		}
+	}
+
+	return nil
+}
+
+// ciProvenance returns the CI run the tool is running in, or nil if it isn't or
+// provenance is disabled
+func ciProvenance() *provenance {
+	if !recordProvenance {
+		return nil
+	}
+	return detectProvenance(os.Getenv)
+}
+
+// footer is the line added to the end of the release body, eg:
+// "Built by [release #123](https://github.com/o/r/actions/runs/1) (job build, triggered by push, on ubuntu Linux X64)"
+func (p *provenance) footer() string {
+	name := p.Workflow
+	if name == "" {
+		name = p.System
+	}
+	if p.RunNumber != "" {
+		name = fmt.Sprintf("%s #%s", name, p.RunNumber)
+	}
+	if p.RunURL != "" {
+		name = fmt.Sprintf("[%s](%s)", name, p.RunURL)
+	}
+
+	var details []string
+	if p.Job != "" {
+		details = append(details, "job "+p.Job)
+	}
+	if p.Trigger != "" {
+		details = append(details, "triggered by "+p.Trigger)
+	}
+	if p.Runner != "" {
+		details = append(details, "on "+p.Runner)
+	}
+
+	footer := "Built by " + name
+	if len(details) > 0 {
+		footer += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return footer
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestDetectProvenance checks CI runs are recognized from the environment and described in the footer
+func TestDetectProvenance(t *testing.T) {
+	provenanceTests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name: "github actions",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "clcollins/go-git-release",
+				"GITHUB_RUN_ID":     "987",
+				"GITHUB_RUN_NUMBER": "123",
+				"GITHUB_WORKFLOW":   "release",
+				"GITHUB_JOB":        "build",
+				"GITHUB_EVENT_NAME": "push",
+				"RUNNER_OS":         "Linux",
+				"RUNNER_ARCH":       "X64",
+			},
+			expected: "Built by [release #123](https://github.com/clcollins/go-git-release/actions/runs/987) (job build, triggered by push, on Linux X64)",
+		},
+		{
+			name: "jenkins",
+			env: map[string]string{
+				"JENKINS_URL":  "https://jenkins.example.com/",
+				"JOB_NAME":     "go-git-release",
+				"BUILD_NUMBER": "7",
+				"BUILD_URL":    "https://jenkins.example.com/job/go-git-release/7/",
+			},
+			expected: "Built by [go-git-release #7](https://jenkins.example.com/job/go-git-release/7/)",
+		},
+		{
+			name: "not ci",
+			env:  map[string]string{},
+		},
+	}
+
+	for _, testSpec := range provenanceTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				p := detectProvenance(func(k string) string { return testSpec.env[k] })
+				if testSpec.expected == "" {
+					Nil(t, p)
+					return
+				}
+				Equal(t, testSpec.expected, p.footer())
+			},
+		)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// provider is a forge releases are published on. The forge is selected by the host of the
+// release repository; hosts no provider claims are assumed to be Github Enterprise Server.
+type provider interface {
+	// name is the forge's name, as shown in messages
+	name() string
+	// handles returns true if the repository host is on the forge
+	handles(host string) bool
+	// authenticate returns the credentials to release on the repository with, and checks
+	// they can
+	authenticate(gURL *gitURL) (*UserAuth, error)
+	listReleases(gURL *gitURL) (*releases, error)
+	createRelease(auth *UserAuth, gURL *gitURL, request *newReleaseRequest) (*release, error)
+	// uploadAsset uploads the artifact to the release, retrying as the forge needs
+	uploadAsset(auth *UserAuth, r *release, a *artifact) (*asset, error)
+}
+
+// shorthandProvider is the forge the repositories of owner/name shorthands are on
+var shorthandProvider string
+
+// providerHosts are the hosts of the forges shorthandProvider may be, by name
+var providerHosts = map[string]func() string{
+	"github":    githubHost,
+	"gitlab":    gitlabHost,
+	"bitbucket": func() string { return bitbucketHost },
+}
+
+// validProviderName checks the forge is one shorthands can be on
+func validProviderName(name string) error {
+	if _, ok := providerHosts[name]; !ok {
+		return errors.New("provider must be one of: github, gitlab, bitbucket")
+	}
+	return nil
+}
+
+// shorthandHost returns the host of the repositories of owner/name shorthands
+func shorthandHost() string {
+	if host, ok := providerHosts[shorthandProvider]; ok {
+		return host()
+	}
+	return githubHost()
+}
+
+// providers are the forges, in the order their hosts are matched
+var providers = []provider{&githubProvider{}, &gitlabProvider{}, &bitbucketProvider{}}
+
+// providerFor returns the forge of the repository, by its host
+func providerFor(gURL *gitURL) provider {
+	host := ""
+	if gURL.parsedURL != nil {
+		host = gURL.parsedURL.Host
+	}
+
+	for _, p := range providers {
+		if p.handles(host) {
+			return p
+		}
+	}
+
+	return &githubProvider{}
+}
+
+// githubOnlySettings returns the settings in use that only work with Github releases, for
+// the forges that have to refuse them
+func githubOnlySettings() []string {
+	settings := map[string]bool{
+		"draft":              draft,
+		"prerelease":         prerelease,
+		"publishAt":          publishAt != "",
+		"replaceAssets":      replaceAssets,
+		"update":             updateExisting,
+		"notesFromPRs":       notesFromPRs,
+		"generateNotes":      generateNotes,
+		"securityAdvisories": securityAdvisories || len(securityAdvisoryIDs) > 0,
+		"notifyTeams":        len(notifyTeams) > 0,
+		"notifyCodeowners":   notifyCodeowners,
+		"showLinks":          showLinks,
+		"linksFile":          linksFile != "",
+		"versionSuite":       versionSuite != "",
+		"discussionCategory": discussionCategory != "",
+		"latest":             markLatest != "",
+		"pr":                 pullRequestNumber > 0,
+		"tagWithAPI":         tagWithAPI,
+	}
+
+	var unsupported []string
+	for name, set := range settings {
+		if set {
+			unsupported = append(unsupported, name)
+		}
+	}
+	sort.Strings(unsupported)
+
+	return unsupported
+}
+
+// githubProvider publishes releases on github.com, or the Github Enterprise Server instance
+// of the configured endpoints
+type githubProvider struct{}
+
+func (g *githubProvider) name() string {
+	return "Github"
+}
+
+func (g *githubProvider) handles(host string) bool {
+	return host == defaultGithubHost || host == githubHost()
+}
+
+func (g *githubProvider) authenticate(gURL *gitURL) (*UserAuth, error) {
+	auth, err := authenticate()
+	if err != nil {
+		return nil, err
+	}
+
+	// A token that can't publish fails here, with what it's missing; --ci checked it before the clone
+	if !ciMode {
+		if err = tokenPreflight(auth, gURL); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth, nil
+}
+
+func (g *githubProvider) listReleases(gURL *gitURL) (*releases, error) {
+	return getReleases(gURL)
+}
+
+func (g *githubProvider) createRelease(auth *UserAuth, gURL *gitURL, request *newReleaseRequest) (*release, error) {
+	return postRelease(auth, gURL, request)
+}
+
+func (g *githubProvider) uploadAsset(auth *UserAuth, r *release, a *artifact) (*asset, error) {
+	return uploadAssetWithRetry(auth, r, a)
+}
+
+// multipartFile is an upload's multipart body, closing the file streamed in it
+type multipartFile struct {
+	io.Reader
+	io.Closer
+}
+
+// newMultipartUploadRequest creates the request uploading the artifact as the field of a
+// multipart form, for forges without raw binary uploads. The file is streamed between the
+// form's header and closing boundary, so its length is known up front and it isn't read
+// into memory.
+func newMultipartUploadRequest(uploadURL, field string, a *artifact, headers map[string]string) (*http.Request, error) {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	if _, err = w.CreateFormFile(field, a.name); err != nil {
+		return nil, err
+	}
+	headerLength := form.Len()
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	header, footer := form.Bytes()[:headerLength], form.Bytes()[headerLength:]
+
+	open := func() (io.ReadCloser, error) {
+		f, err := os.Open(a.path)
+		if err != nil {
+			return nil, err
+		}
+		return multipartFile{Reader: io.MultiReader(bytes.NewReader(header), f, bytes.NewReader(footer)), Closer: f}, nil
+	}
+
+	body, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newPostRequest(uploadURL, body, headers)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.ContentLength = int64(len(header)) + info.Size() + int64(len(footer))
+	req.GetBody = open
+
+	return req, nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestProviderFor checks the forge is selected by the host of the repository
+func TestProviderFor(t *testing.T) {
+	defer func(e endpoint) { githubEndpoint = e }(githubEndpoint)
+
+	for _, u := range []string{"git@github.com:o/r.git", "https://github.com/o/r.git", "https://ghe.example.com/o/r.git"} {
+		gURL, err := parseGitURL(u)
+		Nil(t, err)
+		Equal(t, "Github", providerFor(gURL).name(), u)
+	}
+
+	gURL, err := parseGitURL("git@ghe.example.com:o/r.git")
+	Nil(t, err)
+	False(t, (&githubProvider{}).handles(gURL.parsedURL.Host))
+
+	githubEndpoint.AuthURL = "https://ghe.example.com"
+	True(t, (&githubProvider{}).handles(gURL.parsedURL.Host))
+}
+
+// TestProviderForGitlab checks gitlab.com, the configured GitLab instance and hosts named
+// like one are released on GitLab
+func TestProviderForGitlab(t *testing.T) {
+	defer func(u string) { gitlabURL = u }(gitlabURL)
+	gitlabURL = "https://code.example.com"
+
+	for _, u := range []string{"git@gitlab.com:o/r.git", "https://gitlab.example.com/o/r.git", "https://code.example.com/o/r.git"} {
+		gURL, err := parseGitURL(u)
+		Nil(t, err)
+		Equal(t, "GitLab", providerFor(gURL).name(), u)
+	}
+}
+
+// TestProviderForBitbucket checks bitbucket.org repositories are published to their downloads
+func TestProviderForBitbucket(t *testing.T) {
+	for _, u := range []string{"git@bitbucket.org:o/r.git", "https://bitbucket.org/o/r.git"} {
+		gURL, err := parseGitURL(u)
+		Nil(t, err)
+		Equal(t, "Bitbucket", providerFor(gURL).name(), u)
+	}
+}
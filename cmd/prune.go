@@ -0,0 +1,240 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var pruneOlderThan int
+var pruneKeep int
+var pruneDryRun bool
+
+// draftChannel is the channel of draft releases, whatever their tag
+const draftChannel = "draft"
+
+// pruneCmd deletes old prereleases and drafts
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old prereleases and drafts",
+	Long: `prune deletes the repository's prereleases and drafts that are older than --olderThan days, or beyond
+the last --keep of their channel. A prerelease's channel is the first identifier of its version's prerelease,
+eg: "rc" for v1.2.0-rc.1, and drafts are a channel of their own. With both, only the releases matching both are
+deleted. Published final releases, and the tags of deleted releases, are never removed, nor are drafts waiting
+to be published: those scheduled with --publishAt, those staged with a staging manifest in the working
+directory, and every draft of a private repository, as it may be an embargoed fix. The releases to delete are
+listed before anything is deleted; with --dryRun, nothing is.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneOlderThan <= 0 && pruneKeep < 0 {
+			return fmt.Errorf("either --olderThan or --keep is required")
+		}
+
+		return prune()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().IntVar(&pruneOlderThan, "olderThan", 0, "delete prereleases and drafts older than this many days")
+	pruneCmd.Flags().IntVar(&pruneKeep, "keep", -1, "keep only this many of the most recent prereleases and drafts of each channel")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dryRun", false, "list the releases that would be deleted without deleting them")
+}
+
+// prunable is a prerelease or draft, with the channel and age it is pruned by
+type prunable struct {
+	release *release
+	tag     string
+	channel string
+	created time.Time
+}
+
+// releaseChannel returns the channel of a prerelease or draft, and false for a published
+// final release, which is never pruned
+func releaseChannel(r *release) (string, bool) {
+	if r.Draft != nil && *r.Draft {
+		return draftChannel, true
+	}
+	if r.Prerelease == nil || !*r.Prerelease {
+		return "", false
+	}
+
+	if r.TagName != nil {
+		if v, err := parseVersion(*r.TagName); err == nil && v.isPrerelease() {
+			return strings.SplitN(v.prerelease, ".", 2)[0], true
+		}
+	}
+	return "prerelease", true
+}
+
+// heldDraft returns why the draft is waiting to be published, and mustn't be pruned, or
+// nothing for other releases. Drafts of private repositories may be embargoed releases
+// waiting for disclose.
+func heldDraft(r *release, private bool) string {
+	if r.Draft == nil || !*r.Draft {
+		return ""
+	}
+
+	if r.Body != nil {
+		if _, scheduled := scheduledTime(*r.Body); scheduled {
+			return "scheduled"
+		}
+	}
+	if r.TagName != nil {
+		if _, err := os.Stat(defaultStagingManifestPath(*r.TagName)); err == nil {
+			return "staged"
+		}
+	}
+	if private {
+		return "embargoed"
+	}
+	return ""
+}
+
+// releaseTime returns when the release was published, or created for drafts
+func releaseTime(r *release) (time.Time, bool) {
+	for _, at := range []*string{r.PublishedAt, r.CreatedAt} {
+		if at == nil || *at == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, *at); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// pruneCandidates returns the prereleases and drafts to delete, by channel and newest first:
+// those older than olderThan days, if set, and beyond the last keep of their channel, if set.
+// Drafts waiting to be published are left out.
+func pruneCandidates(all []release, now time.Time, olderThan, keep int, private bool) []prunable {
+	channels := make(map[string][]prunable)
+	var names []string
+	for i := range all {
+		r := &all[i]
+		channel, ok := releaseChannel(r)
+		if !ok || r.ID == nil {
+			continue
+		}
+		if held := heldDraft(r, private); held != "" {
+			if verbose && r.TagName != nil {
+				noteInfo(fmt.Sprintf("Keeping the %s draft %s", held, *r.TagName))
+			}
+			continue
+		}
+		created, ok := releaseTime(r)
+		if !ok {
+			continue
+		}
+
+		var tag string
+		if r.TagName != nil {
+			tag = *r.TagName
+		}
+		if _, seen := channels[channel]; !seen {
+			names = append(names, channel)
+		}
+		channels[channel] = append(channels[channel], prunable{release: r, tag: tag, channel: channel, created: created})
+	}
+	sort.Strings(names)
+
+	cutoff := now.Add(-time.Duration(olderThan) * 24 * time.Hour)
+	var candidates []prunable
+	for _, name := range names {
+		releases := channels[name]
+		sort.SliceStable(releases, func(i, j int) bool { return releases[i].created.After(releases[j].created) })
+
+		for i, p := range releases {
+			if keep >= 0 && i < keep {
+				continue
+			}
+			if olderThan > 0 && !p.created.Before(cutoff) {
+				continue
+			}
+			candidates = append(candidates, p)
+		}
+	}
+
+	return candidates
+}
+
+func prune() error {
+	repoURL := repositoryURL
+	if upstreamRepositoryURL != "" {
+		repoURL = upstreamRepositoryURL
+	}
+	gURL, err := parseGitURL(repoURL)
+	if err != nil {
+		return err
+	}
+	if _, ok := providerFor(gURL).(*githubProvider); !ok {
+		return fmt.Errorf("prune only supports Github releases")
+	}
+
+	// Drafts are only listed for an authenticated user, and deleting needs authorization anyway
+	auth, err := authenticate()
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	all, err := listReleases(auth, gURL)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	repo, err := getRepository(auth, gURL)
+	if err != nil {
+		return fmt.Errorf("failed retrieving repository: %w", err)
+	}
+
+	candidates := pruneCandidates(all, time.Now(), pruneOlderThan, pruneKeep, repo.Private)
+	if len(candidates) == 0 {
+		fmt.Println("No prereleases or drafts to prune")
+		return nil
+	}
+
+	fmt.Printf("Prereleases and drafts to delete from %s/%s:\n", gURL.organization, gURL.repository)
+	for _, p := range candidates {
+		fmt.Printf("\t%s (%s, %s)\n", p.tag, p.channel, p.created.Format("2006-01-02"))
+	}
+
+	if pruneDryRun {
+		fmt.Printf("%d releases would be deleted\n", len(candidates))
+		return nil
+	}
+
+	if !confirm(fmt.Sprintf("Delete %d releases?", len(candidates))) {
+		return fmt.Errorf("prune %w", errHalted)
+	}
+
+	for _, p := range candidates {
+		if err = deleteRelease(auth, gURL, *p.release.ID); err != nil {
+			return fmt.Errorf("failed deleting release %s: %w", p.tag, err)
+		}
+		fmt.Printf("Deleted release %s\n", p.tag)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// pruneTestReleases are a final release, prereleases on two channels and a draft, by age in days
+func pruneTestReleases(now time.Time) []release {
+	var all []release
+	for i, spec := range []struct {
+		tag               string
+		draft, prerelease bool
+		age               int
+	}{
+		{tag: "v1.0.0", age: 90},
+		{tag: "v1.1.0-rc.1", prerelease: true, age: 60},
+		{tag: "v1.1.0-rc.2", prerelease: true, age: 40},
+		{tag: "v1.1.0-beta.1", prerelease: true, age: 70},
+		{tag: "v1.1.0-rc.3", prerelease: true, age: 5},
+		{tag: "v1.2.0", draft: true, age: 50},
+	} {
+		id, tag, draft, pre := i+1, spec.tag, spec.draft, spec.prerelease
+		at := now.Add(-time.Duration(spec.age) * 24 * time.Hour).Format(time.RFC3339)
+		r := release{ID: &id, TagName: &tag, Draft: &draft, Prerelease: &pre, CreatedAt: &at}
+		if !draft {
+			r.PublishedAt = &at
+		}
+		all = append(all, r)
+	}
+	return all
+}
+
+// TestPruneCandidates checks final releases are never pruned, and each channel keeps its newest
+func TestPruneCandidates(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	tags := func(candidates []prunable) []string {
+		var tags []string
+		for _, p := range candidates {
+			tags = append(tags, p.tag)
+		}
+		return tags
+	}
+
+	Equal(t, []string{"v1.1.0-beta.1", "v1.2.0", "v1.1.0-rc.2", "v1.1.0-rc.1"}, tags(pruneCandidates(pruneTestReleases(now), now, 30, -1, false)))
+	Equal(t, []string{"v1.1.0-rc.1"}, tags(pruneCandidates(pruneTestReleases(now), now, 0, 2, false)))
+	Equal(t, []string{"v1.1.0-rc.2", "v1.1.0-rc.1"}, tags(pruneCandidates(pruneTestReleases(now), now, 30, 1, false)))
+	Empty(t, pruneCandidates(pruneTestReleases(now), now, 100, -1, false))
+
+	// Drafts of a private repository may be embargoed, so only its prereleases are pruned
+	Equal(t, []string{"v1.1.0-beta.1", "v1.1.0-rc.2", "v1.1.0-rc.1"}, tags(pruneCandidates(pruneTestReleases(now), now, 30, -1, true)))
+}
+
+// TestHeldDraft checks scheduled, staged and embargoed drafts are kept
+func TestHeldDraft(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	Nil(t, err)
+	Nil(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	draft, published := true, false
+	tag, staged := "v1.0.0", "v1.1.0"
+	scheduled := scheduleNotes("notes", time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC), "")
+	Nil(t, ioutil.WriteFile(defaultStagingManifestPath(staged), []byte("{}"), 0644))
+
+	Equal(t, "", heldDraft(&release{TagName: &tag, Draft: &draft}, false))
+	Equal(t, "scheduled", heldDraft(&release{TagName: &tag, Draft: &draft, Body: &scheduled}, false))
+	Equal(t, "staged", heldDraft(&release{TagName: &staged, Draft: &draft}, false))
+	Equal(t, "embargoed", heldDraft(&release{TagName: &tag, Draft: &draft}, true))
+	Equal(t, "", heldDraft(&release{TagName: &staged, Draft: &published, Body: &scheduled}, true))
+}
+
+// TestPrune checks the releases are deleted once confirmed, and not at all with --dryRun
+func TestPrune(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+	defer func(url string, older, keep int, dry, f bool) {
+		repositoryURL, pruneOlderThan, pruneKeep, pruneDryRun, force, cachedAuth = url, older, keep, dry, f, nil
+	}(repositoryURL, pruneOlderThan, pruneKeep, pruneDryRun, force)
+	repositoryURL, pruneOlderThan, pruneKeep, pruneDryRun, force = "https://github.com/o/r.git", 0, 0, true, true
+	cachedAuth = &UserAuth{AccessToken: "secret", TokenType: "token"}
+
+	id, tag, draft := 3, "v1.2.0", true
+	at := time.Now().Format(time.RFC3339)
+	for i := 0; i < 2; i++ {
+		gock.New("https://api.github.com").
+			Get("/repos/o/r/releases").
+			Reply(200).
+			JSON([]release{{ID: &id, TagName: &tag, Draft: &draft, CreatedAt: &at}})
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/o/r").
+		Times(2).
+		Reply(200).
+		JSON(map[string]interface{}{"full_name": "o/r", "private": false})
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/3").
+		MatchHeader("Authorization", "token secret").
+		Reply(204)
+
+	Nil(t, prune())
+	False(t, gock.IsDone())
+
+	pruneDryRun = false
+	Nil(t, prune())
+	True(t, gock.IsDone())
+
+	// Only Github releases are pruned
+	repositoryURL = "https://gitlab.com/o/r.git"
+	EqualError(t, prune(), "prune only supports Github releases")
+}
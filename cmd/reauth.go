@@ -0,0 +1,80 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+)
+
+// reauthenticate discards the token Github rejected, eg: because it expired during a long
+// build, removing it from the token store, and authenticates again. It returns false if
+// the new token is the rejected one, eg: from --token, so retrying can't help.
+func reauthenticate() (bool, error) {
+	rejected := cachedAuth
+	cachedAuth = nil
+
+	if rejected != nil {
+		store, err := newTokenStore()
+		if err == nil && store != nil {
+			stored, err := store.load()
+			if err == nil && stored != nil && stored.AccessToken == rejected.AccessToken {
+				if err = store.clear(); err != nil {
+					fmt.Printf("WARNING: cannot remove the rejected token from %s: %s\n", store, err)
+				}
+			}
+		}
+	}
+
+	auth, err := authenticate()
+	if err != nil {
+		return false, err
+	}
+
+	return rejected == nil || auth.AccessToken != rejected.AccessToken, nil
+}
+
+// publishResuming publishes the release, and if Github rejects the token part way,
+// authenticates again once and resumes, rather than failing after the build
+func (p *pipeline) publishResuming() error {
+	err := p.publish()
+	if err == nil || !isHTTPStatus(err, 401) {
+		return err
+	}
+	if _, github := providerFor(p.releaseRepo).(*githubProvider); !github {
+		return err
+	}
+
+	fmt.Printf("WARNING: Github rejected the token while publishing; authenticating again to resume: %s\n", err)
+	renewed, authErr := reauthenticate()
+	if authErr != nil {
+		fmt.Printf("WARNING: cannot authenticate again: %s\n", authErr)
+		return err
+	}
+	if !renewed {
+		return err
+	}
+
+	// The release created before the token was rejected is reused, replacing its assets,
+	// for this release only; watch and serve go on to release other tags
+	if p.release != nil {
+		defer func(r bool) { replaceAssets = r }(replaceAssets)
+		replaceAssets = true
+	}
+
+	return p.publish()
+}
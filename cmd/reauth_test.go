@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestReauthenticate checks a rejected stored token is removed, and that the same token is not retried
+func TestReauthenticate(t *testing.T) {
+	defer unsetTokenEnv()()
+	defer func(gh, git, cache bool, id string) {
+		ghCredentials, gitCredentials, cacheToken, clientID, token, cachedAuth = gh, git, cache, id, "", nil
+	}(ghCredentials, gitCredentials, cacheToken, clientID)
+	ghCredentials, gitCredentials, cacheToken, clientID = false, false, true, ""
+
+	dir, err := ioutil.TempDir("", "reauth")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+	defer func(f func() (string, error)) { tokenCachePath = f }(tokenCachePath)
+	tokenCachePath = func() (string, error) { return path, nil }
+
+	// The expired device flow token is removed, and the device flow can't run without a client ID
+	store := &fileTokenStore{path: path}
+	expired := &UserAuth{AccessToken: "expired", TokenType: "bearer"}
+	Nil(t, store.save(expired))
+	cachedAuth = expired
+
+	_, err = reauthenticate()
+	NotNil(t, err)
+	stored, err := store.load()
+	Nil(t, err)
+	Nil(t, stored)
+
+	// A provided token is the same when it's read again
+	token = "secret"
+	cachedAuth = &UserAuth{AccessToken: "secret", TokenType: "token"}
+	renewed, err := reauthenticate()
+	Nil(t, err)
+	False(t, renewed)
+}
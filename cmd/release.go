@@ -26,9 +26,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context/ctxhttp"
@@ -57,30 +61,78 @@ import (
 
 const githubDeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
 
-const (
-	errAuthorizationPending       = "pending"
-	errSlowDown                   = "slow_down"
-	errExpiredToken               = "expired_token"
-	errUnsupportedGrantType       = "unsupported_grant_type"
-	errIncorrectClientCredentials = "incorrect_client_credentials"
-	errIncorrectDeviceCode        = "incorrect_device_code"
-	errAccessDenied               = "access_denied"
+// OAuth2 device flow error codes, see:
+// https://docs.github.com/en/free-pro-team@latest/developers/apps/authorizing-oauth-apps#error-codes-for-the-device-flow
+var (
+	errAuthorizationPending       = &oauthError{Code: "authorization_pending"}
+	errSlowDown                   = &oauthError{Code: "slow_down"}
+	errExpiredToken               = &oauthError{Code: "expired_token"}
+	errUnsupportedGrantType       = &oauthError{Code: "unsupported_grant_type"}
+	errIncorrectClientCredentials = &oauthError{Code: "incorrect_client_credentials"}
+	errIncorrectDeviceCode        = &oauthError{Code: "incorrect_device_code"}
+	errAccessDenied               = &oauthError{Code: "access_denied"}
 )
 
+// errAuthorizationTimeout is returned when the user doesn't authorize the device in time
+var errAuthorizationTimeout = errors.New("timeout reached")
+
 // githubEndpoint is an endpoint representation for GitHub API authentication
 var githubEndpoint = endpoint{
-	AuthURL:       "",
-	DeviceAuthURL: "https://github.com/login/device/code",
-	TokenURL:      "https://github.com/login/oauth/access_token",
-	ReleasesURL:   "https://api.github.com/repos/{ower}/{repo}/releases",
+	AuthURL:       defaultAuthURL,
+	DeviceAuthURL: defaultAuthURL + deviceAuthPath,
+	TokenURL:      defaultAuthURL + tokenPath,
+	APIURL:        "https://api.github.com",
+	UploadURL:     "",
 }
 
+// defaultAuthURL is github.com, where the device flow and the release pages are served
+const defaultAuthURL = "https://github.com"
+
+// The paths of the device flow endpoints, under the AuthURL
+const (
+	deviceAuthPath = "/login/device/code"
+	tokenPath      = "/login/oauth/access_token"
+)
+
 // endpoint contains the different authentication urls for a given service
 type endpoint struct {
+	// AuthURL is the Github web host, eg: https://github.example.com on Github Enterprise Server
 	AuthURL       string
 	DeviceAuthURL string
 	TokenURL      string
-	ReleasesURL   string
+	// APIURL is the base of the REST API, eg: https://github.example.com/api/v3
+	APIURL string
+	// UploadURL, if set, replaces the base of the upload_url the API returns for releases,
+	// eg: https://github.example.com/api/uploads
+	UploadURL string
+}
+
+// githubAPIURL returns the URL of the REST API path, eg: "user"
+func githubAPIURL(path string) string {
+	return strings.TrimSuffix(githubEndpoint.APIURL, "/") + "/" + path
+}
+
+// githubWebURL returns the URL of the path on the Github web host, eg: "o/r/releases"
+func githubWebURL(path string) string {
+	return strings.TrimSuffix(githubEndpoint.AuthURL, "/") + "/" + path
+}
+
+// defaultGithubHost is the host of github.com repositories
+const defaultGithubHost = "github.com"
+
+// githubHost returns the host of the Github web host, which repositories are cloned from
+func githubHost() string {
+	u, err := url.Parse(githubEndpoint.AuthURL)
+	if err != nil || u.Host == "" {
+		return defaultGithubHost
+	}
+	return u.Host
+}
+
+// validEndpointURL returns true if u is an absolute http(s) URL
+func validEndpointURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
 }
 
 // DeviceAuth contains the response from an OAuth2 device flow auth request
@@ -99,6 +151,8 @@ type UserAuth struct {
 	TokenType   string `json:"token_type"`
 	Scope       string `json:"scope"`
 	raw         map[string]interface{}
+	// expiresAt is when the token expires, if it is known to, eg: a Github App's
+	expiresAt time.Time
 }
 
 type releases []release
@@ -122,7 +176,9 @@ type release struct {
 	TarballURL  *string  `json:"tarball_url,omitempty"`
 	Author      *user    `json:"author,omitempty"`
 	NodeID      *string  `json:"node_id,omitempty"`
-	raw         map[string]interface{}
+	// DiscussionURL is the discussion opened for the release, if any
+	DiscussionURL *string `json:"discussion_url,omitempty"`
+	raw           map[string]interface{}
 }
 
 type asset struct {
@@ -188,6 +244,75 @@ func newPostRequest(url string, data io.Reader, headers ...map[string]string) (*
 	return r, nil
 }
 
+// newJSONPostRequest creates an http.Request posting the payload, encoded as JSON,
+// to the provided URL
+func newJSONPostRequest(url string, payload interface{}, headers ...map[string]string) (*http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPostRequest(url, bytes.NewReader(data), append(headers, jsonHeaders())...)
+}
+
+// newPatchRequest creates an http.Request using the provided URL and JSON data
+// and sets the Content-Type and Accept headers to values we can work with
+func newPatchRequest(url string, data io.Reader, headers ...map[string]string) (*http.Request, error) {
+	r, err := http.NewRequest("PATCH", url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	for _, h := range headers {
+		for k, v := range h {
+			r.Header.Set(k, v)
+		}
+	}
+
+	return r, nil
+}
+
+// newDeleteRequest creates a DELETE http.Request using the provided URL
+func newDeleteRequest(url string, headers ...map[string]string) (*http.Request, error) {
+	r, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	for _, h := range headers {
+		for k, v := range h {
+			r.Header.Set(k, v)
+		}
+	}
+
+	return r, nil
+}
+
+// githubRepoURL returns the Github API URL for the path under the repository,
+// eg: "releases" or "issues/1/comments"
+func githubRepoURL(gURL *gitURL, path string) string {
+	return githubAPIURL(fmt.Sprintf("repos/%s/%s/%s", gURL.organization, gURL.repository, path))
+}
+
+// authHeaders returns the Authorization header for requests made on behalf of the user
+func authHeaders(auth *UserAuth) map[string]string {
+	return map[string]string{
+		"Authorization": fmt.Sprintf("%s %s", auth.TokenType, auth.AccessToken),
+	}
+}
+
+// jsonHeaders returns the Content-Type header for requests with a JSON body
+func jsonHeaders() map[string]string {
+	return map[string]string{
+		"Content-Type": "application/json",
+	}
+}
+
 // getAccessToken calls into the userAuthURL to check and see if the user has authorized
 // this device to act on their behalf, and returns a response
 func getAccessToken(req *http.Request) (*UserAuth, bool, error) {
@@ -209,24 +334,18 @@ func getAccessToken(req *http.Request) (*UserAuth, bool, error) {
 		return nil, false, err
 	}
 
-	switch e := auth.raw["error"]; e {
-	case "authorization_pending":
+	code, _ := auth.raw["error"].(string)
+	if code == "" {
+		return auth, true, nil
+	}
+
+	description, _ := auth.raw["error_description"].(string)
+	e := &oauthError{Code: code, Description: description}
+	if errors.Is(e, errAuthorizationPending) {
 		return auth, false, nil
-	case "slow_down":
-		return auth, false, fmt.Errorf("%v", e)
-	case "expired_token":
-		return auth, false, fmt.Errorf("%v", e)
-	case "unsupported_grant_type":
-		return auth, false, fmt.Errorf("%v", e)
-	case "incorrect_client_credentials":
-		return auth, false, fmt.Errorf("%v", e)
-	case "incorrect_device_code":
-		return auth, false, fmt.Errorf("%v", e)
-	case "access_denied":
-		return auth, false, fmt.Errorf("%v", e)
 	}
 
-	return auth, true, nil
+	return auth, false, e
 
 }
 
@@ -237,11 +356,18 @@ func makeHTTPRequest(req *http.Request) ([]byte, error) {
 		noteInfo("Making HTTP Request")
 	}
 
+	// Wait out Github maintenance and outages, up to maxWait
+	return retryUnavailable(req, doHTTPRequest)
+}
+
+// doHTTPRequest executes the request once, and returns the response body
+func doHTTPRequest(req *http.Request) ([]byte, error) {
 	// create a context and execute the http request
-	r, err := ctxhttp.Do(context.TODO(), nil, req)
+	r, err := ctxhttp.Do(context.TODO(), httpClient, req)
 	if err != nil {
 		return nil, err
 	}
+	defer r.Body.Close()
 
 	// read the body of the returned request
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
@@ -249,9 +375,15 @@ func makeHTTPRequest(req *http.Request) ([]byte, error) {
 		return nil, err
 	}
 
-	// Return the error if we don't receive a 200 or a 201
-	if code := r.StatusCode; code != 200 && code != 201 {
-		return body, fmt.Errorf(r.Status)
+	// Return the error if we don't receive a 200, a 201, or a 204 for deletes
+	if code := r.StatusCode; code != 200 && code != 201 && code != 204 {
+		return nil, &httpError{
+			StatusCode:          code,
+			Status:              r.Status,
+			Body:                body,
+			RetryAfter:          parseRetryAfter(r.Header.Get("Retry-After")),
+			AcceptedPermissions: r.Header.Get(acceptedPermissionsHeader),
+		}
 	}
 
 	return body, err
@@ -273,7 +405,7 @@ func pollForAccessToken(userAuthURL, clientID, deviceCode, grantType string, exp
 	for {
 		select {
 		case <-timeout:
-			return nil, errors.New("timeout reached")
+			return nil, errAuthorizationTimeout
 		case <-ticker:
 			// create an http.Request
 			req, err := newPostRequest(userAuthURL, strings.NewReader(params.Encode()))
@@ -285,7 +417,7 @@ func pollForAccessToken(userAuthURL, clientID, deviceCode, grantType string, exp
 				return auth, nil
 			}
 			if err != nil {
-				if err.Error() == "slow_down" {
+				if errors.Is(err, errSlowDown) {
 					if verbose {
 						noteInfo(fmt.Sprintf("slow down; adding %v seconds to interval\n", auth.raw["interval"]))
 					}
@@ -339,6 +471,11 @@ func requestDeviceAndUserCodes(deviceAuthURL, clientID, scope string) (*DeviceAu
 }
 
 func openbrowser(url string) {
+	// There is no one to look at it in CI; the callers print the URL
+	if ciMode {
+		return
+	}
+
 	var err error
 
 	switch runtime.GOOS {
@@ -357,62 +494,93 @@ func openbrowser(url string) {
 
 }
 
-// getReleases retrieves a slice of releases from the gitURL
+// getReleases lists the releases of the repository, without auth, so drafts aren't
+// included. The list is cached for the rest of the run, and concurrent lookups of the
+// same repository share one request.
 func getReleases(gURL *gitURL) (*releases, error) {
+	return releasesCache.get(releaseCacheKey(gURL), func() (*releases, error) {
+		return fetchReleases(nil, gURL)
+	})
+}
+
+// releasesPerPage is the most releases Github lists in a page
+const releasesPerPage = 100
+
+// fetchReleases requests the list of releases of the repository, a page at a time until
+// the last. Drafts, and the releases of private repositories, are only listed with auth.
+func fetchReleases(auth *UserAuth, gURL *gitURL) (*releases, error) {
 	var releasesList releases
-	// TEMP RELEASES URL HERE; LEARN TO TEMPLATE AND USE githubEndpoint.ReleasesURL
-	releasesURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", gURL.organization, gURL.repository)
 
-	req, err := newGetRequest(releasesURL, url.Values{})
-	if err != nil {
-		return &releasesList, err
-	}
+	for page := 1; ; page++ {
+		query := url.Values{"per_page": {strconv.Itoa(releasesPerPage)}, "page": {strconv.Itoa(page)}}
+		req, err := newGetRequest(githubRepoURL(gURL, "releases")+"?"+query.Encode(), url.Values{})
+		if err != nil {
+			return &releasesList, err
+		}
+		if auth != nil {
+			for k, v := range authHeaders(auth) {
+				req.Header.Set(k, v)
+			}
+		}
 
-	body, err := makeHTTPRequest(req)
-	if err != nil {
-		return &releasesList, err
-	}
+		body, err := makeHTTPRequest(req)
+		if err != nil {
+			return &releasesList, err
+		}
 
-	if err = json.Unmarshal(body, &releasesList); err != nil {
-		return &releasesList, err
-	}
+		var pageList releases
+		if err = json.Unmarshal(body, &pageList); err != nil {
+			return nil, err
+		}
+		releasesList = append(releasesList, pageList...)
 
-	// unmarshal the raw data
-	if err = json.Unmarshal(body, &releasesList); err != nil {
-		return nil, err
+		if len(pageList) < releasesPerPage {
+			return &releasesList, nil
+		}
 	}
-
-	return &releasesList, nil
 }
 
-// createRelease accepts a release name, description, commit value, tag name, target_commitish
-func createRelease(auth *UserAuth, gURL *gitURL, tag, tagMessage, commitish string, draft, prerelease bool) (*release, error) {
-	var newRelease release
-
-	// TEMP RELEASES URL HERE; LEARN TO TEMPLATE AND USE githubEndpoint.ReleasesURL
-	releasesURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", gURL.organization, gURL.repository)
-
-	headers := make(map[string]string)
-	headers["Authorization"] = fmt.Sprintf("%s %s", auth.TokenType, auth.AccessToken)
+// releaseForTag returns the release of the tag, or nil if it has none
+func releaseForTag(releasesList *releases, tag string) *release {
+	if releasesList == nil {
+		return nil
+	}
+	for i, r := range *releasesList {
+		if r.TagName != nil && *r.TagName == tag {
+			return &(*releasesList)[i]
+		}
+	}
+	return nil
+}
 
+// createRelease accepts a tag name, release name, description, target_commitish, and
+// whether the release is a draft or prerelease
+func createRelease(p provider, auth *UserAuth, gURL *gitURL, tag, name, tagMessage, commitish string, draft, prerelease bool) (*release, error) {
 	releaseRequest := &newReleaseRequest{
 		TagName:    tag,
-		Name:       tag,
+		Name:       name,
 		Body:       tagMessage,
 		Draft:      draft,
 		Prerelease: prerelease,
+		MakeLatest: markLatest,
+		// Github opens the discussion once a draft is published
+		DiscussionCategoryName: discussionCategory,
 	}
 
 	if commitish != "" {
 		releaseRequest.TargetCommitish = commitish
 	}
 
-	data, err := json.Marshal(releaseRequest)
-	if err != nil {
-		return nil, err
-	}
+	return p.createRelease(auth, gURL, releaseRequest)
+}
+
+// postRelease creates the release described by the request
+func postRelease(auth *UserAuth, gURL *gitURL, releaseRequest *newReleaseRequest) (*release, error) {
+	var newRelease release
 
-	req, err := newPostRequest(releasesURL, bytes.NewBuffer(data), headers)
+	releasesURL := githubRepoURL(gURL, "releases")
+
+	req, err := newJSONPostRequest(releasesURL, releaseRequest, authHeaders(auth))
 	if err != nil {
 		return nil, err
 	}
@@ -421,6 +589,15 @@ func createRelease(auth *UserAuth, gURL *gitURL, tag, tagMessage, commitish stri
 	// If there's an error here, wait until after trying to unmarshal the body from JSON
 	// so we might get an indication of the issue
 	body, requestErr := makeHTTPRequest(req)
+	var respErr *httpError
+	if errors.As(requestErr, &respErr) {
+		body = respErr.Body
+	} else if requestErr != nil {
+		return nil, requestErr
+	}
+
+	// The new release isn't in any cached list of releases
+	releasesCache.invalidate(releaseCacheKey(gURL))
 
 	if err := json.Unmarshal(body, &newRelease); err != nil {
 		return nil, err
@@ -448,4 +625,616 @@ type newReleaseRequest struct {
 	Body            string `json:"body,omitempty"`
 	Draft           bool   `json:"draft,omitempty"`
 	Prerelease      bool   `json:"prerelease,omitempty"`
+	// MakeLatest is "true", "false" or "legacy"; Github makes new releases the latest by default
+	MakeLatest string `json:"make_latest,omitempty"`
+	// DiscussionCategoryName opens a discussion in the category linked to the release
+	DiscussionCategoryName string `json:"discussion_category_name,omitempty"`
+}
+
+// releaseUpdateRequest is the payload for editing an existing release.
+// Only the fields that are set are changed.
+type releaseUpdateRequest struct {
+	Name       *string `json:"name,omitempty"`
+	Body       *string `json:"body,omitempty"`
+	Draft      *bool   `json:"draft,omitempty"`
+	Prerelease *bool   `json:"prerelease,omitempty"`
+	MakeLatest string  `json:"make_latest,omitempty"`
+}
+
+// getRelease retrieves a single release by ID. Authentication is required to see drafts.
+func getRelease(auth *UserAuth, gURL *gitURL, id int) (*release, error) {
+	req, err := newGetRequest(githubRepoURL(gURL, fmt.Sprintf("releases/%d", id)), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var r release
+	if err = json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// getReleaseByTag requests the published release of the tag, or returns nil if it has
+// none. Drafts aren't found by their tag.
+// https://docs.github.com/en/rest/releases/releases#get-a-release-by-tag-name
+func getReleaseByTag(auth *UserAuth, gURL *gitURL, tag string) (*release, error) {
+	req, err := newGetRequest(githubRepoURL(gURL, "releases/tags/"+url.PathEscape(tag)), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		for k, v := range authHeaders(auth) {
+			req.Header.Set(k, v)
+		}
+	}
+
+	body, err := makeHTTPRequest(req)
+	if isHTTPStatus(err, 404) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var r release
+	if err = json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// findRelease returns the release of the tag, or nil if it has none. Github is asked for
+// the release of the tag itself, and the listed releases, matched by their tag rather
+// than their name, cover drafts and the other forges.
+func findRelease(forge provider, auth *UserAuth, gURL *gitURL, releasesList *releases, tag string) (*release, error) {
+	if _, github := forge.(*githubProvider); github {
+		r, err := getReleaseByTag(auth, gURL, tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed retrieving the release of %s: %w", tag, err)
+		}
+		if r != nil {
+			return r, nil
+		}
+	}
+
+	return releaseForTag(releasesList, tag), nil
+}
+
+// repository is the part of a Github repository used by the tool
+type repository struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	// Permissions are those of the authenticated user, if any
+	Permissions *struct {
+		Push bool `json:"push"`
+	} `json:"permissions"`
+}
+
+// getRepository retrieves the repository, authenticated as the user if auth isn't nil
+func getRepository(auth *UserAuth, gURL *gitURL) (*repository, error) {
+	req, err := newGetRequest(strings.TrimSuffix(githubRepoURL(gURL, ""), "/"), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		for k, v := range authHeaders(auth) {
+			req.Header.Set(k, v)
+		}
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var r repository
+	if err = json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// getDefaultBranch retrieves the name of the repository's default branch
+func getDefaultBranch(auth *UserAuth, gURL *gitURL) (string, error) {
+	r, err := getRepository(auth, gURL)
+	if err != nil {
+		return "", err
+	}
+
+	if r.DefaultBranch == "" {
+		return "", errors.New("repository has no default branch")
+	}
+
+	return r.DefaultBranch, nil
+}
+
+// updateRelease edits an existing release, eg: to publish a draft
+func updateRelease(auth *UserAuth, gURL *gitURL, id int, update *releaseUpdateRequest) (*release, error) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newPatchRequest(githubRepoURL(gURL, fmt.Sprintf("releases/%d", id)), bytes.NewBuffer(data), authHeaders(auth))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	releasesCache.invalidate(releaseCacheKey(gURL))
+
+	var r release
+	if err = json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// assetUploadURL returns the URL to upload the named asset to, from the
+// release's upload_url template, eg: ".../releases/1/assets{?name,label}", or where
+// the upload URL settings rewrite it to
+func assetUploadURL(r *release, name string) (string, error) {
+	if r.UploadURL == nil {
+		return "", errors.New("release has no upload url")
+	}
+
+	base := *r.UploadURL
+	if i := strings.Index(base, "{"); i >= 0 {
+		base = base[:i]
+	}
+
+	base, err := rewriteUploadURL(base)
+	if err != nil {
+		return "", err
+	}
+
+	return base + "?" + url.Values{"name": {name}}.Encode(), nil
+}
+
+// uploadAsset uploads an artifact to the release as a raw binary request body
+func uploadAsset(auth *UserAuth, r *release, a *artifact) (*asset, error) {
+	uploadURL, err := assetUploadURL(r, a.name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := authHeaders(auth)
+	headers["Content-Type"] = "application/octet-stream"
+
+	req, err := newPostRequest(uploadURL, f, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream the file rather than reading it into memory. Github needs the length up
+	// front, and GetBody lets the body be read again, eg: to trace the request.
+	req.ContentLength = info.Size()
+	req.GetBody = func() (io.ReadCloser, error) {
+		return os.Open(a.path)
+	}
+	if info.Size() == 0 {
+		req.Body = http.NoBody
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploaded asset
+	if err = json.Unmarshal(body, &uploaded); err != nil {
+		return nil, err
+	}
+
+	return &uploaded, nil
+}
+
+// uploadAttempts is how many times an asset upload is attempted before giving up
+const uploadAttempts = 3
+
+// uploadRetryDelay is the wait before the first retry of a failed upload, growing with each attempt
+var uploadRetryDelay = 2 * time.Second
+
+// retryableUpload returns true if the failed upload may succeed if tried again
+func retryableUpload(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	// 422s are returned when an asset with the same name is left from a failed upload
+	for _, code := range []int{422, 500, 502, 503, 504} {
+		if isHTTPStatus(err, code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uploadAssetWithRetry uploads the artifact, retrying failed uploads. Github's upload
+// endpoint can fail with a 502 and leave an empty asset in the "starter" state, and an
+// asset with the same name makes the retry fail, so it is deleted before retrying. An
+// uploaded asset of the same name is never replaced here; that takes --replaceAssets.
+func uploadAssetWithRetry(auth *UserAuth, r *release, a *artifact) (*asset, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var u *asset
+		u, err = uploadAsset(auth, r, a)
+		if err == nil {
+			return u, nil
+		}
+
+		if attempt == uploadAttempts || !retryableUpload(err) {
+			return nil, err
+		}
+
+		if verbose {
+			noteErr(fmt.Sprintf("upload of %s failed: %s; retrying", a.name, err))
+		}
+
+		leftover, cleanupErr := deleteLeftoverAssets(auth, r, a.name)
+		if cleanupErr != nil {
+			return nil, fmt.Errorf("%w; and failed removing leftover assets: %s", err, cleanupErr)
+		}
+		// Without a leftover, the name is taken by an asset that was uploaded
+		if isHTTPStatus(err, 422) && !leftover {
+			return nil, err
+		}
+
+		time.Sleep(uploadRetryDelay * time.Duration(attempt))
+	}
+}
+
+// assetsPerPage is the most release assets Github lists in a page
+const assetsPerPage = 100
+
+// listReleaseAssets retrieves the assets of the release, a page at a time until the last
+func listReleaseAssets(auth *UserAuth, r *release) ([]*asset, error) {
+	if r.URL == nil {
+		return nil, errors.New("release has no url")
+	}
+
+	var assets []*asset
+	for page := 1; ; page++ {
+		query := url.Values{"per_page": {strconv.Itoa(assetsPerPage)}, "page": {strconv.Itoa(page)}}
+		req, err := newGetRequest(*r.URL+"/assets?"+query.Encode(), url.Values{})
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range authHeaders(auth) {
+			req.Header.Set(k, v)
+		}
+
+		body, err := makeHTTPRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageAssets []*asset
+		if err = json.Unmarshal(body, &pageAssets); err != nil {
+			return nil, err
+		}
+		assets = append(assets, pageAssets...)
+
+		if len(pageAssets) < assetsPerPage {
+			return assets, nil
+		}
+	}
+}
+
+// deleteRelease deletes a release, leaving its tag in place. Releases that are already
+// gone are not an error.
+func deleteRelease(auth *UserAuth, gURL *gitURL, id int) error {
+	req, err := newDeleteRequest(githubRepoURL(gURL, fmt.Sprintf("releases/%d", id)), authHeaders(auth))
+	if err != nil {
+		return err
+	}
+	if _, err = makeHTTPRequest(req); err != nil && !isHTTPStatus(err, 404) {
+		return err
+	}
+	releasesCache.invalidate(releaseCacheKey(gURL))
+
+	return nil
+}
+
+// deleteAsset deletes a release asset. Assets that are already gone are not an error.
+func deleteAsset(auth *UserAuth, a *asset) error {
+	if a.URL == nil {
+		return nil
+	}
+
+	req, err := newDeleteRequest(*a.URL, authHeaders(auth))
+	if err != nil {
+		return err
+	}
+	if _, err = makeHTTPRequest(req); err != nil && !isHTTPStatus(err, 404) {
+		return err
+	}
+
+	return nil
+}
+
+// deleteLeftoverAssets deletes the empty asset a failed upload of the name left in the
+// "starter" state, returning true if there was one. Only the named asset is deleted, as
+// the other uploads running alongside are starters until they finish.
+func deleteLeftoverAssets(auth *UserAuth, r *release, name string) (bool, error) {
+	assets, err := listReleaseAssets(auth, r)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, a := range assets {
+		starter := a.State != nil && *a.State == "starter"
+		if !starter || a.Name == nil || *a.Name != name {
+			continue
+		}
+
+		if verbose && a.URL != nil {
+			noteInfo(fmt.Sprintf("Deleting leftover asset %s", *a.URL))
+		}
+
+		if err = deleteAsset(auth, a); err != nil {
+			return found, err
+		}
+		found = true
+	}
+
+	return found, nil
+}
+
+// replacementSuffix is added to the names of the assets uploaded to replace existing ones,
+// until the existing ones are deleted and they take their names
+const replacementSuffix = ".replacing"
+
+// uploadReplacingAssets uploads the artifacts to the release on the forge, replacing its
+// assets with the same names. The replacements are uploaded under a temporary name, and
+// only once every upload has succeeded are the existing assets deleted and the
+// replacements renamed, so a failed upload leaves the release's assets as they were. It
+// returns the uploaded assets, in the order of the artifacts, and the names of the assets
+// that were replaced.
+func uploadReplacingAssets(p provider, auth *UserAuth, r *release, artifacts []*artifact) ([]*asset, []string, error) {
+	assets, err := listReleaseAssets(auth, r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed listing existing assets: %w", err)
+	}
+
+	// Uploads left unfinished, and the replacements of an earlier failed run, are deleted
+	existing := make(map[string]*asset)
+	for _, a := range assets {
+		if a.Name == nil {
+			continue
+		}
+		starter := a.State != nil && *a.State == "starter"
+		if starter || strings.HasSuffix(*a.Name, replacementSuffix) {
+			if verbose {
+				noteInfo(fmt.Sprintf("Deleting leftover asset %s", *a.Name))
+			}
+			if err = deleteAsset(auth, a); err != nil {
+				return nil, nil, fmt.Errorf("failed deleting leftover asset %s: %w", *a.Name, err)
+			}
+			continue
+		}
+		existing[*a.Name] = a
+	}
+
+	uploads := make([]*artifact, len(artifacts))
+	for i, a := range artifacts {
+		uploads[i] = a
+		if existing[a.name] != nil {
+			replacement := *a
+			replacement.name = a.name + replacementSuffix
+			uploads[i] = &replacement
+		}
+	}
+
+	uploaded, err := uploadAssets(p, auth, r, uploads)
+	if err != nil {
+		// The replacements that were uploaded are deleted again, leaving the existing assets
+		for _, a := range uploaded {
+			if a.Name == nil || !strings.HasSuffix(*a.Name, replacementSuffix) {
+				continue
+			}
+			if derr := deleteAsset(auth, a); derr != nil {
+				fmt.Printf("WARNING: failed deleting replacement asset %s: %s\n", *a.Name, derr)
+			}
+		}
+		return nil, nil, err
+	}
+
+	var replaced []string
+	for i, a := range artifacts {
+		old := existing[a.name]
+		if old == nil {
+			continue
+		}
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Replacing existing asset %s", a.name))
+		}
+
+		// Github refuses two assets of the same name, so the replacement can only be
+		// renamed once the existing asset is deleted
+		if err = deleteAsset(auth, old); err != nil {
+			return uploaded, replaced, fmt.Errorf("failed deleting existing asset %s: %w", a.name, err)
+		}
+		renamed, err := renameAssetWithRetry(auth, uploaded[i], a.name)
+		if err != nil {
+			return uploaded, replaced, fmt.Errorf("failed renaming replacement asset %s, left as %s; rename it to %s: %w", a.name, a.name+replacementSuffix, a.name, err)
+		}
+		uploaded[i] = renamed
+		replaced = append(replaced, a.name)
+	}
+
+	return uploaded, replaced, nil
+}
+
+// renameAssetWithRetry renames a release asset, retrying failed renames as uploads are
+func renameAssetWithRetry(auth *UserAuth, a *asset, name string) (*asset, error) {
+	for attempt := 1; ; attempt++ {
+		renamed, err := renameAsset(auth, a, name)
+		if err == nil {
+			return renamed, nil
+		}
+
+		if attempt == uploadAttempts || !retryableUpload(err) {
+			return nil, err
+		}
+
+		if verbose {
+			noteErr(fmt.Sprintf("renaming %s failed: %s; retrying", name, err))
+		}
+
+		time.Sleep(uploadRetryDelay * time.Duration(attempt))
+	}
+}
+
+// renameAsset renames a release asset, returning the renamed asset
+// https://docs.github.com/en/rest/releases/assets#update-a-release-asset
+func renameAsset(auth *UserAuth, a *asset, name string) (*asset, error) {
+	if a.URL == nil {
+		return nil, errors.New("asset has no url")
+	}
+
+	data, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newPatchRequest(*a.URL, bytes.NewBuffer(data), authHeaders(auth))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var renamed asset
+	if err = json.Unmarshal(body, &renamed); err != nil {
+		return nil, err
+	}
+
+	return &renamed, nil
+}
+
+// deleteUnbuiltAssets deletes the release's assets that aren't among the artifacts, so an
+// updated release has only those its latest run built. It returns the names of the
+// assets that were deleted.
+func deleteUnbuiltAssets(auth *UserAuth, r *release, artifacts []*artifact) ([]string, error) {
+	names := make(map[string]bool)
+	for _, a := range artifacts {
+		names[a.name] = true
+	}
+
+	assets, err := listReleaseAssets(auth, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, a := range assets {
+		if a.Name == nil || names[*a.Name] {
+			continue
+		}
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Deleting asset %s, which is no longer built", *a.Name))
+		}
+
+		if err = deleteAsset(auth, a); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, *a.Name)
+	}
+
+	return deleted, nil
+}
+
+// uploadAssets uploads the artifacts to the release on the forge, up to uploadConcurrency at a time.
+// The uploaded assets are returned in the order of the artifacts. Every artifact is
+// attempted, and any that fail are reported together in an uploadError.
+func uploadAssets(p provider, auth *UserAuth, r *release, artifacts []*artifact) ([]*asset, error) {
+	results := make([]*asset, len(artifacts))
+	errs := make([]error, len(artifacts))
+
+	workers := uploadConcurrency
+	if workers > len(artifacts) {
+		workers = len(artifacts)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	status := newProgress()
+	var finished int32
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if verbose {
+					noteInfo(fmt.Sprintf("Uploading %s (%s)", artifacts[i].name, formatSize(artifacts[i].size)))
+				}
+				results[i], errs[i] = p.uploadAsset(auth, r, artifacts[i])
+				status.update(fmt.Sprintf("Uploaded %d of %d assets", atomic.AddInt32(&finished, 1), len(artifacts)))
+			}
+		}()
+	}
+
+	for i := range artifacts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	status.done("")
+
+	uploaded := make([]*asset, 0, len(artifacts))
+	failures := &uploadError{}
+	for i, a := range artifacts {
+		if errs[i] != nil {
+			failures.failed = append(failures.failed, assetFailure{name: a.name, err: errs[i]})
+			continue
+		}
+		uploaded = append(uploaded, results[i])
+	}
+
+	if len(failures.failed) > 0 {
+		return uploaded, failures
+	}
+
+	return uploaded, nil
 }
@@ -2,15 +2,21 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	. "github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 )
 
 // TestNewGetRequest calls newGetRequest,
@@ -190,6 +196,570 @@ func TestRequestDeviceAndUserCodes(t *testing.T) {
 func TestGetReleases(t *testing.T) {
 }
 
-// TestCreateRelease
+// TestNewJSONPostRequest checks the payload is encoded as JSON, and the request says so
+func TestNewJSONPostRequest(t *testing.T) {
+	req, err := newJSONPostRequest("https://api.example.org/api/testendpoint", &newReleaseRequest{TagName: "v1.0", Draft: true}, map[string]string{"Authorization": "bearer abc123"})
+	Nil(t, err)
+
+	Equal(t, "POST", req.Method)
+	Equal(t, "application/json", req.Header.Get("Content-Type"))
+	Equal(t, "bearer abc123", req.Header.Get("Authorization"))
+
+	body, err := ioutil.ReadAll(req.Body)
+	Nil(t, err)
+	JSONEq(t, `{"tag_name": "v1.0", "draft": true}`, string(body))
+	// The body can be sent again when a request is retried
+	NotNil(t, req.GetBody)
+}
+
+// TestCreateRelease checks the release parameters are sent as a JSON body
 func TestCreateRelease(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+
+	gock.New("https://api.github.com").
+		Post("/repos/o/r/releases").
+		MatchHeader("Content-Type", "^application/json$").
+		MatchHeader("Authorization", "token secret").
+		JSON(map[string]interface{}{
+			"tag_name":         "v1.0.0",
+			"target_commitish": "abc123",
+			"name":             "v1.0.0",
+			"body":             "notes",
+			"prerelease":       true,
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{"id": 1, "tag_name": "v1.0.0", "html_url": "https://github.com/o/r/releases/tag/v1.0.0"})
+
+	gURL := &gitURL{organization: "o", repository: "r"}
+	r, err := createRelease(&githubProvider{}, &UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "v1.0.0", "v1.0.0", "notes", "abc123", false, true)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://github.com/o/r/releases/tag/v1.0.0", *r.HTMLURL)
+	True(t, gock.IsDone())
+}
+
+// TestCreateReleaseLatest checks --latest is sent as make_latest, so old versions don't take the badge
+func TestCreateReleaseLatest(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+	defer func(latest string) { markLatest = latest }(markLatest)
+	markLatest = "false"
+
+	gock.New("https://api.github.com").
+		Post("/repos/o/r/releases").
+		JSON(map[string]interface{}{
+			"tag_name":    "v1.0.1",
+			"name":        "v1.0.1",
+			"body":        "notes",
+			"make_latest": "false",
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{"id": 1, "tag_name": "v1.0.1"})
+
+	gURL := &gitURL{organization: "o", repository: "r"}
+	_, err := createRelease(&githubProvider{}, &UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "v1.0.1", "v1.0.1", "notes", "", false, false)
+	Nil(t, err, "%v", err)
+	True(t, gock.IsDone())
+}
+
+// TestCreateReleaseDiscussion checks the release opens a discussion in the --discussionCategory
+func TestCreateReleaseDiscussion(t *testing.T) {
+	defer gock.Off()
+	defer releasesCache.reset()
+	defer func(category string) { discussionCategory = category }(discussionCategory)
+	discussionCategory = "Announcements"
+
+	gock.New("https://api.github.com").
+		Post("/repos/o/r/releases").
+		JSON(map[string]interface{}{
+			"tag_name":                 "v1.0.0",
+			"name":                     "v1.0.0",
+			"body":                     "notes",
+			"discussion_category_name": "Announcements",
+		}).
+		Reply(201).
+		JSON(map[string]interface{}{"id": 1, "tag_name": "v1.0.0", "discussion_url": "https://github.com/o/r/discussions/7"})
+
+	gURL := &gitURL{organization: "o", repository: "r"}
+	r, err := createRelease(&githubProvider{}, &UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "v1.0.0", "v1.0.0", "notes", "", false, false)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://github.com/o/r/discussions/7", *r.DiscussionURL)
+	True(t, gock.IsDone())
+}
+
+// TestUploadAssets checks every asset is attempted, the uploads are returned in
+// order, and each failure is reported
+func TestUploadAssets(t *testing.T) {
+	defer gock.Off()
+	defer func(d, w time.Duration) { uploadRetryDelay, maxWait = d, w }(uploadRetryDelay, maxWait)
+	uploadRetryDelay, maxWait = 0, 0
+
+	dir, err := ioutil.TempDir("", "upload")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var artifacts []*artifact
+	for _, name := range []string{"app-darwin", "app-linux", "app-windows.exe"} {
+		path := filepath.Join(dir, name)
+		Nil(t, ioutil.WriteFile(path, []byte(name), 0644))
+		artifacts = append(artifacts, &artifact{path: path, name: name, size: int64(len(name))})
+	}
+
+	for _, name := range []string{"app-darwin", "app-windows.exe"} {
+		gock.New("https://uploads.github.com").
+			Post("/repos/o/r/releases/1/assets").
+			MatchParam("name", name).
+			Reply(201).
+			JSON(map[string]string{"name": name})
+	}
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		MatchParam("name", "app-linux").
+		Times(uploadAttempts).
+		Reply(500)
+	// The other uploads are in flight, so their starter assets are left alone
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Times(uploadAttempts - 1).
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app-darwin", "state": "starter", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+		})
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{URL: &releaseURL, UploadURL: &uploadURL}
+	auth := &UserAuth{TokenType: "bearer", AccessToken: "token"}
+
+	uploaded, err := uploadAssets(&githubProvider{}, auth, r, artifacts)
+
+	var e *uploadError
+	if True(t, errors.As(err, &e)) {
+		Equal(t, 1, len(e.failed))
+		Equal(t, "app-linux", e.failed[0].name)
+		True(t, isHTTPStatus(err, 500))
+		NotContains(t, err.Error(), "leftover")
+	}
+
+	names := []string{}
+	for _, u := range uploaded {
+		names = append(names, *u.Name)
+	}
+	Equal(t, []string{"app-darwin", "app-windows.exe"}, names)
+}
+
+// TestUploadAssetStreams checks assets are streamed with their length set up front
+func TestUploadAssetStreams(t *testing.T) {
+	defer gock.Off()
+
+	f, err := ioutil.TempFile("", "asset")
+	Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.Write([]byte("binary\x00contents"))
+	Nil(t, err)
+	f.Close()
+
+	var length int64
+	var streamed bool
+	var body []byte
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			length = req.ContentLength
+			_, streamed = req.Body.(*os.File)
+			body, err = ioutil.ReadAll(req.Body)
+			return true, err
+		}).
+		Reply(201).
+		JSON(map[string]string{"name": "asset"})
+
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	_, err = uploadAsset(&UserAuth{}, &release{UploadURL: &uploadURL}, &artifact{path: f.Name(), name: "asset"})
+	Nil(t, err)
+	Equal(t, int64(15), length)
+	True(t, streamed)
+	Equal(t, "binary\x00contents", string(body))
+}
+
+// TestUploadAssetRetry checks a failed upload's leftover assets are deleted and the upload retried
+func TestUploadAssetRetry(t *testing.T) {
+	defer gock.Off()
+	defer func(d, w time.Duration) { uploadRetryDelay, maxWait = d, w }(uploadRetryDelay, maxWait)
+	uploadRetryDelay = 0
+	// The upload's own retry handles the 502, rather than waiting for Github to recover
+	maxWait = 0
+
+	f, err := ioutil.TempFile("", "asset")
+	Nil(t, err)
+	defer os.Remove(f.Name())
+	f.Close()
+
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		Reply(502)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app", "state": "starter", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+			{"name": "other", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/11"},
+		})
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/assets/10").
+		Reply(204)
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		Reply(201).
+		JSON(map[string]string{"name": "app", "state": "uploaded"})
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{URL: &releaseURL, UploadURL: &uploadURL}
+
+	u, err := uploadAssetWithRetry(&UserAuth{}, r, &artifact{path: f.Name(), name: "app"})
+	Nil(t, err)
+	Equal(t, "uploaded", *u.State)
+	True(t, gock.IsDone())
+}
+
+// TestUploadAssetRetryExisting checks an uploaded asset of the same name is not replaced, and the upload not retried
+func TestUploadAssetRetryExisting(t *testing.T) {
+	defer gock.Off()
+	defer func(d time.Duration) { uploadRetryDelay = d }(uploadRetryDelay)
+	uploadRetryDelay = 0
+
+	f, err := ioutil.TempFile("", "asset")
+	Nil(t, err)
+	defer os.Remove(f.Name())
+	f.Close()
+
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		Reply(422)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+		})
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{URL: &releaseURL, UploadURL: &uploadURL}
+
+	_, err = uploadAssetWithRetry(&UserAuth{}, r, &artifact{path: f.Name(), name: "app"})
+	True(t, isHTTPStatus(err, 422))
+	True(t, gock.IsDone())
+}
+
+// TestGetDefaultBranch checks the default branch is read from the repository, with the token for private repositories
+func TestGetDefaultBranch(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r").
+		MatchHeader("Authorization", "token secret").
+		Reply(200).
+		JSON(map[string]string{"default_branch": "trunk"})
+
+	b, err := getDefaultBranch(&UserAuth{AccessToken: "secret", TokenType: "token"}, &gitURL{organization: "o", repository: "r"})
+	Nil(t, err)
+	Equal(t, "trunk", b)
+}
+
+// TestListReleaseAssetsPages checks every page of assets is listed, until one isn't full
+func TestListReleaseAssetsPages(t *testing.T) {
+	defer gock.Off()
+
+	full := make([]map[string]interface{}, assetsPerPage)
+	for i := range full {
+		full[i] = map[string]interface{}{"id": i, "name": fmt.Sprintf("app-%d", i)}
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		MatchParam("page", "1").
+		MatchParam("per_page", "100").
+		Reply(200).
+		JSON(full)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		MatchParam("page", "2").
+		MatchHeader("Authorization", "token secret").
+		Reply(200).
+		JSON([]map[string]interface{}{{"id": 100, "name": "SHA256SUMS"}})
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	assets, err := listReleaseAssets(&UserAuth{AccessToken: "secret", TokenType: "token"}, &release{URL: &releaseURL})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Len(t, assets, assetsPerPage+1)
+	Equal(t, "SHA256SUMS", *assets[assetsPerPage].Name)
+	True(t, gock.IsDone())
+}
+
+// TestUploadReplacingAssets checks the replacements are uploaded before the existing
+// assets are deleted, and renamed after
+func TestUploadReplacingAssets(t *testing.T) {
+	defer gock.Off()
+
+	dir := t.TempDir()
+	var artifacts []*artifact
+	for _, name := range []string{"app", "new"} {
+		path := filepath.Join(dir, name)
+		Nil(t, ioutil.WriteFile(path, []byte(name), 0644))
+		artifacts = append(artifacts, &artifact{path: path, name: name, size: int64(len(name))})
+	}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+			{"name": "other", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/11"},
+			{"name": "new.replacing", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/12"},
+		})
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/assets/12").
+		Reply(204)
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		MatchParam("name", "app.replacing").
+		Reply(201).
+		JSON(map[string]string{"name": "app.replacing", "url": "https://api.github.com/repos/o/r/releases/assets/20"})
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		MatchParam("name", "new").
+		Reply(201).
+		JSON(map[string]string{"name": "new", "url": "https://api.github.com/repos/o/r/releases/assets/21"})
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/assets/10").
+		Reply(204)
+	gock.New("https://api.github.com").
+		Patch("/repos/o/r/releases/assets/20").
+		JSON(map[string]string{"name": "app"}).
+		Reply(200).
+		JSON(map[string]string{"name": "app", "url": "https://api.github.com/repos/o/r/releases/assets/20"})
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{URL: &releaseURL, UploadURL: &uploadURL}
+
+	uploaded, replaced, err := uploadReplacingAssets(&githubProvider{}, &UserAuth{}, r, artifacts)
+	Nil(t, err)
+	Equal(t, []string{"app"}, replaced)
+	if Equal(t, 2, len(uploaded)) {
+		Equal(t, "app", *uploaded[0].Name)
+		Equal(t, "new", *uploaded[1].Name)
+	}
+	True(t, gock.IsDone())
+}
+
+// TestUploadReplacingAssetsFailure checks a failed upload leaves the existing assets, and
+// deletes the replacements that were uploaded
+func TestUploadReplacingAssetsFailure(t *testing.T) {
+	defer gock.Off()
+
+	dir := t.TempDir()
+	var artifacts []*artifact
+	for _, name := range []string{"app", "new"} {
+		path := filepath.Join(dir, name)
+		Nil(t, ioutil.WriteFile(path, []byte(name), 0644))
+		artifacts = append(artifacts, &artifact{path: path, name: name, size: int64(len(name))})
+	}
+
+	// The failed upload checks for a leftover of its own before giving up
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Times(2).
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+		})
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		MatchParam("name", "app.replacing").
+		Reply(201).
+		JSON(map[string]string{"name": "app.replacing", "url": "https://api.github.com/repos/o/r/releases/assets/20"})
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		MatchParam("name", "new").
+		Reply(422)
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/assets/20").
+		Reply(204)
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{URL: &releaseURL, UploadURL: &uploadURL}
+
+	_, replaced, err := uploadReplacingAssets(&githubProvider{}, &UserAuth{}, r, artifacts)
+	True(t, isHTTPStatus(err, 422))
+	Empty(t, replaced)
+	True(t, gock.IsDone())
+	False(t, gock.HasUnmatchedRequest())
+}
+
+// TestUploadReplacingAssetsRenameFailure checks a replacement that can't be renamed is
+// retried, and then named in the error
+func TestUploadReplacingAssetsRenameFailure(t *testing.T) {
+	defer gock.Off()
+	defer func(d time.Duration) { uploadRetryDelay = d }(uploadRetryDelay)
+	uploadRetryDelay = 0
+
+	path := filepath.Join(t.TempDir(), "app")
+	Nil(t, ioutil.WriteFile(path, []byte("app"), 0644))
+	artifacts := []*artifact{{path: path, name: "app", size: 3}}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+		})
+	gock.New("https://uploads.github.com").
+		Post("/repos/o/r/releases/1/assets").
+		MatchParam("name", "app.replacing").
+		Reply(201).
+		JSON(map[string]string{"name": "app.replacing", "url": "https://api.github.com/repos/o/r/releases/assets/20"})
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/assets/10").
+		Reply(204)
+	gock.New("https://api.github.com").
+		Patch("/repos/o/r/releases/assets/20").
+		Times(uploadAttempts).
+		Reply(502)
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	uploadURL := "https://uploads.github.com/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{URL: &releaseURL, UploadURL: &uploadURL}
+
+	_, replaced, err := uploadReplacingAssets(&githubProvider{}, &UserAuth{}, r, artifacts)
+	True(t, isHTTPStatus(err, 502))
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "left as app.replacing")
+	}
+	Empty(t, replaced)
+	True(t, gock.IsDone())
+}
+
+// TestDeleteUnbuiltAssets checks only the assets that aren't among the artifacts are deleted
+func TestDeleteUnbuiltAssets(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/1/assets").
+		Reply(200).
+		JSON([]map[string]string{
+			{"name": "app", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/10"},
+			{"name": "app-darwin", "state": "uploaded", "url": "https://api.github.com/repos/o/r/releases/assets/11"},
+		})
+	gock.New("https://api.github.com").
+		Delete("/repos/o/r/releases/assets/11").
+		Reply(204)
+
+	releaseURL := "https://api.github.com/repos/o/r/releases/1"
+	r := &release{URL: &releaseURL}
+
+	deleted, err := deleteUnbuiltAssets(&UserAuth{}, r, []*artifact{{name: "app"}})
+	Nil(t, err)
+	Equal(t, []string{"app-darwin"}, deleted)
+	True(t, gock.IsDone())
+}
+
+// TestReleaseForTag checks the release of the tag is found in the list, if it has one
+func TestReleaseForTag(t *testing.T) {
+	tags := []string{"v1.1.0", "v1.0.0"}
+	releasesList := releases{{TagName: &tags[0]}, {TagName: &tags[1]}}
+
+	Equal(t, &releasesList[1], releaseForTag(&releasesList, "v1.0.0"))
+	Nil(t, releaseForTag(&releasesList, "v2.0.0"))
+	Nil(t, releaseForTag(nil, "v1.0.0"))
+}
+
+// TestValidEndpointURL checks the device flow endpoints must be absolute http(s) URLs
+func TestValidEndpointURL(t *testing.T) {
+	True(t, validEndpointURL("https://github.com/login/device/code"))
+	True(t, validEndpointURL("http://ghe.example.com/login/oauth/access_token"))
+	False(t, validEndpointURL("github.com/login/device/code"))
+	False(t, validEndpointURL("ftp://github.com/login"))
+	False(t, validEndpointURL(""))
+}
+
+// TestEnterpriseEndpoints checks the API, web and upload URLs follow the configured hosts
+func TestEnterpriseEndpoints(t *testing.T) {
+	defer func(e endpoint) { githubEndpoint = e }(githubEndpoint)
+	gURL := &gitURL{organization: "o", repository: "r"}
+	uploadURL := "https://github.example.com/api/uploads/repos/o/r/releases/1/assets{?name,label}"
+	r := &release{UploadURL: &uploadURL}
+
+	Equal(t, "https://api.github.com/repos/o/r/releases", githubRepoURL(gURL, "releases"))
+	Equal(t, "github.com", githubHost())
+
+	githubEndpoint.AuthURL = "https://github.example.com/"
+	githubEndpoint.APIURL = "https://github.example.com/api/v3/"
+	Equal(t, "https://github.example.com/api/v3/repos/o/r/releases", githubRepoURL(gURL, "releases"))
+	Equal(t, "https://github.example.com/api/v3/user", githubUserURL())
+	Equal(t, "https://github.example.com/o/r/releases/latest", githubWebURL("o/r/releases/latest"))
+	Equal(t, "github.example.com", githubHost())
+
+	// The upload_url the API returns is used as it is, unless an upload URL is configured
+	u, err := assetUploadURL(r, "app")
+	Nil(t, err)
+	Equal(t, "https://github.example.com/api/uploads/repos/o/r/releases/1/assets?name=app", u)
+
+	githubEndpoint.UploadURL = "https://uploads.internal.example.com/"
+	u, err = assetUploadURL(r, "app")
+	Nil(t, err)
+	Equal(t, "https://uploads.internal.example.com/repos/o/r/releases/1/assets?name=app", u)
+}
+
+// TestReleaseState checks drafts, prereleases and published releases are told apart
+func TestReleaseState(t *testing.T) {
+	yes, no := true, false
+	Equal(t, "draft", releaseState(&release{Draft: &yes, Prerelease: &yes}))
+	Equal(t, "prerelease", releaseState(&release{Draft: &no, Prerelease: &yes}))
+	Equal(t, "published", releaseState(&release{Draft: &no, Prerelease: &no}))
+	Equal(t, "published", releaseState(&release{}))
+}
+
+// TestFindRelease checks the release is looked up by its tag, and drafts found by tag in the list
+func TestFindRelease(t *testing.T) {
+	defer gock.Off()
+	gURL := &gitURL{organization: "o", repository: "r"}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/tags/v1.0.0").
+		Reply(200).
+		JSON(map[string]interface{}{"id": 1, "tag_name": "v1.0.0", "name": "First release"})
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/tags/v1.1.0").
+		Times(2).
+		Reply(404).
+		JSON(map[string]string{"message": "Not Found"})
+
+	// The names of releases needn't be their tags
+	tags, names, draft := []string{"v1.1.0", "v1.0.0"}, []string{"v1.0.0", "First release"}, true
+	listed := &releases{{TagName: &tags[0], Name: &names[0], Draft: &draft}, {TagName: &tags[1], Name: &names[1]}}
+
+	r, err := findRelease(&githubProvider{}, nil, gURL, listed, "v1.0.0")
+	Nil(t, err)
+	if NotNil(t, r) {
+		Equal(t, 1, *r.ID)
+	}
+
+	r, err = findRelease(&githubProvider{}, nil, gURL, listed, "v1.1.0")
+	Nil(t, err)
+	if NotNil(t, r) {
+		True(t, *r.Draft)
+	}
+
+	r, err = findRelease(&githubProvider{}, nil, gURL, &releases{}, "v1.1.0")
+	Nil(t, err)
+	Nil(t, r)
+	True(t, gock.IsDone())
 }
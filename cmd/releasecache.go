@@ -0,0 +1,99 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"strings"
+	"sync"
+)
+
+// releasesCache holds the release lists retrieved during the run
+var releasesCache = newReleaseCache()
+
+// releaseCache memoizes the release lists of each repository, so repeated lookups
+// don't repeat the API request. Failed lookups are not cached.
+type releaseCache struct {
+	mu      sync.Mutex
+	entries map[string]*releaseLookup
+}
+
+// releaseLookup is a request for a release list, shared by everyone looking it up
+type releaseLookup struct {
+	// done is closed once the request is complete
+	done     chan struct{}
+	releases *releases
+	err      error
+}
+
+func newReleaseCache() *releaseCache {
+	return &releaseCache{entries: make(map[string]*releaseLookup)}
+}
+
+// releaseCacheKey identifies the repository in the cache
+func releaseCacheKey(gURL *gitURL) string {
+	return strings.ToLower(gURL.organization + "/" + gURL.repository)
+}
+
+// get returns the cached release list for key, calling fetch if there isn't one. Lookups
+// made while fetch is running wait for it rather than making their own request.
+func (c *releaseCache) get(key string, fetch func() (*releases, error)) (*releases, error) {
+	c.mu.Lock()
+	if l, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-l.done
+		return l.releases, l.err
+	}
+
+	l := &releaseLookup{done: make(chan struct{})}
+	c.entries[key] = l
+	c.mu.Unlock()
+
+	l.releases, l.err = fetch()
+	if l.err != nil {
+		c.forget(key, l)
+	}
+	close(l.done)
+
+	return l.releases, l.err
+}
+
+// forget removes the lookup for key, if it hasn't already been replaced
+func (c *releaseCache) forget(key string, l *releaseLookup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries[key] == l {
+		delete(c.entries, key)
+	}
+}
+
+// invalidate drops the release list for key, eg: once a release is created on it
+func (c *releaseCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// reset drops every cached release list
+func (c *releaseCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*releaseLookup)
+}
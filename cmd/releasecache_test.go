@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestReleaseCacheCoalesces checks concurrent lookups of a repository share one request
+func TestReleaseCacheCoalesces(t *testing.T) {
+	c := newReleaseCache()
+
+	var calls int32
+	release := make(chan struct{})
+	fetch := func() (*releases, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &releases{}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*releases, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.get("owner/repo", fetch)
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+
+	Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, r := range results {
+		Same(t, results[0], r)
+	}
+
+	c.invalidate("owner/repo")
+	_, err := c.get("owner/repo", fetch)
+	Nil(t, err)
+	Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+// TestReleaseCacheErrors checks failed lookups are retried
+func TestReleaseCacheErrors(t *testing.T) {
+	c := newReleaseCache()
+
+	calls := 0
+	fetch := func() (*releases, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("rate limited")
+		}
+		return &releases{}, nil
+	}
+
+	_, err := c.get("owner/repo", fetch)
+	Error(t, err)
+
+	r, err := c.get("owner/repo", fetch)
+	Nil(t, err)
+	NotNil(t, r)
+	Equal(t, 2, calls)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// listOutput is the format list writes the releases in, table or json
+var listOutput string
+
+// listCmd prints the repository's releases
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the repository's releases",
+	Long: `list prints the releases of the repository, newest first, with their tag, name, date, state and
+number of assets, as a table or, with --output json, as JSON for scripts. Drafts are listed when a token
+for the repository is already available, without running the device flow.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if listOutput != "table" && listOutput != "json" {
+			return fmt.Errorf("unknown --output %s, must be table or json", listOutput)
+		}
+
+		repoURL := repositoryURL
+		if upstreamRepositoryURL != "" {
+			repoURL = upstreamRepositoryURL
+		}
+		gURL, err := parseGitURL(repoURL)
+		if err != nil {
+			return err
+		}
+
+		auth, _, err := existingAuth()
+		if err != nil {
+			return err
+		}
+		all, err := repositoryReleases(auth, gURL)
+		if err != nil {
+			return fmt.Errorf("failed retrieving list of releases: %w", err)
+		}
+
+		return writeReleaseList(os.Stdout, all, listOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&listOutput, "output", "table", "format to write the releases in: table or json")
+}
+
+// releaseListing is a release as list writes it
+type releaseListing struct {
+	Tag    string `json:"tag"`
+	Name   string `json:"name"`
+	Date   string `json:"date,omitempty"`
+	State  string `json:"state"`
+	Assets int    `json:"assets"`
+	URL    string `json:"url,omitempty"`
+}
+
+// repositoryReleases lists the releases of the repository on its forge. Github only lists
+// drafts to those who can push, so they're listed with the auth, if not nil.
+func repositoryReleases(auth *UserAuth, gURL *gitURL) (*releases, error) {
+	forge := providerFor(gURL)
+	if _, ok := forge.(*githubProvider); ok && auth != nil {
+		return fetchReleases(auth, gURL)
+	}
+
+	return forge.listReleases(gURL)
+}
+
+// listingFor returns the release as list writes it. Drafts have no publishing date, so are
+// dated when they were created.
+func listingFor(r *release) releaseListing {
+	l := releaseListing{State: releaseState(r), Assets: len(r.Assets)}
+	if r.TagName != nil {
+		l.Tag = *r.TagName
+	}
+	if r.Name != nil {
+		l.Name = *r.Name
+	}
+	if r.PublishedAt != nil {
+		l.Date = *r.PublishedAt
+	} else if r.CreatedAt != nil {
+		l.Date = *r.CreatedAt
+	}
+	if r.HTMLURL != nil {
+		l.URL = *r.HTMLURL
+	}
+	return l
+}
+
+// writeReleaseList writes the releases as a table, or as a JSON array
+func writeReleaseList(w io.Writer, all *releases, output string) error {
+	listings := make([]releaseListing, 0, len(*all))
+	for i := range *all {
+		listings = append(listings, listingFor(&(*all)[i]))
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tNAME\tDATE\tSTATE\tASSETS")
+	for _, l := range listings {
+		date := l.Date
+		if t, err := time.Parse(time.RFC3339, l.Date); err == nil {
+			date = t.UTC().Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", l.Tag, l.Name, date, l.State, l.Assets)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestFetchReleasesPages checks every page of releases is fetched, until one isn't full
+func TestFetchReleasesPages(t *testing.T) {
+	defer gock.Off()
+
+	full := make([]map[string]interface{}, releasesPerPage)
+	for i := range full {
+		full[i] = map[string]interface{}{"id": i, "tag_name": "v0.0.1"}
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		MatchParam("page", "1").
+		MatchParam("per_page", "100").
+		Reply(200).
+		JSON(full)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		MatchParam("page", "2").
+		MatchHeader("Authorization", "token secret").
+		Reply(200).
+		JSON([]map[string]interface{}{{"id": 100, "tag_name": "v0.0.0"}})
+
+	all, err := fetchReleases(&UserAuth{AccessToken: "secret", TokenType: "token"}, &gitURL{organization: "o", repository: "r"})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Len(t, *all, releasesPerPage+1)
+	Equal(t, "v0.0.0", *(*all)[releasesPerPage].TagName)
+	True(t, gock.IsDone())
+}
+
+// TestWriteReleaseList checks the releases are listed as a table, or as JSON
+func TestWriteReleaseList(t *testing.T) {
+	tags, names, dates := []string{"v1.1.0", "v1.0.0"}, []string{"Second", "First"}, []string{"2021-03-02T10:00:00Z", "2021-02-01T10:00:00Z"}
+	draft := true
+	a := "app"
+	all := &releases{
+		{TagName: &tags[0], Name: &names[0], CreatedAt: &dates[0], Draft: &draft},
+		{TagName: &tags[1], Name: &names[1], PublishedAt: &dates[1], Assets: []*asset{{Name: &a}}},
+	}
+
+	var out bytes.Buffer
+	Nil(t, writeReleaseList(&out, all, "table"))
+	Equal(t, "TAG     NAME    DATE        STATE      ASSETS\n"+
+		"v1.1.0  Second  2021-03-02  draft      0\n"+
+		"v1.0.0  First   2021-02-01  published  1\n", out.String())
+
+	out.Reset()
+	Nil(t, writeReleaseList(&out, all, "json"))
+	Contains(t, out.String(), `"tag": "v1.0.0",
+    "name": "First",
+    "date": "2021-02-01T10:00:00Z",
+    "state": "published",
+    "assets": 1`)
+}
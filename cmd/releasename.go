@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// releaseName is the template of the release's title, eg: "MyApp {{ .Tag }}"; releases
+// are titled with their tag without one
+var releaseName string
+
+// releaseNameVars are the values available to the release name template: the build
+// info, the tag and the project
+type releaseNameVars struct {
+	*buildInfo
+	// Project is the name of the repository
+	Project string
+	Tag     string
+}
+
+// parseReleaseName parses the template, and checks it renders a usable title
+func parseReleaseName(text string) (*template.Template, error) {
+	tmpl, err := template.New("releaseName").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &buildInfo{Version: "v1.0.0", Commit: "0000000000000000000000000000000000000000", ShortCommit: "0000000", Date: "20200601", Describe: "v1.0.0"}
+	_, err = executeReleaseName(tmpl, releaseNameVars{buildInfo: b, Project: "project", Tag: "v1.0.0"})
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// executeReleaseName renders the template into a release title
+func executeReleaseName(tmpl *template.Template, vars releaseNameVars) (string, error) {
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSpace(rendered.String())
+	if name == "" {
+		return "", fmt.Errorf("rendered an empty release name")
+	}
+
+	return name, nil
+}
+
+// renderReleaseName returns the title of the release: the rendered --releaseName, or the
+// tag without one
+func renderReleaseName(text, project, tag string, b *buildInfo) (string, error) {
+	if text == "" {
+		return tag, nil
+	}
+
+	tmpl, err := parseReleaseName(text)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := executeReleaseName(tmpl, releaseNameVars{buildInfo: b, Project: project, Tag: tag})
+	if err != nil {
+		return "", fmt.Errorf("failed rendering release name: %w", err)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestRenderReleaseName checks releases are titled by the template, or with the tag without one
+func TestRenderReleaseName(t *testing.T) {
+	b := &buildInfo{Version: "v1.2.0+build.7", ShortCommit: "abc1234"}
+
+	name, err := renderReleaseName("", "app", "v1.2.0", b)
+	Nil(t, err)
+	Equal(t, "v1.2.0", name)
+
+	name, err = renderReleaseName("MyApp {{.Tag}}", "app", "v1.2.0", b)
+	Nil(t, err)
+	Equal(t, "MyApp v1.2.0", name)
+
+	name, err = renderReleaseName("{{ .Project }} {{ .Version }} ({{ .ShortCommit }})", "app", "v1.2.0", b)
+	Nil(t, err)
+	Equal(t, "app v1.2.0+build.7 (abc1234)", name)
+}
+
+// TestParseReleaseName checks templates that can't render a usable title are rejected
+func TestParseReleaseName(t *testing.T) {
+	_, err := parseReleaseName("MyApp {{ .Tag }}")
+	Nil(t, err)
+
+	for _, text := range []string{
+		"{{ .Tag",
+		"{{ .Codename }}",
+		"  ",
+		"{{ if false }}{{ .Tag }}{{ end }}",
+	} {
+		_, err := parseReleaseName(text)
+		NotNil(t, err, text)
+	}
+}
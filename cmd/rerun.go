@@ -0,0 +1,160 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rerunChanges is the action taken when a re-run built artifacts that differ from those
+// already uploaded to the release: warn or fail
+var rerunChanges string
+
+// assetChange is an artifact that differs from the asset of the same name a previous run
+// uploaded, by digest, or by size if the release has no checksum file
+type assetChange struct {
+	name     string
+	previous string
+	current  string
+}
+
+func (c assetChange) String() string {
+	return fmt.Sprintf("%s: %s, now %s", c.name, c.previous, c.current)
+}
+
+// releaseChecksums downloads and parses the checksum file uploaded with the release,
+// into dir, or returns nil if it has none
+func releaseChecksums(auth *UserAuth, r *release, dir string) (checksumManifest, error) {
+	for _, algorithm := range []string{"sha256", "sha512"} {
+		name := checksumFileName(algorithm)
+		for _, a := range r.Assets {
+			if a.Name == nil || *a.Name != name {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if err := downloadReleaseAsset(auth, a, path); err != nil {
+				return nil, fmt.Errorf("failed downloading %s: %w", name, err)
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return parseChecksumManifest(string(data))
+		}
+	}
+
+	return nil, nil
+}
+
+// changedArtifacts returns the artifacts whose digests differ from those in the previous
+// checksum file. Without one, the sizes of the release's assets are compared instead.
+// Checksum files and signatures are left out, as they change with what they sign.
+func changedArtifacts(artifacts []*artifact, r *release, previous checksumManifest) ([]assetChange, error) {
+	sizes := make(map[string]int)
+	for _, a := range r.Assets {
+		if a.Name != nil && a.Size != nil {
+			sizes[*a.Name] = *a.Size
+		}
+	}
+
+	var changes []assetChange
+	for _, a := range artifacts {
+		if isChecksumAsset(a.name) {
+			continue
+		}
+
+		if previous == nil {
+			if size, ok := sizes[a.name]; ok && int64(size) != a.size {
+				changes = append(changes, assetChange{name: a.name, previous: formatSize(int64(size)), current: formatSize(a.size)})
+			}
+			continue
+		}
+
+		digest, ok := previous[a.name]
+		if !ok {
+			continue
+		}
+		h, err := hasherForDigest(digest)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(a.path)
+		if err != nil {
+			return nil, err
+		}
+		current, err := hashReader(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if current != digest {
+			changes = append(changes, assetChange{name: a.name, previous: digest, current: current})
+		}
+	}
+
+	return changes, nil
+}
+
+// compareRerun compares the artifacts of a re-run with what the previous run uploaded to
+// the release, so a build that isn't reproducible doesn't silently change published
+// assets. The differences are printed as warnings, or returned as an error if
+// rerunChanges is "fail", before anything on the release is replaced.
+func compareRerun(auth *UserAuth, r *release, artifacts []*artifact) error {
+	dir, err := createTempDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	previous, err := releaseChecksums(auth, r, dir)
+	if err != nil {
+		return err
+	}
+
+	changes, err := changedArtifacts(artifacts, r, previous)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		if verbose {
+			noteInfo("The re-built artifacts match those already uploaded")
+		}
+		return nil
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+
+	if rerunChanges == "fail" {
+		return fmt.Errorf("%d artifact(s) differ from those already uploaded to release %s:\n%s", len(changes), tag, strings.Join(lines, "\n"))
+	}
+
+	fmt.Printf("WARNING: %d artifact(s) differ from those already uploaded to release %s; the build may not be reproducible:\n", len(changes), tag)
+	for _, l := range lines {
+		fmt.Printf("\t%s\n", l)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// rerunArtifacts writes the artifacts of a re-run to dir
+func rerunArtifacts(t *testing.T, dir string, contents map[string]string) []*artifact {
+	var artifacts []*artifact
+	for _, name := range []string{"app-linux", "app-darwin", "SHA256SUMS"} {
+		path := filepath.Join(dir, name)
+		Nil(t, ioutil.WriteFile(path, []byte(contents[name]), 0644))
+		artifacts = append(artifacts, &artifact{path: path, name: name, size: int64(len(contents[name]))})
+	}
+	return artifacts
+}
+
+// TestChangedArtifacts checks artifacts are compared by digest, or by size without a checksum file
+func TestChangedArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rerun")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	artifacts := rerunArtifacts(t, dir, map[string]string{"app-linux": "linux", "app-darwin": "darwin!", "SHA256SUMS": "sums"})
+	linux, err := fileDigest(artifacts[0].path)
+	Nil(t, err)
+
+	// The checksum file itself, and artifacts that weren't uploaded before, aren't compared
+	previous := checksumManifest{"app-linux": linux, "app-darwin": "0000000000000000000000000000000000000000000000000000000000000000"}
+	changes, err := changedArtifacts(artifacts, &release{}, previous)
+	Nil(t, err)
+	Equal(t, []assetChange{{name: "app-darwin", previous: previous["app-darwin"], current: "8e22534049f27589d6720c33867a0cef3fe0f4c20cebf3147b1b4b193c370617"}}, changes)
+
+	linuxName, linuxSize, darwinName, darwinSize := "app-linux", 5, "app-darwin", 6
+	r := &release{Assets: []*asset{{Name: &linuxName, Size: &linuxSize}, {Name: &darwinName, Size: &darwinSize}}}
+	changes, err = changedArtifacts(artifacts, r, nil)
+	Nil(t, err)
+	Equal(t, []assetChange{{name: "app-darwin", previous: "6B", current: "7B"}}, changes)
+}
+
+// TestCompareRerun checks differences fail the re-run before the release is changed, if set to
+func TestCompareRerun(t *testing.T) {
+	defer gock.Off()
+	defer func(action string) { rerunChanges = action }(rerunChanges)
+
+	dir, err := ioutil.TempDir("", "rerun")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	artifacts := rerunArtifacts(t, dir, map[string]string{"app-linux": "linux", "app-darwin": "darwin"})
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/assets/2").
+		Times(2).
+		Reply(200).
+		BodyString("0000000000000000000000000000000000000000000000000000000000000000  app-linux\n")
+
+	sums, sumsURL := "SHA256SUMS", "https://api.github.com/repos/o/r/releases/assets/2"
+	r := &release{Assets: []*asset{{Name: &sums, URL: &sumsURL}}}
+	auth := &UserAuth{TokenType: "token", AccessToken: "token"}
+
+	rerunChanges = "warn"
+	Nil(t, compareRerun(auth, r, artifacts))
+
+	rerunChanges = "fail"
+	err = compareRerun(auth, r, artifacts)
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "1 artifact(s) differ")
+		Contains(t, err.Error(), "app-linux: 0000")
+	}
+	True(t, gock.IsDone())
+}
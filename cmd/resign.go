@@ -0,0 +1,226 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// resignCmd replaces the checksum file and signature of an existing release
+var resignCmd = &cobra.Command{
+	Use:   "resign",
+	Short: "Regenerate the checksum file and signature of an existing release",
+	Long: `resign downloads the assets of the release of --tag, writes their checksum file again with the
+--checksumAlgorithm, signs it with the current --checksumSignCommand, if set, along with the assets uploaded
+with detached signatures (.sig or .asc), and replaces the checksum file and signature assets of the release with them, eg: after
+rotating the signing key or changing the algorithm. The new ones are uploaded before the old ones are deleted.
+The other assets are left as they are. Checksum files and signatures of other algorithms are removed.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			return fmt.Errorf("tag is required")
+		}
+		return resign()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resignCmd)
+}
+
+// assetSignatures returns the extensions of the detached signatures uploaded alongside
+// the release's assets, eg: app-linux.asc, by the name of the asset they sign
+func assetSignatures(r *release) map[string]string {
+	names := make(map[string]bool)
+	for _, a := range r.Assets {
+		if a.Name != nil {
+			names[*a.Name] = true
+		}
+	}
+
+	signatures := make(map[string]string)
+	for name := range names {
+		for _, ext := range signatureExts {
+			signed := strings.TrimSuffix(name, ext)
+			if signed != name && names[signed] && !isChecksumAsset(name) {
+				signatures[signed] = ext
+			}
+		}
+	}
+	return signatures
+}
+
+// downloadSignedAssets downloads the release's assets, other than its checksum files and
+// the signatures of its assets, into dir
+func downloadSignedAssets(auth *UserAuth, r *release, dir string) ([]*artifact, error) {
+	signed := make(map[string]bool)
+	for name, ext := range assetSignatures(r) {
+		signed[name+ext] = true
+	}
+
+	var artifacts []*artifact
+	for _, a := range r.Assets {
+		if a.Name == nil || isChecksumAsset(*a.Name) || signed[*a.Name] {
+			continue
+		}
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Downloading %s", *a.Name))
+		}
+		path := filepath.Join(dir, *a.Name)
+		if err := downloadReleaseAsset(auth, a, path); err != nil {
+			return nil, fmt.Errorf("failed downloading %s: %w", *a.Name, err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, &artifact{path: path, name: *a.Name, size: info.Size()})
+	}
+
+	return artifacts, nil
+}
+
+// staleChecksumAssets returns the release's checksum files and signatures that aren't replaced
+func staleChecksumAssets(r *release, replacements []*artifact) []*asset {
+	replaced := make(map[string]bool)
+	for _, a := range replacements {
+		replaced[a.name] = true
+	}
+
+	var stale []*asset
+	for _, a := range r.Assets {
+		if a.Name != nil && isChecksumAsset(*a.Name) && !replaced[*a.Name] {
+			stale = append(stale, a)
+		}
+	}
+	return stale
+}
+
+func resign() error {
+	repoURL := repositoryURL
+	if upstreamRepositoryURL != "" {
+		repoURL = upstreamRepositoryURL
+	}
+	gURL, err := parseGitURL(repoURL)
+	if err != nil {
+		return err
+	}
+	forge := providerFor(gURL)
+	if _, ok := forge.(*githubProvider); !ok {
+		return fmt.Errorf("resign only supports Github releases")
+	}
+
+	auth, err := forge.authenticate(gURL)
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	// Drafts are listed too, as staged releases may need resigning before they're published
+	all, err := listReleases(auth, gURL)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+	r := releaseForTag((*releases)(&all), tag)
+	if r == nil {
+		return fmt.Errorf("no release found for tag %s on %s/%s", tag, gURL.organization, gURL.repository)
+	}
+
+	tempDir, err := createTempDir()
+	if err != nil {
+		return fmt.Errorf("cannot create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	artifacts, err := downloadSignedAssets(auth, r, tempDir)
+	if err != nil {
+		return err
+	}
+	if len(artifacts) == 0 {
+		return fmt.Errorf("release %s has no assets to checksum", tag)
+	}
+
+	// The assets' own signatures are made again too, and checksummed with them
+	var replacements []*artifact
+	signatures := assetSignatures(r)
+	if len(signatures) > 0 && checksumSignCommand == "" {
+		return fmt.Errorf("release %s has signatures of its assets; set --checksumSignCommand to sign them again", tag)
+	}
+	for _, a := range artifacts {
+		ext, ok := signatures[a.name]
+		if !ok {
+			continue
+		}
+		sig, err := signFile(a, checksumSignCommand, ext)
+		if err != nil {
+			return err
+		}
+		replacements = append(replacements, sig)
+	}
+
+	sums, err := writeChecksumFile(tempDir, append(artifacts, replacements...), checksumAlgorithm)
+	if err != nil {
+		return fmt.Errorf("failed writing checksum file: %w", err)
+	}
+	replacements = append(replacements, sums)
+	if checksumSignCommand != "" {
+		sig, err := signChecksumFile(sums, checksumSignCommand)
+		if err != nil {
+			return err
+		}
+		replacements = append(replacements, sig)
+	}
+
+	names := []string{}
+	for _, a := range replacements {
+		names = append(names, a.name)
+	}
+	stale := staleChecksumAssets(r, replacements)
+
+	fmt.Printf("Replacing %s of release %s, checksumming %d assets\n", strings.Join(names, ", "), tag, len(artifacts))
+	for _, a := range stale {
+		fmt.Printf("Removing %s\n", *a.Name)
+	}
+	if !confirm("Would you like to continue?") {
+		return fmt.Errorf("resign %w", errHalted)
+	}
+
+	// The new checksum file and signatures are uploaded before the old ones are deleted
+	if _, _, err = uploadReplacingAssets(forge, auth, r, replacements); err != nil {
+		return stageFailed(errUpload, err)
+	}
+	for _, a := range stale {
+		if err = deleteAsset(auth, a); err != nil {
+			return stageFailed(errUpload, fmt.Errorf("failed deleting %s: %w", *a.Name, err))
+		}
+	}
+
+	summary := &releaseSummary{tag: tag}
+	if r.HTMLURL != nil {
+		summary.releaseURL = *r.HTMLURL
+	}
+	summary.print()
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestSignChecksumFile checks the signing command is rendered, and its signature returned to upload
+func TestSignChecksumFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sign")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "SHA256SUMS")
+	Nil(t, ioutil.WriteFile(path, []byte("sums\n"), 0644))
+	sums := &artifact{path: path, name: "SHA256SUMS", size: 5}
+
+	sig, err := signChecksumFile(sums, "cp {{ .Path }} {{ .Signature }}")
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, &artifact{path: path + ".sig", name: "SHA256SUMS.sig", size: 5}, sig)
+
+	// A command that writes no signature fails, rather than uploading a stale one
+	_, err = signChecksumFile(sums, "true {{ .Path }}")
+	NotNil(t, err)
+}
+
+// TestStaleChecksumAssets checks the checksum files and signatures that aren't replaced are found
+func TestStaleChecksumAssets(t *testing.T) {
+	var assets []*asset
+	for _, name := range []string{"app-linux", "SHA256SUMS", "SHA256SUMS.sig", "SHA256SUMS.asc", "SHA512SUMS", "SHA512SUMS.sig"} {
+		name := name
+		assets = append(assets, &asset{Name: &name})
+	}
+	r := &release{Assets: assets}
+
+	stale := staleChecksumAssets(r, []*artifact{{name: "SHA512SUMS"}, {name: "SHA512SUMS.sig"}})
+	var names []string
+	for _, a := range stale {
+		names = append(names, *a.Name)
+	}
+	Equal(t, []string{"SHA256SUMS", "SHA256SUMS.sig", "SHA256SUMS.asc"}, names)
+}
+
+// TestAssetSignatures checks the detached signatures of assets are found, but not the
+// checksum file's
+func TestAssetSignatures(t *testing.T) {
+	var assets []*asset
+	for _, name := range []string{"app-linux", "app-linux.asc", "app-darwin", "app-darwin.sig", "orphan.sig", "SHA256SUMS", "SHA256SUMS.sig"} {
+		name := name
+		assets = append(assets, &asset{Name: &name})
+	}
+
+	Equal(t, map[string]string{"app-linux": ".asc", "app-darwin": ".sig"}, assetSignatures(&release{Assets: assets}))
+}
+
+// TestDownloadSignedAssets checks only the assets the checksums are of are downloaded
+func TestDownloadSignedAssets(t *testing.T) {
+	defer gock.Off()
+
+	dir, err := ioutil.TempDir("", "resign")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/assets/1").
+		MatchHeader("Accept", "application/octet-stream").
+		Reply(200).
+		BodyString("binary")
+
+	app, appURL := "app-linux", "https://api.github.com/repos/o/r/releases/assets/1"
+	sums, sumsURL := "SHA256SUMS", "https://api.github.com/repos/o/r/releases/assets/2"
+	sig, sigURL := "app-linux.asc", "https://api.github.com/repos/o/r/releases/assets/3"
+	r := &release{Assets: []*asset{{Name: &app, URL: &appURL}, {Name: &sums, URL: &sumsURL}, {Name: &sig, URL: &sigURL}}}
+
+	artifacts, err := downloadSignedAssets(&UserAuth{TokenType: "token", AccessToken: "token"}, r, dir)
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, []*artifact{{path: filepath.Join(dir, "app-linux"), name: "app-linux", size: 6}}, artifacts)
+	True(t, gock.IsDone())
+}
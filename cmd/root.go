@@ -19,7 +19,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -27,19 +32,82 @@ import (
 	"github.com/spf13/viper"
 )
 
-// TODO: remove this clientID
+// defaultClientID is the client ID of the OAuth app the device flow authorizes, set at
+// build time, eg: -ldflags "-X github.com/clcollins/go-git-release/cmd.defaultClientID=<id>"
+var defaultClientID = ""
+
+// The OAuth app and device flow parameters, so forks and Github Enterprise Server users
+// can use their own app
 var clientID string
+var oauthScope string
+var oauthGrantType string
 
 var cfgFile string
 var verbose bool
 var force bool
+var promptTimeout time.Duration
+var promptDefault string
 var privateKey string
 var repositoryURL string
+var repo string
+var upstreamRepositoryURL string
+var upstreamRepo string
+var pushTagTo string
 var commitish string
+var pullRequestNumber int
 var branch string
 var tag string
 var tagMessage string
+var tagMessageTemplate string
+var tagWithAPI bool
+var tagCleanup string
 var makeTarget string
+var buildMetadata []string
+var buildCounter string
+var artifactPatterns []string
+var maxAssetSize string
+var maxTotalSize string
+var sizeBudgetAction string
+var uploadConcurrency int
+var replaceAssets bool
+var updateExisting bool
+var planPath string
+var applyPlanPath string
+var verifyUploads bool
+var verifyUploadsSample int
+var stripSymbols bool
+var stripCommand string
+var symbolHook string
+var postProcessors []postProcessor
+var archives archiveConfig
+var minSoakDays int
+var minSoakDownloads int
+var ignoreSoak bool
+var notifyTeams []string
+var notifyCodeowners bool
+var notifyIssue int
+var showLinks bool
+var scan bool
+var scanCommand string
+var scanFormat string
+var scanFailOn string
+var scanWarnOn string
+var scanIgnore []string
+var linksFile string
+var mirrors []mirror
+var traceHTTP string
+var recordHTTP string
+var replayHTTP string
+var notesFromPRs bool
+var notesExcludeLabels []string
+var notesSections []notesSection
+
+// discussionCategory is the category of the Github Discussion opened for each release
+var discussionCategory string
+
+// markLatest is the make_latest of the release: "true", "false" or "legacy"; Github makes
+// new releases the latest if not set
+var markLatest string
 
 // TODO: Make this configurable
 var defaultEditor string = "vim"
@@ -52,7 +120,7 @@ var remote string = "origin"
 // gitopts holds config info for git operations
 // and is parsed during init for package cmd
 var gitopts struct {
-	progress *os.File
+	progress io.Writer
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -62,52 +130,127 @@ var rootCmd = &cobra.Command{
 	Long: `go-git-release is a tool for tagging, building artifacts, and creating a Github release for a project with
 a single command. At the moment, a Makefile with a "build" target is required.`,
 
-	PreRun: func(cmd *cobra.Command, args []string) {
-		// Parse viper flags
-		cfg := viper.AllSettings()
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setup(initialValidation)
+	},
 
-		// Show the input if verbose
-		if verbose {
-			fmt.Println("Using settings:")
-			for k, v := range cfg {
-				fmt.Printf("\t%v: %v\n", k, v)
+	// Uncomment the following line if your bare application
+	// has an action associated with it:
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			if !isInteractive() {
+				return fmt.Errorf("tag is required")
+			}
+
+			var err error
+			tag, err = promptInput("Tag to release, eg: v1.2.3", promptOptions{Validate: validReleaseTag})
+			if err != nil {
+				return fmt.Errorf("tag is required: %w", err)
 			}
-			fmt.Printf("\n")
 		}
 
-		// TODO: remove this clientID
-		clientID = viper.GetString("clientID")
+		err := run()
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
 
-		verbose = viper.GetBool("verbose")
-		repositoryURL = viper.GetString("repositoryURL")
-		commitish = viper.GetString("commitish")
-		branch = viper.GetString("branch")
-		makeTarget = viper.GetString("makeTarget")
+// secretSuffixes are the endings of the setting keys whose values are secrets;
+// viper lowercases every key
+var secretSuffixes = []string{"token", "secret", "password", "passphrase"}
 
-		errs := initialValidation()
-		if len(errs) != 0 {
-			for i := range errs {
-				fmt.Println(i)
+// maskSecrets returns a copy of the settings with the value of every secret
+// setting, including those nested in maps, replaced so it can be printed
+func maskSecrets(cfg map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if m, ok := v.(map[string]interface{}); ok {
+			masked[k] = maskSecrets(m)
+			continue
+		}
+		masked[k] = v
+		for _, suffix := range secretSuffixes {
+			if strings.HasSuffix(strings.ToLower(k), suffix) && fmt.Sprint(v) != "" {
+				masked[k] = "********"
 			}
-			// cmd.Help()
-			os.Exit(1)
 		}
+	}
+	return masked
+}
 
-		// Set git to write to stdout for verbose output
-		if verbose {
-			gitopts.progress = os.Stdout
+// setup loads the settings and checks them with validate, exiting on the first
+// problems found, then sets up the HTTP client and git output as they configure
+func setup(validate func() []error) {
+	// Parse viper flags
+	cfg := viper.AllSettings()
+
+	// Show the input if verbose
+	if verbose {
+		fmt.Println("Using settings:")
+		for k, v := range maskSecrets(cfg) {
+			fmt.Printf("\t%v: %v\n", k, v)
 		}
-	},
+		fmt.Printf("\n")
+	}
 
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	RunE: func(cmd *cobra.Command, args []string) error {
-		err := run()
+	errs := loadSettings()
+	if len(errs) != 0 {
+		for i := range errs {
+			fmt.Println(errs[i])
+		}
+		os.Exit(exitInvalid)
+	}
+
+	errs = validate()
+	if len(errs) != 0 {
+		for i := range errs {
+			fmt.Println(errs[i])
+		}
+		// cmd.Help()
+		os.Exit(exitInvalid)
+	}
+
+	configureClients()
+}
+
+// configureClients sets up the HTTP client and git output as the settings configure
+func configureClients() {
+	// Record every HTTP exchange to, or replay them from, a cassette
+	if recordHTTP != "" {
+		if err := enableHTTPRecord(recordHTTP); err != nil {
+			fmt.Printf("cannot record HTTP cassette: %s\n", err)
+			os.Exit(exitInvalid)
+		}
+	}
+	if replayHTTP != "" {
+		if err := enableHTTPReplay(replayHTTP); err != nil {
+			fmt.Printf("cannot replay HTTP cassette: %s\n", err)
+			os.Exit(exitInvalid)
+		}
+	}
+
+	// Write a trace of every HTTP request to the traceHTTP file
+	if traceHTTP != "" {
+		f, err := os.OpenFile(traceHTTP, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
 		if err != nil {
-			return err
+			fmt.Printf("cannot open HTTP trace file: %s\n", err)
+			os.Exit(exitInvalid)
 		}
-		return nil
-	},
+		enableHTTPTrace(f)
+	}
+
+	// Identify the tool and run in every HTTP request
+	enableRequestIdentification()
+	if verbose {
+		noteInfo(fmt.Sprintf("Correlation ID %s", correlationID))
+	}
+
+	// Set git to write to stdout for verbose output
+	if verbose {
+		gitopts.progress = newProgressWriter(os.Stdout)
+	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -115,7 +258,13 @@ a single command. At the moment, a Makefile with a "build" target is required.`,
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(2)
+		fmt.Printf("Correlation ID: %s\n", correlationID)
+		if hint := ciHint(err); ciMode && hint != "" {
+			fmt.Println(hint)
+		}
+		code := exitCodeFor(err)
+		fmt.Printf("Run go-git-release explain-exit %d for what the exit code means\n", code)
+		os.Exit(code)
 	}
 }
 
@@ -125,7 +274,7 @@ func init() {
 	home, err = homedir.Dir()
 	if err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitInvalid)
 	}
 }
 
@@ -138,8 +287,55 @@ func init() {
 	// Enable verbose output
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
 
+	// The OAuth app of the device flow
+	rootCmd.PersistentFlags().StringVar(&clientID, "clientID", defaultClientID, "client ID of the OAuth app the device flow authorizes")
+	rootCmd.PersistentFlags().StringVar(&oauthScope, "oauthScope", defaultScope, "OAuth scopes requested by the device flow, space separated")
+	rootCmd.PersistentFlags().StringVar(&oauthGrantType, "oauthGrantType", githubDeviceGrantType, "OAuth grant type used to poll for the device flow token")
+	rootCmd.PersistentFlags().StringVar(&githubEndpoint.AuthURL, "authURL", githubEndpoint.AuthURL, "URL of the Github web host the device flow and the release pages are on, eg: https://github.example.com on Github Enterprise Server")
+	rootCmd.PersistentFlags().StringVar(&githubEndpoint.DeviceAuthURL, "deviceAuthURL", githubEndpoint.DeviceAuthURL, "URL the device flow requests its codes from, eg: on Github Enterprise Server")
+	rootCmd.PersistentFlags().StringVar(&githubEndpoint.TokenURL, "tokenURL", githubEndpoint.TokenURL, "URL the device flow polls for its token, eg: on Github Enterprise Server")
+
+	// The Github API, eg: of a Github Enterprise Server instance
+	rootCmd.PersistentFlags().StringVar(&githubEndpoint.APIURL, "apiURL", githubEndpoint.APIURL, "base URL of the Github REST API, eg: https://github.example.com/api/v3 on Github Enterprise Server")
+	rootCmd.PersistentFlags().StringVar(&githubEndpoint.UploadURL, "uploadURL", "", "(optional) base URL assets are uploaded to, instead of the one the API returns, eg: https://github.example.com/api/uploads")
+	rootCmd.PersistentFlags().StringVar(&uploadURLTemplate, "uploadURLTemplate", "", "(optional) template of the URL assets are uploaded to, instead of the one the API returns, with {{ .UploadURL }}, {{ .Host }}, {{ .Owner }}, {{ .Repo }} and {{ .ReleaseID }}")
+
+	// Authenticate with a personal access token instead of the device flow
+	rootCmd.PersistentFlags().StringVar(&token, "token", "", "(optional) Github token to use instead of the device flow; GITHUB_TOKEN or GH_TOKEN are used if not set")
+	rootCmd.PersistentFlags().Int64Var(&appID, "appID", 0, "(optional) ID of a Github App to authenticate as, with an installation token, instead of a user")
+	rootCmd.PersistentFlags().Int64Var(&appInstallationID, "appInstallationID", 0, "(optional) ID of the Github App's installation (default is the installation on the repository)")
+	rootCmd.PersistentFlags().StringVar(&appPrivateKey, "appPrivateKey", "", "(optional) path of the Github App's private key")
+	rootCmd.PersistentFlags().StringVar(&appPrivateKeyEnv, "appPrivateKeyEnv", "", "(optional) environment variable containing the Github App's private key")
+	rootCmd.PersistentFlags().BoolVar(&ghCredentials, "ghCredentials", true, "reuse the token of the gh CLI, if it is logged in to the Github host")
+	rootCmd.PersistentFlags().BoolVar(&gitCredentials, "gitCredentials", true, "reuse the credentials for the git host in ~/.netrc or git's credential helpers, for https and the Github API")
+	rootCmd.PersistentFlags().BoolVar(&ciMode, "ci", false, "run unattended, as in CI: only use a provided token or Github App, never prompt or open a browser, and fail fast if the token lacks permissions; enabled in Github Actions jobs")
+	ciFlag = rootCmd.PersistentFlags().Lookup("ci")
+	rootCmd.PersistentFlags().BoolVar(&cacheToken, "cacheToken", true, "keep the device flow token in the user config directory for later runs, while it is valid")
+
+	// GitLab, for repositories on gitlab.com or a self-hosted instance
+	rootCmd.PersistentFlags().StringVar(&gitlabURL, "gitlabURL", defaultGitlabURL, "URL of the GitLab instance of repositories hosted on GitLab, eg: https://gitlab.example.com; the instance running the job in GitLab CI")
+	rootCmd.PersistentFlags().StringVar(&gitlabToken, "gitlabToken", "", "(optional) GitLab access token to release with; GITLAB_TOKEN, or the CI_JOB_TOKEN in GitLab CI, is used if not set")
+	rootCmd.PersistentFlags().StringVar(&gitlabAssetLinkURL, "gitlabAssetLinkURL", "", "(optional) template of the URL GitLab release assets are linked to, with {{ .Tag }} and {{ .Name }}, instead of uploading them to the project")
+
+	// Bitbucket, for repositories on Bitbucket Cloud
+	rootCmd.PersistentFlags().StringVar(&bitbucketUsername, "bitbucketUsername", "", "(optional) Bitbucket user the bitbucketToken is an app password of; BITBUCKET_USERNAME is used if not set")
+	rootCmd.PersistentFlags().StringVar(&bitbucketToken, "bitbucketToken", "", "(optional) Bitbucket access token, or app password of the bitbucketUsername, to publish downloads with; BITBUCKET_TOKEN is used if not set")
+
+	// Accessible output
+	rootCmd.PersistentFlags().BoolVar(&noColor, "noColor", false, "disable colored output (also disabled by the NO_COLOR environment variable)")
+	rootCmd.PersistentFlags().BoolVar(&plainOutput, "plain", false, "screen reader friendly output: no color, and periodic status lines instead of redrawn progress")
+
+	// Write sanitized HTTP request/response dumps to a file
+	rootCmd.PersistentFlags().StringVar(&traceHTTP, "traceHTTP", "", "(optional) file to write a sanitized trace of every HTTP request and response to")
+	rootCmd.PersistentFlags().StringVar(&recordHTTP, "recordHTTP", "", "(optional) file to record a sanitized cassette of every HTTP request and response to")
+	rootCmd.PersistentFlags().StringVar(&replayHTTP, "replayHTTP", "", "(optional) cassette to replay HTTP responses from, instead of using the network")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "userAgent", "", "(optional) User-Agent of every HTTP request, instead of go-git-release/<version> (<commit>)")
+
 	// Don't prompt for anything; just do
 	rootCmd.PersistentFlags().BoolVarP(&force, "force", "f", false, "force; do not prompt for anything")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "nonInteractive", false, "never prompt: yes/no prompts answer --promptDefault, other prompts their default, and those without one fail; implied by --ci")
+	rootCmd.PersistentFlags().DurationVar(&promptTimeout, "promptTimeout", 10*time.Minute, "how long to wait for an answer to a prompt before using its default, eg: --promptDefault for yes/no prompts; 0 waits indefinitely")
+	rootCmd.PersistentFlags().StringVar(&promptDefault, "promptDefault", "no", "answer used when a yes/no prompt times out or there is no input: no (abort) or yes")
 
 	// TODO: Do we need this? If we're cloning the repo to a temp dir, it'll always be "origin".
 	// TODO: Or do we want to act on a clone in the cwd?
@@ -149,6 +345,17 @@ func init() {
 	// Repository; required
 	rootCmd.PersistentFlags().StringVarP(&repositoryURL, "repositoryURL", "r", "", "repository url")
 
+	// Repository as an owner/name shorthand; alternative to repositoryURL
+	rootCmd.PersistentFlags().StringVar(&repo, "repo", "", "repository as owner/name, instead of a repository url")
+
+	// Upstream repository to create the release on when repositoryURL is a fork; optional
+	rootCmd.PersistentFlags().StringVar(&upstreamRepositoryURL, "upstreamRepositoryURL", "", "(optional) upstream repository url to create the release on, when the repository is a fork")
+	rootCmd.PersistentFlags().StringVar(&upstreamRepo, "upstreamRepo", "", "(optional) upstream repository as owner/name, instead of an upstream repository url")
+	rootCmd.PersistentFlags().StringVar(&shorthandProvider, "provider", "github", "forge of the owner/name repositories: github, gitlab or bitbucket")
+	rootCmd.PersistentFlags().StringVar(&gitProtocol, "gitProtocol", protocolSSH, "protocol of the clone URLs built from owner/name repositories: ssh or https")
+	rootCmd.PersistentFlags().StringVar(&pushTagTo, "pushTagTo", "fork", "when releasing from a fork, the repository to push the tag to: fork or upstream")
+	rootCmd.PersistentFlags().StringSliceVar(&aliasTags, "aliasTag", []string{}, "(optional) alias tags to create or move to the release commit once it is released, eg: v1")
+
 	// Commitish value to use as the basis for the relase
 	rootCmd.PersistentFlags().StringVarP(
 		&commitish,
@@ -160,6 +367,8 @@ func init() {
 			"if a tag is provided, the commit for that tag will be used, but the tag itself will not",
 	)
 
+	rootCmd.PersistentFlags().IntVar(&pullRequestNumber, "pr", 0, "(optional) number of a merged pull request to release; its merge commit is used as the commitish")
+
 	// The branch to use if the commitish is not provided; defaults to the repository default branch
 	rootCmd.PersistentFlags().StringVarP(
 		&branch,
@@ -169,28 +378,277 @@ func init() {
 		"if commitish is not provided, the latest commit from this branch is used for the release (default is the repository default)",
 	)
 
-	// Tag name; required
+	// Tag name; required to create a release, subcommands prompt for it when omitted
 	rootCmd.PersistentFlags().StringVarP(&tag, "tag", "t", "", "tag to create or use for the release")
-	rootCmd.MarkPersistentFlagRequired("tag")
+
+	// Release state; optional
+	rootCmd.PersistentFlags().BoolVar(&draft, "draft", false, "create the release as a draft, to publish from the Github UI; stage also records a staging manifest to review it with")
+	rootCmd.PersistentFlags().BoolVar(&prerelease, "prerelease", false, "mark the release as a prerelease")
+	rootCmd.PersistentFlags().StringVar(&markLatest, "latest", "", "(optional) whether the release is marked the latest: true, false, or legacy to pick the latest by date and version; Github marks new releases the latest if not set")
+	rootCmd.PersistentFlags().StringVar(&discussionCategory, "discussionCategory", "", "(optional) open a Github Discussion in this category linked to the release, eg: Announcements")
+	rootCmd.PersistentFlags().StringVar(&releaseName, "releaseName", "", "(optional) template of the release title, eg: MyApp {{ .Tag }}; the tag if not set")
+
+	// Publish the release at a later time; optional
+	rootCmd.PersistentFlags().StringVar(&publishAt, "publishAt", "", "(optional) RFC3339 time to publish the release at, eg: 2020-06-01T09:00:00Z; until then it is a draft with its assets uploaded")
+	rootCmd.PersistentFlags().BoolVar(&publishWait, "publishWait", true, "with publishAt, wait to publish the draft; otherwise it is left for go-git-release watch --publishScheduled")
 
 	// Tag message; optional - will prompt otherwise
 	rootCmd.PersistentFlags().StringVarP(&tagMessage, "tagMessage", "m", "", "annotated tag message")
 
+	// Creating a rejected tag with the Github API; optional - never implied by --force
+	rootCmd.PersistentFlags().BoolVar(&tagWithAPI, "tagWithAPI", false, "if pushing the tag is rejected, eg: as tags are protected, offer to create it with the Github API instead")
+
+	// Tag message template; optional - rendered instead of prompting for a message
+	rootCmd.PersistentFlags().StringVar(
+		&tagMessageTemplate,
+		"tagMessageTemplate",
+		"",
+		"(optional) template, or path to a template file, for the annotated tag message; "+
+			"may reference {{ .Tag }}, {{ .Version }}, {{ .PreviousTag }}, {{ .Commit }}, {{ .Date }} and {{ .Shortlog }}",
+	)
+
+	// Tag message cleanup mode; optional
+	rootCmd.PersistentFlags().StringVar(
+		&tagCleanup,
+		"tagCleanup",
+		cleanupStrip,
+		"how the tag message is cleaned up, as for git tag --cleanup: strip, whitespace, verbatim or scissors",
+	)
+
+	// Read the release notes from a file instead of the tag message; optional
+	rootCmd.PersistentFlags().StringVar(&notesFile, "notesFile", "", "(optional) file to read the release notes from instead of the tag message, or - for stdin")
+
+	// Summarize the changes into the release notes' highlights with an external command; optional
+	rootCmd.PersistentFlags().StringVar(&notesSummarizer, "notesSummarizer", "", "(optional) command reading the commits and pull requests of the release on stdin, and writing a summary of them for a Highlights section to stdout")
+	rootCmd.PersistentFlags().DurationVar(&notesSummarizerTimeout, "notesSummarizerTimeout", 2*time.Minute, "how long the notesSummarizer may run before the release goes ahead without highlights")
+
+	// Use the release notes Github generates; optional
+	rootCmd.PersistentFlags().BoolVar(&generateNotes, "generateNotes", false, "use the release notes Github generates for the changes since the previous tag, after any --notesFile header, instead of the tag message")
+
+	// Generate the release notes from the pull requests merged since the previous tag; optional
+	rootCmd.PersistentFlags().BoolVar(&notesFromPRs, "notesFromPRs", false, "generate release notes from the pull requests merged since the previous tag")
+	rootCmd.PersistentFlags().StringVar(&notesSince, "since", "", "tag or commit to generate the release notes from pull requests since, instead of the previous tag")
+	rootCmd.PersistentFlags().BoolVar(&notesCache, "notesCache", true, "keep the pull requests found for the generated release notes in the user cache directory, so later runs only walk the new commits")
+	rootCmd.PersistentFlags().BoolVar(&securityAdvisories, "securityAdvisories", false, "add a Security fixes section to the release notes, listing the repository's published security advisories patched in the release")
+	rootCmd.PersistentFlags().StringSliceVar(&securityAdvisoryIDs, "securityAdvisoryIDs", []string{}, "security advisories, by GHSA ID, to list in the Security fixes section even if their patched versions don't name the release")
+	rootCmd.PersistentFlags().BoolVar(&diffStats, "diffStats", false, "append the files and lines changed since the previous tag, and the most changed packages, to the release notes")
+	rootCmd.PersistentFlags().BoolVar(&recordProvenance, "provenance", false, "(optional) when running in CI, link the release to the CI run that built it in the release notes, staging manifest and audit events")
+	rootCmd.PersistentFlags().StringSliceVar(&notesExcludeLabels, "notesExcludeLabels", []string{"skip-changelog"}, "pull requests with these labels are left out of the generated release notes")
+
 	// Make target for build; optional (defaults to "buildRelease")
 	rootCmd.PersistentFlags().StringVarP(&makeTarget, "makeTarget", "M", "buildRelease", "make target to build artifacts")
 
+	// Directories the build needs, to only check those out of large repositories; optional
+	rootCmd.PersistentFlags().StringSliceVar(&sparsePaths, "sparsePath", []string{}, "(optional) directory the build needs, repeatable; only these and the files at the repository root are checked out")
+	rootCmd.PersistentFlags().BoolVar(&inMemoryClone, "inMemory", false, "clone into memory rather than a temporary directory for the commands that don't build: sync-notes, disclose, and backfill without --build")
+
+	// Build metadata appended to the version passed to the build; optional
+	rootCmd.PersistentFlags().StringSliceVar(
+		&buildMetadata,
+		"buildMetadata",
+		[]string{},
+		"(optional) templates of build metadata identifiers to append to the version, eg: build.{{ .BuildNumber }},sha.{{ .ShortCommit }}",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&buildCounter,
+		"buildCounter",
+		"",
+		"(optional) source of the monotonic build number: file:<path>, github-variable:<NAME> or api:<url>",
+	)
+
+	// Glob patterns, relative to the repository root, of the artifacts produced by the build
+	rootCmd.PersistentFlags().StringSliceVarP(&artifactPatterns, "artifacts", "a", []string{"bin/*"}, "glob patterns of the build artifacts to release")
+
+	// Size budgets for the build artifacts; optional
+	rootCmd.PersistentFlags().StringVar(&maxAssetSize, "maxAssetSize", "", "(optional) maximum size of any single artifact, eg: 50MB")
+	rootCmd.PersistentFlags().StringVar(&maxTotalSize, "maxTotalSize", "", "(optional) maximum combined size of all artifacts, eg: 200MB")
+	rootCmd.PersistentFlags().StringVar(&sizeBudgetAction, "sizeBudgetAction", "warn", "action to take when a size budget is exceeded: warn or fail")
+	rootCmd.PersistentFlags().IntVar(&uploadConcurrency, "uploadConcurrency", 4, "number of release assets to upload at the same time")
+	rootCmd.PersistentFlags().BoolVar(&verifyUploads, "verifyUploads", false, "download the assets again after uploading them, and check they match the artifacts")
+	rootCmd.PersistentFlags().IntVar(&verifyUploadsSample, "verifySample", 0, "number of randomly selected assets to download again with --verifyUploads (default is all)")
+	rootCmd.PersistentFlags().BoolVar(&replaceAssets, "replaceAssets", false, "if the tag already has a release, eg: from a partially failed run, upload to it, replacing assets of the same name")
+	rootCmd.PersistentFlags().BoolVar(&gitNote, "gitNote", false, "record the release, and the digests of its artifacts, in a git note on the released commit in refs/notes/releases, and push it")
+	rootCmd.PersistentFlags().StringVar(&rerunChanges, "rerunChanges", "warn", "action to take when a re-run's artifacts differ from those already uploaded to the release: warn or fail")
+	rootCmd.PersistentFlags().BoolVar(&updateExisting, "update", false, "if the tag already has a release, update its name and notes, its prerelease flag if --prerelease is set, and reconcile its assets with the artifacts, instead of failing")
+	rootCmd.PersistentFlags().StringVar(&planPath, "plan", "", "(optional) write the plan of the release to the file, eg: release.plan.json, without pushing, signing or publishing anything")
+	rootCmd.PersistentFlags().StringVar(&applyPlanPath, "apply", "", "(optional) release as planned in the plan file written by --plan, failing if the commit, assets or release have changed since")
+	rootCmd.PersistentFlags().DurationVar(&maxWait, "maxWait", 5*time.Minute, "how long to keep retrying while the Github API is under maintenance or unavailable; 0 fails straight away")
+	rootCmd.PersistentFlags().BoolVar(&checksums, "checksums", false, "upload a checksum file of the artifacts, eg: SHA256SUMS, with the release")
+	rootCmd.PersistentFlags().StringVar(&checksumAlgorithm, "checksumAlgorithm", "sha256", "digest algorithm of the checksum file: sha256 or sha512")
+	rootCmd.PersistentFlags().StringVar(&checksumSignCommand, "checksumSignCommand", "", "(optional) command signing the checksum file with a detached signature, eg: gpg --batch --yes --detach-sign --output {{ .Signature }} {{ .Path }}")
+	rootCmd.PersistentFlags().StringVar(&assetNameTemplate, "assetNameTemplate", "", "(optional) template of the binary asset names, eg: {{.Project}}_{{.Tag}}_{{.OS}}_{{.Arch}}{{.Ext}}")
+	rootCmd.PersistentFlags().StringToStringVar(&assetMetadata, "assetMetadata", map[string]string{}, "(optional) templates of metadata recorded in a .meta.json sidecar uploaded alongside each asset, by key, eg: target={{ .OS }}/{{ .Arch }},describe={{ .Describe }}")
+	rootCmd.PersistentFlags().StringSliceVar(&assetMetadataEnv, "assetMetadataEnv", []string{"GOFLAGS", "GOOS", "GOARCH", "CGO_ENABLED"}, "environment variables the asset metadata templates may read as {{ .Env.NAME }}; others, eg: tokens, are left out")
+	rootCmd.PersistentFlags().StringVar(&auditWebhookURL, "auditWebhookURL", "", "(optional) endpoint to send release.started, release.published and release.failed events to")
+	rootCmd.PersistentFlags().StringVar(&auditWebhookSecretEnv, "auditWebhookSecretEnv", "", "(optional) environment variable containing the secret audit events are signed with")
+
+	// Strip binary artifacts, releasing their debug symbols separately; optional
+	rootCmd.PersistentFlags().BoolVar(&stripSymbols, "stripSymbols", false, "strip binary artifacts and release their debug symbols as separate .debug.tar.gz assets")
+	rootCmd.PersistentFlags().StringVar(&stripCommand, "stripCommand", "strip", "command used to strip binary artifacts")
+	rootCmd.PersistentFlags().StringVar(&symbolHook, "symbolHook", "", "(optional) command to receive each unstripped binary, eg: to upload to a symbol server, instead of releasing the debug archives")
+
+	// Prerelease soak policy for final releases; optional
+	rootCmd.PersistentFlags().IntVar(&minSoakDays, "minSoakDays", 0, "(optional) days a prerelease of the same version must have been published before a final release")
+	rootCmd.PersistentFlags().IntVar(&minSoakDownloads, "minSoakDownloads", 0, "(optional) downloads the prereleases of the same version must have before a final release")
+	rootCmd.PersistentFlags().BoolVar(&ignoreSoak, "ignoreSoak", false, "publish a final release even if the prerelease soak policy is not met")
+
+	// Version suite of the organization's repositories released in step; optional
+	rootCmd.PersistentFlags().StringVar(&versionSuite, "versionSuite", "", "(optional) pattern of the organization's repositories released at the same versions, eg: platform-*, warning about those whose latest release is of another version")
+
+	// Notify owners about the release; optional
+	rootCmd.PersistentFlags().StringSliceVar(&notifyTeams, "notifyTeams", []string{}, "(optional) users or teams to mention about the release, eg: @org/team")
+	rootCmd.PersistentFlags().BoolVar(&notifyCodeowners, "notifyCodeowners", false, "mention the CODEOWNERS of the paths changed since the previous release")
+	rootCmd.PersistentFlags().IntVar(&notifyIssue, "notifyIssue", 0, "(optional) issue to comment on with the mentions; a new issue is opened if not set")
+
+	// Dependency vulnerability and license scan before tagging; optional
+	rootCmd.PersistentFlags().BoolVar(&scan, "scan", false, "scan the dependencies before tagging, with govulncheck for Go projects or --scanCommand")
+	rootCmd.PersistentFlags().StringVar(&scanCommand, "scanCommand", "", "(optional) scanner command to run in the repository, writing its findings to stdout")
+	rootCmd.PersistentFlags().StringVar(&scanFormat, "scanFormat", "", "format of the scanner command output: sarif (default) or govulncheck")
+	rootCmd.PersistentFlags().StringVar(&scanFailOn, "scanFailOn", "high", "block the release on findings at or above this severity: low, medium, high or critical")
+	rootCmd.PersistentFlags().StringVar(&scanWarnOn, "scanWarnOn", "low", "warn about findings at or above this severity")
+	rootCmd.PersistentFlags().StringSliceVar(&scanIgnore, "scanIgnore", []string{}, "(optional) IDs of findings to ignore, eg: GO-2023-1234")
+
+	// Badge and latest download links for docs; optional
+	rootCmd.PersistentFlags().BoolVar(&showLinks, "showLinks", false, "print a latest version badge and latest download links for each asset in the summary")
+	rootCmd.PersistentFlags().StringVar(&linksFile, "linksFile", "", "(optional) file to write the badge and download links to, as markdown")
+
 	// Bind these values to viper
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("clientID", rootCmd.PersistentFlags().Lookup("clientID"))
+	viper.BindPFlag("oauthScope", rootCmd.PersistentFlags().Lookup("oauthScope"))
+	viper.BindPFlag("oauthGrantType", rootCmd.PersistentFlags().Lookup("oauthGrantType"))
+	viper.BindPFlag("authURL", rootCmd.PersistentFlags().Lookup("authURL"))
+	viper.BindPFlag("deviceAuthURL", rootCmd.PersistentFlags().Lookup("deviceAuthURL"))
+	viper.BindPFlag("tokenURL", rootCmd.PersistentFlags().Lookup("tokenURL"))
+	viper.BindPFlag("apiURL", rootCmd.PersistentFlags().Lookup("apiURL"))
+	viper.BindPFlag("uploadURL", rootCmd.PersistentFlags().Lookup("uploadURL"))
+	viper.BindPFlag("uploadURLTemplate", rootCmd.PersistentFlags().Lookup("uploadURLTemplate"))
+	viper.BindPFlag("gitlabURL", rootCmd.PersistentFlags().Lookup("gitlabURL"))
+	viper.BindPFlag("gitlabToken", rootCmd.PersistentFlags().Lookup("gitlabToken"))
+	viper.BindPFlag("gitlabAssetLinkURL", rootCmd.PersistentFlags().Lookup("gitlabAssetLinkURL"))
+	viper.BindPFlag("bitbucketUsername", rootCmd.PersistentFlags().Lookup("bitbucketUsername"))
+	viper.BindPFlag("bitbucketToken", rootCmd.PersistentFlags().Lookup("bitbucketToken"))
+	viper.BindPFlag("appID", rootCmd.PersistentFlags().Lookup("appID"))
+	viper.BindPFlag("appInstallationID", rootCmd.PersistentFlags().Lookup("appInstallationID"))
+	viper.BindPFlag("appPrivateKey", rootCmd.PersistentFlags().Lookup("appPrivateKey"))
+	viper.BindPFlag("appPrivateKeyEnv", rootCmd.PersistentFlags().Lookup("appPrivateKeyEnv"))
+	viper.BindPFlag("ghCredentials", rootCmd.PersistentFlags().Lookup("ghCredentials"))
+	viper.BindPFlag("gitCredentials", rootCmd.PersistentFlags().Lookup("gitCredentials"))
+	viper.BindPFlag("cacheToken", rootCmd.PersistentFlags().Lookup("cacheToken"))
+	viper.BindPFlag("noColor", rootCmd.PersistentFlags().Lookup("noColor"))
+	viper.BindPFlag("plain", rootCmd.PersistentFlags().Lookup("plain"))
 	viper.BindPFlag("force", rootCmd.PersistentFlags().Lookup("force"))
+	viper.BindPFlag("nonInteractive", rootCmd.PersistentFlags().Lookup("nonInteractive"))
+	viper.BindPFlag("promptTimeout", rootCmd.PersistentFlags().Lookup("promptTimeout"))
+	viper.BindPFlag("promptDefault", rootCmd.PersistentFlags().Lookup("promptDefault"))
+	viper.BindPFlag("traceHTTP", rootCmd.PersistentFlags().Lookup("traceHTTP"))
+	viper.BindPFlag("userAgent", rootCmd.PersistentFlags().Lookup("userAgent"))
+	viper.BindPFlag("recordHTTP", rootCmd.PersistentFlags().Lookup("recordHTTP"))
+	viper.BindPFlag("replayHTTP", rootCmd.PersistentFlags().Lookup("replayHTTP"))
 	viper.BindPFlag("repositoryURL", rootCmd.PersistentFlags().Lookup("repositoryURL"))
+	viper.BindPFlag("repo", rootCmd.PersistentFlags().Lookup("repo"))
+	viper.BindPFlag("upstreamRepositoryURL", rootCmd.PersistentFlags().Lookup("upstreamRepositoryURL"))
+	viper.BindPFlag("upstreamRepo", rootCmd.PersistentFlags().Lookup("upstreamRepo"))
+	viper.BindPFlag("provider", rootCmd.PersistentFlags().Lookup("provider"))
+	viper.BindPFlag("gitProtocol", rootCmd.PersistentFlags().Lookup("gitProtocol"))
+	viper.BindPFlag("pushTagTo", rootCmd.PersistentFlags().Lookup("pushTagTo"))
+	viper.BindPFlag("aliasTag", rootCmd.PersistentFlags().Lookup("aliasTag"))
 	viper.BindPFlag("commitish", rootCmd.PersistentFlags().Lookup("commitish"))
+	viper.BindPFlag("pr", rootCmd.PersistentFlags().Lookup("pr"))
 	viper.BindPFlag("branch", rootCmd.PersistentFlags().Lookup("branch"))
 	viper.BindPFlag("makeTarget", rootCmd.PersistentFlags().Lookup("makeTarget"))
+	viper.BindPFlag("buildMetadata", rootCmd.PersistentFlags().Lookup("buildMetadata"))
+	viper.BindPFlag("sparsePath", rootCmd.PersistentFlags().Lookup("sparsePath"))
+	viper.BindPFlag("inMemory", rootCmd.PersistentFlags().Lookup("inMemory"))
+	viper.BindPFlag("buildCounter", rootCmd.PersistentFlags().Lookup("buildCounter"))
+	viper.BindPFlag("notesFile", rootCmd.PersistentFlags().Lookup("notesFile"))
+	viper.BindPFlag("notesFromPRs", rootCmd.PersistentFlags().Lookup("notesFromPRs"))
+	viper.BindPFlag("generateNotes", rootCmd.PersistentFlags().Lookup("generateNotes"))
+	viper.BindPFlag("notesSummarizer", rootCmd.PersistentFlags().Lookup("notesSummarizer"))
+	viper.BindPFlag("notesSummarizerTimeout", rootCmd.PersistentFlags().Lookup("notesSummarizerTimeout"))
+	viper.BindPFlag("since", rootCmd.PersistentFlags().Lookup("since"))
+	viper.BindPFlag("notesCache", rootCmd.PersistentFlags().Lookup("notesCache"))
+	viper.BindPFlag("securityAdvisories", rootCmd.PersistentFlags().Lookup("securityAdvisories"))
+	viper.BindPFlag("securityAdvisoryIDs", rootCmd.PersistentFlags().Lookup("securityAdvisoryIDs"))
+	viper.BindPFlag("diffStats", rootCmd.PersistentFlags().Lookup("diffStats"))
+	viper.BindPFlag("provenance", rootCmd.PersistentFlags().Lookup("provenance"))
+	viper.BindPFlag("notesExcludeLabels", rootCmd.PersistentFlags().Lookup("notesExcludeLabels"))
+	viper.BindPFlag("tagMessageTemplate", rootCmd.PersistentFlags().Lookup("tagMessageTemplate"))
+	viper.BindPFlag("tagWithAPI", rootCmd.PersistentFlags().Lookup("tagWithAPI"))
+	viper.BindPFlag("tagCleanup", rootCmd.PersistentFlags().Lookup("tagCleanup"))
+	viper.BindPFlag("draft", rootCmd.PersistentFlags().Lookup("draft"))
+	viper.BindPFlag("prerelease", rootCmd.PersistentFlags().Lookup("prerelease"))
+	viper.BindPFlag("releaseName", rootCmd.PersistentFlags().Lookup("releaseName"))
+	viper.BindPFlag("discussionCategory", rootCmd.PersistentFlags().Lookup("discussionCategory"))
+	viper.BindPFlag("latest", rootCmd.PersistentFlags().Lookup("latest"))
+	viper.BindPFlag("publishAt", rootCmd.PersistentFlags().Lookup("publishAt"))
+	viper.BindPFlag("publishWait", rootCmd.PersistentFlags().Lookup("publishWait"))
+	viper.BindPFlag("artifacts", rootCmd.PersistentFlags().Lookup("artifacts"))
+	viper.BindPFlag("maxAssetSize", rootCmd.PersistentFlags().Lookup("maxAssetSize"))
+	viper.BindPFlag("maxTotalSize", rootCmd.PersistentFlags().Lookup("maxTotalSize"))
+	viper.BindPFlag("sizeBudgetAction", rootCmd.PersistentFlags().Lookup("sizeBudgetAction"))
+	viper.BindPFlag("uploadConcurrency", rootCmd.PersistentFlags().Lookup("uploadConcurrency"))
+	viper.BindPFlag("replaceAssets", rootCmd.PersistentFlags().Lookup("replaceAssets"))
+	viper.BindPFlag("update", rootCmd.PersistentFlags().Lookup("update"))
+	viper.BindPFlag("plan", rootCmd.PersistentFlags().Lookup("plan"))
+	viper.BindPFlag("apply", rootCmd.PersistentFlags().Lookup("apply"))
+	viper.BindPFlag("rerunChanges", rootCmd.PersistentFlags().Lookup("rerunChanges"))
+	viper.BindPFlag("gitNote", rootCmd.PersistentFlags().Lookup("gitNote"))
+	viper.BindPFlag("verifyUploads", rootCmd.PersistentFlags().Lookup("verifyUploads"))
+	viper.BindPFlag("verifySample", rootCmd.PersistentFlags().Lookup("verifySample"))
+	viper.BindPFlag("maxWait", rootCmd.PersistentFlags().Lookup("maxWait"))
+	viper.BindPFlag("checksums", rootCmd.PersistentFlags().Lookup("checksums"))
+	viper.BindPFlag("checksumAlgorithm", rootCmd.PersistentFlags().Lookup("checksumAlgorithm"))
+	viper.BindPFlag("checksumSignCommand", rootCmd.PersistentFlags().Lookup("checksumSignCommand"))
+	viper.BindPFlag("assetNameTemplate", rootCmd.PersistentFlags().Lookup("assetNameTemplate"))
+	viper.BindPFlag("assetMetadata", rootCmd.PersistentFlags().Lookup("assetMetadata"))
+	viper.BindPFlag("assetMetadataEnv", rootCmd.PersistentFlags().Lookup("assetMetadataEnv"))
+	viper.BindPFlag("auditWebhookURL", rootCmd.PersistentFlags().Lookup("auditWebhookURL"))
+	viper.BindPFlag("auditWebhookSecretEnv", rootCmd.PersistentFlags().Lookup("auditWebhookSecretEnv"))
+	viper.BindPFlag("stripSymbols", rootCmd.PersistentFlags().Lookup("stripSymbols"))
+	viper.BindPFlag("stripCommand", rootCmd.PersistentFlags().Lookup("stripCommand"))
+	viper.BindPFlag("symbolHook", rootCmd.PersistentFlags().Lookup("symbolHook"))
+	viper.BindPFlag("minSoakDays", rootCmd.PersistentFlags().Lookup("minSoakDays"))
+	viper.BindPFlag("minSoakDownloads", rootCmd.PersistentFlags().Lookup("minSoakDownloads"))
+	viper.BindPFlag("ignoreSoak", rootCmd.PersistentFlags().Lookup("ignoreSoak"))
+	viper.BindPFlag("versionSuite", rootCmd.PersistentFlags().Lookup("versionSuite"))
+	viper.BindPFlag("notifyTeams", rootCmd.PersistentFlags().Lookup("notifyTeams"))
+	viper.BindPFlag("notifyCodeowners", rootCmd.PersistentFlags().Lookup("notifyCodeowners"))
+	viper.BindPFlag("notifyIssue", rootCmd.PersistentFlags().Lookup("notifyIssue"))
+	viper.BindPFlag("showLinks", rootCmd.PersistentFlags().Lookup("showLinks"))
+	viper.BindPFlag("scan", rootCmd.PersistentFlags().Lookup("scan"))
+	viper.BindPFlag("scanCommand", rootCmd.PersistentFlags().Lookup("scanCommand"))
+	viper.BindPFlag("scanFormat", rootCmd.PersistentFlags().Lookup("scanFormat"))
+	viper.BindPFlag("scanFailOn", rootCmd.PersistentFlags().Lookup("scanFailOn"))
+	viper.BindPFlag("scanWarnOn", rootCmd.PersistentFlags().Lookup("scanWarnOn"))
+	viper.BindPFlag("scanIgnore", rootCmd.PersistentFlags().Lookup("scanIgnore"))
+	viper.BindPFlag("linksFile", rootCmd.PersistentFlags().Lookup("linksFile"))
 
 }
 
+// globalConfigPath returns the path of the user's config, as written by setup
+var globalConfigPath = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-git-release", "config.yaml"), nil
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	// The user's global config has the defaults the repository's config file overrides
+	if path, err := globalConfigPath(); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			viper.SetConfigFile(path)
+			if err := viper.ReadInConfig(); err != nil {
+				fmt.Println(err)
+				os.Exit(exitInvalid)
+			}
+			fmt.Println("Using global config file:", path)
+		}
+	}
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -199,7 +657,7 @@ func initConfig() {
 		dir, err := os.Getwd()
 		if err != nil {
 			fmt.Println(err)
-			os.Exit(1)
+			os.Exit(exitInvalid)
 		}
 
 		// Search config in current directory with name ".go-git-release.yaml"
@@ -209,17 +667,206 @@ func initConfig() {
 
 	viper.AutomaticEnv() // read in environment variables that match
 
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
+	// If a config file is found, read it in over the global config.
+	if err := viper.MergeInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	} else {
 		if verbose {
 			fmt.Println(err)
-			os.Exit(1)
+			os.Exit(exitInvalid)
 		}
 	}
 }
 
+// loadSettings reads the settings from the flags, environment and config file, and
+// returns an error for each config file section that can't be read
+func loadSettings() []error {
+	e := make([]error, 0)
+
+	clientID = viper.GetString("clientID")
+	oauthScope = viper.GetString("oauthScope")
+	oauthGrantType = viper.GetString("oauthGrantType")
+	githubEndpoint.AuthURL = viper.GetString("authURL")
+	githubEndpoint.DeviceAuthURL = viper.GetString("deviceAuthURL")
+	githubEndpoint.TokenURL = viper.GetString("tokenURL")
+	githubEndpoint.APIURL = viper.GetString("apiURL")
+	githubEndpoint.UploadURL = viper.GetString("uploadURL")
+	uploadURLTemplate = viper.GetString("uploadURLTemplate")
+
+	// In a Github Actions job, they default to those of the instance running it
+	if githubActions() {
+		if u := os.Getenv("GITHUB_SERVER_URL"); u != "" && !viper.IsSet("authURL") {
+			githubEndpoint.AuthURL = u
+		}
+		if u := os.Getenv("GITHUB_API_URL"); u != "" && !viper.IsSet("apiURL") {
+			githubEndpoint.APIURL = u
+		}
+	}
+
+	gitlabURL = viper.GetString("gitlabURL")
+	gitlabToken = viper.GetString("gitlabToken")
+	gitlabAssetLinkURL = viper.GetString("gitlabAssetLinkURL")
+	bitbucketUsername = viper.GetString("bitbucketUsername")
+	bitbucketToken = viper.GetString("bitbucketToken")
+
+	// In a GitLab CI job, it defaults to the instance running it
+	if gitlabCI() {
+		if u := os.Getenv("CI_SERVER_URL"); u != "" && !viper.IsSet("gitlabURL") {
+			gitlabURL = u
+		}
+	}
+
+	// The device flow endpoints default to those of the web host
+	authURL := strings.TrimSuffix(githubEndpoint.AuthURL, "/")
+	if !viper.IsSet("deviceAuthURL") {
+		githubEndpoint.DeviceAuthURL = authURL + deviceAuthPath
+	}
+	if !viper.IsSet("tokenURL") {
+		githubEndpoint.TokenURL = authURL + tokenPath
+	}
+
+	verbose = viper.GetBool("verbose")
+	// token is only read from --token, as AutomaticEnv would take any TOKEN in the
+	// environment; GITHUB_TOKEN and GH_TOKEN are read by providedToken
+	appID = viper.GetInt64("appID")
+	appInstallationID = viper.GetInt64("appInstallationID")
+	appPrivateKey = viper.GetString("appPrivateKey")
+	appPrivateKeyEnv = viper.GetString("appPrivateKeyEnv")
+	ghCredentials = viper.GetBool("ghCredentials")
+	gitCredentials = viper.GetBool("gitCredentials")
+	// ciMode is only read from --ci, as AutomaticEnv would take the CI=true most CI
+	// runners set, and turn off the gh CLI and other credentials outside Github Actions
+	if githubActions() && !ciFlag.Changed {
+		ciMode = true
+	}
+	cacheToken = viper.GetBool("cacheToken")
+	// Where the token is kept is only configurable via the config file
+	tokenStorage = viper.GetString("auth.storage")
+	if tokenStorage == "" {
+		tokenStorage = tokenStorageFile
+	}
+	noColor = viper.GetBool("noColor")
+	plainOutput = viper.GetBool("plain")
+	nonInteractive = viper.GetBool("nonInteractive")
+	promptTimeout = viper.GetDuration("promptTimeout")
+	promptDefault = viper.GetString("promptDefault")
+	repositoryURL = viper.GetString("repositoryURL")
+	traceHTTP = viper.GetString("traceHTTP")
+	userAgent = viper.GetString("userAgent")
+	recordHTTP = viper.GetString("recordHTTP")
+	replayHTTP = viper.GetString("replayHTTP")
+	repo = viper.GetString("repo")
+	shorthandProvider = viper.GetString("provider")
+	gitProtocol = viper.GetString("gitProtocol")
+	// CI has a token, but usually no SSH key, to clone with
+	if ciMode && !viper.IsSet("gitProtocol") {
+		gitProtocol = protocolHTTPS
+	}
+	// Per-host protocol overrides are only configurable via the config file
+	gitProtocolHosts = viper.GetStringMapString("gitProtocolHosts")
+	upstreamRepositoryURL = viper.GetString("upstreamRepositoryURL")
+	upstreamRepo = viper.GetString("upstreamRepo")
+	pushTagTo = viper.GetString("pushTagTo")
+	aliasTags = viper.GetStringSlice("aliasTag")
+	commitish = viper.GetString("commitish")
+	pullRequestNumber = viper.GetInt("pr")
+	branch = viper.GetString("branch")
+	makeTarget = viper.GetString("makeTarget")
+	buildMetadata = viper.GetStringSlice("buildMetadata")
+	sparsePaths = viper.GetStringSlice("sparsePath")
+	inMemoryClone = viper.GetBool("inMemory")
+	buildCounter = viper.GetString("buildCounter")
+	tagMessageTemplate = viper.GetString("tagMessageTemplate")
+	tagWithAPI = viper.GetBool("tagWithAPI")
+	draft = viper.GetBool("draft")
+	prerelease = viper.GetBool("prerelease")
+	prereleaseSet = viper.IsSet("prerelease")
+	releaseName = viper.GetString("releaseName")
+	discussionCategory = viper.GetString("discussionCategory")
+	markLatest = viper.GetString("latest")
+	publishAt = viper.GetString("publishAt")
+	publishWait = viper.GetBool("publishWait")
+	tagCleanup = viper.GetString("tagCleanup")
+	artifactPatterns = viper.GetStringSlice("artifacts")
+	maxAssetSize = viper.GetString("maxAssetSize")
+	maxTotalSize = viper.GetString("maxTotalSize")
+	sizeBudgetAction = viper.GetString("sizeBudgetAction")
+	uploadConcurrency = viper.GetInt("uploadConcurrency")
+	replaceAssets = viper.GetBool("replaceAssets")
+	updateExisting = viper.GetBool("update")
+	planPath = viper.GetString("plan")
+	applyPlanPath = viper.GetString("apply")
+	rerunChanges = viper.GetString("rerunChanges")
+	gitNote = viper.GetBool("gitNote")
+	verifyUploads = viper.GetBool("verifyUploads")
+	verifyUploadsSample = viper.GetInt("verifySample")
+	maxWait = viper.GetDuration("maxWait")
+	checksums = viper.GetBool("checksums")
+	checksumAlgorithm = viper.GetString("checksumAlgorithm")
+	checksumSignCommand = viper.GetString("checksumSignCommand")
+	assetNameTemplate = viper.GetString("assetNameTemplate")
+	assetMetadata = viper.GetStringMapString("assetMetadata")
+	assetMetadataEnv = viper.GetStringSlice("assetMetadataEnv")
+	auditWebhookURL = viper.GetString("auditWebhookURL")
+	auditWebhookSecretEnv = viper.GetString("auditWebhookSecretEnv")
+	stripSymbols = viper.GetBool("stripSymbols")
+	stripCommand = viper.GetString("stripCommand")
+	symbolHook = viper.GetString("symbolHook")
+	minSoakDays = viper.GetInt("minSoakDays")
+	minSoakDownloads = viper.GetInt("minSoakDownloads")
+	ignoreSoak = viper.GetBool("ignoreSoak")
+	versionSuite = viper.GetString("versionSuite")
+	notifyTeams = viper.GetStringSlice("notifyTeams")
+	notifyCodeowners = viper.GetBool("notifyCodeowners")
+	notifyIssue = viper.GetInt("notifyIssue")
+	showLinks = viper.GetBool("showLinks")
+	scan = viper.GetBool("scan")
+	scanCommand = viper.GetString("scanCommand")
+	scanFormat = viper.GetString("scanFormat")
+	scanFailOn = viper.GetString("scanFailOn")
+	scanWarnOn = viper.GetString("scanWarnOn")
+	scanIgnore = viper.GetStringSlice("scanIgnore")
+	linksFile = viper.GetString("linksFile")
+
+	// Post processors are only configurable via the config file
+	if err := viper.UnmarshalKey("postProcessors", &postProcessors); err != nil {
+		e = append(e, fmt.Errorf("invalid postProcessors configuration: %w", err))
+	}
+
+	// Archives are only configurable via the config file
+	if err := viper.UnmarshalKey("archives", &archives); err != nil {
+		e = append(e, fmt.Errorf("invalid archives configuration: %w", err))
+	}
+	if err := viper.UnmarshalKey("sourceArchive", &sourceArchive); err != nil {
+		e = append(e, fmt.Errorf("invalid sourceArchive configuration: %w", err))
+	}
+
+	notesFile = viper.GetString("notesFile")
+	notesFromPRs = viper.GetBool("notesFromPRs")
+	generateNotes = viper.GetBool("generateNotes")
+	notesSummarizer = viper.GetString("notesSummarizer")
+	notesSummarizerTimeout = viper.GetDuration("notesSummarizerTimeout")
+	notesSince = viper.GetString("since")
+	notesCache = viper.GetBool("notesCache")
+	securityAdvisories = viper.GetBool("securityAdvisories")
+	securityAdvisoryIDs = viper.GetStringSlice("securityAdvisoryIDs")
+	diffStats = viper.GetBool("diffStats")
+	recordProvenance = viper.GetBool("provenance")
+	notesExcludeLabels = viper.GetStringSlice("notesExcludeLabels")
+
+	// Release notes sections are only configurable via the config file
+	if err := viper.UnmarshalKey("notesSections", &notesSections); err != nil {
+		e = append(e, fmt.Errorf("invalid notesSections configuration: %w", err))
+	}
+
+	// Mirrors are only configurable via the config file
+	if err := viper.UnmarshalKey("mirrors", &mirrors); err != nil {
+		e = append(e, fmt.Errorf("invalid mirrors configuration: %w", err))
+	}
+
+	return e
+}
+
 // validate if enough info is provided to work
 // Precidence:
 //   * RepositoryURL is required
@@ -234,10 +881,246 @@ func initConfig() {
 func initialValidation() []error {
 	e := make([]error, 0)
 
-	// repositoryURL is required
-	if repositoryURL == "" {
-		e = appendErr(e, "repositoryURL")
+	// In a Github Actions job, the repository defaults to the one running the workflow
+	if ciMode && repositoryURL == "" && repo == "" {
+		repo = os.Getenv("GITHUB_REPOSITORY")
+	}
+
+	// repositoryURL, or the repo shorthand it is resolved from, is required
+	if repositoryURL != "" && repo != "" {
+		e = append(e, fmt.Errorf("only one of repositoryURL or repo may be provided"))
+	} else if repo != "" {
+		u, err := repositoryURLFromShorthand(repo)
+		if err != nil {
+			e = append(e, err)
+		}
+		repositoryURL = u
+	} else if repositoryURL == "" {
+		e = appendErr(e, "repositoryURL or repo")
+	}
+
+	// The upstream repository to release on when releasing from a fork is optional
+	if upstreamRepositoryURL != "" && upstreamRepo != "" {
+		e = append(e, fmt.Errorf("only one of upstreamRepositoryURL or upstreamRepo may be provided"))
+	} else if upstreamRepo != "" {
+		u, err := repositoryURLFromShorthand(upstreamRepo)
+		if err != nil {
+			e = append(e, err)
+		}
+		upstreamRepositoryURL = u
+	}
+
+	e = append(e, authValidation()...)
+
+	if promptDefault != "no" && promptDefault != "yes" {
+		e = append(e, fmt.Errorf("promptDefault must be one of: no, yes"))
+	}
+
+	if err := validProviderName(shorthandProvider); err != nil {
+		e = append(e, err)
+	}
+	if !validGitProtocol(gitProtocol) {
+		e = append(e, fmt.Errorf("gitProtocol must be one of: ssh, https"))
+	}
+	for host, protocol := range gitProtocolHosts {
+		if !validGitProtocol(protocol) {
+			e = append(e, fmt.Errorf("gitProtocolHosts %s must be one of: ssh, https", host))
+		}
+	}
+
+	if pushTagTo != "fork" && pushTagTo != "upstream" {
+		e = append(e, fmt.Errorf("pushTagTo must be one of: fork, upstream"))
+	}
+
+	for _, alias := range aliasTags {
+		if err := validAliasTag(alias, tag); err != nil {
+			e = append(e, err)
+		}
+	}
+
+	for _, id := range securityAdvisoryIDs {
+		if !advisoryIDExpression.MatchString(id) {
+			e = append(e, fmt.Errorf("securityAdvisoryIDs %q is not a GHSA ID, eg: GHSA-abcd-1234-wxyz", id))
+		}
+	}
+
+	if err := validateNotesFile(notesFile); err != nil {
+		e = append(e, fmt.Errorf("invalid notesFile: %w", err))
+	}
+
+	if notesSummarizer != "" {
+		if fields, err := splitCommand(notesSummarizer); err != nil || len(fields) == 0 {
+			e = append(e, fmt.Errorf("invalid notesSummarizer command %q", notesSummarizer))
+		}
+	}
+
+	switch markLatest {
+	case "", "true", "false", "legacy":
+	default:
+		e = append(e, fmt.Errorf("invalid latest %q; must be true, false or legacy", markLatest))
+	}
+
+	if checksumSignCommand != "" && !checksums {
+		e = append(e, fmt.Errorf("checksumSignCommand signs the checksum file, so requires checksums"))
+	}
+
+	if generateNotes && notesFromPRs {
+		e = append(e, fmt.Errorf("only one of generateNotes or notesFromPRs may be provided"))
+	}
+
+	if notesSince != "" && !notesFromPRs {
+		e = append(e, fmt.Errorf("since only applies to release notes generated with notesFromPRs"))
+	}
+
+	publishTime = time.Time{}
+	if publishAt != "" {
+		t, err := parsePublishAt(publishAt)
+		if err != nil {
+			e = append(e, err)
+		}
+		publishTime = t
+	}
+	if draft && publishAt != "" {
+		e = append(e, fmt.Errorf("draft cannot be used with publishAt, which publishes the draft at its time"))
+	}
+
+	if !validCleanupMode(tagCleanup) {
+		e = append(e, fmt.Errorf("tagCleanup must be one of: strip, whitespace, verbatim, scissors"))
+	}
+
+	if sizeBudgetAction != "warn" && sizeBudgetAction != "fail" {
+		e = append(e, fmt.Errorf("sizeBudgetAction must be one of: warn, fail"))
+	}
+
+	if rerunChanges != "warn" && rerunChanges != "fail" {
+		e = append(e, fmt.Errorf("rerunChanges must be one of: warn, fail"))
+	}
+
+	if pullRequestNumber < 0 {
+		e = append(e, fmt.Errorf("pr must be a pull request number"))
+	} else if pullRequestNumber > 0 && commitish != "" {
+		e = append(e, fmt.Errorf("only one of commitish or pr may be provided"))
+	}
+
+	if planPath != "" && applyPlanPath != "" {
+		e = append(e, fmt.Errorf("plan and apply cannot be used together"))
+	}
+
+	if checksumAlgorithm != "sha256" && checksumAlgorithm != "sha512" {
+		e = append(e, fmt.Errorf("checksumAlgorithm must be one of: sha256, sha512"))
+	}
+
+	if assetNameTemplate != "" {
+		if _, err := parseAssetNameTemplate(assetNameTemplate); err != nil {
+			e = append(e, fmt.Errorf("invalid assetNameTemplate: %w", err))
+		}
+	}
+	if _, err := parseAssetMetadata(assetMetadata); err != nil {
+		e = append(e, err)
+	}
+	if releaseName != "" {
+		if _, err := parseReleaseName(releaseName); err != nil {
+			e = append(e, fmt.Errorf("invalid releaseName: %w", err))
+		}
+	}
+
+	for i, p := range sparsePaths {
+		cleaned, err := cleanSparsePath(p)
+		if err != nil {
+			e = append(e, err)
+		}
+		sparsePaths[i] = cleaned
+	}
+
+	if _, err := path.Match(versionSuite, ""); err != nil {
+		e = append(e, fmt.Errorf("invalid versionSuite: %w", err))
+	}
+
+	if githubEndpoint.UploadURL != "" && uploadURLTemplate != "" {
+		e = append(e, fmt.Errorf("only one of uploadURL or uploadURLTemplate may be provided"))
+	}
+	if uploadURLTemplate != "" {
+		if _, err := parseUploadURLTemplate(uploadURLTemplate); err != nil {
+			e = append(e, fmt.Errorf("invalid uploadURLTemplate: %w", err))
+		}
+	}
+
+	if !validEndpointURL(gitlabURL) {
+		e = append(e, fmt.Errorf("gitlabURL must be an http or https URL"))
+	}
+	if gitlabAssetLinkURL != "" {
+		if _, err := parseGitlabAssetLinkURL(gitlabAssetLinkURL); err != nil {
+			e = append(e, fmt.Errorf("invalid gitlabAssetLinkURL: %w", err))
+		}
+	}
+
+	if uploadConcurrency < 1 {
+		e = append(e, fmt.Errorf("uploadConcurrency must be at least 1"))
+	}
+
+	if verifyUploadsSample < 0 {
+		e = append(e, fmt.Errorf("verifySample cannot be negative"))
+	}
+
+	for name, severity := range map[string]string{"scanFailOn": scanFailOn, "scanWarnOn": scanWarnOn} {
+		if severity != "" && severityRank(severity) < 0 {
+			e = append(e, fmt.Errorf("%s must be one of: %s", name, strings.Join(severities, ", ")))
+		}
+	}
+
+	if scanFormat != "" && scanFormat != scanFormatSARIF && scanFormat != scanFormatGovulncheck {
+		e = append(e, fmt.Errorf("scanFormat must be one of: sarif, govulncheck"))
+	}
+
+	if buildCounter != "" {
+		counterType := strings.SplitN(buildCounter, ":", 2)[0]
+		if counterType != "file" && counterType != "github-variable" && counterType != "api" {
+			e = append(e, fmt.Errorf("buildCounter must be one of: file:<path>, github-variable:<NAME>, api:<url>"))
+		}
+	}
+
+	return e
+}
+
+// authValidation checks the settings used to authenticate with Github, which are all the
+// auth commands need
+func authValidation() []error {
+	e := make([]error, 0)
+
+	endpoints := map[string]string{
+		"authURL":       githubEndpoint.AuthURL,
+		"deviceAuthURL": githubEndpoint.DeviceAuthURL,
+		"tokenURL":      githubEndpoint.TokenURL,
+		"apiURL":        githubEndpoint.APIURL,
+	}
+	if githubEndpoint.UploadURL != "" {
+		endpoints["uploadURL"] = githubEndpoint.UploadURL
+	}
+	for name, u := range endpoints {
+		if !validEndpointURL(u) {
+			e = append(e, fmt.Errorf("%s must be an http or https URL", name))
+		}
+	}
+
+	if tokenStorage != tokenStorageFile && tokenStorage != tokenStorageKeyring {
+		e = append(e, fmt.Errorf("auth.storage must be one of: file, keyring"))
 	}
+
+	// A Github App needs its private key, and the other App settings are only used with one
+	if appID != 0 && appPrivateKey == "" && appPrivateKeyEnv == "" {
+		e = append(e, fmt.Errorf("appID requires appPrivateKey or appPrivateKeyEnv"))
+	}
+	if appPrivateKey != "" && appPrivateKeyEnv != "" {
+		e = append(e, fmt.Errorf("only one of appPrivateKey or appPrivateKeyEnv may be provided"))
+	}
+	if appID == 0 && (appInstallationID != 0 || appPrivateKey != "" || appPrivateKeyEnv != "") {
+		e = append(e, fmt.Errorf("appInstallationID, appPrivateKey and appPrivateKeyEnv require appID"))
+	}
+
+	if recordHTTP != "" && replayHTTP != "" {
+		e = append(e, fmt.Errorf("recordHTTP and replayHTTP cannot be used together"))
+	}
+
 	return e
 }
 
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestMaskSecrets checks tokens, secrets and passwords are masked in the settings, including nested ones, and other settings are kept
+func TestMaskSecrets(t *testing.T) {
+	cfg := map[string]interface{}{
+		"token":                 "ghp_secret",
+		"gitlabtoken":           "glpat-secret",
+		"bitbuckettoken":        "app-password",
+		"tokenurl":              "https://github.com/login/oauth/access_token",
+		"auditwebhooksecretenv": "AUDIT_SECRET",
+		"repository":            "https://github.com/clcollins/go-git-release",
+		"webhook": map[string]interface{}{
+			"secret": "hmac-secret",
+			"path":   "/hooks",
+		},
+		"signing": map[string]interface{}{
+			"password":   "p12-password",
+			"passphrase": "key-passphrase",
+		},
+	}
+
+	masked := maskSecrets(cfg)
+	Equal(t, "********", masked["token"])
+	Equal(t, "********", masked["gitlabtoken"])
+	Equal(t, "********", masked["bitbuckettoken"])
+	Equal(t, "https://github.com/login/oauth/access_token", masked["tokenurl"])
+	Equal(t, "AUDIT_SECRET", masked["auditwebhooksecretenv"])
+	Equal(t, "https://github.com/clcollins/go-git-release", masked["repository"])
+	Equal(t, map[string]interface{}{"secret": "********", "path": "/hooks"}, masked["webhook"])
+	Equal(t, map[string]interface{}{"password": "********", "passphrase": "********"}, masked["signing"])
+	Equal(t, "ghp_secret", cfg["token"], "the settings themselves are not changed")
+}
@@ -18,21 +18,25 @@ limitations under the License.
 package cmd
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
 )
 
-const scope = "repo"
+// defaultScope is the OAuth scope the device flow requests, enough to publish releases
+// to private repositories
+const defaultScope = "repo"
 
 func noteInfo(msg string) {
 	note(msg, "info")
@@ -42,10 +46,24 @@ func noteErr(msg string) {
 	note(msg, "error")
 }
 
+// reportErr prints the error even when not verbose, eg: for the watch daemon, whose
+// failures would otherwise go unseen
+func reportErr(msg string) {
+	printNote(msg, "error")
+}
+
 func note(msg string, level string) {
 	if verbose {
-		fmt.Printf("[%s] %s\n", strings.ToUpper(level), msg)
+		printNote(msg, level)
+	}
+}
+
+func printNote(msg string, level string) {
+	color := colorCyan
+	if level == "error" {
+		color = colorRed
 	}
+	fmt.Printf("%s %s\n", colorize("["+strings.ToUpper(level)+"]", color), msg)
 }
 
 var emptyCommitarray = make([]byte, 20)
@@ -78,47 +96,27 @@ func createTempFile() (*os.File, error) {
 
 }
 
-// confirm prompts the user for yes or no, with a message from the provided string
-// Immediately returns true (yes) if the "force" flag is set
-func confirm(s string) bool {
-	// If the force flag is set, assume true
-	if force {
-		return true
-	}
-
-	reader := bufio.NewReader(os.Stdin)
-
-	for {
-		fmt.Printf("%s [y/n]: \n", s)
-
-		response, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-
-		response = strings.ToLower(strings.TrimSpace(response))
-
-		if response == "y" || response == "yes" {
-			return true
-		} else if response == "n" || response == "no" {
-			return false
-		}
-	}
-}
-
-// cloneRepo clones the provided git repository into the provided directory using the SSH Agent "git" identity
+// cloneRepo clones the provided git repository into the provided directory, authenticated
+// for the URL's protocol: the SSH agent or key for SSH URLs, the forge's token for https
 func cloneRepo(url, dir, branch string) (*git.Repository, error) {
-	auth, keyErr := ssh.NewSSHAgentAuth("git")
+	return cloneWith(url, branch, func(o *git.CloneOptions) (*git.Repository, error) {
+		return git.PlainClone(dir, false, o)
+	})
+}
 
-	if keyErr != nil {
-		return nil, keyErr
+// cloneWith clones the repository with the clone func, which stores it on disk or in memory
+func cloneWith(url, branch string, clone func(*git.CloneOptions) (*git.Repository, error)) (*git.Repository, error) {
+	auth, err := gitAuth(url)
+	if err != nil {
+		return nil, err
 	}
 
 	cloneOpts := &git.CloneOptions{
 		Progress: gitopts.progress,
 		URL:      url,
 		Auth:     auth,
+		// Sparse clones only check out the cone, below
+		NoCheckout: len(sparsePaths) > 0,
 	}
 
 	// Convert the branch strings to a real ReferenceName type
@@ -134,26 +132,44 @@ func cloneRepo(url, dir, branch string) (*git.Repository, error) {
 	// }
 
 	// Validate the options we are going to pass into the PlainClone function
-	err := cloneOpts.Validate()
+	err = cloneOpts.Validate()
 	if err != nil {
 		return nil, err
 	}
 
-	// Clone the repository to the temporary directory
-	repo, err := git.PlainClone(dir, false, cloneOpts)
+	// Clone the repository to the temporary directory, or memory
+	repo, err := clone(cloneOpts)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if len(sparsePaths) > 0 {
+		head, err := repo.Head()
+		if err != nil {
+			return nil, err
+		}
+		if err = sparseCheckout(repo, head.Hash(), sparsePaths); err != nil {
+			return nil, fmt.Errorf("failed checking out %s: %w", strings.Join(sparsePaths, ", "), err)
+		}
+	}
+
 	return repo, nil
 }
 
+// sshKeyNames are the private keys in ~/.ssh used to authenticate git over ssh without
+// an ssh agent, in the order they're tried
+var sshKeyNames = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
 func publicKey(keyname string) (*ssh.PublicKeys, error) {
 
 	var publicKey *ssh.PublicKeys
 
-	sshPath := os.Getenv("HOME") + "/.ssh/" + keyname
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	sshPath := filepath.Join(home, ".ssh", keyname)
 
 	sshKey, err := ioutil.ReadFile(sshPath)
 
@@ -161,7 +177,17 @@ func publicKey(keyname string) (*ssh.PublicKeys, error) {
 		return nil, err
 	}
 
-	publicKey, err = ssh.NewPublicKeys("git", []byte(sshKey), "")
+	// Encrypted keys are unlocked with a passphrase typed at the prompt
+	passphrase := ""
+	var missing *cryptossh.PassphraseMissingError
+	if _, err = cryptossh.ParseRawPrivateKey(sshKey); errors.As(err, &missing) {
+		passphrase, err = promptSecret(fmt.Sprintf("Passphrase for %s", sshPath))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	publicKey, err = ssh.NewPublicKeys("git", []byte(sshKey), passphrase)
 
 	if err != nil {
 		return nil, err
@@ -172,35 +198,210 @@ func publicKey(keyname string) (*ssh.PublicKeys, error) {
 }
 
 func run() error {
+	// Create a tempDir to clone into
+	if verbose {
+		noteInfo("Creating temporary directory")
+	}
+	tempDir, err := createTempDir()
+	if err != nil {
+		return fmt.Errorf("cannot create temporary directory: %w", err)
+	}
+
+	// Cleanup tempDir
+	defer os.Remove(tempDir)
+
+	// The notes are read before anything prompts, as they may be piped on stdin
+	notes, err := readNotesFile(notesFile, os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	// Planning changes nothing, so has nothing to audit
+	if planPath != "" {
+		p, err := preparePipeline(tempDir)
+		if err != nil {
+			return err
+		}
+		p.notes, p.plan = notes, &releasePlan{}
+
+		if err = p.runBuild(); err != nil {
+			return err
+		}
+		if err = p.processArtifacts(); err != nil {
+			return err
+		}
+
+		return p.planRelease(p.plan)
+	}
+
+	// The applied plan's tag message recreates the same tag
+	var plan *releasePlan
+	if applyPlanPath != "" {
+		plan, err = readReleasePlan(applyPlanPath)
+		if err != nil {
+			return fmt.Errorf("failed reading release plan: %w", err)
+		}
+		if tagMessage == "" {
+			tagMessage = plan.TagMessage
+		}
+	}
+
+	return auditedRelease(func() (*pipeline, error) {
+		p, err := preparePipeline(tempDir)
+		if err != nil {
+			return nil, err
+		}
+		p.notes, p.applied = notes, plan
+
+		err = p.runBuild()
+		if err != nil {
+			return p, err
+		}
+
+		err = p.processArtifacts()
+		if err != nil {
+			return p, err
+		}
+
+		if p.applied != nil {
+			if err = p.checkPlan(p.applied); err != nil {
+				return p, fmt.Errorf("cannot apply %s: %w", applyPlanPath, err)
+			}
+		}
+
+		return p, p.publishResuming()
+	})
+}
+
+// pipeline is the state passed between the stages of a release
+type pipeline struct {
+	gURL *gitURL
+	// releaseRepo is the repository the release is created on, which is the upstream
+	// repository when releasing from a fork
+	releaseRepo *gitURL
+	dir         string
+	repo        *git.Repository
+	build       *buildInfo
+	artifacts   []*artifact
+	// release is set once the release is created
+	release *release
+	// notes are the release notes from --notesFile, if any
+	notes string
+	// tagged is set when the run created the tag, rather than checking it out
+	tagged bool
+	// plan is set when the release is only planned, with --plan
+	plan *releasePlan
+	// applied is the plan the release applies, with --apply
+	applied *releasePlan
+}
+
+// newPipeline parses the repository URLs for a release
+func newPipeline(dir string) (*pipeline, error) {
 	// parse the user-provided git url
 	if verbose {
 		noteInfo("Parsing Git URL")
 	}
 	gURL, err := parseGitURL(repositoryURL)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// When releasing from a fork, the release is created on the upstream repository
+	releaseRepo := gURL
+	if upstreamRepositoryURL != "" {
+		releaseRepo, err = parseGitURL(upstreamRepositoryURL)
+		if err != nil {
+			return nil, err
+		}
+		if verbose {
+			noteInfo(fmt.Sprintf("Releasing fork %s on upstream %s", gURL.raw, releaseRepo.raw))
+		}
+	}
+
+	return &pipeline{gURL: gURL, releaseRepo: releaseRepo, dir: dir}, nil
+}
+
+// defaultBranch is the repository's default branch, when the release is made from it
+// because neither --branch nor --commitish is provided
+var defaultBranch string
+
+// cloneBranch returns the branch to clone: the --branch, or if neither it nor a commitish
+// is provided, the Github repository's default branch, so the release and the changes
+// since the previous tag come from it rather than a guess at main or master. Other forges
+// clone the remote HEAD, which is their default branch.
+func cloneBranch(gURL *gitURL) string {
+	defaultBranch = ""
+	if branch != "" || commitish != "" {
+		return branch
+	}
+	if _, ok := providerFor(gURL).(*githubProvider); !ok {
+		return ""
+	}
+
+	// Private repositories are only found with the token
+	auth, err := authenticate()
+	if err != nil {
+		fmt.Printf("WARNING: cannot find the default branch of %s, cloning the remote HEAD: %s\n", gURL.raw, err)
+		return ""
+	}
+
+	b, err := getDefaultBranch(auth, gURL)
+	if err != nil {
+		fmt.Printf("WARNING: cannot find the default branch of %s, cloning the remote HEAD: %s\n", gURL.raw, err)
+		return ""
 	}
 
-	// Create a tempDir to clone into
 	if verbose {
-		noteInfo("Creating temporary directory")
+		noteInfo(fmt.Sprintf("Using the default branch %s", b))
 	}
-	tempDir, err := createTempDir()
+
+	defaultBranch = b
+	return b
+}
+
+// preparePipeline clones the repository into dir, and checks out the existing tag or
+// creates it, ready for the build
+func preparePipeline(dir string) (*pipeline, error) {
+	p, err := newPipeline(dir)
 	if err != nil {
-		return fmt.Errorf("cannot create temporary directory: %s", err)
+		return nil, err
 	}
 
-	// Cleanup tempDir
-	defer os.Remove(tempDir)
+	// A job without the token or permissions it needs fails before the clone and build
+	if ciMode {
+		forge := providerFor(p.releaseRepo)
+		if _, github := forge.(*githubProvider); github {
+			err = ciPreflight(p.releaseRepo)
+		} else {
+			_, err = forge.authenticate(p.releaseRepo)
+		}
+		if err != nil {
+			return nil, stageFailed(errAuth, err)
+		}
+	}
+
+	// The pull request is merged on the repository the release is created on
+	if pullRequestNumber > 0 {
+		if err = releasePullRequest(p.releaseRepo); err != nil {
+			return nil, err
+		}
+	}
 
 	// Clone the remote
 	// If there is a branch, check that branch out specifically
 	if verbose {
-		noteInfo(fmt.Sprintf("Cloning %s into %s\n", gURL.raw, tempDir))
+		noteInfo(fmt.Sprintf("Cloning %s into %s\n", p.gURL.raw, dir))
 	}
-	repo, err := cloneRepo(gURL.raw, tempDir, branch)
+	repo, err := cloneRepo(p.gURL.raw, dir, cloneBranch(p.gURL))
 	if err != nil {
-		return fmt.Errorf("cannot clone repository: %s", err)
+		return nil, stageFailed(errClone, err)
+	}
+
+	if upstreamRepositoryURL != "" && pushTagTo == "upstream" {
+		err = addUpstreamRemote(repo, p.releaseRepo.raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot add upstream remote: %w", err)
+		}
 	}
 
 	if verbose {
@@ -210,17 +411,17 @@ func run() error {
 	errs := postCloneValidation()
 	if len(errs) != 0 {
 		for i := range errs {
-			fmt.Println(i)
+			fmt.Println(errs[i])
 		}
-		return fmt.Errorf("missing information")
+		return nil, fmt.Errorf("missing information")
 	}
 
-	tagObj, err := getTagFromString(tag, repo)
+	tagTarget, err := existingTagCommit(repo, tag)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if tagObj != nil {
+	if !tagTarget.IsZero() {
 		if !force {
 			// If the force flag was not set, prompt the user
 			fmt.Println("Provided tag already exists. Would you like to continue?")
@@ -229,18 +430,23 @@ func run() error {
 			// Prompt the user to continue
 			c := confirm("Would you like to continue?")
 			if !c {
-				return errors.New("tag exists; execution halted by user")
+				return nil, fmt.Errorf("tag exists; execution %w", errHalted)
 			}
 		}
 
 		// If proceed, then checkout the existing Tag target commiit
 		// No need to create a tag - already exists
 		if verbose {
-			noteInfo(fmt.Sprintf("Checking out Tag %s\n", tagObj.Name))
+			noteInfo(fmt.Sprintf("Checking out Tag %s\n", tag))
 		}
-		_, err = checkoutCommitish(repo, tagObj.Target)
+		_, err = checkoutCommitish(repo, tagTarget)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		err = scanRepository(dir)
+		if err != nil {
+			return nil, err
 		}
 	} else {
 		// Checkout the commitish, if provided, to create the tag with
@@ -251,57 +457,173 @@ func run() error {
 		}
 		repo, err = checkoutCommitish(repo, plumbing.NewHash(commitish))
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		// Scan before tagging, so a blocked release leaves no tag behind
+		err = scanRepository(dir)
+		if err != nil {
+			return nil, err
+		}
+
 		// Create the tag
 		if verbose {
 			fmt.Printf("Creating Tag %s\n", tag)
 		}
 		err = createTag(repo)
 		if err != nil {
-			return fmt.Errorf("cannot create tag: %s", err)
+			return nil, stageFailed(errTag, err)
 		}
+		p.tagged = true
+	}
+	p.repo = repo
+
+	// Work out the version, with any build metadata, to pass to the build
+	p.build, err = newBuildInfo(repo, p.releaseRepo, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed preparing build info: %w", err)
+	}
+	if verbose && p.build.Version != tag {
+		noteInfo(fmt.Sprintf("Building version %s", p.build.Version))
 	}
 
+	return p, nil
+}
+
+// runBuild runs the make target in the cloned repository, and finds the artifacts it built
+func (p *pipeline) runBuild() error {
 	// Run a build
 	if verbose {
 		fmt.Println("Building artifacts")
 	}
-	err = makeBuild(tempDir)
+	err := makeBuild(p.dir, p.build.environment())
 	if err != nil {
-		return fmt.Errorf("failed building artifacts: %s", err)
+		return stageFailed(errBuild, err)
 	}
 
-	// Create a release
-	// request user & device codes
 	if verbose {
-		fmt.Println("Authorizing device")
+		noteInfo("Finding build artifacts")
+	}
+	p.artifacts, err = findArtifacts(p.dir, artifactPatterns)
+	if err != nil {
+		return fmt.Errorf("failed finding build artifacts: %w", err)
+	}
+
+	return nil
+}
+
+// processArtifacts strips, post processes, archives and checks the size of the built artifacts
+func (p *pipeline) processArtifacts() error {
+	// Archives of the same commit are byte-identical, however often they're rebuilt
+	modTime, err := archiveTime(p.repo)
+	if err != nil {
+		return err
 	}
 
-	authResponse, err := requestDeviceAndUserCodes(githubEndpoint.DeviceAuthURL, clientID, scope)
+	p.artifacts, err = processDebugSymbols(p.artifacts, modTime)
 	if err != nil {
-		return fmt.Errorf("failed requesting device and user codes from github: %s", err)
+		return err
 	}
 
-	// prompt user to authorize
-	fmt.Printf("Please enter your one-time verification code at %s\n", authResponse.VerificationURI)
-	fmt.Printf("One-time code: %s\n", authResponse.UserCode)
-	openbrowser(authResponse.VerificationURI)
+	// Signed artifacts are compared with the plan as they were built
+	if err = recordUnsignedDigests(p.artifacts, postProcessors); err != nil {
+		return err
+	}
 
-	// poll for auth status
-	if verbose {
-		noteInfo("Polling for access token")
+	// Compress, sign, etc. the artifacts with any configured post processors. Planning
+	// records the signatures instead of making them.
+	processors := postProcessors
+	if p.plan != nil {
+		processors, err = p.plan.deferSigning(p.artifacts, postProcessors)
+		if err != nil {
+			return err
+		}
+	}
+	err = runPostProcessors(p.artifacts, processors)
+	if err != nil {
+		return err
 	}
-	userAuthResponse, err := pollForAccessToken(
-		githubEndpoint.TokenURL,
-		clientID,
-		authResponse.DeviceCode,
-		githubDeviceGrantType,
-		authResponse.ExpiresIn,
-		authResponse.Interval,
-	)
+
+	// Wrap the processed binaries in per-platform archives, if configured
+	p.artifacts, err = archiveArtifacts(p.artifacts, p.dir, archives, modTime)
 	if err != nil {
-		return fmt.Errorf("failed checking for authorization and retrieving access token: %s", err)
+		return err
+	}
+
+	// Archive the source of the tagged commit, for distro packagers
+	if sourceArchive.Enabled {
+		src, err := createSourceArchive(p.repo, p.dir, p.releaseRepo.repository, tag, sourceArchive, modTime)
+		if err != nil {
+			return err
+		}
+		p.artifacts = append(p.artifacts, src)
+	}
+
+	// Name the assets consistently, before the names are checked or recorded anywhere
+	err = renameAssets(p.artifacts, assetNameTemplate, p.releaseRepo.repository, p.build)
+	if err != nil {
+		return err
+	}
+
+	// Bitbucket's downloads are shared by all tags, so each release's are named for its tag
+	_, bitbucket := providerFor(p.releaseRepo).(*bitbucketProvider)
+	if bitbucket {
+		versionDownloadNames(p.artifacts, tag)
+	}
+
+	// Check the artifacts against the size budgets before anything is published
+	err = enforceSizeBudgets(p.artifacts)
+	if err != nil {
+		return err
+	}
+
+	// Each asset's metadata is uploaded alongside it, and covered by the checksums
+	sidecars, err := writeAssetSidecars(p.artifacts, assetMetadata, p.releaseRepo.repository, p.build)
+	if err != nil {
+		return fmt.Errorf("failed writing asset metadata: %w", err)
+	}
+	p.artifacts = append(p.artifacts, sidecars...)
+
+	// The checksums are of the final artifacts, as they are uploaded
+	if checksums && len(p.artifacts) > 0 {
+		sums, err := writeChecksumFile(p.dir, p.artifacts, checksumAlgorithm)
+		if err != nil {
+			return fmt.Errorf("failed writing checksum file: %w", err)
+		}
+		p.artifacts = append(p.artifacts, sums)
+
+		if checksumSignCommand != "" && p.plan != nil {
+			p.plan.deferChecksumSigning(sums)
+		} else if checksumSignCommand != "" {
+			sig, err := signChecksumFile(sums, checksumSignCommand)
+			if err != nil {
+				return err
+			}
+			p.artifacts = append(p.artifacts, sig)
+		}
+	}
+
+	// The checksum file and its signature are named for the tag too
+	if bitbucket {
+		versionDownloadNames(p.artifacts, tag)
+	}
+
+	// Github would reject, or rename, assets uploaded under a name already taken
+	return checkAssetNames(p.artifacts, p.dir, assetNameTemplate)
+}
+
+// publish creates the release, uploads the artifacts, and reports on it
+func (p *pipeline) publish() error {
+	releaseRepo, repo, artifacts := p.releaseRepo, p.repo, p.artifacts
+
+	summary := &releaseSummary{tag: tag, version: p.build.Version}
+
+	// Create a release
+	// request user & device codes
+	forge := providerFor(releaseRepo)
+	userAuthResponse, err := forge.authenticate(releaseRepo)
+	if err != nil {
+		return stageFailed(errAuth, err)
 	}
 
 	// List releases (does one exist?)
@@ -309,31 +631,121 @@ func run() error {
 	if verbose {
 		noteInfo("Getting existing releases")
 	}
-	releases, err := getReleases(gURL)
+	releases, err := forge.listReleases(releaseRepo)
 	if err != nil {
-		return fmt.Errorf("failed retrieving list of releases: %s", err)
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
 	}
 
+	// Bitbucket's releases are its tags, so the tag pushed for this one is already listed
+	_, tagsAreReleases := forge.(*bitbucketProvider)
 	if verbose {
 		noteInfo("Checking if release already exists")
-		for _, release := range *releases {
-			fmt.Printf("Found release: %s\n", *release.Name)
-			if tag == *release.Name {
-				return fmt.Errorf("release with tag \"%s\" already exists", tag)
-			}
+	}
+	found, err := findRelease(forge, userAuthResponse, releaseRepo, releases, tag)
+	if err != nil {
+		return err
+	}
+	if found != nil && !replaceAssets && !updateExisting && !tagsAreReleases {
+		return fmt.Errorf("release with tag \"%s\" already exists", tag)
+	}
+
+	// Re-running a partially failed release reuses it, replacing the assets it already has.
+	// Updating it also brings its name and notes up to date with this run.
+	var existing *release
+	if replaceAssets || updateExisting {
+		existing = found
+	}
+	// A resumed publish reuses the release it created, even if it's a draft that isn't listed
+	if existing == nil && p.release != nil && replaceAssets {
+		existing = p.release
+	}
+
+	// The re-run's artifacts should be those the previous run uploaded
+	if existing != nil {
+		if err = compareRerun(userAuthResponse, existing, artifacts); err != nil {
+			return stageFailed(errUpload, err)
 		}
 	}
 
-	// Create a Release
-	// https://docs.github.com/en/free-pro-team@latest/rest/reference/repos#create-a-release
-	if verbose {
-		noteInfo("Creating release")
+	// A scheduled release is a draft until its publishing time. A released re-run stays
+	// as it is.
+	scheduled := !publishTime.IsZero()
+	if scheduled && existing != nil && (existing.Draft == nil || !*existing.Draft) {
+		fmt.Printf("WARNING: release %s is already published; publishAt is ignored\n", tag)
+		scheduled = false
+	}
+
+	// Final releases may require their prereleases to have soaked first
+	err = checkSoakPolicy(releases, tag, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Suites released in step are warned about the repositories at other versions
+	checkVersionSuite(userAuthResponse, releaseRepo, tag)
+
+	previous := previousRelease(releases, tag)
+	summary.sizes = compareAssetSizes(artifacts, previous)
+
+	releaseBody, aliases, err := p.releaseNotes(userAuthResponse, scheduled)
+	if err != nil {
+		return err
 	}
-	resp, err := createRelease(userAuthResponse, gURL, tag, tagMessage, "", false, false)
+	prov := ciProvenance()
+
+	name, err := renderReleaseName(releaseName, p.releaseRepo.repository, tag, p.build)
 	if err != nil {
-		return fmt.Errorf("failed creating release: %s", err)
+		return stageFailed(errRelease, err)
+	}
+
+	resp := existing
+	switch {
+	case resp != nil && updateExisting:
+		// Update a Release
+		// https://docs.github.com/en/rest/releases/releases#update-a-release
+		// Its draft state is left as it is, so a published release isn't withdrawn
+		if verbose {
+			noteInfo(fmt.Sprintf("Updating release %q", name))
+		}
+		update := &releaseUpdateRequest{
+			Name:       &name,
+			Body:       &releaseBody,
+			MakeLatest: markLatest,
+		}
+		// Its prerelease flag is only changed when --prerelease is set
+		if prereleaseSet {
+			update.Prerelease = &prerelease
+		}
+		resp, err = updateRelease(userAuthResponse, releaseRepo, *existing.ID, update)
+		if err != nil {
+			return stageFailed(errRelease, fmt.Errorf("failed updating release: %w", err))
+		}
+		fmt.Printf("Updated %s release %s\n", releaseState(resp), tag)
+	case resp != nil:
+		fmt.Printf("Release %s already exists; replacing its assets\n", tag)
+	default:
+		// Create a Release
+		// https://docs.github.com/en/free-pro-team@latest/rest/reference/repos#create-a-release
+		// The release targets the commit the tag was created on, from --commitish or the
+		// branch's head, in case the tag hasn't reached the forge
+		if verbose {
+			noteInfo(fmt.Sprintf("Creating release %q at %s", name, p.build.ShortCommit))
+		}
+		resp, err = createRelease(forge, userAuthResponse, releaseRepo, tag, name, releaseBody, p.build.Commit, draft || scheduled, prerelease)
+		if err != nil {
+			return stageFailed(errRelease, err)
+		}
+		fmt.Printf("Created %s release %s\n", releaseState(resp), tag)
+	}
+	p.release = resp
+	summary.state = releaseState(resp)
+
+	if resp.HTMLURL != nil {
+		summary.releaseURL = *resp.HTMLURL
+	}
+	if resp.DiscussionURL != nil {
+		summary.discussionURL = *resp.DiscussionURL
 	}
-	fmt.Printf("CREATE RELEASE RESPONSE: %+v\n", resp)
 
 	if verbose {
 		fmt.Println("Uploading release assets")
@@ -341,9 +753,208 @@ func run() error {
 
 	// Upload Release Assets
 	// https://docs.github.com/en/free-pro-team@latest/rest/reference/repos#upload-a-release-asset
+	// Github won't upload over an existing asset, so the existing ones are only replaced
+	// once every upload has succeeded
+	var uploaded []*asset
+	if existing != nil {
+		var replaced []string
+		uploaded, replaced, err = uploadReplacingAssets(forge, userAuthResponse, resp, artifacts)
+		if err != nil {
+			return stageFailed(errUpload, err)
+		}
+		if len(replaced) > 0 {
+			fmt.Printf("Replaced existing assets: %s\n", strings.Join(replaced, ", "))
+		}
+
+		if updateExisting {
+			deleted, err := deleteUnbuiltAssets(userAuthResponse, resp, artifacts)
+			if err != nil {
+				return stageFailed(errUpload, fmt.Errorf("failed deleting assets no longer built: %w", err))
+			}
+			if len(deleted) > 0 {
+				fmt.Printf("Deleted assets no longer built: %s\n", strings.Join(deleted, ", "))
+			}
+		}
+	} else {
+		uploaded, err = uploadAssets(forge, userAuthResponse, resp, artifacts)
+		if err != nil {
+			return stageFailed(errUpload, err)
+		}
+	}
+
+	// The scheduled draft is published at its time, by this run or the watch daemon
+	if scheduled {
+		if !publishWait {
+			summary.scheduledAt = publishTime
+			summary.print()
+			return nil
+		}
+
+		resp, err = waitAndPublish(userAuthResponse, releaseRepo, resp, publishTime)
+		if err != nil {
+			return stageFailed(errRelease, fmt.Errorf("failed publishing scheduled release: %w", err))
+		}
+		p.release = resp
+	}
+
+	// Download the assets again, to check they arrived intact
+	if verifyUploads {
+		if draft {
+			fmt.Println("WARNING: uploads are not verified for draft releases, which have no public download URLs")
+		} else if err = verifyUploadedAssets(artifacts, uploaded, verifyUploadsSample); err != nil {
+			return stageFailed(errUpload, err)
+		}
+	}
+
+	// A staged draft is recorded for review instead of being announced
+	if draft && stagingManifestPath != "" {
+		err = writeStagingManifest(stagingManifestPath, releaseRepo, resp, artifacts, uploaded, prov)
+		if err != nil {
+			return fmt.Errorf("failed writing staging manifest: %w", err)
+		}
+		summary.stagingManifestPath = stagingManifestPath
+		summary.print()
+		return nil
+	}
+
+	err = updateAliasTags(repo, aliases, plumbing.NewHash(p.build.Commit))
+	if err != nil {
+		return fmt.Errorf("failed updating alias tags: %w", err)
+	}
+	if !draft {
+		summary.aliases = aliasTags
+	}
+
+	// A provider independent record of the release, kept with the repository
+	if gitNote && draft {
+		fmt.Println("WARNING: draft releases are not recorded in git notes")
+	} else if gitNote {
+		record, err := newReleaseRecord(releaseRepo, resp, p.build.Commit, artifacts, prov)
+		if err != nil {
+			return fmt.Errorf("failed recording the release in %s: %w", releaseNotesRef, err)
+		}
+		if err = recordReleaseNote(repo, plumbing.NewHash(p.build.Commit), record); err != nil {
+			return fmt.Errorf("failed recording the release in %s: %w", releaseNotesRef, err)
+		}
+	}
+
+	// Let the responsible owners know what shipped, once it's published
+	if draft && (len(notifyTeams) > 0 || notifyCodeowners) {
+		fmt.Println("WARNING: owners are not notified of draft releases")
+	} else if len(notifyTeams) > 0 || notifyCodeowners {
+		mentions, err := releaseOwners(repo, p.dir, previous)
+		if err != nil {
+			return fmt.Errorf("failed finding owners to notify: %w", err)
+		}
+
+		if len(mentions) > 0 {
+			if verbose {
+				noteInfo(fmt.Sprintf("Notifying %s", strings.Join(mentions, ", ")))
+			}
+			summary.notificationURL, err = notifyOwners(userAuthResponse, releaseRepo, tag, summary.releaseURL, mentions)
+			if err != nil {
+				return fmt.Errorf("failed notifying owners: %w", err)
+			}
+		}
+	}
+
+	err = generateLinks(summary, releaseRepo, artifacts)
+	if err != nil {
+		return err
+	}
+
+	summary.print()
+
 	return nil
 }
 
+// releaseNotes renders the body of the release, and plans the alias tags it mentions. An
+// applied plan publishes the notes that were reviewed in it.
+func (p *pipeline) releaseNotes(auth *UserAuth, scheduled bool) (string, []aliasUpdate, error) {
+	var err error
+
+	// The release notes default to the tag message, unless they are read from --notesFile.
+	// Github's generated notes replace the tag message, after the --notesFile header, if any.
+	releaseBody := tagMessage
+	if p.notes != "" || generateNotes {
+		releaseBody = p.notes
+	}
+	var changes string
+	if generateNotes {
+		changes, err = generatedReleaseNotes(auth, p.releaseRepo, p.repo, tag)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed generating release notes: %w", err)
+		}
+	}
+	if notesFromPRs {
+		changes, err = generateNotesFromPullRequests(auth, p.releaseRepo, p.repo, tag)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed generating release notes: %w", err)
+		}
+	}
+
+	// The highlights are a summary of the changes, so come before them. The summarizer is
+	// an external tool, so the release goes ahead without them if it fails.
+	if notesSummarizer != "" {
+		highlights, err := releaseHighlights(p.repo, p.dir, tag, changes)
+		if err != nil {
+			fmt.Printf("WARNING: failed summarizing the release highlights: %s\n", err)
+		}
+		releaseBody = strings.TrimSpace(releaseBody + "\n\n" + highlights)
+	}
+	releaseBody = strings.TrimSpace(releaseBody + "\n\n" + changes)
+
+	// The notes are marked as the section sync-notes replaces from the CHANGELOG
+	releaseBody = markNotes(releaseBody)
+
+	if securityAdvisories || len(securityAdvisoryIDs) > 0 {
+		fixes, err := securityFixesNotes(auth, p.releaseRepo, tag)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed retrieving security advisories: %w", err)
+		}
+		releaseBody = strings.TrimSpace(releaseBody + "\n\n" + fixes)
+	}
+
+	if diffStats {
+		stats, err := releaseDiffStat(p.repo, tag)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed computing diff stats: %w", err)
+		}
+		if stats != nil {
+			releaseBody = strings.TrimSpace(releaseBody + "\n\n" + stats.markdown())
+		}
+	}
+
+	// Link the release back to the CI run that built it
+	if prov := ciProvenance(); prov != nil {
+		releaseBody = strings.TrimSpace(releaseBody + "\n\n---\n" + prov.footer())
+	}
+
+	// Alias tags are checked before the release is created, and moved once it is complete
+	var aliases []aliasUpdate
+	if len(aliasTags) > 0 {
+		if draft || (scheduled && !publishWait) {
+			fmt.Println("WARNING: alias tags are not moved for draft releases")
+		} else {
+			aliases, err = planAliasTags(p.repo, aliasTags, plumbing.NewHash(p.build.Commit))
+			if err != nil {
+				return "", nil, err
+			}
+			releaseBody = strings.TrimSpace(releaseBody + "\n\n" + aliasNote(aliasTags))
+		}
+	}
+
+	if scheduled {
+		releaseBody = scheduleNotes(releaseBody, publishTime, markLatest)
+	}
+
+	if p.applied != nil {
+		releaseBody = p.applied.Notes
+	}
+
+	return releaseBody, aliases, nil
+}
+
 type gitURL struct {
 	parsedURL    *url.URL
 	organization string
@@ -355,15 +966,22 @@ type gitURL struct {
 func parseGitURL(repositoryURL string) (*gitURL, error) {
 	var err error
 
-	// Raw working Regex for git URLs:  (git@|(https?:\/\/))((.*)(?::|\/)(\w*)\/([\w\-]*)(?:.git)?)
+	// Raw working Regex for git URLs:  (git@|(https?:\/\/))(((?:[^\/@]+@)?[^\/:@]+(?::\d+)?)(?::|\/)([\w\-]+(?:\/[\w\-]+)*)\/([\w\-]*)(?:.git)?)
 	// tested against:
 	// git@github.com:foo/barbazbingo.git
 	// http://testing_foo.github.com/foo/barbaz_bingo_bango.git
 	// https://testing3.github.com/foo/barbaz-bingo-bango.git
+	// https://gitlab.com/foo/bar/baz/bingo.git
+	//
+	// The organization is every path segment before the repository, so it includes the
+	// subgroups of GitLab's nested namespaces
 
-	expression := `(?P<scheme>git@|(https?:\/\/))(?P<host>.*)(?P<pathSeparator>:|\/)(?P<organization>\w*)\/(?P<repository>[\w\-]*)(?P<suffix>.git)?`
+	expression := `(?P<scheme>git@|(https?:\/\/))(?P<host>(?:[^\/@]+@)?[^\/:@]+(?::\d+)?)(?P<pathSeparator>:|\/)(?P<organization>[\w\-]+(?:\/[\w\-]+)*)\/(?P<repository>[\w\-]*)(?P<suffix>.git)?`
 	re := regexp.MustCompile(expression)
 	matches := re.FindStringSubmatch(repositoryURL)
+	if matches == nil {
+		return nil, fmt.Errorf("cannot parse git url: %s", repositoryURL)
+	}
 
 	u := &gitURL{
 		parsedURL: &url.URL{
@@ -379,6 +997,24 @@ func parseGitURL(repositoryURL string) (*gitURL, error) {
 	return u, err
 }
 
+// repoShorthandExpression matches an "owner/name" repository shorthand
+var repoShorthandExpression = regexp.MustCompile(`^(?P<organization>[\w\-\.]+)\/(?P<repository>[\w\-\.]+)$`)
+
+// repositoryURLFromShorthand converts an "owner/name" repository shorthand, as accepted
+// by the --repo flag, into the clone URL for the repository on the configured provider, in
+// the configured gitProtocol
+func repositoryURLFromShorthand(repo string) (string, error) {
+	matches := repoShorthandExpression.FindStringSubmatch(repo)
+	if matches == nil {
+		return "", fmt.Errorf("repo must be in the form owner/name: %s", repo)
+	}
+
+	organization := matches[repoShorthandExpression.SubexpIndex("organization")]
+	repository := strings.TrimSuffix(matches[repoShorthandExpression.SubexpIndex("repository")], ".git")
+
+	return cloneURLFor(shorthandHost(), organization, repository), nil
+}
+
 func formatURLPath(matches []string, re *regexp.Regexp) string {
 	return fmt.Sprintf(matches[re.SubexpIndex("pathSeparator")] + matches[re.SubexpIndex("organization")] + "/" + matches[re.SubexpIndex("repository")] + matches[re.SubexpIndex("suffix")])
 }
@@ -388,6 +1024,10 @@ func checkoutCommitish(repo *git.Repository, commitish plumbing.Hash) (*git.Repo
 		return repo, nil
 	}
 
+	if len(sparsePaths) > 0 {
+		return repo, sparseCheckout(repo, commitish, sparsePaths)
+	}
+
 	ref, err := repo.Head()
 	if err != nil {
 		return repo, err
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestCheckoutCommitish checks the worktree is moved to the commitish, or left at HEAD without one
+func TestCheckoutCommitish(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.Commit("first", map[string]string{"README.md": "first\n"})
+	second := repo.Commit("second", map[string]string{"README.md": "second\n", "NOTES.md": "notes\n"})
+
+	checkoutCommitishTests := []struct {
+		name      string
+		commitish plumbing.Hash
+		head      plumbing.Hash
+		failure   bool
+	}{
+		{name: "no commitish", head: second.Hash},
+		{name: "earlier commit", commitish: first.Hash, head: first.Hash},
+		{name: "latest commit", commitish: second.Hash, head: second.Hash},
+		{name: "unknown commit", commitish: plumbing.NewHash("0123456789abcdef0123456789abcdef01234567"), failure: true},
+	}
+
+	for _, testSpec := range checkoutCommitishTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			_, err := checkoutCommitish(repo.Repository, testSpec.commitish)
+			if testSpec.failure {
+				NotNil(t, err)
+				return
+			}
+			Nil(t, err)
+
+			head, err := repo.Head()
+			Nil(t, err)
+			Equal(t, testSpec.head, head.Hash())
+		})
+	}
+}
+
+// TestIsLocalGitURL checks only repositories on the local filesystem are pushed to without credentials
+func TestIsLocalGitURL(t *testing.T) {
+	for u, local := range map[string]bool{
+		"/srv/git/r.git":               true,
+		"file:///srv/git/r.git":        true,
+		"git@github.com:o/r.git":       false,
+		"ssh://git@github.com/o/r.git": false,
+		"https://github.com/o/r.git":   false,
+	} {
+		Equal(t, local, isLocalGitURL(u), u)
+	}
+}
+
+// TestParseGitURL checks the organization and repository are parsed from each form of URL, including GitLab subgroups
+func TestParseGitURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		host         string
+		organization string
+		repository   string
+	}{
+		{"git@github.com:foo/barbazbingo.git", "github.com", "foo", "barbazbingo"},
+		{"http://testing_foo.github.com/foo/barbaz_bingo_bango.git", "testing_foo.github.com", "foo", "barbaz_bingo_bango"},
+		{"https://testing3.github.com/foo/barbaz-bingo-bango.git", "testing3.github.com", "foo", "barbaz-bingo-bango"},
+		{"https://github.com/my-org/repo", "github.com", "my-org", "repo"},
+		{"https://gitlab.com/group/subgroup/repo.git", "gitlab.com", "group/subgroup", "repo"},
+		{"git@gitlab.example.com:group/a/b/repo.git", "gitlab.example.com", "group/a/b", "repo"},
+		{"https://gitlab.example.com:8443/group/subgroup/repo.git", "gitlab.example.com:8443", "group/subgroup", "repo"},
+	}
+
+	for _, testSpec := range tests {
+		gURL, err := parseGitURL(testSpec.url)
+		Nil(t, err, testSpec.url)
+		Equal(t, testSpec.host, gURL.parsedURL.Host, testSpec.url)
+		Equal(t, testSpec.organization, gURL.organization, testSpec.url)
+		Equal(t, testSpec.repository, gURL.repository, testSpec.url)
+	}
+}
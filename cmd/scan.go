@@ -0,0 +1,393 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scanner output formats
+const (
+	scanFormatGovulncheck = "govulncheck"
+	scanFormatSARIF       = "sarif"
+)
+
+// defaultGoScanCommand is run for Go projects when no scanner command is configured
+const defaultGoScanCommand = "govulncheck -json ./..."
+
+// severities in increasing order. Findings without a severity are treated as unknownSeverity.
+var severities = []string{"low", "medium", "high", "critical"}
+
+// unknownSeverity is the severity of findings the scanner doesn't rate, such as most
+// govulncheck findings, so they block the release unless ignored
+const unknownSeverity = "high"
+
+// severityRank returns the position of the severity in severities, or -1 if it is not one
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if s == strings.ToLower(severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// finding is a single issue reported by a scanner
+type finding struct {
+	id       string
+	severity string
+	location string
+	summary  string
+}
+
+// String returns the finding as a single line for the output
+func (f finding) String() string {
+	s := fmt.Sprintf("[%s] %s", f.severity, f.id)
+	if f.location != "" {
+		s += " in " + f.location
+	}
+	if f.summary != "" {
+		s += ": " + f.summary
+	}
+	return s
+}
+
+// govulncheckMessage is one of the JSON objects streamed by govulncheck -json
+type govulncheckMessage struct {
+	OSV *struct {
+		ID               string `json:"id"`
+		Summary          string `json:"summary"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	} `json:"osv"`
+	Finding *struct {
+		OSV   string `json:"osv"`
+		Trace []struct {
+			Module   string `json:"module"`
+			Package  string `json:"package"`
+			Function string `json:"function"`
+		} `json:"trace"`
+	} `json:"finding"`
+}
+
+// parseGovulncheck returns the vulnerabilities govulncheck found to be called by the
+// code. Vulnerabilities in imported packages or required modules that are never
+// called are not reported, the same as govulncheck's default output.
+func parseGovulncheck(r io.Reader) ([]finding, error) {
+	summaries := make(map[string]string)
+	ratings := make(map[string]string)
+	called := make(map[string]string)
+
+	decoder := json.NewDecoder(r)
+	for {
+		var msg govulncheckMessage
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid govulncheck output: %w", err)
+		}
+
+		if msg.OSV != nil {
+			summaries[msg.OSV.ID] = msg.OSV.Summary
+			ratings[msg.OSV.ID] = strings.ToLower(msg.OSV.DatabaseSpecific.Severity)
+		}
+
+		if msg.Finding != nil && len(msg.Finding.Trace) > 0 && msg.Finding.Trace[0].Function != "" {
+			frame := msg.Finding.Trace[0]
+			called[msg.Finding.OSV] = frame.Package + "." + frame.Function
+		}
+	}
+
+	var findings []finding
+	for id, location := range called {
+		findings = append(findings, finding{
+			id:       id,
+			severity: ratedSeverity(ratings[id]),
+			location: location,
+			summary:  summaries[id],
+		})
+	}
+	sortFindings(findings)
+
+	return findings, nil
+}
+
+// sarifLog is the subset of a SARIF 2.1.0 log we use
+type sarifLog struct {
+	Runs []struct {
+		Tool struct {
+			Driver struct {
+				Rules []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+			Properties sarifProperties `json:"properties"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+type sarifRule struct {
+	ID         string          `json:"id"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifProperties struct {
+	// SecuritySeverity is a CVSS score, as a string, used by Github code scanning
+	SecuritySeverity string `json:"security-severity"`
+}
+
+// parseSARIF returns the results in a SARIF log, such as those written by osv-scanner,
+// trivy, grype or license checkers. The severity comes from the security-severity
+// property of the result or its rule, or the result level.
+func parseSARIF(r io.Reader) ([]finding, error) {
+	var log sarifLog
+	if err := json.NewDecoder(r).Decode(&log); err != nil {
+		return nil, fmt.Errorf("invalid SARIF output: %w", err)
+	}
+
+	var findings []finding
+	for _, run := range log.Runs {
+		rules := make(map[string]sarifRule)
+		for _, rule := range run.Tool.Driver.Rules {
+			rules[rule.ID] = rule
+		}
+
+		for _, result := range run.Results {
+			score := result.Properties.SecuritySeverity
+			if score == "" {
+				score = rules[result.RuleID].Properties.SecuritySeverity
+			}
+
+			severity := cvssSeverity(score)
+			if severity == "" {
+				severity = sarifLevelSeverity(result.Level)
+			}
+
+			f := finding{id: result.RuleID, severity: ratedSeverity(severity), summary: result.Message.Text}
+			if len(result.Locations) > 0 {
+				f.location = result.Locations[0].PhysicalLocation.ArtifactLocation.URI
+			}
+			findings = append(findings, f)
+		}
+	}
+	sortFindings(findings)
+
+	return findings, nil
+}
+
+// cvssSeverity converts a CVSS score to its qualitative severity, or "" if it is not a score
+func cvssSeverity(score string) string {
+	s, err := strconv.ParseFloat(score, 64)
+	switch {
+	case err != nil || s <= 0:
+		return ""
+	case s >= 9:
+		return "critical"
+	case s >= 7:
+		return "high"
+	case s >= 4:
+		return "medium"
+	}
+	return "low"
+}
+
+// sarifLevelSeverity converts a SARIF result level to a severity
+func sarifLevelSeverity(level string) string {
+	switch level {
+	case "error":
+		return "high"
+	case "warning":
+		return "medium"
+	case "note":
+		return "low"
+	}
+	return ""
+}
+
+// ratedSeverity returns the severity if it is known, and unknownSeverity otherwise.
+// Github advisories rate "moderate" rather than "medium".
+func ratedSeverity(severity string) string {
+	if severity == "moderate" {
+		severity = "medium"
+	}
+	if severityRank(severity) < 0 {
+		return unknownSeverity
+	}
+	return severity
+}
+
+// sortFindings orders the findings by descending severity, then ID
+func sortFindings(findings []finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		ri, rj := severityRank(findings[i].severity), severityRank(findings[j].severity)
+		if ri != rj {
+			return ri > rj
+		}
+		return findings[i].id < findings[j].id
+	})
+}
+
+// evaluateFindings splits the findings that aren't ignored into those that block the
+// release, at or above failOn, and those to warn about, at or above warnOn
+func evaluateFindings(findings []finding, failOn, warnOn string, ignore []string) (blocking, warnings []finding) {
+	ignored := make(map[string]bool)
+	for _, id := range ignore {
+		ignored[id] = true
+	}
+
+	for _, f := range findings {
+		if ignored[f.id] {
+			continue
+		}
+
+		rank := severityRank(f.severity)
+		switch {
+		case failOn != "" && rank >= severityRank(failOn):
+			blocking = append(blocking, f)
+		case warnOn != "" && rank >= severityRank(warnOn):
+			warnings = append(warnings, f)
+		}
+	}
+
+	return blocking, warnings
+}
+
+// scanCommandFor returns the scanner command and output format for the repository,
+// defaulting to govulncheck for Go projects. An empty command means nothing is scanned.
+func scanCommandFor(dir string) (string, string) {
+	if scanCommand != "" {
+		format := scanFormat
+		if format == "" {
+			format = scanFormatSARIF
+		}
+		return scanCommand, format
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return defaultGoScanCommand, scanFormatGovulncheck
+	}
+
+	return "", ""
+}
+
+// runScanner runs the scanner command in dir and returns its standard output. Scanners
+// commonly exit non-zero when they have findings, so the exit status is only an error
+// if nothing was written.
+func runScanner(dir, command string) ([]byte, error) {
+	fields, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no scanner command provided")
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && stdout.Len() > 0) {
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// scanRepository runs the dependency scanner against the checked out repository, and
+// returns an error if any findings are at or above the scanFailOn severity
+func scanRepository(dir string) error {
+	if !scan {
+		return nil
+	}
+
+	command, format := scanCommandFor(dir)
+	if command == "" {
+		fmt.Println("WARNING: no scanner configured for this project; use --scanCommand")
+		return nil
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Scanning dependencies with %s", command))
+	}
+
+	output, err := runScanner(dir, command)
+	if err != nil {
+		return fmt.Errorf("failed running scanner: %w", err)
+	}
+
+	var findings []finding
+	switch format {
+	case scanFormatGovulncheck:
+		findings, err = parseGovulncheck(bytes.NewReader(output))
+	case scanFormatSARIF:
+		findings, err = parseSARIF(bytes.NewReader(output))
+	default:
+		err = fmt.Errorf("unknown scan format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	blocking, warnings := evaluateFindings(findings, scanFailOn, scanWarnOn, scanIgnore)
+
+	for _, f := range warnings {
+		fmt.Printf("WARNING: %s\n", f)
+	}
+
+	if len(blocking) > 0 {
+		lines := make([]string, 0, len(blocking))
+		for _, f := range blocking {
+			lines = append(lines, "\t"+f.String())
+		}
+		return fmt.Errorf(
+			"%w: %d findings at or above %s severity; fix them or add their IDs to scanIgnore:\n%s",
+			errScanBlocked, len(blocking), scanFailOn, strings.Join(lines, "\n"),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestParseGovulncheck checks only vulnerabilities in called functions are reported
+func TestParseGovulncheck(t *testing.T) {
+	output := `{"config":{"protocol_version":"v1.0.0","scanner_name":"govulncheck"}}
+{"progress":{"message":"Scanning your code and 42 packages across 3 dependent modules for known vulnerabilities..."}}
+{"osv":{"id":"GO-2023-0001","summary":"Panic in parser","database_specific":{"url":"https://pkg.go.dev/vuln/GO-2023-0001"}}}
+{"osv":{"id":"GO-2023-0002","summary":"Unused vulnerable code"}}
+{"osv":{"id":"GHSA-xxxx-yyyy-zzzz","summary":"Rated advisory","database_specific":{"severity":"MODERATE"}}}
+{"finding":{"osv":"GO-2023-0001","trace":[{"module":"example.com/a","package":"example.com/a/parse","function":"Parse"}]}}
+{"finding":{"osv":"GO-2023-0002","trace":[{"module":"example.com/b","package":"example.com/b"}]}}
+{"finding":{"osv":"GHSA-xxxx-yyyy-zzzz","trace":[{"module":"example.com/c","package":"example.com/c","function":"Do"}]}}
+`
+
+	findings, err := parseGovulncheck(strings.NewReader(output))
+	Nil(t, err)
+	Equal(t, []finding{
+		{id: "GO-2023-0001", severity: "high", location: "example.com/a/parse.Parse", summary: "Panic in parser"},
+		{id: "GHSA-xxxx-yyyy-zzzz", severity: "medium", location: "example.com/c.Do", summary: "Rated advisory"},
+	}, findings)
+
+	_, err = parseGovulncheck(strings.NewReader("not json"))
+	Error(t, err)
+}
+
+// TestParseSARIF checks severities are taken from result or rule scores, then levels
+func TestParseSARIF(t *testing.T) {
+	output := `{
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {"name": "osv-scanner", "rules": [
+      {"id": "CVE-2024-0001", "properties": {"security-severity": "9.8"}}
+    ]}},
+    "results": [
+      {"ruleId": "CVE-2024-0001", "level": "warning", "message": {"text": "Remote code execution"},
+       "locations": [{"physicalLocation": {"artifactLocation": {"uri": "go.mod"}}}]},
+      {"ruleId": "CVE-2024-0002", "properties": {"security-severity": "5.3"}, "message": {"text": "Information disclosure"}},
+      {"ruleId": "GPL-3.0", "level": "note", "message": {"text": "Copyleft license"}}
+    ]
+  }]
+}`
+
+	findings, err := parseSARIF(strings.NewReader(output))
+	Nil(t, err)
+	Equal(t, []finding{
+		{id: "CVE-2024-0001", severity: "critical", location: "go.mod", summary: "Remote code execution"},
+		{id: "CVE-2024-0002", severity: "medium", summary: "Information disclosure"},
+		{id: "GPL-3.0", severity: "low", summary: "Copyleft license"},
+	}, findings)
+}
+
+// TestEvaluateFindings checks the severity thresholds and ignored IDs
+func TestEvaluateFindings(t *testing.T) {
+	findings := []finding{
+		{id: "A", severity: "critical"},
+		{id: "B", severity: "high"},
+		{id: "C", severity: "medium"},
+		{id: "D", severity: "low"},
+	}
+
+	blocking, warnings := evaluateFindings(findings, "high", "medium", []string{"B"})
+	Equal(t, []finding{{id: "A", severity: "critical"}}, blocking)
+	Equal(t, []finding{{id: "C", severity: "medium"}}, warnings)
+
+	blocking, warnings = evaluateFindings(findings, "", "", nil)
+	Empty(t, blocking)
+	Empty(t, warnings)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// publishAt is when a release is published, eg: for a coordinated launch. Until then,
+// the release is a draft with its assets already uploaded.
+var publishAt string
+
+// publishWait keeps the run going until publishAt to publish the draft itself; otherwise
+// it is left for the watch daemon to publish
+var publishWait bool
+
+// publishTime is publishAt, parsed by initialValidation
+var publishTime time.Time
+
+// scheduleExpression finds the publishing time recorded in a scheduled draft's notes, so
+// the schedule survives the run that made it, and is visible to reviewers of the draft
+var scheduleExpression = regexp.MustCompile(`\n*<!-- go-git-release publish-at: (\S+) -->\n*`)
+
+// scheduledLatestExpression finds the make_latest recorded in a scheduled draft's notes,
+// as Github ignores it for drafts, so it is sent again when the draft is published
+var scheduledLatestExpression = regexp.MustCompile(`\n*<!-- go-git-release latest: (\S+) -->\n*`)
+
+// parsePublishAt parses the publishing time, an RFC3339 timestamp
+func parsePublishAt(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("publishAt must be an RFC3339 timestamp, eg: 2020-06-01T09:00:00Z")
+	}
+	return t, nil
+}
+
+// scheduleNotes records the publishing time in the release notes, and the make_latest to
+// publish the release with, if any
+func scheduleNotes(body string, at time.Time, latest string) string {
+	body = strings.TrimSpace(body) + fmt.Sprintf("\n\n<!-- go-git-release publish-at: %s -->", at.UTC().Format(time.RFC3339))
+	if latest != "" {
+		body += fmt.Sprintf("\n<!-- go-git-release latest: %s -->", latest)
+	}
+	return body
+}
+
+// scheduledLatest returns the make_latest recorded in the release notes, if any
+func scheduledLatest(body string) string {
+	if matches := scheduledLatestExpression.FindStringSubmatch(body); matches != nil {
+		return matches[1]
+	}
+	return ""
+}
+
+// scheduledTime returns the publishing time recorded in the release notes, if any
+func scheduledTime(body string) (time.Time, bool) {
+	matches := scheduleExpression.FindStringSubmatch(body)
+	if matches == nil {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, matches[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// unscheduledNotes removes the publishing time, and make_latest, from the release notes
+func unscheduledNotes(body string) string {
+	body = scheduledLatestExpression.ReplaceAllString(body, "\n\n")
+	return strings.TrimSpace(scheduleExpression.ReplaceAllString(body, "\n\n"))
+}
+
+// publishScheduledRelease publishes the scheduled draft, without its publishing time, and
+// with the make_latest recorded with it
+func publishScheduledRelease(auth *UserAuth, gURL *gitURL, r *release) (*release, error) {
+	if r.ID == nil {
+		return nil, fmt.Errorf("release has no ID")
+	}
+
+	var body, latest string
+	if r.Body != nil {
+		body = unscheduledNotes(*r.Body)
+		latest = scheduledLatest(*r.Body)
+	}
+
+	published := false
+	return updateRelease(auth, gURL, *r.ID, &releaseUpdateRequest{Body: &body, Draft: &published, MakeLatest: latest})
+}
+
+// publishSleep waits until the publishing time; it is replaced in tests
+var publishSleep = time.Sleep
+
+// waitAndPublish waits until the publishing time, then publishes the scheduled draft
+func waitAndPublish(auth *UserAuth, gURL *gitURL, r *release, at time.Time) (*release, error) {
+	if wait := time.Until(at); wait > 0 {
+		fmt.Printf("Waiting until %s (%s) to publish %s\n", at.Format(time.RFC3339), wait.Round(time.Second), tag)
+		publishSleep(wait)
+	}
+
+	return publishScheduledRelease(auth, gURL, r)
+}
+
+// listReleases lists the repository's releases as the authenticated user, who unlike
+// getReleases also sees drafts and the releases of private repositories
+func listReleases(auth *UserAuth, gURL *gitURL) ([]release, error) {
+	all, err := fetchReleases(auth, gURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return *all, nil
+}
+
+// publishDueReleases publishes the repository's scheduled drafts whose publishing time
+// has passed, and returns their tags
+func publishDueReleases(auth *UserAuth, gURL *gitURL, now time.Time) ([]string, error) {
+	all, err := listReleases(auth, gURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing draft releases: %w", err)
+	}
+
+	var published []string
+	for i, r := range all {
+		if r.Draft == nil || !*r.Draft || r.Body == nil || r.TagName == nil {
+			continue
+		}
+		at, ok := scheduledTime(*r.Body)
+		if !ok || at.After(now) {
+			continue
+		}
+
+		if _, err = publishScheduledRelease(auth, gURL, &all[i]); err != nil {
+			return published, fmt.Errorf("failed publishing scheduled release %s: %w", *r.TagName, err)
+		}
+		published = append(published, *r.TagName)
+	}
+
+	return published, nil
+}
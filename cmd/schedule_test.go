@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestParsePublishAt checks the publishing time must be an RFC3339 timestamp
+func TestParsePublishAt(t *testing.T) {
+	at, err := parsePublishAt("2020-06-01T09:00:00+02:00")
+	Nil(t, err)
+	True(t, time.Date(2020, 6, 1, 7, 0, 0, 0, time.UTC).Equal(at))
+
+	_, err = parsePublishAt("2020-06-01 09:00")
+	NotNil(t, err)
+}
+
+// TestScheduleNotes checks the publishing time and make_latest are recorded in, and removed
+// from, the notes
+func TestScheduleNotes(t *testing.T) {
+	at := time.Date(2020, 6, 1, 9, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	body := scheduleNotes("Release notes\n", at, "")
+	Equal(t, "Release notes\n\n<!-- go-git-release publish-at: 2020-06-01T07:00:00Z -->", body)
+	Equal(t, "", scheduledLatest(body))
+
+	scheduled, ok := scheduledTime(body)
+	True(t, ok)
+	True(t, at.Equal(scheduled))
+	Equal(t, "Release notes", unscheduledNotes(body))
+
+	// Github may edit the notes around the marker
+	edited := "Release notes\n<!-- go-git-release publish-at: 2020-06-01T07:00:00Z -->\nMore notes"
+	Equal(t, "Release notes\n\nMore notes", unscheduledNotes(edited))
+
+	_, ok = scheduledTime("Release notes")
+	False(t, ok)
+
+	latest := scheduleNotes("Release notes", at, "false")
+	Equal(t, "false", scheduledLatest(latest))
+	Equal(t, "Release notes", unscheduledNotes(latest))
+}
+
+// TestPublishDueReleases checks only the scheduled drafts whose time has come are published
+func TestPublishDueReleases(t *testing.T) {
+	defer gock.Off()
+	gURL, err := parseGitURL("https://github.com/o/r.git")
+	Nil(t, err)
+	auth := &UserAuth{TokenType: "token", AccessToken: "token"}
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{"id": 1, "tag_name": "v1.0.0", "draft": true, "body": "Due\n\n<!-- go-git-release publish-at: 2020-06-01T07:00:00Z -->\n<!-- go-git-release latest: false -->"},
+			{"id": 2, "tag_name": "v1.1.0", "draft": true, "body": "Later\n\n<!-- go-git-release publish-at: 2020-07-01T07:00:00Z -->"},
+			{"id": 3, "tag_name": "v0.9.0", "draft": true, "body": "Not scheduled"},
+			{"id": 4, "tag_name": "v0.8.0", "draft": false, "body": "Published\n\n<!-- go-git-release publish-at: 2020-05-01T07:00:00Z -->"},
+		})
+	gock.New("https://api.github.com").
+		Patch("/repos/o/r/releases/1").
+		JSON(map[string]interface{}{"body": "Due", "draft": false, "make_latest": "false"}).
+		Reply(200).
+		JSON(map[string]interface{}{"id": 1, "tag_name": "v1.0.0", "draft": false})
+
+	published, err := publishDueReleases(auth, gURL, time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC))
+	Nil(t, err)
+	Equal(t, []string{"v1.0.0"}, published)
+	True(t, gock.IsDone())
+}
+
+// TestWaitAndPublish checks the draft is published once the publishing time has come
+func TestWaitAndPublish(t *testing.T) {
+	defer gock.Off()
+	defer func() { publishSleep = time.Sleep }()
+	gURL, err := parseGitURL("https://github.com/o/r.git")
+	Nil(t, err)
+
+	var waited time.Duration
+	publishSleep = func(d time.Duration) { waited = d }
+
+	gock.New("https://api.github.com").
+		Patch("/repos/o/r/releases/1").
+		JSON(map[string]interface{}{"body": "Notes", "draft": false}).
+		Reply(200).
+		JSON(map[string]interface{}{"id": 1, "draft": false})
+
+	id, body := 1, "Notes\n\n<!-- go-git-release publish-at: 2020-06-01T07:00:00Z -->"
+	r, err := waitAndPublish(&UserAuth{TokenType: "token", AccessToken: "token"}, gURL, &release{ID: &id, Body: &body}, time.Now().Add(time.Hour))
+	Nil(t, err)
+	False(t, *r.Draft)
+	InDelta(t, float64(time.Hour), float64(waited), float64(time.Minute))
+}
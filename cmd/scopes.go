@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// releaseScope is the OAuth scope classic tokens need to publish releases; public_repo is
+// enough for public repositories
+const releaseScope = "repo"
+
+// publicReleaseScope is the OAuth scope limited to public repositories
+const publicReleaseScope = "public_repo"
+
+// hasScope returns true if the scopes include the scope
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTokenScopes checks an OAuth token has the scope to publish releases to the
+// repository. Installation tokens, fine-grained tokens and Github App user tokens list no
+// scopes, having permissions instead, so only their access to the repository is checked.
+func checkTokenScopes(auth *UserAuth, info *repository) error {
+	if strings.HasPrefix(auth.AccessToken, installationTokenPrefix) {
+		return nil
+	}
+
+	u, err := getAuthenticatedUser(auth)
+	if err != nil {
+		return fmt.Errorf("failed retrieving the token's scopes: %w", err)
+	}
+	if u.Scopes == nil {
+		return nil
+	}
+
+	if hasScope(u.Scopes, releaseScope) || (!info.Private && hasScope(u.Scopes, publicReleaseScope)) {
+		return nil
+	}
+
+	has := "no scopes"
+	if len(u.Scopes) > 0 {
+		has = "the scopes " + strings.Join(u.Scopes, ", ")
+	}
+	return fmt.Errorf("the token has %s, but publishing releases to %s needs the %s scope; create a token with it, or log in again with: go-git-release auth login", has, info.FullName, releaseScope)
+}
+
+// tokenPreflight checks the token can publish releases to the repository before the release
+// is created, so a token without the scope or permission fails with what it is missing,
+// rather than with a 404 from the release API
+func tokenPreflight(auth *UserAuth, gURL *gitURL) error {
+	info, err := getRepository(auth, gURL)
+	switch {
+	case isHTTPStatus(err, 401):
+		return fmt.Errorf("the token was rejected; it may have expired or been revoked: %w", err)
+	case isHTTPStatus(err, 404):
+		return fmt.Errorf("the token cannot access %s/%s; fine-grained tokens must be granted the repository, with the contents write permission: %w", gURL.organization, gURL.repository, err)
+	case err != nil:
+		return err
+	}
+
+	// Installation tokens have no user permissions; the App's are checked as it authenticates
+	if !strings.HasPrefix(auth.AccessToken, installationTokenPrefix) && info.Permissions != nil && !info.Permissions.Push {
+		return fmt.Errorf("the authenticated user cannot publish releases to %s/%s", gURL.organization, gURL.repository)
+	}
+
+	return checkTokenScopes(auth, info)
+}
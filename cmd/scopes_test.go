@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestTokenPreflight checks tokens that can't publish releases fail with what they're missing
+func TestTokenPreflight(t *testing.T) {
+	defer gock.Off()
+	gURL, err := parseGitURL("https://github.com/o/r.git")
+	Nil(t, err)
+
+	repoScopes, publicScopes, noScopes := "repo, read:org", "public_repo", ""
+
+	tokenPreflightTests := []struct {
+		name    string
+		token   string
+		status  int
+		private bool
+		push    bool
+		// scopes is the X-OAuth-Scopes header, if listed
+		scopes  *string
+		failure string
+	}{
+		{name: "repo scope", token: "gho_user", status: 200, private: true, push: true, scopes: &repoScopes},
+		{name: "public_repo on a public repository", token: "gho_user", status: 200, push: true, scopes: &publicScopes},
+		{name: "public_repo on a private repository", token: "gho_user", status: 200, private: true, push: true, scopes: &publicScopes, failure: "the token has the scopes public_repo, but publishing releases to o/r needs the repo scope"},
+		{name: "no scopes", token: "ghp_user", status: 200, push: true, scopes: &noScopes, failure: "the token has no scopes"},
+		{name: "fine-grained", token: "github_pat_user", status: 200, private: true, push: true},
+		{name: "installation token", token: "ghs_app", status: 200, private: true},
+		{name: "cannot push", token: "gho_user", status: 200, failure: "cannot publish releases"},
+		{name: "no access", token: "github_pat_user", status: 404, failure: "contents write permission"},
+		{name: "rejected", token: "gho_user", status: 401, failure: "rejected"},
+	}
+
+	for _, testSpec := range tokenPreflightTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				defer gock.Off()
+
+				gock.New("https://api.github.com").
+					Get("/repos/o/r").
+					Reply(testSpec.status).
+					JSON(map[string]interface{}{
+						"full_name":   "o/r",
+						"private":     testSpec.private,
+						"permissions": map[string]bool{"push": testSpec.push},
+					})
+				user := gock.New("https://api.github.com").
+					Get("/user").
+					Reply(200)
+				if testSpec.scopes != nil {
+					user.SetHeader(oauthScopesHeader, *testSpec.scopes)
+				}
+				user.JSON(map[string]string{"login": "someone"})
+
+				err := tokenPreflight(&UserAuth{TokenType: "token", AccessToken: testSpec.token}, gURL)
+				if testSpec.failure == "" {
+					Nil(t, err)
+				} else if NotNil(t, err) {
+					Contains(t, err.Error(), testSpec.failure)
+				}
+			},
+		)
+	}
+}
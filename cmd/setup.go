@@ -0,0 +1,175 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// setupCmd walks new users through the settings every release uses, and logging in
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Choose the default settings, and log in",
+	Long: `setup asks for the forge and protocol of owner/name repositories, the OAuth app to log in to Github with,
+and where to keep the token, then writes them to the global config in the user config directory, eg:
+~/.config/go-git-release/config.yaml on Linux, and logs in with the device flow. The current settings are the
+defaults, so setup can be run again to change them. A repository's config file overrides the global config.`,
+
+	// setup doesn't need a repository, only the auth settings
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setup(authValidation)
+	},
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !isInteractive() {
+			return fmt.Errorf("setup %w", errNotInteractive)
+		}
+
+		path, err := globalConfigPath()
+		if err != nil {
+			return fmt.Errorf("cannot find the user config directory: %w", err)
+		}
+
+		return runSetup(stdinPrompter(), path, authLogin)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+}
+
+// nonEmpty checks an answer without a default was given
+func nonEmpty(answer string) error {
+	if answer == "" {
+		return errors.New("an answer is required")
+	}
+	return nil
+}
+
+// runSetup asks for the settings, writes them to the global config at path, keeping any
+// others it has, and logs in to Github with login
+func runSetup(p *prompter, path string, login func() error) error {
+	settings := viper.New()
+	settings.SetConfigFile(path)
+	if err := settings.ReadInConfig(); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed reading %s: %w", path, err)
+	}
+
+	var err error
+	shorthandProvider, err = p.input("Forge of your owner/name repositories: github, gitlab or bitbucket", promptOptions{Default: shorthandProvider, Validate: validProviderName})
+	if err != nil {
+		return err
+	}
+	settings.Set("provider", shorthandProvider)
+
+	gitProtocol, err = p.input("Protocol to clone and push with: ssh or https", promptOptions{
+		Default: gitProtocol,
+		Validate: func(answer string) error {
+			if !validGitProtocol(answer) {
+				return errors.New("gitProtocol must be one of: ssh, https")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	settings.Set("gitProtocol", gitProtocol)
+
+	switch shorthandProvider {
+	case "gitlab":
+		gitlabURL, err = p.input("URL of your GitLab instance", promptOptions{
+			Default: gitlabURL,
+			Validate: func(answer string) error {
+				if !validEndpointURL(answer) {
+					return errors.New("gitlabURL must be an http or https URL")
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+		settings.Set("gitlabURL", gitlabURL)
+	case "bitbucket":
+	default:
+		// The built-in client ID is the default, so isn't pinned in the config
+		clientID, err = p.input("Client ID of the OAuth app to log in to Github with", promptOptions{Default: clientID, Validate: nonEmpty})
+		if err != nil {
+			return err
+		}
+		if clientID != defaultClientID {
+			settings.Set("clientID", clientID)
+		}
+
+		cacheToken = p.confirm("Keep the Github token for later runs?", cacheToken)
+		settings.Set("cacheToken", cacheToken)
+		if cacheToken {
+			tokenStorage, err = p.input("Where to keep the token: file or keyring", promptOptions{
+				Default: tokenStorage,
+				Validate: func(answer string) error {
+					if answer != tokenStorageFile && answer != tokenStorageKeyring {
+						return errors.New("auth.storage must be one of: file, keyring")
+					}
+					return nil
+				},
+			})
+			if err != nil {
+				return err
+			}
+			settings.Set("auth.storage", tokenStorage)
+		}
+	}
+
+	// The settings are kept even if logging in fails, to retry with auth login
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed creating %s: %w", filepath.Dir(path), err)
+	}
+	if err = settings.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	fmt.Fprintf(p.out, "Wrote the settings to %s\n", path)
+
+	switch shorthandProvider {
+	case "gitlab":
+		fmt.Fprintln(p.out, "Releases on GitLab are made with the access token in GITLAB_TOKEN, or --gitlabToken")
+		return nil
+	case "bitbucket":
+		fmt.Fprintln(p.out, "Downloads on Bitbucket are published with the access token in BITBUCKET_TOKEN, or --bitbucketToken")
+		return nil
+	}
+
+	// Tokens that aren't kept are authorized by each release instead
+	if !cacheToken {
+		fmt.Fprintln(p.out, "The token isn't kept, so each release logs in with the device flow")
+		return nil
+	}
+
+	if !p.confirm("Log in to Github now?", true) {
+		fmt.Fprintln(p.out, "Log in later with: go-git-release auth login")
+		return nil
+	}
+
+	return login()
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	. "github.com/stretchr/testify/assert"
+)
+
+// answers returns the lines of the answers, with the input ending after them
+func answers(lines ...string) <-chan string {
+	c := make(chan string, len(lines))
+	for _, l := range lines {
+		c <- l + "\n"
+	}
+	close(c)
+	return c
+}
+
+// TestRunSetup checks the answers are written to the global config, keeping its other settings, before logging in
+func TestRunSetup(t *testing.T) {
+	defer func(p, proto, id, storage string, cache bool) {
+		shorthandProvider, gitProtocol, clientID, tokenStorage, cacheToken = p, proto, id, storage, cache
+	}(shorthandProvider, gitProtocol, clientID, tokenStorage, cacheToken)
+	shorthandProvider, gitProtocol, clientID, tokenStorage, cacheToken = "github", protocolSSH, "", tokenStorageFile, true
+
+	dir, err := ioutil.TempDir("", "setup")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "go-git-release", "config.yaml")
+	Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+	Nil(t, ioutil.WriteFile(path, []byte("checksums: true\n"), 0600))
+
+	// The client ID has no default, so is asked for again; the rest take the defaults
+	loggedIn := false
+	err = runSetup(testPrompter(answers("", "https", "", "abc123", "", "keyring", ""), time.Second), path, func() error {
+		loggedIn = true
+		return nil
+	})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	True(t, loggedIn)
+
+	written := viper.New()
+	written.SetConfigFile(path)
+	Nil(t, written.ReadInConfig())
+	Equal(t, "github", written.GetString("provider"))
+	Equal(t, protocolHTTPS, written.GetString("gitProtocol"))
+	Equal(t, "abc123", written.GetString("clientID"))
+	Equal(t, tokenStorageKeyring, written.GetString("auth.storage"))
+	True(t, written.GetBool("checksums"))
+}
+
+// TestRunSetupGitlab checks forges other than Github are set up without logging in
+func TestRunSetupGitlab(t *testing.T) {
+	defer func(p, proto, u string) { shorthandProvider, gitProtocol, gitlabURL = p, proto, u }(shorthandProvider, gitProtocol, gitlabURL)
+	shorthandProvider, gitProtocol, gitlabURL = "github", protocolSSH, defaultGitlabURL
+
+	dir, err := ioutil.TempDir("", "setup")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "go-git-release", "config.yaml")
+
+	err = runSetup(testPrompter(answers("forgejo", "gitlab", "", "https://gitlab.example.com"), time.Second), path, func() error {
+		return errors.New("logged in")
+	})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, "https://gitlab.example.com", gitlabURL)
+
+	written := viper.New()
+	written.SetConfigFile(path)
+	Nil(t, written.ReadInConfig())
+	Equal(t, "gitlab", written.GetString("provider"))
+	Equal(t, "https://gitlab.example.com", written.GetString("gitlabURL"))
+	False(t, written.IsSet("clientID"))
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	processorTypeCommand      = "command"
+	processorTypeNotarize     = "notarize"
+	processorTypeAuthenticode = "authenticode"
+)
+
+// defaultTimestampURL is the RFC3161 timestamp server used for Authenticode signatures
+// if one is not configured
+const defaultTimestampURL = "http://timestamp.digicert.com"
+
+// staplableExtensions are the artifact types notarization tickets can be stapled to.
+// Bare executables are notarized, but Gatekeeper has to look the ticket up online.
+var staplableExtensions = map[string]bool{
+	".dmg": true,
+	".pkg": true,
+	".app": true,
+}
+
+// signingCommand runs the signing tools, and is replaced in tests
+var signingCommand = runCommand
+
+// notarize submits a macOS artifact to Apple's notary service with notarytool and waits
+// for the result, then staples the ticket to the artifact if its type supports it.
+// Credentials are taken from a notarytool keychain profile if one is configured,
+// otherwise from an Apple ID, team ID and app-specific password environment variable,
+// which notarytool reads itself so the password is never in its arguments.
+func notarize(p postProcessor, a *artifact) error {
+	submission := a.path
+
+	// notarytool only accepts zip, dmg and pkg files, so zip bare binaries
+	if !staplableExtensions[filepath.Ext(a.path)] {
+		submission = a.path + ".notarize.zip"
+		// The submission isn't released, so it needn't be reproducible
+		if err := writeZip(submission, []archiveFile{{path: a.path, name: a.name}}, time.Now()); err != nil {
+			return fmt.Errorf("failed creating notarization archive: %w", err)
+		}
+		defer os.Remove(submission)
+	}
+
+	args := []string{"notarytool", "submit", submission, "--wait"}
+
+	switch {
+	case p.KeychainProfile != "":
+		args = append(args, "--keychain-profile", p.KeychainProfile)
+	case p.AppleID != "" && p.TeamID != "":
+		if _, err := secretFromEnv(p.PasswordEnv); err != nil {
+			return err
+		}
+		args = append(args, "--apple-id", p.AppleID, "--team-id", p.TeamID, "--password", "@env:"+p.PasswordEnv)
+	default:
+		return fmt.Errorf("notarization requires a keychainProfile, or an appleID and teamID")
+	}
+
+	if err := signingCommand("xcrun", args...); err != nil {
+		return fmt.Errorf("notarization failed: %w", err)
+	}
+
+	if staplableExtensions[filepath.Ext(a.path)] {
+		if err := signingCommand("xcrun", "stapler", "staple", a.path); err != nil {
+			return fmt.Errorf("stapling failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// authenticodeSign signs a Windows executable with osslsigncode, using either a local
+// PFX certificate or a key held in a cloud KMS/HSM exposed through a PKCS#11 module.
+// The certificate's password is passed to osslsigncode in a file only the user can read,
+// rather than in its arguments. The signed executable replaces the original artifact.
+func authenticodeSign(p postProcessor, a *artifact) error {
+	signed := a.path + ".signed"
+
+	args := []string{"sign"}
+
+	switch {
+	case p.Certificate != "" && p.PKCS11Module == "":
+		args = append(args, "-pkcs12", p.Certificate)
+		if p.PasswordEnv != "" {
+			passwordFile, err := writePasswordFile(p.PasswordEnv)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(passwordFile)
+			args = append(args, "-readpass", passwordFile)
+		}
+	case p.PKCS11Module != "" && p.Key != "" && p.Certificate != "":
+		args = append(args, "-pkcs11module", p.PKCS11Module, "-key", p.Key, "-certs", p.Certificate)
+	default:
+		return fmt.Errorf("authenticode signing requires a certificate, and a pkcs11Module and key when signing with a KMS")
+	}
+
+	timestampURL := p.TimestampURL
+	if timestampURL == "" {
+		timestampURL = defaultTimestampURL
+	}
+
+	args = append(args, "-h", "sha256", "-ts", timestampURL, "-in", a.path, "-out", signed)
+
+	if err := signingCommand("osslsigncode", args...); err != nil {
+		os.Remove(signed)
+		return fmt.Errorf("authenticode signing failed: %w", err)
+	}
+
+	return os.Rename(signed, a.path)
+}
+
+// secretFromEnv returns the value of the named environment variable, erroring if it is unset
+func secretFromEnv(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("no password environment variable configured")
+	}
+
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+// writePasswordFile writes the password in the named environment variable to a temporary
+// file readable only by the user, and returns its path for the caller to remove
+func writePasswordFile(name string) (string, error) {
+	password, err := secretFromEnv(name)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile("", "go-git-release-pass")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err = f.WriteString(password); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// fakeSigningCommand records the command lines run by the signing processors, and the
+// contents of any password file they are given, writing each -out file osslsigncode would
+func fakeSigningCommand(t *testing.T) (*[][]string, *[]string, func()) {
+	var commands [][]string
+	var passwords []string
+	previous := signingCommand
+	signingCommand = func(command string, args ...string) error {
+		commands = append(commands, append([]string{command}, args...))
+		for i := 0; i < len(args)-1; i++ {
+			switch args[i] {
+			case "-readpass":
+				password, err := ioutil.ReadFile(args[i+1])
+				Nil(t, err)
+				passwords = append(passwords, string(password))
+			case "-out":
+				Nil(t, ioutil.WriteFile(args[i+1], []byte("signed"), 0644))
+			}
+		}
+		return nil
+	}
+	return &commands, &passwords, func() { signingCommand = previous }
+}
+
+// TestSigningCommands checks the argv of each signing tool, and that passwords are never in it
+func TestSigningCommands(t *testing.T) {
+	defer os.Setenv("SIGNING_PASSWORD", os.Getenv("SIGNING_PASSWORD"))
+	os.Setenv("SIGNING_PASSWORD", "hunter2")
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name      string
+		sign      func(postProcessor, *artifact) error
+		processor postProcessor
+		artifact  string
+		commands  [][]string
+		passwords []string
+	}{
+		{
+			name:      "notarize with a keychain profile",
+			sign:      notarize,
+			processor: postProcessor{KeychainProfile: "release"},
+			artifact:  "app.dmg",
+			commands: [][]string{
+				{"xcrun", "notarytool", "submit", "app.dmg", "--wait", "--keychain-profile", "release"},
+				{"xcrun", "stapler", "staple", "app.dmg"},
+			},
+		},
+		{
+			name:      "notarize with an Apple ID",
+			sign:      notarize,
+			processor: postProcessor{AppleID: "dev@example.com", TeamID: "TEAM", PasswordEnv: "SIGNING_PASSWORD"},
+			artifact:  "app.pkg",
+			commands: [][]string{
+				{"xcrun", "notarytool", "submit", "app.pkg", "--wait", "--apple-id", "dev@example.com", "--team-id", "TEAM", "--password", "@env:SIGNING_PASSWORD"},
+				{"xcrun", "stapler", "staple", "app.pkg"},
+			},
+		},
+		{
+			name:      "authenticode with a PFX",
+			sign:      authenticodeSign,
+			processor: postProcessor{Certificate: "cert.pfx", PasswordEnv: "SIGNING_PASSWORD"},
+			artifact:  "app.exe",
+			commands: [][]string{
+				{"osslsigncode", "sign", "-pkcs12", "cert.pfx", "-readpass", "PASSWORD_FILE", "-h", "sha256", "-ts", defaultTimestampURL, "-in", "app.exe", "-out", "app.exe.signed"},
+			},
+			passwords: []string{"hunter2"},
+		},
+		{
+			name:      "authenticode with a KMS key",
+			sign:      authenticodeSign,
+			processor: postProcessor{Certificate: "cert.pem", PKCS11Module: "kms.so", Key: "pkcs11:object=release", TimestampURL: "http://ts.example.com"},
+			artifact:  "app.exe",
+			commands: [][]string{
+				{"osslsigncode", "sign", "-pkcs11module", "kms.so", "-key", "pkcs11:object=release", "-certs", "cert.pem", "-h", "sha256", "-ts", "http://ts.example.com", "-in", "app.exe", "-out", "app.exe.signed"},
+			},
+		},
+	}
+
+	for _, testSpec := range tests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			commands, passwords, restore := fakeSigningCommand(t)
+			defer restore()
+
+			path := filepath.Join(dir, testSpec.artifact)
+			Nil(t, ioutil.WriteFile(path, []byte("binary"), 0644))
+
+			Nil(t, testSpec.sign(testSpec.processor, &artifact{path: path, name: testSpec.artifact}))
+
+			// Compare the arguments relative to the temporary directory, without the password file's random name
+			var got [][]string
+			for _, c := range *commands {
+				var args []string
+				for i, arg := range c {
+					switch {
+					case i > 0 && c[i-1] == "-readpass":
+						arg = "PASSWORD_FILE"
+					default:
+						arg = strings.TrimPrefix(arg, dir+string(filepath.Separator))
+					}
+					NotContains(t, arg, "hunter2")
+					args = append(args, arg)
+				}
+				got = append(got, args)
+			}
+			Equal(t, testSpec.commands, got)
+			Equal(t, testSpec.passwords, *passwords)
+		})
+	}
+}
+
+// TestSigningRequiresPassword checks signing with an Apple ID fails when its password isn't set
+func TestSigningRequiresPassword(t *testing.T) {
+	defer os.Setenv("SIGNING_PASSWORD", os.Getenv("SIGNING_PASSWORD"))
+	os.Unsetenv("SIGNING_PASSWORD")
+
+	_, _, restore := fakeSigningCommand(t)
+	defer restore()
+
+	err := notarize(postProcessor{AppleID: "dev@example.com", TeamID: "TEAM", PasswordEnv: "SIGNING_PASSWORD"}, &artifact{path: "app.dmg"})
+	EqualError(t, err, "environment variable SIGNING_PASSWORD is not set")
+}
@@ -0,0 +1,193 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the accepted size suffixes to their multiplier in bytes
+var sizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+}
+
+// parseSize converts a human readable size such as "512KB" or "25MB" into bytes.
+// An empty string returns 0, which disables the budget it is used for.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	re := regexp.MustCompile(`^(?P<value>[0-9]+(?:\.[0-9]+)?)\s*(?P<unit>[a-zA-Z]*)$`)
+	matches := re.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	multiplier, ok := sizeUnits[strings.ToUpper(matches[re.SubexpIndex("unit")])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+
+	value, err := strconv.ParseFloat(matches[re.SubexpIndex("value")], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
+
+// formatSize returns a human readable representation of a size in bytes
+func formatSize(size int64) string {
+	abs := size
+	if abs < 0 {
+		abs = -abs
+	}
+
+	switch {
+	case abs >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(size)/(1<<30))
+	case abs >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	case abs >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// checkSizeBudgets compares the artifacts against the per-asset and total size budgets,
+// and returns a message for each budget that was exceeded. A budget of 0 is not enforced.
+func checkSizeBudgets(artifacts []*artifact, maxAsset, maxTotal int64) []string {
+	var exceeded []string
+	var total int64
+
+	for _, a := range artifacts {
+		total += a.size
+		if maxAsset > 0 && a.size > maxAsset {
+			exceeded = append(exceeded, fmt.Sprintf(
+				"asset %s is %s, exceeding the per-asset budget of %s",
+				a.name, formatSize(a.size), formatSize(maxAsset),
+			))
+		}
+	}
+
+	if maxTotal > 0 && total > maxTotal {
+		exceeded = append(exceeded, fmt.Sprintf(
+			"assets total %s, exceeding the total budget of %s",
+			formatSize(total), formatSize(maxTotal),
+		))
+	}
+
+	return exceeded
+}
+
+// enforceSizeBudgets checks the artifacts against the configured size budgets.
+// Exceeded budgets are printed as warnings, or returned as an error if
+// sizeBudgetAction is "fail".
+func enforceSizeBudgets(artifacts []*artifact) error {
+	maxAsset, err := parseSize(maxAssetSize)
+	if err != nil {
+		return fmt.Errorf("maxAssetSize: %w", err)
+	}
+
+	maxTotal, err := parseSize(maxTotalSize)
+	if err != nil {
+		return fmt.Errorf("maxTotalSize: %w", err)
+	}
+
+	exceeded := checkSizeBudgets(artifacts, maxAsset, maxTotal)
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	if sizeBudgetAction == "fail" {
+		return fmt.Errorf("size budget exceeded: %s", strings.Join(exceeded, "; "))
+	}
+
+	for _, msg := range exceeded {
+		fmt.Printf("WARNING: %s\n", msg)
+	}
+
+	return nil
+}
+
+// assetSizeChange records the size of an artifact alongside the size of the asset
+// with the same name in the previous release, if there was one
+type assetSizeChange struct {
+	name     string
+	size     int64
+	previous int64
+	isNew    bool
+}
+
+// compareAssetSizes matches each artifact with the asset of the same name in the
+// previous release. A nil previous release marks every artifact as new.
+func compareAssetSizes(artifacts []*artifact, previous *release) []assetSizeChange {
+	previousSizes := make(map[string]int64)
+	if previous != nil {
+		for _, a := range previous.Assets {
+			if a.Name != nil && a.Size != nil {
+				previousSizes[*a.Name] = int64(*a.Size)
+			}
+		}
+	}
+
+	changes := make([]assetSizeChange, 0, len(artifacts))
+	for _, a := range artifacts {
+		prev, ok := previousSizes[a.name]
+		changes = append(changes, assetSizeChange{
+			name:     a.name,
+			size:     a.size,
+			previous: prev,
+			isNew:    !ok,
+		})
+	}
+
+	return changes
+}
+
+// String returns the size change formatted for the release summary
+func (c assetSizeChange) String() string {
+	if c.isNew {
+		return fmt.Sprintf("%s: %s (new)", c.name, formatSize(c.size))
+	}
+
+	delta := c.size - c.previous
+	var percent float64
+	if c.previous != 0 {
+		percent = float64(delta) / float64(c.previous) * 100
+	}
+
+	sign := "+"
+	if delta < 0 {
+		sign = ""
+	}
+
+	return fmt.Sprintf("%s: %s (%s%s, %s%.1f%%)", c.name, formatSize(c.size), sign, formatSize(delta), sign, percent)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestParseSize checks human readable sizes are converted to bytes
+func TestParseSize(t *testing.T) {
+	sizeTests := []struct {
+		input    string
+		expected int64
+		err      bool
+	}{
+		{input: "", expected: 0},
+		{input: "1024", expected: 1024},
+		{input: "512B", expected: 512},
+		{input: "2KB", expected: 2048},
+		{input: "1.5M", expected: 1572864},
+		{input: "25 mb", expected: 26214400},
+		{input: "1GB", expected: 1073741824},
+		{input: "ten", err: true},
+		{input: "10TB", err: true},
+	}
+
+	for _, testSpec := range sizeTests {
+		t.Run(
+			testSpec.input,
+			func(t *testing.T) {
+				size, err := parseSize(testSpec.input)
+				if testSpec.err {
+					Error(t, err)
+					return
+				}
+				Nil(t, err)
+				Equal(t, testSpec.expected, size)
+			},
+		)
+	}
+}
+
+// TestCheckSizeBudgets checks exceeded per-asset and total budgets are reported
+func TestCheckSizeBudgets(t *testing.T) {
+	artifacts := []*artifact{
+		{name: "small", size: 10},
+		{name: "large", size: 100},
+	}
+
+	Empty(t, checkSizeBudgets(artifacts, 0, 0))
+	Empty(t, checkSizeBudgets(artifacts, 100, 110))
+	Len(t, checkSizeBudgets(artifacts, 50, 0), 1)
+	Len(t, checkSizeBudgets(artifacts, 0, 100), 1)
+	Len(t, checkSizeBudgets(artifacts, 50, 100), 2)
+}
+
+// TestCompareAssetSizes checks artifacts are matched to the previous release assets by name
+func TestCompareAssetSizes(t *testing.T) {
+	name := "large"
+	size := 80
+	previous := &release{
+		Assets: []*asset{{Name: &name, Size: &size}},
+	}
+
+	artifacts := []*artifact{
+		{name: "small", size: 10},
+		{name: "large", size: 100},
+	}
+
+	changes := compareAssetSizes(artifacts, previous)
+	Len(t, changes, 2)
+	True(t, changes[0].isNew)
+	False(t, changes[1].isNew)
+	Equal(t, int64(80), changes[1].previous)
+	Equal(t, "large: 100B (+20B, +25.0%)", changes[1].String())
+
+	changes = compareAssetSizes(artifacts, nil)
+	True(t, changes[0].isNew)
+	True(t, changes[1].isNew)
+}
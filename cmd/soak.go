@@ -0,0 +1,111 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// prereleaseSoak describes how long the prereleases of a version have been available,
+// and how many times their assets have been downloaded
+type prereleaseSoak struct {
+	count     int
+	earliest  time.Time
+	downloads int
+}
+
+// measureSoak finds the published prereleases of the same major.minor.patch version
+// as final, and returns the earliest publish date and the combined download count
+func measureSoak(releasesList *releases, final *version) prereleaseSoak {
+	var soak prereleaseSoak
+
+	if releasesList == nil {
+		return soak
+	}
+
+	for _, r := range *releasesList {
+		if r.TagName == nil || r.PublishedAt == nil {
+			continue
+		}
+		if r.Draft != nil && *r.Draft {
+			continue
+		}
+
+		v, err := parseVersion(*r.TagName)
+		if err != nil || !v.isPrerelease() || !v.sameCore(final) {
+			continue
+		}
+
+		published, err := time.Parse(time.RFC3339, *r.PublishedAt)
+		if err != nil {
+			continue
+		}
+
+		soak.count++
+		if soak.earliest.IsZero() || published.Before(soak.earliest) {
+			soak.earliest = published
+		}
+
+		for _, a := range r.Assets {
+			if a.DownloadCount != nil {
+				soak.downloads += *a.DownloadCount
+			}
+		}
+	}
+
+	return soak
+}
+
+// checkSoakPolicy enforces the minimum soak time and downloads of prereleases before
+// a final release is published. Prerelease tags, tags that are not semantic versions,
+// and releases with ignoreSoak set are not gated.
+func checkSoakPolicy(releasesList *releases, tag string, now time.Time) error {
+	if ignoreSoak || (minSoakDays <= 0 && minSoakDownloads <= 0) {
+		return nil
+	}
+
+	final, err := parseVersion(tag)
+	if err != nil || final.isPrerelease() {
+		return nil
+	}
+
+	soak := measureSoak(releasesList, final)
+	if soak.count == 0 {
+		return fmt.Errorf("soak policy: no prerelease of %s has been published; use --ignoreSoak to override", final.core())
+	}
+
+	if minSoakDays > 0 {
+		required := time.Duration(minSoakDays) * 24 * time.Hour
+		if soaked := now.Sub(soak.earliest); soaked < required {
+			return fmt.Errorf(
+				"soak policy: prereleases of %s have been available for %.1f days, %d are required; use --ignoreSoak to override",
+				final.core(), soaked.Hours()/24, minSoakDays,
+			)
+		}
+	}
+
+	if minSoakDownloads > 0 && soak.downloads < minSoakDownloads {
+		return fmt.Errorf(
+			"soak policy: prereleases of %s have %d downloads, %d are required; use --ignoreSoak to override",
+			final.core(), soak.downloads, minSoakDownloads,
+		)
+	}
+
+	return nil
+}
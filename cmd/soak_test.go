@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// soakRelease returns a published release of the tag, with an asset downloaded the number of times
+func soakRelease(tag, publishedAt string, downloads int, draft bool) release {
+	return release{TagName: &tag, PublishedAt: &publishedAt, Draft: &draft, Assets: []*asset{{DownloadCount: &downloads}}}
+}
+
+// TestMeasureSoak checks only the published prereleases of the same version are measured
+func TestMeasureSoak(t *testing.T) {
+	list := &releases{
+		soakRelease("v1.2.0-rc.2", "2021-01-05T00:00:00Z", 30, false),
+		soakRelease("v1.2.0-rc.1", "2021-01-01T00:00:00Z", 10, false),
+		soakRelease("v1.2.0-rc.3", "2020-12-01T00:00:00Z", 100, true),
+		soakRelease("v1.1.0-rc.1", "2020-11-01T00:00:00Z", 100, false),
+		soakRelease("v1.1.0", "2020-11-02T00:00:00Z", 100, false),
+	}
+
+	final, err := parseVersion("v1.2.0")
+	Nil(t, err)
+	Equal(t, prereleaseSoak{count: 2, earliest: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), downloads: 40}, measureSoak(list, final))
+
+	final, err = parseVersion("v2.0.0")
+	Nil(t, err)
+	Equal(t, prereleaseSoak{}, measureSoak(list, final))
+	Equal(t, prereleaseSoak{}, measureSoak(nil, final))
+}
+
+// TestCheckSoakPolicy checks final releases are gated on the soak time and downloads of their prereleases
+func TestCheckSoakPolicy(t *testing.T) {
+	defer func(d, n int, i bool) { minSoakDays, minSoakDownloads, ignoreSoak = d, n, i }(minSoakDays, minSoakDownloads, ignoreSoak)
+
+	list := &releases{
+		soakRelease("v1.2.0-rc.1", "2021-01-01T00:00:00Z", 10, false),
+	}
+	now := time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	checkSoakPolicyTests := []struct {
+		name             string
+		tag              string
+		minSoakDays      int
+		minSoakDownloads int
+		ignoreSoak       bool
+		err              bool
+	}{
+		{name: "no policy", tag: "v1.2.0"},
+		{name: "soaked long enough", tag: "v1.2.0", minSoakDays: 7},
+		{name: "not soaked long enough", tag: "v1.2.0", minSoakDays: 8, err: true},
+		{name: "downloaded enough", tag: "v1.2.0", minSoakDownloads: 10},
+		{name: "not downloaded enough", tag: "v1.2.0", minSoakDownloads: 11, err: true},
+		{name: "no prerelease", tag: "v1.3.0", minSoakDays: 1, err: true},
+		{name: "ignored", tag: "v1.3.0", minSoakDays: 1, ignoreSoak: true},
+		{name: "prerelease", tag: "v1.3.0-rc.1", minSoakDays: 1},
+		{name: "not a version", tag: "nightly", minSoakDays: 1},
+	}
+	for _, testSpec := range checkSoakPolicyTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			minSoakDays, minSoakDownloads, ignoreSoak = testSpec.minSoakDays, testSpec.minSoakDownloads, testSpec.ignoreSoak
+
+			err := checkSoakPolicy(list, testSpec.tag, now)
+			if testSpec.err {
+				NotNil(t, err)
+				Contains(t, err.Error(), "--ignoreSoak")
+			} else {
+				Nil(t, err)
+			}
+		})
+	}
+}
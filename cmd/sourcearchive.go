@@ -0,0 +1,310 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// sourceArchiveConfig configures a source archive of the tagged commit, uploaded with the
+// release for distro packagers. It is only configurable in the config file, under
+// "sourceArchive".
+type sourceArchiveConfig struct {
+	// Enabled turns on the source archive
+	Enabled bool `mapstructure:"enabled"`
+	// Include are .gitignore style patterns of files in the worktree to add even if they
+	// are untracked, ignored or export-ignored, eg: generated files or vendored dependencies
+	Include []string `mapstructure:"include"`
+	// Exclude are .gitignore style patterns of tracked files to leave out, in addition to
+	// those with the export-ignore attribute
+	Exclude []string `mapstructure:"exclude"`
+}
+
+var sourceArchive sourceArchiveConfig
+
+// sourceFile is a file to be written into the source archive
+type sourceFile struct {
+	// name is the slash separated path of the file in the repository
+	name string
+	mode os.FileMode
+	size int64
+	// linkTarget is the target of a symlink
+	linkTarget string
+	open       func() (io.ReadCloser, error)
+}
+
+// sourceArchiveName returns the name of the source archive, and of the directory its files
+// are in, eg: project-1.2.0 for v1.2.0, as distro packaging expects
+func sourceArchiveName(project, tag string) string {
+	version := tag
+	if v, err := parseVersion(tag); err == nil {
+		version = strings.TrimPrefix(v.String(), v.prefix)
+	}
+	return fmt.Sprintf("%s-%s", project, version)
+}
+
+// gitignoreMatcher returns a matcher for the .gitignore style patterns, or nil if there are none
+func gitignoreMatcher(patterns []string) gitignore.Matcher {
+	if len(patterns) == 0 {
+		return nil
+	}
+	var ps []gitignore.Pattern
+	for _, p := range patterns {
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+	return gitignore.NewMatcher(ps)
+}
+
+// exportIgnored returns true if the path, or any directory containing it, has the
+// export-ignore attribute, as git archive checks it
+func exportIgnored(m gitattributes.Matcher, path []string) bool {
+	for i := 1; i <= len(path); i++ {
+		results, _ := m.Match(path[:i], []string{"export-ignore"})
+		if a, ok := results["export-ignore"]; ok && a.IsSet() {
+			return true
+		}
+	}
+	return false
+}
+
+// trackedSourceFiles returns the files of the commit, leaving out those that are
+// export-ignored by the .gitattributes in the worktree or match an exclude pattern.
+// Untracked and ignored files are never part of the commit.
+func trackedSourceFiles(repo *git.Repository, commit *object.Commit, exclude []string) ([]sourceFile, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := gitattributes.ReadPatterns(wt.Filesystem, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading .gitattributes: %w", err)
+	}
+	attributesMatcher := gitattributes.NewMatcher(attributes)
+	excludeMatcher := gitignoreMatcher(exclude)
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []sourceFile
+	err = tree.Files().ForEach(func(f *object.File) error {
+		path := strings.Split(f.Name, "/")
+		if exportIgnored(attributesMatcher, path) {
+			return nil
+		}
+		if excludeMatcher != nil && excludeMatcher.Match(path, false) {
+			return nil
+		}
+
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+		sf := sourceFile{name: f.Name, mode: mode, size: f.Size}
+
+		if f.Mode == filemode.Symlink {
+			sf.linkTarget, err = f.Contents()
+			if err != nil {
+				return err
+			}
+		} else {
+			file := f
+			sf.open = func() (io.ReadCloser, error) { return file.Reader() }
+		}
+
+		files = append(files, sf)
+		return nil
+	})
+
+	return files, err
+}
+
+// includedSourceFiles returns the files in the worktree at repoDir matching the include patterns
+func includedSourceFiles(repoDir string, include []string) ([]sourceFile, error) {
+	m := gitignoreMatcher(include)
+	if m == nil {
+		return nil, nil
+	}
+
+	var files []sourceFile
+	err := filepath.Walk(repoDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(repoDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := filepath.ToSlash(rel)
+		if !m.Match(strings.Split(name, "/"), false) {
+			return nil
+		}
+
+		sf := sourceFile{name: name, mode: info.Mode(), size: info.Size()}
+		if info.Mode()&os.ModeSymlink != 0 {
+			sf.linkTarget, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		} else if info.Mode().IsRegular() {
+			sf.open = func() (io.ReadCloser, error) { return os.Open(p) }
+		} else {
+			return nil
+		}
+
+		files = append(files, sf)
+		return nil
+	})
+
+	return files, err
+}
+
+// writeSourceTarGz writes the files into a gzipped tarball at dest, under the prefix
+// directory. Every entry has the modTime and no owner, so the archive is the same
+// whenever it is built.
+func writeSourceTarGz(dest, prefix string, files []sourceFile, modTime time.Time) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	gw.ModTime = modTime
+	tw := tar.NewWriter(gw)
+
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    prefix + "/" + f.name,
+			Mode:    int64(archiveMode(f.mode)),
+			ModTime: modTime,
+			Format:  tar.FormatPAX,
+		}
+
+		if f.linkTarget != "" {
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = f.linkTarget
+			header.Mode = 0777
+			if err = tw.WriteHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header.Typeflag = tar.TypeReg
+		header.Size = f.size
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		in, err := f.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, in)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("failed archiving %s: %w", f.name, err)
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// createSourceArchive writes a source archive of the checked out commit into repoDir: its
+// tracked files, less those export-ignored or excluded, plus the included worktree files
+func createSourceArchive(repo *git.Repository, repoDir, project, tag string,
+	config sourceArchiveConfig, modTime time.Time) (*artifact, error) {
+	commit, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := trackedSourceFiles(repo, commit, config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	included, err := includedSourceFiles(repoDir, config.Include)
+	if err != nil {
+		return nil, fmt.Errorf("failed finding included files: %w", err)
+	}
+
+	// Included files replace the committed ones, eg: if they were regenerated by the build
+	byName := make(map[string]sourceFile)
+	for _, f := range append(files, included...) {
+		byName[f.name] = f
+	}
+	files = files[:0]
+	for _, f := range byName {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	prefix := sourceArchiveName(project, tag)
+	name := prefix + ".tar.gz"
+
+	// The files were all found already, so the archive doesn't include itself
+	dest := filepath.Join(repoDir, name)
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Archiving %d source files as %s", len(files), name))
+	}
+	if err = writeSourceTarGz(dest, prefix, files, modTime); err != nil {
+		return nil, fmt.Errorf("failed writing source archive: %w", err)
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &artifact{path: dest, name: name, size: info.Size()}, nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestSourceArchiveName checks the archive is named for the version, without its prefix
+func TestSourceArchiveName(t *testing.T) {
+	Equal(t, "project-1.2.0", sourceArchiveName("project", "v1.2.0"))
+	Equal(t, "project-1.2.0-rc.1", sourceArchiveName("project", "1.2.0-rc.1"))
+	Equal(t, "project-nightly", sourceArchiveName("project", "nightly"))
+}
+
+// TestCreateSourceArchive checks the archive has the committed files, less those
+// export-ignored or excluded, plus the included ones
+func TestCreateSourceArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	Nil(t, err)
+	tree, err := repo.Worktree()
+	Nil(t, err)
+
+	committed := map[string]string{
+		".gitattributes":   "docs export-ignore\n*.png export-ignore\n",
+		".gitignore":       "bin/\nvendor/\n",
+		"main.go":          "package main\n",
+		"docs/guide.md":    "# Guide\n",
+		"logo.png":         "png",
+		"testdata/case.in": "input\n",
+		"pkg/lib.go":       "package pkg\n",
+	}
+	for name, content := range committed {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+		_, err = tree.Add(name)
+		Nil(t, err)
+	}
+	when := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, err = tree.Commit("first", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: when}})
+	Nil(t, err)
+
+	// Build output, and dependencies vendored at release time, are untracked
+	for _, name := range []string{"bin/tool", "vendor/dep/dep.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		Nil(t, ioutil.WriteFile(path, []byte("untracked"), 0644))
+	}
+
+	a, err := createSourceArchive(repo, dir, "project", "v1.2.0", sourceArchiveConfig{
+		Enabled: true,
+		Include: []string{"vendor/"},
+		Exclude: []string{"testdata/"},
+	}, when)
+	if !Nil(t, err) {
+		return
+	}
+
+	Equal(t, "project-1.2.0.tar.gz", a.name)
+	Equal(t, []string{
+		"project-1.2.0/.gitattributes",
+		"project-1.2.0/.gitignore",
+		"project-1.2.0/main.go",
+		"project-1.2.0/pkg/lib.go",
+		"project-1.2.0/vendor/dep/dep.go",
+	}, tarNames(t, a.path))
+}
@@ -0,0 +1,198 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// sparsePaths are the directories a clone checks out, besides the files at the root of
+// the repository, like git sparse-checkout in cone mode. Every file is checked out
+// without any.
+var sparsePaths []string
+
+// cleanSparsePath returns the sparse path as a directory of the repository, or an error
+// if it is outside it
+func cleanSparsePath(p string) (string, error) {
+	cleaned := path.Clean(strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("sparsePath %q must be a directory of the repository", p)
+	}
+	return cleaned, nil
+}
+
+// inSparseCone returns true if the file is checked out: files at the root of the
+// repository, and those under any of the directories
+func inSparseCone(name string, dirs []string) bool {
+	if !strings.Contains(name, "/") {
+		return true
+	}
+	for _, d := range dirs {
+		if strings.HasPrefix(name, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseFiles returns the files of the commit in the sparse cone
+func sparseFiles(commit *object.Commit, dirs []string) ([]*object.File, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*object.File
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if inSparseCone(f.Name, dirs) {
+			files = append(files, f)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// sparseCheckout writes the files of the commit in the sparse cone to the worktree and
+// the index, and points HEAD at it, as a checkout of the hash does. The files of the
+// commit checked out before that aren't in this one are removed. This is a sparse
+// checkout, not a partial clone: every object is still fetched. go-git only writes
+// version 2 indexes, which can't mark files skip-worktree, so the files outside the cone
+// are left out of the index.
+func sparseCheckout(repo *git.Repository, hash plumbing.Hash, dirs []string) error {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	files, err := sparseFiles(commit, dirs)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		keep[f.Name] = true
+	}
+
+	if head, err := repo.Head(); err == nil && head.Hash() != hash {
+		previous, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		old, err := sparseFiles(previous, dirs)
+		if err != nil {
+			return err
+		}
+		for _, f := range old {
+			if keep[f.Name] {
+				continue
+			}
+			if err := wt.Filesystem.Remove(f.Name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err = writeSparseFile(wt, f); err != nil {
+			return fmt.Errorf("failed checking out %s: %w", f.Name, err)
+		}
+	}
+	if err = writeSparseIndex(repo, wt, files); err != nil {
+		return fmt.Errorf("failed writing the index: %w", err)
+	}
+
+	// A checkout of a hash detaches HEAD; checking out HEAD itself leaves it on its branch
+	if head, err := repo.Head(); err == nil && head.Hash() == hash {
+		return nil
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash))
+}
+
+// writeSparseIndex replaces the index with the checked out files, with their stat
+// information from the worktree so they aren't reported as modified
+func writeSparseIndex(repo *git.Repository, wt *git.Worktree, files []*object.File) error {
+	idx := &index.Index{Version: 2}
+	for _, f := range files {
+		e := &index.Entry{Name: f.Name, Hash: f.Hash, Mode: f.Mode}
+		if info, err := wt.Filesystem.Lstat(f.Name); err == nil {
+			e.ModifiedAt = info.ModTime()
+			e.Size = uint32(info.Size())
+		}
+		idx.Entries = append(idx.Entries, e)
+	}
+	sort.Slice(idx.Entries, func(i, j int) bool { return idx.Entries[i].Name < idx.Entries[j].Name })
+
+	return repo.Storer.SetIndex(idx)
+}
+
+// writeSparseFile writes the file to the worktree, with its mode
+func writeSparseFile(wt *git.Worktree, f *object.File) error {
+	if dir := path.Dir(f.Name); dir != "." {
+		if err := wt.Filesystem.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	contents, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	if f.Mode == filemode.Symlink {
+		target, err := ioutil.ReadAll(contents)
+		if err != nil {
+			return err
+		}
+		wt.Filesystem.Remove(f.Name)
+		return wt.Filesystem.Symlink(string(target), f.Name)
+	}
+
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+	out, err := wt.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, contents); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
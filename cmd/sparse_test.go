@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-billy/v5/util"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestCleanSparsePath checks sparse paths are directories of the repository
+func TestCleanSparsePath(t *testing.T) {
+	for p, expected := range map[string]string{"app": "app", "/services/api/": "services/api", "tools\\build": "tools/build", "app/../lib": "lib"} {
+		cleaned, err := cleanSparsePath(p)
+		Nil(t, err, p)
+		Equal(t, expected, cleaned, p)
+	}
+
+	for _, p := range []string{"", "/", ".", "..", "../other", "app/../../other"} {
+		_, err := cleanSparsePath(p)
+		NotNil(t, err, p)
+	}
+}
+
+// TestSparseCheckout checks only the root files and the sparse directories are checked out and indexed, and files removed since are deleted
+func TestSparseCheckout(t *testing.T) {
+	repo := gitfixture.New(t)
+	wt, err := repo.Worktree()
+	Nil(t, err)
+	fs := wt.Filesystem
+
+	first := repo.Commit("first", map[string]string{"Makefile": "build:", "app/main.go": "package main", "app/cmd/run.go": "package cmd", "docs/guide.md": "# Guide"})
+	_, err = wt.Remove("app/main.go")
+	Nil(t, err)
+	second := repo.Commit("second", map[string]string{"app/new.go": "package main"})
+
+	// The clone checks nothing out
+	Nil(t, util.RemoveAll(fs, "app"))
+	Nil(t, util.RemoveAll(fs, "docs"))
+
+	exists := func(name string) bool {
+		_, err := fs.Stat(name)
+		return err == nil
+	}
+
+	Nil(t, sparseCheckout(repo.Repository, first.Hash, []string{"app"}))
+	True(t, exists("Makefile"))
+	True(t, exists("app/main.go"))
+	True(t, exists("app/cmd/run.go"))
+	False(t, exists("docs/guide.md"))
+	head, err := repo.Head()
+	Nil(t, err)
+	Equal(t, first.Hash, head.Hash())
+
+	Nil(t, sparseCheckout(repo.Repository, second.Hash, []string{"app"}))
+	False(t, exists("app/main.go"))
+	True(t, exists("app/new.go"))
+	False(t, exists("docs/guide.md"))
+	head, err = repo.Head()
+	Nil(t, err)
+	Equal(t, second.Hash, head.Hash())
+
+	// The index has the checked out files, which are unmodified
+	idx, err := repo.Storer.Index()
+	Nil(t, err)
+	var names []string
+	for _, e := range idx.Entries {
+		names = append(names, e.Name)
+	}
+	Equal(t, []string{"Makefile", "app/cmd/run.go", "app/new.go"}, names)
+	status, err := wt.Status()
+	Nil(t, err)
+	for _, name := range names {
+		_, changed := status[name]
+		False(t, changed, name)
+	}
+}
@@ -0,0 +1,373 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// draft creates the release as a draft, with --draft or by staging it
+var draft bool
+
+// prerelease marks the release as a prerelease
+var prerelease bool
+
+// prereleaseSet is whether --prerelease was set, by the flag or a config file, so an
+// updated release only has its prerelease flag changed when asked to
+var prereleaseSet bool
+
+// stagingManifestPath is where the staging manifest of a staged draft is recorded
+var stagingManifestPath string
+
+// stagingManifest records what was staged to a draft release, so it can be
+// reviewed against the draft before publishing
+type stagingManifest struct {
+	Tag        string        `json:"tag"`
+	Repository string        `json:"repository"`
+	ReleaseID  int           `json:"release_id"`
+	Body       string        `json:"body"`
+	Assets     []stagedAsset `json:"assets"`
+	// Provenance is the CI run that built the assets, if any
+	Provenance *provenance `json:"provenance,omitempty"`
+}
+
+// stagedAsset is an asset uploaded to a staged draft
+type stagedAsset struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Metadata is the asset metadata recorded in the asset's sidecar, if any
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// stageCmd runs the release pipeline, uploading the assets to a draft release
+var stageCmd = &cobra.Command{
+	Use:   "stage",
+	Short: "Tag, build and upload artifacts to a draft release for review",
+	Long: `stage runs the release pipeline, but creates the release as a draft and records what was uploaded
+in a staging manifest. Review and publish the draft with the publish subcommand.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			return fmt.Errorf("tag is required")
+		}
+
+		// The staged draft is published once reviewed, rather than at a time
+		if publishAt != "" {
+			return fmt.Errorf("publishAt cannot be used with stage; publish the draft with the publish subcommand")
+		}
+
+		draft = true
+		if stagingManifestPath == "" {
+			stagingManifestPath = defaultStagingManifestPath(tag)
+		}
+
+		return run()
+	},
+}
+
+// publishCmd compares a staged draft against its staging manifest, and publishes it
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Review and publish a draft release",
+	Long: `publish shows the differences between a staged draft release and the assets and notes recorded in
+its staging manifest, and publishes the draft once confirmed. Without a staging manifest, the draft
+release of the tag is shown and published as it is. With --final, a prerelease is published as a final
+release.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tag == "" {
+			return fmt.Errorf("tag is required")
+		}
+
+		// Only the default manifest may be missing, for drafts that weren't staged
+		requireManifest := stagingManifestPath != ""
+		if stagingManifestPath == "" {
+			stagingManifestPath = defaultStagingManifestPath(tag)
+		}
+
+		return publish(requireManifest)
+	},
+}
+
+// publishFinal clears the prerelease flag of the draft as it is published
+var publishFinal bool
+
+func init() {
+	rootCmd.AddCommand(stageCmd)
+	rootCmd.AddCommand(publishCmd)
+
+	for _, c := range []*cobra.Command{stageCmd, publishCmd} {
+		c.Flags().StringVar(&stagingManifestPath, "stagingManifest", "", "path of the staging manifest (default is <tag>.staging.json)")
+	}
+	publishCmd.Flags().BoolVar(&publishFinal, "final", false, "publish a prerelease as a final release")
+}
+
+// defaultStagingManifestPath returns the staging manifest path for a tag
+func defaultStagingManifestPath(tag string) string {
+	return fmt.Sprintf("%s.staging.json", tag)
+}
+
+// writeStagingManifest records the staged draft and its uploaded assets
+func writeStagingManifest(path string, gURL *gitURL, r *release, artifacts []*artifact, uploaded []*asset, prov *provenance) error {
+	if r.ID == nil {
+		return errors.New("release has no ID")
+	}
+
+	manifest := stagingManifest{
+		Tag:        tag,
+		Repository: fmt.Sprintf("%s/%s", gURL.organization, gURL.repository),
+		ReleaseID:  *r.ID,
+		Provenance: prov,
+	}
+
+	if r.Body != nil {
+		manifest.Body = *r.Body
+	}
+
+	for i, a := range artifacts {
+		if i >= len(uploaded) {
+			break
+		}
+
+		digest, err := fileDigest(a.path)
+		if err != nil {
+			return err
+		}
+
+		manifest.Assets = append(manifest.Assets, stagedAsset{
+			Name:     a.name,
+			Size:     a.size,
+			SHA256:   digest,
+			Metadata: a.metadata,
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// readStagingManifest reads a staging manifest written by writeStagingManifest
+func readStagingManifest(path string) (*stagingManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest stagingManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// diffStagedRelease compares the staged manifest with the draft release, and
+// returns a line for every difference found
+func diffStagedRelease(manifest *stagingManifest, r *release) []string {
+	var diff []string
+
+	published := make(map[string]*asset)
+	for _, a := range r.Assets {
+		if a.Name != nil {
+			published[*a.Name] = a
+		}
+	}
+
+	staged := make(map[string]bool)
+	for _, s := range manifest.Assets {
+		staged[s.Name] = true
+
+		a, ok := published[s.Name]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("- %s: staged but missing from the draft", s.Name))
+		case a.Size != nil && int64(*a.Size) != s.Size:
+			diff = append(diff, fmt.Sprintf("~ %s: staged %s, draft has %s", s.Name, formatSize(s.Size), formatSize(int64(*a.Size))))
+		case a.State != nil && *a.State != "uploaded":
+			diff = append(diff, fmt.Sprintf("~ %s: draft asset is in state %q", s.Name, *a.State))
+		}
+	}
+
+	for name := range published {
+		if !staged[name] {
+			diff = append(diff, fmt.Sprintf("+ %s: on the draft but was not staged", name))
+		}
+	}
+
+	var body string
+	if r.Body != nil {
+		body = *r.Body
+	}
+	if body != manifest.Body {
+		diff = append(diff, fmt.Sprintf("~ notes changed:\n--- staged\n%s\n+++ draft\n%s", manifest.Body, body))
+	}
+
+	return diff
+}
+
+func publish(requireManifest bool) error {
+	manifest, err := readStagingManifest(stagingManifestPath)
+	if err != nil && (requireManifest || !errors.Is(err, os.ErrNotExist)) {
+		return fmt.Errorf("failed reading staging manifest: %w", err)
+	}
+
+	if manifest != nil && manifest.Tag != tag {
+		return fmt.Errorf("staging manifest %s is for tag %s, not %s", stagingManifestPath, manifest.Tag, tag)
+	}
+
+	gURL, err := parseGitURL(repositoryURL)
+	if err != nil {
+		return err
+	}
+
+	auth, err := authenticate()
+	if err != nil {
+		return err
+	}
+
+	all, err := fetchReleases(auth, gURL)
+	if err != nil {
+		return fmt.Errorf("failed retrieving list of releases: %w", err)
+	}
+
+	var r *release
+	var artifacts []*artifact
+	if manifest != nil {
+		r, err = reviewStagedRelease(auth, gURL, manifest)
+		if err != nil {
+			return err
+		}
+
+		// The staged assets are no longer on disk, so the links are built from their names
+		for _, s := range manifest.Assets {
+			artifacts = append(artifacts, &artifact{name: s.Name, size: s.Size})
+		}
+	} else {
+		r, err = reviewDraftRelease(auth, gURL, all)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range r.Assets {
+			if a.Name != nil && a.Size != nil {
+				artifacts = append(artifacts, &artifact{name: *a.Name, size: int64(*a.Size)})
+			}
+		}
+	}
+
+	// Publishing a final release may require its prereleases to have soaked first
+	if publishFinal || r.Prerelease == nil || !*r.Prerelease {
+		if err = checkSoakPolicy(all, tag, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if !confirm("Publish this release?") {
+		return fmt.Errorf("publish %w", errHalted)
+	}
+
+	published, prerelease := false, false
+	update := &releaseUpdateRequest{Draft: &published, MakeLatest: markLatest}
+	if publishFinal {
+		update.Prerelease = &prerelease
+	}
+	r, err = updateRelease(auth, gURL, *r.ID, update)
+	if err != nil {
+		return fmt.Errorf("failed publishing release: %w", err)
+	}
+
+	summary := &releaseSummary{tag: tag}
+	if r.HTMLURL != nil {
+		summary.releaseURL = *r.HTMLURL
+	}
+
+	err = generateLinks(summary, gURL, artifacts)
+	if err != nil {
+		return err
+	}
+
+	summary.print()
+
+	return nil
+}
+
+// reviewStagedRelease retrieves the staged draft, and shows how it differs from its
+// staging manifest
+func reviewStagedRelease(auth *UserAuth, gURL *gitURL, manifest *stagingManifest) (*release, error) {
+	r, err := getRelease(auth, gURL, manifest.ReleaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving staged release: %w", err)
+	}
+
+	if r.Draft == nil || !*r.Draft {
+		return nil, fmt.Errorf("release %s is not a draft", tag)
+	}
+
+	fmt.Printf("Staged release %s (%d assets)\n", tag, len(manifest.Assets))
+	for _, s := range manifest.Assets {
+		fmt.Printf("\t%s %s %s\n", s.SHA256, formatSize(s.Size), s.Name)
+	}
+
+	diff := diffStagedRelease(manifest, r)
+	if len(diff) == 0 {
+		fmt.Println("The draft matches the staging manifest")
+	} else {
+		fmt.Println("The draft differs from the staging manifest:")
+		for _, d := range diff {
+			fmt.Printf("\t%s\n", d)
+		}
+	}
+
+	return r, nil
+}
+
+// reviewDraftRelease finds the draft release of the tag, which wasn't staged, and shows
+// its assets
+func reviewDraftRelease(auth *UserAuth, gURL *gitURL, all *releases) (*release, error) {
+	r, err := findRelease(providerFor(gURL), auth, gURL, all, tag)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("no release found for tag %s on %s/%s", tag, gURL.organization, gURL.repository)
+	}
+	if r.Draft == nil || !*r.Draft {
+		return nil, fmt.Errorf("release %s is not a draft", tag)
+	}
+
+	fmt.Printf("Draft release %s (%d assets)\n", tag, len(r.Assets))
+	for _, a := range r.Assets {
+		if a.Name != nil && a.Size != nil {
+			fmt.Printf("\t%s %s\n", formatSize(int64(*a.Size)), *a.Name)
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestPublishDraftWithoutManifest checks a draft that wasn't staged is found by its tag and
+// published, with --final clearing the prerelease flag
+func TestPublishDraftWithoutManifest(t *testing.T) {
+	defer gock.Off()
+	defer unsetTokenEnv()()
+	defer func(url, path, tg string, final, f bool) {
+		repositoryURL, stagingManifestPath, tag, publishFinal, force, token, cachedAuth = url, path, tg, final, f, "", nil
+	}(repositoryURL, stagingManifestPath, tag, publishFinal, force)
+	repositoryURL, stagingManifestPath, tag = "https://github.com/o/r.git", "missing.staging.json", "v1.0.0"
+	publishFinal, force, token = true, true, "secret"
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{"id": 2, "tag_name": "v1.0.0-rc.1", "draft": false},
+			{"id": 1, "tag_name": "v1.0.0", "draft": true, "prerelease": true,
+				"assets": []map[string]interface{}{{"name": "app-linux", "size": 6}}},
+		})
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/tags/v1.0.0").
+		Reply(404)
+	gock.New("https://api.github.com").
+		Patch("/repos/o/r/releases/1").
+		JSON(map[string]bool{"draft": false, "prerelease": false}).
+		Reply(200).
+		JSON(map[string]interface{}{"id": 1, "tag_name": "v1.0.0", "html_url": "https://github.com/o/r/releases/tag/v1.0.0"})
+
+	Nil(t, publish(false))
+	True(t, gock.IsDone())
+
+	// A manifest that was asked for must exist
+	NotNil(t, publish(true))
+}
+
+// TestPublishFinalSoak checks --final enforces the soak policy before the prerelease flag is cleared
+func TestPublishFinalSoak(t *testing.T) {
+	defer gock.Off()
+	defer unsetTokenEnv()()
+	defer func(url, path, tg string, final, f bool, days int) {
+		repositoryURL, stagingManifestPath, tag, publishFinal, force, minSoakDays, token, cachedAuth = url, path, tg, final, f, days, "", nil
+	}(repositoryURL, stagingManifestPath, tag, publishFinal, force, minSoakDays)
+	repositoryURL, stagingManifestPath, tag = "https://github.com/o/r.git", "missing.staging.json", "v1.0.0"
+	publishFinal, force, token, minSoakDays = true, true, "secret", 7
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases").
+		Reply(200).
+		JSON([]map[string]interface{}{
+			{"id": 2, "tag_name": "v1.0.0-rc.1", "draft": false, "published_at": time.Now().Add(-24 * time.Hour).Format(time.RFC3339)},
+			{"id": 1, "tag_name": "v1.0.0", "draft": true, "prerelease": true},
+		})
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/releases/tags/v1.0.0").
+		Reply(404)
+
+	err := publish(false)
+	if NotNil(t, err) {
+		Contains(t, err.Error(), "soak policy: prereleases of 1.0.0 have been available for 1.0 days, 7 are required")
+	}
+	True(t, gock.IsDone(), "the release isn't published")
+}
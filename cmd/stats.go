@@ -0,0 +1,171 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// statsOutput is the format stats writes the download counts in, table, csv or json
+var statsOutput string
+
+// statsCmd prints the download counts of the repository's release assets
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the download counts of the repository's releases",
+	Long: `stats adds up the download counts of the assets of the repository's published releases, newest first, and
+prints the total of each release and of each of its assets, as a table, or with --output csv or json, for tracking
+adoption over time. With --tag, only that release is counted. Drafts have no downloads, so aren't listed.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsOutput != "table" && statsOutput != "csv" && statsOutput != "json" {
+			return fmt.Errorf("unknown --output %s, must be table, csv or json", statsOutput)
+		}
+
+		repoURL := repositoryURL
+		if upstreamRepositoryURL != "" {
+			repoURL = upstreamRepositoryURL
+		}
+		gURL, err := parseGitURL(repoURL)
+		if err != nil {
+			return err
+		}
+		if _, ok := providerFor(gURL).(*githubProvider); !ok {
+			return fmt.Errorf("stats only supports Github releases")
+		}
+
+		// Private repositories' releases need a token, but counting shouldn't run the device flow
+		auth, _, err := existingAuth()
+		if err != nil {
+			return err
+		}
+		all, err := repositoryReleases(auth, gURL)
+		if err != nil {
+			return fmt.Errorf("failed retrieving list of releases: %w", err)
+		}
+
+		stats := downloadStatsFor(all, tag)
+		if tag != "" && len(stats.Releases) == 0 {
+			return fmt.Errorf("no published release found for tag %s", tag)
+		}
+
+		return writeDownloadStats(os.Stdout, stats, statsOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().StringVar(&statsOutput, "output", "table", "format to write the download counts in: table, csv or json")
+}
+
+// downloadStats are the download counts of the releases, and their total
+type downloadStats struct {
+	Downloads int             `json:"downloads"`
+	Releases  []releaseCounts `json:"releases"`
+}
+
+// releaseCounts are the download counts of a release's assets, and their total
+type releaseCounts struct {
+	Tag       string        `json:"tag"`
+	Date      string        `json:"date,omitempty"`
+	Downloads int           `json:"downloads"`
+	Assets    []assetCounts `json:"assets"`
+}
+
+// assetCounts is the download count of a release asset
+type assetCounts struct {
+	Name      string `json:"name"`
+	Downloads int    `json:"downloads"`
+}
+
+// downloadStatsFor adds up the download counts of the published releases, in the order
+// they're listed, or only of the release of the tag, if not empty
+func downloadStatsFor(all *releases, tag string) *downloadStats {
+	stats := &downloadStats{Releases: []releaseCounts{}}
+	for i := range *all {
+		r := &(*all)[i]
+		if r.TagName == nil || (r.Draft != nil && *r.Draft) || (tag != "" && *r.TagName != tag) {
+			continue
+		}
+
+		counts := releaseCounts{Tag: *r.TagName, Assets: []assetCounts{}}
+		if r.PublishedAt != nil {
+			counts.Date = *r.PublishedAt
+		}
+		for _, a := range r.Assets {
+			if a.Name == nil {
+				continue
+			}
+			c := assetCounts{Name: *a.Name}
+			if a.DownloadCount != nil {
+				c.Downloads = *a.DownloadCount
+			}
+			counts.Assets = append(counts.Assets, c)
+			counts.Downloads += c.Downloads
+		}
+
+		stats.Releases = append(stats.Releases, counts)
+		stats.Downloads += counts.Downloads
+	}
+
+	return stats
+}
+
+// writeDownloadStats writes the download counts as a table of each release's total
+// followed by its assets, as CSV with a row for each asset, or as JSON
+func writeDownloadStats(w io.Writer, stats *downloadStats, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"tag", "date", "asset", "downloads"}); err != nil {
+			return err
+		}
+		for _, r := range stats.Releases {
+			for _, a := range r.Assets {
+				if err := cw.Write([]string{r.Tag, r.Date, a.Name, strconv.Itoa(a.Downloads)}); err != nil {
+					return err
+				}
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tASSET\tDOWNLOADS")
+	for _, r := range stats.Releases {
+		fmt.Fprintf(tw, "%s\t\t%d\n", r.Tag, r.Downloads)
+		for _, a := range r.Assets {
+			fmt.Fprintf(tw, "\t%s\t%d\n", a.Name, a.Downloads)
+		}
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\n", stats.Downloads)
+	return tw.Flush()
+}
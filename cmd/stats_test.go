@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestDownloadStats checks the download counts are added up by release, leaving out drafts
+func TestDownloadStats(t *testing.T) {
+	tags, date := []string{"v1.2.0", "v1.1.0", "v1.0.0"}, "2021-02-01T10:00:00Z"
+	draft := true
+	linux, darwin, sums := "app-linux", "app-darwin", "SHA256SUMS"
+	counts := []int{10, 2, 0, 7}
+	all := &releases{
+		{TagName: &tags[0], Draft: &draft},
+		{TagName: &tags[1], PublishedAt: &date, Assets: []*asset{
+			{Name: &linux, DownloadCount: &counts[0]},
+			{Name: &darwin, DownloadCount: &counts[1]},
+			{Name: &sums, DownloadCount: &counts[2]},
+		}},
+		{TagName: &tags[2], Assets: []*asset{{Name: &linux, DownloadCount: &counts[3]}}},
+	}
+
+	stats := downloadStatsFor(all, "")
+	Equal(t, 19, stats.Downloads)
+	Len(t, stats.Releases, 2)
+	Equal(t, 12, stats.Releases[0].Downloads)
+
+	var out bytes.Buffer
+	Nil(t, writeDownloadStats(&out, stats, "table"))
+	Equal(t, "TAG     ASSET       DOWNLOADS\n"+
+		"v1.1.0              12\n"+
+		"        app-linux   10\n"+
+		"        app-darwin  2\n"+
+		"        SHA256SUMS  0\n"+
+		"v1.0.0              7\n"+
+		"        app-linux   7\n"+
+		"TOTAL               19\n", out.String())
+
+	out.Reset()
+	Nil(t, writeDownloadStats(&out, stats, "csv"))
+	Equal(t, "tag,date,asset,downloads\n"+
+		"v1.1.0,2021-02-01T10:00:00Z,app-linux,10\n"+
+		"v1.1.0,2021-02-01T10:00:00Z,app-darwin,2\n"+
+		"v1.1.0,2021-02-01T10:00:00Z,SHA256SUMS,0\n"+
+		"v1.0.0,,app-linux,7\n", out.String())
+
+	out.Reset()
+	Nil(t, writeDownloadStats(&out, downloadStatsFor(all, "v1.0.0"), "json"))
+	Contains(t, out.String(), `"downloads": 7,
+  "releases": [
+    {
+      "tag": "v1.0.0",`)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// versionSuite is a pattern of the names of the organization's repositories that are
+// released in step with this one, eg: "platform-*"
+var versionSuite string
+
+// suiteSearchInterval spaces the search requests, which Github allows 30 a minute of
+const suiteSearchInterval = 2 * time.Second
+
+// suiteRequestInterval spaces the requests for the suite's releases, so a large suite
+// doesn't trip the secondary rate limits
+var suiteRequestInterval = 250 * time.Millisecond
+
+// maxSuiteRateLimitRetries is how many times a rate limited request is retried
+const maxSuiteRateLimitRetries = 3
+
+// rateLimiter spaces requests at least interval apart, and waits out the rate limits Github
+// responds with
+type rateLimiter struct {
+	interval time.Duration
+	last     time.Time
+}
+
+// rateLimited returns the wait the response asks for if it is a rate limit, primary or
+// secondary, and whether it is one
+func rateLimited(err error) (time.Duration, bool) {
+	var e *httpError
+	if !errors.As(err, &e) || (e.StatusCode != 403 && e.StatusCode != 429) {
+		return 0, false
+	}
+	if e.RetryAfter > 0 {
+		return e.RetryAfter, true
+	}
+	if strings.Contains(strings.ToLower(string(e.Body)), "rate limit") {
+		return time.Minute, true
+	}
+	return 0, false
+}
+
+// do makes the request once the interval has passed since the last one, retrying it after
+// the wait a rate limit asks for
+func (l *rateLimiter) do(req *http.Request) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		if wait := l.interval - time.Since(l.last); !l.last.IsZero() && wait > 0 {
+			sleep(wait)
+		}
+		l.last = time.Now()
+
+		body, err := makeHTTPRequest(req)
+		wait, ok := rateLimited(err)
+		if !ok || attempt == maxSuiteRateLimitRetries {
+			return body, err
+		}
+
+		fmt.Printf("Rate limited by Github; retrying in %s\n", wait.Round(time.Second))
+		sleep(wait)
+	}
+}
+
+// suiteSearchQuery returns the repository search for the pattern: the repositories of the
+// organization named with its literal prefix, which are then matched with the pattern
+func suiteSearchQuery(organization, pattern string) string {
+	query := fmt.Sprintf("org:%s archived:false", organization)
+	if prefix := strings.TrimSpace(pattern[:strings.IndexAny(pattern+"*", "*?[")]); prefix != "" {
+		query += fmt.Sprintf(" %s in:name", prefix)
+	}
+	return query
+}
+
+// searchSuiteRepositories returns the names of the organization's repositories matching
+// the pattern. Only the first 100 search results are matched.
+func searchSuiteRepositories(auth *UserAuth, limiter *rateLimiter, organization, pattern string) ([]string, error) {
+	query := url.Values{"q": {suiteSearchQuery(organization, pattern)}, "per_page": {"100"}}
+	req, err := newGetRequest(githubAPIURL("search/repositories?"+query.Encode()), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	body, err := limiter.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var results struct {
+		TotalCount int `json:"total_count"`
+		Items      []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(body, &results); err != nil {
+		return nil, err
+	}
+	if results.TotalCount > len(results.Items) {
+		fmt.Printf("WARNING: only the first %d of %d repositories found for the version suite are checked\n", len(results.Items), results.TotalCount)
+	}
+
+	var names []string
+	for _, item := range results.Items {
+		if ok, _ := path.Match(pattern, item.Name); ok {
+			names = append(names, item.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// latestSuiteRelease returns the tag of the repository's latest release, or "" if it has none
+func latestSuiteRelease(auth *UserAuth, limiter *rateLimiter, gURL *gitURL) (string, error) {
+	req, err := newGetRequest(githubRepoURL(gURL, "releases/latest"), url.Values{})
+	if err != nil {
+		return "", err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	body, err := limiter.do(req)
+	if isHTTPStatus(err, 404) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var r release
+	if err = json.Unmarshal(body, &r); err != nil {
+		return "", err
+	}
+	if r.TagName == nil {
+		return "", nil
+	}
+
+	return *r.TagName, nil
+}
+
+// sameSuiteVersion returns true if the tags are of the same version, ignoring a "v" prefix
+// and build metadata, or are the same tag
+func sameSuiteVersion(a, b string) bool {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return va.compare(vb) == 0
+}
+
+// suiteMismatches returns a description of each repository of the suite whose latest
+// release isn't of the tag's version
+func suiteMismatches(auth *UserAuth, gURL *gitURL, pattern, tag string) ([]string, error) {
+	limiter := &rateLimiter{interval: suiteSearchInterval}
+	names, err := searchSuiteRepositories(auth, limiter, gURL.organization, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter.interval = suiteRequestInterval
+	var mismatches []string
+	for _, name := range names {
+		if strings.EqualFold(name, gURL.repository) {
+			continue
+		}
+
+		latest, err := latestSuiteRelease(auth, limiter, &gitURL{organization: gURL.organization, repository: name})
+		if err != nil {
+			return nil, fmt.Errorf("failed retrieving the latest release of %s/%s: %w", gURL.organization, name, err)
+		}
+
+		switch {
+		case latest == "":
+			mismatches = append(mismatches, fmt.Sprintf("%s/%s has no releases", gURL.organization, name))
+		case !sameSuiteVersion(latest, tag):
+			mismatches = append(mismatches, fmt.Sprintf("%s/%s is at %s", gURL.organization, name, latest))
+		}
+	}
+
+	return mismatches, nil
+}
+
+// checkVersionSuite warns about the repositories of the version suite that aren't released
+// at the tag's version. It only warns, as the suite's releases are made one at a time; a
+// failed check is warned about too, and doesn't stop the release.
+func checkVersionSuite(auth *UserAuth, gURL *gitURL, tag string) {
+	if versionSuite == "" {
+		return
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Checking the versions of the %s repositories of %s", versionSuite, gURL.organization))
+	}
+
+	mismatches, err := suiteMismatches(auth, gURL, versionSuite, tag)
+	if err != nil {
+		fmt.Printf("WARNING: the version suite wasn't checked: %s\n", err)
+		return
+	}
+
+	for _, m := range mismatches {
+		fmt.Printf("WARNING: version suite mismatch, releasing %s but %s\n", tag, m)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestSuiteSearchQuery checks the repositories are searched for by the pattern's literal prefix
+func TestSuiteSearchQuery(t *testing.T) {
+	Equal(t, "org:o archived:false platform- in:name", suiteSearchQuery("o", "platform-*"))
+	Equal(t, "org:o archived:false", suiteSearchQuery("o", "*-service"))
+	Equal(t, "org:o archived:false api in:name", suiteSearchQuery("o", "api"))
+}
+
+// TestSuiteMismatches checks the suite's repositories at other versions, or with no releases, are found
+func TestSuiteMismatches(t *testing.T) {
+	defer gock.Off()
+	defer func(f func(time.Duration)) { sleep = f }(sleep)
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	gock.New("https://api.github.com").
+		Get("/search/repositories").
+		MatchParam("q", "org:o archived:false platform- in:name").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"total_count": 5,
+			"items": []map[string]string{
+				{"name": "platform-api"}, {"name": "platform-web"}, {"name": "platform-cli"}, {"name": "platform-new"}, {"name": "platform"},
+			},
+		})
+	// A secondary rate limit is waited out
+	gock.New("https://api.github.com").
+		Get("/repos/o/platform-web/releases/latest").
+		Reply(403).
+		SetHeader("Retry-After", "30").
+		JSON(map[string]string{"message": "You have exceeded a secondary rate limit"})
+	gock.New("https://api.github.com").
+		Get("/repos/o/platform-web/releases/latest").
+		Reply(200).
+		JSON(map[string]string{"tag_name": "1.2.0"})
+	gock.New("https://api.github.com").
+		Get("/repos/o/platform-cli/releases/latest").
+		Reply(200).
+		JSON(map[string]string{"tag_name": "v1.1.3"})
+	gock.New("https://api.github.com").
+		Get("/repos/o/platform-new/releases/latest").
+		Reply(404)
+
+	gURL := &gitURL{organization: "o", repository: "platform-api"}
+	mismatches, err := suiteMismatches(&UserAuth{AccessToken: "secret", TokenType: "token"}, gURL, "platform-*", "v1.2.0")
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Equal(t, []string{"o/platform-cli is at v1.1.3", "o/platform-new has no releases"}, mismatches)
+	Contains(t, waits, 30*time.Second)
+	True(t, gock.IsDone())
+}
+
+// TestRateLimiter checks requests are spaced by the interval
+func TestRateLimiter(t *testing.T) {
+	defer gock.Off()
+	defer func(f func(time.Duration)) { sleep = f }(sleep)
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	gock.New("https://api.github.com").
+		Get("/rate").
+		Times(2).
+		Reply(200).
+		BodyString("{}")
+
+	limiter := &rateLimiter{interval: time.Hour}
+	for i := 0; i < 2; i++ {
+		req, err := newGetRequest("https://api.github.com/rate", nil)
+		Nil(t, err)
+		body, err := limiter.do(req)
+		Nil(t, err)
+		True(t, bytes.Equal([]byte("{}"), body))
+	}
+	if Len(t, waits, 1) {
+		True(t, waits[0] > 59*time.Minute)
+	}
+}
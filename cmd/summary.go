@@ -0,0 +1,132 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// releaseSummary collects the details reported to the user once a release is complete
+type releaseSummary struct {
+	tag     string
+	version string
+	// state is draft, prerelease or published
+	state               string
+	aliases             []string
+	releaseURL          string
+	discussionURL       string
+	notificationURL     string
+	stagingManifestPath string
+	sizes               []assetSizeChange
+	links               *releaseLinks
+	linksFile           string
+	// scheduledAt is when the draft is published by the watch daemon, if it is scheduled
+	scheduledAt time.Time
+}
+
+// print writes the summary to stdout
+func (s *releaseSummary) print() {
+	fmt.Printf("\nRelease summary for %s\n", s.tag)
+
+	if s.version != "" && s.version != s.tag {
+		fmt.Printf("\tVersion: %s\n", s.version)
+	}
+
+	if len(s.aliases) > 0 {
+		fmt.Printf("\tAlias tags: %s\n", strings.Join(s.aliases, ", "))
+	}
+
+	if s.state != "" {
+		fmt.Printf("\tState: %s\n", s.state)
+	}
+
+	if s.releaseURL != "" {
+		fmt.Printf("\tURL: %s\n", s.releaseURL)
+	}
+
+	if s.discussionURL != "" {
+		fmt.Printf("\tDiscussion: %s\n", s.discussionURL)
+	}
+
+	if s.stagingManifestPath != "" {
+		fmt.Printf("\tStaged as a draft; review and publish with: go-git-release publish --tag %s\n", s.tag)
+		fmt.Printf("\tStaging manifest: %s\n", s.stagingManifestPath)
+	}
+
+	if !s.scheduledAt.IsZero() {
+		fmt.Printf("\tDraft until %s, when go-git-release watch --publishScheduled publishes it\n", s.scheduledAt.Format(time.RFC3339))
+	}
+
+	if s.notificationURL != "" {
+		fmt.Printf("\tOwners notified: %s\n", s.notificationURL)
+	}
+
+	if len(s.sizes) > 0 {
+		fmt.Println("\tAsset sizes (compared to the previous release):")
+		for _, c := range s.sizes {
+			fmt.Printf("\t\t%s\n", c)
+		}
+	}
+
+	if s.links != nil {
+		fmt.Printf("\tBadge: %s\n", s.links.badge)
+		if len(s.links.downloads) > 0 {
+			fmt.Println("\tLatest download links:")
+			for _, d := range s.links.downloads {
+				fmt.Printf("\t\t%s\n", d)
+			}
+		}
+	}
+
+	if s.linksFile != "" {
+		fmt.Printf("\tLinks written to: %s\n", s.linksFile)
+	}
+}
+
+// releaseState describes whether the release is a draft, a prerelease or published
+func releaseState(r *release) string {
+	switch {
+	case r.Draft != nil && *r.Draft:
+		return "draft"
+	case r.Prerelease != nil && *r.Prerelease:
+		return "prerelease"
+	}
+	return "published"
+}
+
+// previousRelease returns the most recent published release that is not for the
+// provided tag, or nil if there isn't one. Github lists releases newest first.
+func previousRelease(releasesList *releases, tag string) *release {
+	if releasesList == nil {
+		return nil
+	}
+
+	for i, r := range *releasesList {
+		if r.Draft != nil && *r.Draft {
+			continue
+		}
+		if r.TagName != nil && *r.TagName == tag {
+			continue
+		}
+		return &(*releasesList)[i]
+	}
+
+	return nil
+}
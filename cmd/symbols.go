@@ -0,0 +1,120 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// processDebugSymbols strips the symbols from each binary artifact, keeping an
+// unstripped copy. The unstripped copy is either added to the returned artifacts as
+// a "<name>.debug.tar.gz" archive, or passed to the symbolHook command if one is set.
+// If stripSymbols is not enabled the artifacts are returned unchanged.
+func processDebugSymbols(artifacts []*artifact, modTime time.Time) ([]*artifact, error) {
+	if !stripSymbols {
+		return artifacts, nil
+	}
+
+	processed := make([]*artifact, 0, len(artifacts))
+
+	for _, a := range artifacts {
+		processed = append(processed, a)
+
+		if !isBinary(a.path) {
+			continue
+		}
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Stripping debug symbols from %s", a.name))
+		}
+
+		debugPath := a.path + ".debug"
+		if err := copyFile(a.path, debugPath); err != nil {
+			return nil, fmt.Errorf("failed copying %s: %w", a.name, err)
+		}
+		defer os.Remove(debugPath)
+
+		if err := runCommand(stripCommand, a.path); err != nil {
+			return nil, fmt.Errorf("failed stripping %s: %w", a.name, err)
+		}
+
+		info, err := os.Stat(a.path)
+		if err != nil {
+			return nil, err
+		}
+		a.size = info.Size()
+
+		if symbolHook != "" {
+			if verbose {
+				noteInfo(fmt.Sprintf("Passing debug symbols for %s to %s", a.name, symbolHook))
+			}
+			if err := runCommand(symbolHook, debugPath); err != nil {
+				return nil, fmt.Errorf("symbol hook failed for %s: %w", a.name, err)
+			}
+			continue
+		}
+
+		archivePath := a.path + ".debug.tar.gz"
+		err = writeTarGz(archivePath, []archiveFile{{path: debugPath, name: a.name + ".debug"}}, modTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed archiving debug symbols for %s: %w", a.name, err)
+		}
+
+		info, err = os.Stat(archivePath)
+		if err != nil {
+			return nil, err
+		}
+
+		processed = append(processed, &artifact{
+			path: archivePath,
+			name: a.name + ".debug.tar.gz",
+			size: info.Size(),
+		})
+	}
+
+	return processed, nil
+}
+
+// copyFile copies the file at src to dst, preserving the file mode
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestProcessDebugSymbols strips a copy of the test binary, as a real binary, with stand-in strip and hook commands
+func TestProcessDebugSymbols(t *testing.T) {
+	defer func(s bool, c, h string) { stripSymbols, stripCommand, symbolHook = s, c, h }(stripSymbols, stripCommand, symbolHook)
+
+	exe, err := os.Executable()
+	Nil(t, err)
+	if _, ok := binaryPlatform(exe); !ok {
+		t.Skip("test binary is not a binary artifact")
+	}
+
+	processDebugSymbolsTests := []struct {
+		name         string
+		stripSymbols bool
+		stripCommand string
+		symbolHook   string
+		expected     []string
+		archived     bool
+		err          bool
+	}{
+		{name: "disabled", expected: []string{"app", "notes.txt"}},
+		{name: "debug archive", stripSymbols: true, stripCommand: "true", expected: []string{"app", "app.debug.tar.gz", "notes.txt"}, archived: true},
+		{name: "symbol hook", stripSymbols: true, stripCommand: "true", symbolHook: "true", expected: []string{"app", "notes.txt"}},
+		{name: "strip fails", stripSymbols: true, stripCommand: "false", err: true},
+		{name: "symbol hook fails", stripSymbols: true, stripCommand: "true", symbolHook: "false", err: true},
+	}
+	for _, testSpec := range processDebugSymbolsTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			stripSymbols, stripCommand, symbolHook = testSpec.stripSymbols, testSpec.stripCommand, testSpec.symbolHook
+
+			dir := t.TempDir()
+			app := filepath.Join(dir, "app")
+			Nil(t, copyFile(exe, app))
+			notes := filepath.Join(dir, "notes.txt")
+			Nil(t, ioutil.WriteFile(notes, []byte("notes\n"), 0644))
+
+			processed, err := processDebugSymbols([]*artifact{{path: app, name: "app"}, {path: notes, name: "notes.txt"}}, time.Time{})
+			if testSpec.err {
+				NotNil(t, err)
+				return
+			}
+			Nil(t, err)
+
+			var names []string
+			for _, a := range processed {
+				names = append(names, a.name)
+			}
+			Equal(t, testSpec.expected, names)
+			if testSpec.archived {
+				Equal(t, []string{"app.debug"}, tarNames(t, processed[1].path))
+			}
+
+			// The unstripped copy is only kept in the archive
+			_, err = os.Stat(app + ".debug")
+			True(t, os.IsNotExist(err))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// gitTagRequest is the payload creating an annotated tag object
+// https://docs.github.com/en/rest/git/tags#create-a-tag-object
+type gitTagRequest struct {
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+	Object  string `json:"object"`
+	Type    string `json:"type"`
+	Tagger  struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Date  string `json:"date"`
+	} `json:"tagger"`
+}
+
+// gitRefRequest is the payload creating a reference
+// https://docs.github.com/en/rest/git/refs#create-a-reference
+type gitRefRequest struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+// pushRejected returns true if the push failed because the remote refused it, eg: for
+// missing permissions or a protected tag, rather than because it couldn't be reached
+func pushRejected(err error) bool {
+	return errors.Is(err, transport.ErrAuthorizationFailed) ||
+		errors.Is(err, transport.ErrAuthenticationRequired) ||
+		strings.Contains(err.Error(), "unable to authenticate") ||
+		strings.Contains(err.Error(), "command error on refs/tags/")
+}
+
+// createTagWithAPI creates the tag on Github with its refs API, with --tagWithAPI and once
+// confirmed, when pushing it was rejected but the token may still have the rights to. A
+// rejection may be a protected tag doing its job, so --force alone never bypasses it. The
+// tag object is recreated from the local one, as the API can't take it as it is. The push
+// error is returned for other forges, or if the fallback isn't enabled or is declined.
+func createTagWithAPI(repo *git.Repository, pushErr error) error {
+	u, err := remoteURL(repo, remote)
+	if err != nil {
+		return pushErr
+	}
+	gURL, err := parseGitURL(u)
+	if err != nil {
+		return pushErr
+	}
+	if _, ok := providerFor(gURL).(*githubProvider); !ok {
+		return pushErr
+	}
+
+	fmt.Printf("Pushing tag %s was rejected: %s\n", tag, pushErr)
+	if !tagWithAPI {
+		fmt.Println("Use --tagWithAPI to create it with the Github API instead")
+		return pushErr
+	}
+	if !confirm("Create the tag with the Github API instead?") {
+		return pushErr
+	}
+
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		return err
+	}
+	tagObj, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		return fmt.Errorf("tag %s is not annotated: %w", tag, err)
+	}
+
+	auth, err := authenticate()
+	if err != nil {
+		return stageFailed(errAuth, err)
+	}
+
+	tagRequest := gitTagRequest{Tag: tag, Message: tagObj.Message, Object: tagObj.Target.String(), Type: "commit"}
+	tagRequest.Tagger.Name = tagObj.Tagger.Name
+	tagRequest.Tagger.Email = tagObj.Tagger.Email
+	tagRequest.Tagger.Date = tagObj.Tagger.When.UTC().Format(time.RFC3339)
+
+	req, err := newJSONPostRequest(githubRepoURL(gURL, "git/tags"), &tagRequest, authHeaders(auth))
+	if err != nil {
+		return err
+	}
+	body, err := makeHTTPRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed creating tag object: %w", err)
+	}
+
+	var created struct {
+		SHA string `json:"sha"`
+	}
+	if err = json.Unmarshal(body, &created); err != nil {
+		return err
+	}
+
+	req, err = newJSONPostRequest(githubRepoURL(gURL, "git/refs"), &gitRefRequest{Ref: ref.Name().String(), SHA: created.SHA}, authHeaders(auth))
+	if err != nil {
+		return err
+	}
+	if _, err = makeHTTPRequest(req); err != nil {
+		return fmt.Errorf("failed creating tag reference: %w", err)
+	}
+
+	fmt.Printf("Created tag %s with the Github API\n", tag)
+	return nil
+}
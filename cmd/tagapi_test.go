@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestPushRejected checks refused pushes are told apart from those that didn't reach the remote
+func TestPushRejected(t *testing.T) {
+	True(t, pushRejected(fmt.Errorf("push: %w", transport.ErrAuthorizationFailed)))
+	True(t, pushRejected(errors.New("command error on refs/tags/v1.0.0: protected ref")))
+	False(t, pushRejected(errors.New("dial tcp: connection refused")))
+}
+
+// TestCreateTagWithAPI checks the local tag is recreated on Github as a tag object and ref
+func TestCreateTagWithAPI(t *testing.T) {
+	defer gock.Off()
+	defer func(tg, r string, f, api bool) { tag, remote, force, tagWithAPI, cachedAuth = tg, r, f, api, nil }(tag, remote, force, tagWithAPI)
+	tag, remote, force, tagWithAPI = "v1.0.0", "origin", true, false
+	cachedAuth = &UserAuth{AccessToken: "secret", TokenType: "token"}
+
+	repo := gitfixture.New(t)
+	head := repo.CommitWith("first")
+	_, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"https://github.com/o/r.git"}})
+	Nil(t, err)
+	_, err = repo.CreateTag(tag, head.Hash, &git.CreateTagOptions{Tagger: &object.Signature{Name: "test", Email: "test@example.com", When: head.Author.When}, Message: "First release\n"})
+	Nil(t, err)
+
+	gock.New("https://api.github.com").
+		Post("/repos/o/r/git/tags").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"tag":     "v1.0.0",
+			"message": "First release\n",
+			"object":  head.Hash.String(),
+			"type":    "commit",
+			"tagger":  map[string]string{"name": "test", "email": "test@example.com", "date": "2021-01-01T00:01:00Z"},
+		}).
+		Reply(201).
+		JSON(map[string]string{"sha": "abc123"})
+	gock.New("https://api.github.com").
+		Post("/repos/o/r/git/refs").
+		JSON(map[string]string{"ref": "refs/tags/v1.0.0", "sha": "abc123"}).
+		Reply(201)
+
+	// --force alone doesn't bypass a protected tag
+	pushErr := errors.New("command error on refs/tags/v1.0.0: protected ref")
+	Equal(t, pushErr, createTagWithAPI(repo.Repository, pushErr))
+
+	tagWithAPI = true
+	Nil(t, createTagWithAPI(repo.Repository, pushErr))
+	True(t, gock.IsDone())
+
+	// Declining keeps the push error
+	defer func(n bool, d string) { nonInteractive, promptDefault = n, d }(nonInteractive, promptDefault)
+	force, nonInteractive, promptDefault = false, true, "no"
+	Equal(t, pushErr, createTagWithAPI(repo.Repository, pushErr))
+}
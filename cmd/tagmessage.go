@@ -0,0 +1,110 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// tagMessageVars are the values available to a tagMessageTemplate
+type tagMessageVars struct {
+	Tag         string
+	Version     string
+	PreviousTag string
+	Commit      string
+	Date        string
+	Shortlog    string
+}
+
+// loadTagMessageTemplate returns the contents of the file if the provided value is
+// the path to an existing file, otherwise the value itself is the template
+func loadTagMessageTemplate(value string) (string, error) {
+	info, err := os.Stat(value)
+	if err != nil || info.IsDir() {
+		return value, nil
+	}
+
+	data, err := ioutil.ReadFile(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// tagMessageValues collects the template values for the tag from the repository
+// history, with the shortlog covering the commits since the previous tag
+func tagMessageValues(repo *git.Repository, tag string, now time.Time) (*tagMessageVars, error) {
+	vars := &tagMessageVars{
+		Tag:     tag,
+		Version: strings.TrimPrefix(tag, "v"),
+		Date:    now.Format("2006-01-02"),
+	}
+
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+	vars.Commit = head.Hash.String()
+
+	prevTag, prevCommit, err := changelogBase(repo, head, tag)
+	if err != nil {
+		return nil, err
+	}
+	vars.PreviousTag = prevTag
+
+	commits, err := commitsBetween(repo, prevCommit, head)
+	if err != nil {
+		return nil, err
+	}
+	vars.Shortlog = shortlog(commits)
+
+	return vars, nil
+}
+
+// renderTagMessage renders the tag message template with the values for the tag
+func renderTagMessage(repo *git.Repository, tag, tmpl string) (string, error) {
+	source, err := loadTagMessageTemplate(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := template.New("tagMessageTemplate").Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := tagMessageValues(repo, tag, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err = t.Execute(&rendered, vars); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestLoadTagMessageTemplate checks the template is read from a file, or is the value itself
+func TestLoadTagMessageTemplate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tag-message.tmpl")
+	Nil(t, ioutil.WriteFile(file, []byte("Release {{.Tag}}\n"), 0644))
+
+	loadTagMessageTemplateTests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "file", value: file, expected: "Release {{.Tag}}\n"},
+		{name: "inline", value: "Release {{.Version}}", expected: "Release {{.Version}}"},
+		{name: "missing file", value: filepath.Join(dir, "missing.tmpl"), expected: filepath.Join(dir, "missing.tmpl")},
+		{name: "directory", value: dir, expected: dir},
+	}
+	for _, testSpec := range loadTagMessageTemplateTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			source, err := loadTagMessageTemplate(testSpec.value)
+			Nil(t, err)
+			Equal(t, testSpec.expected, source)
+		})
+	}
+}
+
+// TestTagMessageValues checks the values cover the history since the previous tag
+func TestTagMessageValues(t *testing.T) {
+	repo := gitfixture.New(t)
+	repo.Tag("v1.0.0", repo.Commit("first", nil))
+	repo.Commit("second", nil)
+	head := repo.Commit("third", nil)
+
+	vars, err := tagMessageValues(repo.Repository, "v1.1.0", time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC))
+	Nil(t, err)
+	Equal(t, &tagMessageVars{
+		Tag:         "v1.1.0",
+		Version:     "1.1.0",
+		PreviousTag: "v1.0.0",
+		Commit:      head.Hash.String(),
+		Date:        "2021-02-03",
+		Shortlog:    "test (2):\n      second\n      third",
+	}, vars)
+}
+
+// TestRenderTagMessage checks the template is rendered with the tag's values
+func TestRenderTagMessage(t *testing.T) {
+	repo := gitfixture.New(t)
+	repo.Tag("v1.0.0", repo.Commit("first", nil))
+	repo.Commit("second", nil)
+
+	renderTagMessageTests := []struct {
+		name     string
+		template string
+		expected string
+		err      bool
+	}{
+		{name: "tag and version", template: "{{.Tag}} is version {{.Version}}", expected: "v1.1.0 is version 1.1.0"},
+		{name: "shortlog", template: "Changes since {{.PreviousTag}}:\n\n{{.Shortlog}}", expected: "Changes since v1.0.0:\n\ntest (1):\n      second"},
+		{name: "invalid template", template: "{{.Tag", err: true},
+		{name: "unknown value", template: "{{.Branch}}", err: true},
+	}
+	for _, testSpec := range renderTagMessageTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			rendered, err := renderTagMessage(repo.Repository, "v1.1.0", testSpec.template)
+			if testSpec.err {
+				NotNil(t, err)
+				return
+			}
+			Nil(t, err)
+			Equal(t, testSpec.expected, rendered)
+		})
+	}
+}
@@ -18,18 +18,18 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 )
 
 func getTagFromString(tag string, repo *git.Repository) (*object.Tag, error) {
-	tagFoundError := "tag exists"
 	var tagObj *object.Tag
 
 	tags, err := repo.TagObjects()
@@ -41,14 +41,11 @@ func getTagFromString(tag string, repo *git.Repository) (*object.Tag, error) {
 		if t.Name == tag {
 			// User-provided tag found in repo already
 			tagObj = t
-			return fmt.Errorf(tagFoundError)
+			return storer.ErrStop
 		}
 		return nil
 	})
-
-	// If there are any errors that aren't specificially tagFoundErrors
-	// return, nothing and the error
-	if err != nil && err.Error() != tagFoundError {
+	if err != nil {
 		return nil, err
 	}
 
@@ -56,7 +53,41 @@ func getTagFromString(tag string, repo *git.Repository) (*object.Tag, error) {
 	return tagObj, nil
 }
 
-func setTag(repo *git.Repository, tag string, message string, tagger *object.Signature) (bool, error) {
+// existingTagCommit returns the commit the tag points at, or the zero hash if there is no
+// such tag. Lightweight tags have no tag object, but are released from their commit all
+// the same, eg: when the watcher finds one.
+func existingTagCommit(repo *git.Repository, tag string) (plumbing.Hash, error) {
+	tagObj, err := getTagFromString(tag, repo)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if tagObj != nil {
+		return tagObj.Target, nil
+	}
+
+	c, err := commitForTag(repo, tag)
+	if errors.Is(err, git.ErrTagNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return c.Hash, nil
+}
+
+// tagWriter creates tags of HEAD, as a *git.Repository does
+type tagWriter interface {
+	Head() (*plumbing.Reference, error)
+	CreateTag(name string, hash plumbing.Hash, opts *git.CreateTagOptions) (*plumbing.Reference, error)
+}
+
+// pusher pushes refs to a repository's remotes, as a *git.Repository does
+type pusher interface {
+	remoteFinder
+	Push(o *git.PushOptions) error
+}
+
+func setTag(repo tagWriter, tag string, message string, tagger *object.Signature) (bool, error) {
 
 	head, err := repo.Head()
 
@@ -104,8 +135,18 @@ func defaultSignature(name, email string) *object.Signature {
 	}
 }
 
-func pushTags(repo *git.Repository) error {
-	auth, err := ssh.NewSSHAgentAuth("git")
+func pushTags(repo pusher) error {
+	return pushRefSpecs(repo, []config.RefSpec{config.RefSpec("refs/tags/*:refs/tags/*")})
+}
+
+// pushRefSpecs pushes the refspecs to the remote
+func pushRefSpecs(repo pusher, refSpecs []config.RefSpec) error {
+	u, err := remoteURL(repo, remote)
+	if err != nil {
+		return err
+	}
+
+	auth, err := gitAuth(u)
 
 	if err != nil {
 		return err
@@ -114,7 +155,7 @@ func pushTags(repo *git.Repository) error {
 	pushOpts := &git.PushOptions{
 		RemoteName: remote,
 		Progress:   gitopts.progress,
-		RefSpecs:   []config.RefSpec{config.RefSpec("refs/tags/*:refs/tags/*")},
+		RefSpecs:   refSpecs,
 		Auth:       auth,
 	}
 
@@ -134,12 +175,19 @@ func pushTags(repo *git.Repository) error {
 	return nil
 }
 
-// stripComments removes lines beginning with a "#" from the input string
-func stripComments(s string) string {
-
-	r := regexp.MustCompile("(?m)^#.*")
-	return r.ReplaceAllString(s, "")
+// addUpstreamRemote adds the upstream repository as a remote named "upstream" and
+// makes it the remote tags are pushed to
+func addUpstreamRemote(repo *git.Repository, url string) error {
+	_, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: "upstream",
+		URLs: []string{url},
+	})
+	if err != nil {
+		return err
+	}
 
+	remote = "upstream"
+	return nil
 }
 
 // creates a tag a user-provided annotation
@@ -150,19 +198,45 @@ func createTag(repo *git.Repository) error {
 		return err
 	}
 
+	// Render the tag message template, if one was provided instead of a message
+	if tagMessage == "" && tagMessageTemplate != "" {
+		if verbose {
+			fmt.Println("Rendering tag message template")
+		}
+		tagMessage, err = renderTagMessage(repo, tag, tagMessageTemplate)
+		if err != nil {
+			return fmt.Errorf("failed rendering tag message template: %w", err)
+		}
+	}
+
+	commentChar, err := resolveCommentChar(repoConfig.Raw.Section("core").Option("commentChar"), tagMessage)
+	if err != nil {
+		return err
+	}
+
 	// Prompt for a tag annotation message if one was not provided
 	if tagMessage == "" {
 		if verbose {
 			fmt.Println("No tag message provided")
 		}
-		input, err := captureInputFromEditor(getPreferredEditorFromEnvironment)
+		if ciMode {
+			return fmt.Errorf("no tag message in --ci mode; provide --tagMessage or --tagMessageTemplate")
+		}
+		input, err := captureInputFromEditor(getPreferredEditorFromEnvironment, tagMessagePrompt(tagCleanup, commentChar))
 		if err != nil {
 			return err
 		}
 		tagMessage = string(input)
 	}
 
-	tagMessage = stripComments(tagMessage)
+	tagMessage, err = cleanupMessage(tagMessage, tagCleanup, commentChar)
+	if err != nil {
+		return err
+	}
+
+	if tagCleanup != cleanupVerbatim && tagMessage == "" {
+		return errEmptyTagMessage
+	}
 
 	tagged, err := setTag(
 		repo,
@@ -175,7 +249,12 @@ func createTag(repo *git.Repository) error {
 	)
 
 	if err != nil {
-		return fmt.Errorf("failed creating tag: %s", err)
+		return fmt.Errorf("failed creating tag: %w", err)
+	}
+
+	// A planned release only tags the clone; the tag is pushed when the plan is applied
+	if tagged && planPath != "" {
+		return nil
 	}
 
 	if tagged {
@@ -184,8 +263,13 @@ func createTag(repo *git.Repository) error {
 		}
 		err = pushTags(repo)
 
+		// The token may be able to create the tag where git can't push it
+		if err != nil && pushRejected(err) {
+			err = createTagWithAPI(repo, err)
+		}
+
 		if err != nil {
-			return fmt.Errorf("failed pushing tag to remote: %s", err)
+			return fmt.Errorf("failed pushing tag to remote: %w", err)
 		}
 	}
 
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/clcollins/go-git-release/internal/gitfixture"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestGetTagFromString checks only annotated tags are found, as lightweight tags have no tag object
+func TestGetTagFromString(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.Commit("first", map[string]string{"README.md": "first\n"})
+	second := repo.Commit("second", map[string]string{"README.md": "second\n"})
+	repo.AnnotatedTag("v1.0.0", first, "First release\n")
+	repo.AnnotatedTag("v1.1.0", second, "Second release\n")
+	repo.Tag("v1.1.1", second)
+
+	getTagFromStringTests := []struct {
+		tag    string
+		target plumbing.Hash
+	}{
+		{tag: "v1.0.0", target: first.Hash},
+		{tag: "v1.1.0", target: second.Hash},
+		{tag: "v1.1.1"},
+		{tag: "v2.0.0"},
+	}
+
+	for _, testSpec := range getTagFromStringTests {
+		t.Run(testSpec.tag, func(t *testing.T) {
+			tagObj, err := getTagFromString(testSpec.tag, repo.Repository)
+			Nil(t, err)
+			if testSpec.target.IsZero() {
+				Nil(t, tagObj)
+			} else if NotNil(t, tagObj) {
+				Equal(t, testSpec.target, tagObj.Target)
+			}
+		})
+	}
+}
+
+// TestExistingTagCommit checks annotated and lightweight tags are found, and a missing one isn't an error
+func TestExistingTagCommit(t *testing.T) {
+	repo := gitfixture.New(t)
+	first := repo.Commit("first", map[string]string{"README.md": "first\n"})
+	second := repo.Commit("second", map[string]string{"README.md": "second\n"})
+	repo.AnnotatedTag("v1.0.0", first, "First release\n")
+	repo.Tag("v1.1.0", second)
+
+	existingTagCommitTests := []struct {
+		tag    string
+		commit plumbing.Hash
+	}{
+		{tag: "v1.0.0", commit: first.Hash},
+		{tag: "v1.1.0", commit: second.Hash},
+		{tag: "v2.0.0", commit: plumbing.ZeroHash},
+	}
+
+	for _, testSpec := range existingTagCommitTests {
+		t.Run(testSpec.tag, func(t *testing.T) {
+			commit, err := existingTagCommit(repo.Repository, testSpec.tag)
+			Nil(t, err)
+			Equal(t, testSpec.commit, commit)
+		})
+	}
+}
+
+// TestSetTag checks the tag is created at HEAD with the message and tagger, and never moved
+func TestSetTag(t *testing.T) {
+	repo := gitfixture.New(t)
+	repo.Commit("first", map[string]string{"README.md": "first\n"})
+	head := repo.Commit("second", map[string]string{"README.md": "second\n"})
+	tagger := repo.Signature()
+
+	setTagTests := []struct {
+		name    string
+		tag     string
+		message string
+		failure error
+	}{
+		{name: "new tag", tag: "v1.0.0", message: "First release\n"},
+		{name: "existing tag", tag: "v1.0.0", message: "Again\n", failure: git.ErrTagExists},
+		{name: "no message", tag: "v1.0.1", failure: git.ErrMissingMessage},
+	}
+
+	for _, testSpec := range setTagTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			tagged, err := setTag(repo, testSpec.tag, testSpec.message, tagger)
+			if testSpec.failure != nil {
+				False(t, tagged)
+				Equal(t, testSpec.failure, err)
+				return
+			}
+
+			True(t, tagged)
+			Nil(t, err)
+			tagObj, err := getTagFromString(testSpec.tag, repo.Repository)
+			Nil(t, err)
+			if NotNil(t, tagObj) {
+				Equal(t, head.Hash, tagObj.Target)
+				Equal(t, testSpec.message, tagObj.Message)
+				Equal(t, "test@example.com", tagObj.Tagger.Email)
+			}
+		})
+	}
+}
+
+// TestPushTags checks the tags reach a local bare remote, and that pushing them again is fine
+func TestPushTags(t *testing.T) {
+	defer func(r string) { remote = r }(remote)
+	remote = "origin"
+
+	repo := gitfixture.New(t)
+	head := repo.Commit("first", map[string]string{"README.md": "first\n"})
+	tagObj := repo.AnnotatedTag("v1.0.0", head, "First release\n")
+	repo.Tag("v1", head)
+
+	bare := gitfixture.Bare(t)
+	repo.AddRemote("origin", bare)
+
+	pushTagsTests := []struct {
+		name string
+	}{
+		{name: "new tags"},
+		{name: "already up to date"},
+	}
+	for _, testSpec := range pushTagsTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			Nil(t, pushTags(repo))
+		})
+	}
+
+	pushed, err := git.PlainOpen(bare)
+	Nil(t, err)
+	ref, err := pushed.Tag("v1.0.0")
+	if NoError(t, err) {
+		Equal(t, tagObj.Hash, ref.Hash())
+	}
+	ref, err = pushed.Tag("v1")
+	if NoError(t, err) {
+		Equal(t, head.Hash, ref.Hash())
+	}
+
+	// A remote that isn't configured can't be pushed to
+	remote = "upstream"
+	NotNil(t, pushTags(repo))
+}
+
+// fakePusher records the refspecs pushed to its remote, and fails the push with err
+type fakePusher struct {
+	remote *git.Remote
+	pushed []config.RefSpec
+	err    error
+}
+
+func (f *fakePusher) Remote(name string) (*git.Remote, error) {
+	if name != f.remote.Config().Name {
+		return nil, git.ErrRemoteNotFound
+	}
+	return f.remote, nil
+}
+
+func (f *fakePusher) Push(o *git.PushOptions) error {
+	f.pushed = append(f.pushed, o.RefSpecs...)
+	return f.err
+}
+
+// TestPushTagsResult checks every tag is pushed to the remote, a remote already up to date isn't an error, and rejected pushes are
+func TestPushTagsResult(t *testing.T) {
+	defer func(r string) { remote = r }(remote)
+	remote = "origin"
+
+	rejected := errors.New("remote rejected")
+	pushTagsResultTests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "pushed"},
+		{name: "already up to date", err: git.NoErrAlreadyUpToDate},
+		{name: "rejected", err: rejected, expected: rejected},
+	}
+	for _, testSpec := range pushTagsResultTests {
+		t.Run(testSpec.name, func(t *testing.T) {
+			p := &fakePusher{
+				remote: git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{"/srv/git/repo.git"}}),
+				err:    testSpec.err,
+			}
+			Equal(t, testSpec.expected, pushTags(p))
+			Equal(t, []config.RefSpec{"refs/tags/*:refs/tags/*"}, p.pushed)
+		})
+	}
+}
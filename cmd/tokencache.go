@@ -0,0 +1,200 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// cacheToken keeps the device flow token between runs, so it is only authorized once
+var cacheToken bool
+
+// githubUserURL returns the API endpoint of the authenticated user, used to check a token
+func githubUserURL() string {
+	return githubAPIURL("user")
+}
+
+// tokenStore keeps the device flow token between runs
+type tokenStore interface {
+	// load returns the stored token, or nil if there isn't one
+	load() (*UserAuth, error)
+	save(auth *UserAuth) error
+	clear() error
+	// String describes where the token is stored
+	String() string
+}
+
+// tokenCachePath returns the path of the token cache file in the user's config directory
+var tokenCachePath = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-git-release", "token.json"), nil
+}
+
+// fileTokenStore keeps the token in a file only the user can read
+type fileTokenStore struct {
+	path string
+}
+
+func (s *fileTokenStore) load() (*UserAuth, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var auth UserAuth
+	if err = json.Unmarshal(data, &auth); err != nil {
+		return nil, err
+	}
+	if auth.AccessToken == "" {
+		return nil, nil
+	}
+
+	return &auth, nil
+}
+
+func (s *fileTokenStore) save(auth *UserAuth) error {
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The file may have been created with wider permissions by something else
+	if err = f.Chmod(0600); err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *fileTokenStore) clear() error {
+	err := os.Remove(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileTokenStore) String() string {
+	return s.path
+}
+
+// newTokenStore returns where the device flow token is kept between runs, per auth.storage,
+// or nil if it isn't. Tokens aren't kept while recording or replaying HTTP cassettes, so the
+// exchanges are the same every time.
+func newTokenStore() (tokenStore, error) {
+	if !cacheToken || recordHTTP != "" || replayHTTP != "" {
+		return nil, nil
+	}
+
+	if tokenStorage == tokenStorageKeyring {
+		return &keyringTokenStore{service: keyringService, account: githubHost()}, nil
+	}
+
+	path, err := tokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens of other Github hosts, eg: Github Enterprise Server, are kept apart
+	if host := githubHost(); host != defaultGithubHost {
+		path = filepath.Join(filepath.Dir(path), "token-"+host+".json")
+	}
+
+	return &fileTokenStore{path: path}, nil
+}
+
+// validateToken returns true if Github still accepts the token
+func validateToken(auth *UserAuth) (bool, error) {
+	req, err := newGetRequest(githubUserURL(), url.Values{})
+	if err != nil {
+		return false, err
+	}
+	for k, v := range authHeaders(auth) {
+		req.Header.Set(k, v)
+	}
+
+	_, err = makeHTTPRequest(req)
+	if isHTTPStatus(err, 401) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// storedAuth returns the token kept by an earlier run, if there is one and it is still
+// valid. Tokens that have expired or been revoked are removed from the store; those that
+// can't be validated are kept, and the next auth source is tried.
+func storedAuth(store tokenStore) (*UserAuth, error) {
+	auth, err := store.load()
+	if err != nil {
+		// A corrupt store is replaced by the next authorization
+		fmt.Printf("WARNING: cannot read the stored token from %s: %s\n", store, err)
+		return nil, nil
+	}
+	if auth == nil {
+		return nil, nil
+	}
+
+	valid, err := validateToken(auth)
+	if err != nil {
+		fmt.Printf("WARNING: cannot validate the stored token from %s: %s\n", store, err)
+		return nil, nil
+	}
+
+	if !valid {
+		if verbose {
+			noteInfo("The stored token is no longer valid; authorizing again")
+		}
+		if err = store.clear(); err != nil {
+			fmt.Printf("WARNING: cannot remove the invalid token from %s: %s\n", store, err)
+		}
+		return nil, nil
+	}
+
+	if verbose {
+		noteInfo(fmt.Sprintf("Using the token stored in %s", store))
+	}
+	return auth, nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestFileTokenStore checks the token is kept in a file only the user can read
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &fileTokenStore{path: filepath.Join(dir, "go-git-release", "token.json")}
+
+	auth, err := store.load()
+	Nil(t, err)
+	Nil(t, auth)
+
+	Nil(t, store.save(&UserAuth{AccessToken: "secret", TokenType: "bearer"}))
+	info, err := os.Stat(store.path)
+	Nil(t, err)
+	Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	auth, err = store.load()
+	Nil(t, err)
+	Equal(t, &UserAuth{AccessToken: "secret", TokenType: "bearer"}, auth)
+
+	Nil(t, store.clear())
+	Nil(t, store.clear())
+	auth, err = store.load()
+	Nil(t, err)
+	Nil(t, auth)
+}
+
+// TestStoredAuth checks stored tokens are validated, invalid ones removed, and ones that can't be validated kept but not used
+func TestStoredAuth(t *testing.T) {
+	defer gock.Off()
+
+	dir, err := ioutil.TempDir("", "tokens")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &fileTokenStore{path: filepath.Join(dir, "token.json")}
+	Nil(t, store.save(&UserAuth{AccessToken: "secret", TokenType: "bearer"}))
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		MatchHeader("Authorization", "bearer secret").
+		Reply(200).
+		JSON(map[string]string{"login": "someone"})
+
+	auth, err := storedAuth(store)
+	Nil(t, err)
+	Equal(t, "secret", auth.AccessToken)
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		ReplyError(errors.New("connection reset"))
+
+	auth, err = storedAuth(store)
+	Nil(t, err)
+	Nil(t, auth)
+	_, err = os.Stat(store.path)
+	Nil(t, err)
+
+	gock.New("https://api.github.com").
+		Get("/user").
+		Reply(401)
+
+	auth, err = storedAuth(store)
+	Nil(t, err)
+	Nil(t, auth)
+	_, err = os.Stat(store.path)
+	True(t, os.IsNotExist(err))
+	True(t, gock.IsDone())
+}
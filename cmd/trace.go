@@ -0,0 +1,183 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpClient is used for every HTTP request made by the tool
+var httpClient = &http.Client{}
+
+// traceBodyLimit is the number of bytes of each request and response body written to the trace
+const traceBodyLimit = 4096
+
+// redactedHeaders are never written to the trace
+var redactedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	"Private-Token": true,
+	"Job-Token":     true,
+}
+
+// redactedParams are query or form parameters whose values are never written to the trace
+var redactedParams = []string{"access_token", "client_secret", "device_code", "token"}
+
+// redactedJSON matches the values of sensitive fields in JSON bodies
+var redactedJSON = regexp.MustCompile(`"(access_token|client_secret|device_code|token)"\s*:\s*"[^"]*"`)
+
+// tracingTransport is an http.RoundTripper that writes a sanitized dump of each
+// request and response to out
+type tracingTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+	mu   sync.Mutex
+}
+
+// enableHTTPTrace makes httpClient write a trace of every request to w
+func enableHTTPTrace(w io.Writer) {
+	httpClient.Transport = &tracingTransport{next: httpClient.Transport, out: w}
+}
+
+// RoundTrip executes the request with the wrapped transport, tracing the exchange
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var trace bytes.Buffer
+	fmt.Fprintf(&trace, "> %s %s\n", req.Method, sanitizeURL(req.URL))
+	writeTraceHeaders(&trace, "> ", req.Header)
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			writeTraceBody(&trace, "> ", body)
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	latency := time.Since(start)
+
+	if err != nil {
+		fmt.Fprintf(&trace, "< error after %s: %s\n", latency, err)
+	} else {
+		fmt.Fprintf(&trace, "< %s (%s)\n", resp.Status, latency)
+		writeTraceHeaders(&trace, "< ", resp.Header)
+
+		// Read the start of the body for the trace, and put it back for the caller
+		prefix, _ := ioutil.ReadAll(io.LimitReader(resp.Body, traceBodyLimit+1))
+		writeTraceBody(&trace, "< ", bytes.NewReader(prefix))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+	}
+	trace.WriteString("\n")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.out.Write(trace.Bytes())
+
+	return resp, err
+}
+
+// sanitizeURL returns the URL with the values of sensitive query parameters redacted
+func sanitizeURL(u *url.URL) string {
+	sanitized := *u
+	query := sanitized.Query()
+	for _, p := range redactedParams {
+		if query.Get(p) != "" {
+			query.Set(p, "REDACTED")
+		}
+	}
+	sanitized.RawQuery = query.Encode()
+	return sanitized.String()
+}
+
+// writeTraceHeaders writes the headers, sorted and without sensitive values, to the trace
+func writeTraceHeaders(w io.Writer, prefix string, headers http.Header) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.Join(headers[name], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "REDACTED"
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", prefix, name, value)
+	}
+}
+
+// writeTraceBody writes up to traceBodyLimit bytes of the body to the trace. Binary
+// bodies are summarised, and sensitive form values are redacted.
+func writeTraceBody(w io.Writer, prefix string, body io.Reader) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, traceBodyLimit+1))
+	if err != nil || len(data) == 0 {
+		return
+	}
+
+	truncated := len(data) > traceBodyLimit
+	if truncated {
+		data = data[:traceBodyLimit]
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		fmt.Fprintf(w, "%s[binary body]\n", prefix)
+		return
+	}
+
+	text := redactBody(string(data))
+
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(w, "%s%s\n", prefix, line)
+	}
+
+	if truncated {
+		fmt.Fprintf(w, "%s[truncated at %d bytes]\n", prefix, traceBodyLimit)
+	}
+}
+
+// redactBody returns the request or response body with sensitive form and JSON values redacted
+func redactBody(text string) string {
+	if form, err := url.ParseQuery(text); err == nil && strings.Contains(text, "=") && !strings.ContainsAny(text, "{}\n ") {
+		for _, p := range redactedParams {
+			if form.Get(p) != "" {
+				form.Set(p, "REDACTED")
+			}
+		}
+		text = form.Encode()
+	}
+	return redactedJSON.ReplaceAllString(text, `"$1":"REDACTED"`)
+}
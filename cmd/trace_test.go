@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestTraceRedactsTokens checks the Github and GitLab token headers are never written to the trace
+func TestTraceRedactsTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	previous := httpClient.Transport
+	defer func() { httpClient.Transport = previous }()
+
+	var trace bytes.Buffer
+	enableHTTPTrace(&trace)
+
+	for header, value := range map[string]string{
+		"Authorization": "token secret",
+		"PRIVATE-TOKEN": "glpat-secret",
+		"JOB-TOKEN":     "job-secret",
+	} {
+		req, err := http.NewRequest("GET", server.URL+"/api/v4/projects/o%2Fr/releases", nil)
+		Nil(t, err)
+		req.Header.Set(header, value)
+
+		_, err = makeHTTPRequest(req)
+		Nil(t, err)
+	}
+
+	NotContains(t, trace.String(), "secret")
+	Contains(t, trace.String(), "> Private-Token: REDACTED")
+	Contains(t, trace.String(), "> Job-Token: REDACTED")
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxWait is how long to keep retrying requests while Github is unavailable. 0 fails straight away.
+var maxWait time.Duration
+
+// unavailableRetryDelay is the first wait before retrying, doubled after each attempt
+var unavailableRetryDelay = 5 * time.Second
+
+// maxUnavailableRetryDelay caps the wait between retries
+const maxUnavailableRetryDelay = time.Minute
+
+// sleep waits between retries; replaced in tests
+var sleep = time.Sleep
+
+// unavailable returns the httpError if it is one Github returns during maintenance or an outage
+func unavailable(err error) (*httpError, bool) {
+	var e *httpError
+	if !errors.As(err, &e) {
+		return nil, false
+	}
+
+	switch e.StatusCode {
+	case 500, 502, 503, 504:
+		return e, true
+	}
+	return nil, false
+}
+
+// parseRetryAfter parses a Retry-After header in seconds, or 0 if there isn't one
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// retriesWithin returns how many retries fit in the remaining time, starting at delay
+func retriesWithin(remaining, delay time.Duration) int {
+	n := 0
+	for delay <= remaining {
+		n++
+		remaining -= delay
+		delay = nextRetryDelay(delay)
+	}
+	return n
+}
+
+// nextRetryDelay doubles the delay, up to maxUnavailableRetryDelay
+func nextRetryDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > maxUnavailableRetryDelay {
+		delay = maxUnavailableRetryDelay
+	}
+	return delay
+}
+
+// describeUnavailable explains the response for the retry status line
+func describeUnavailable(e *httpError) string {
+	if bytes.Contains(bytes.ToLower(e.Body), []byte("maintenance")) {
+		return fmt.Sprintf("Github is under maintenance (%s)", e.Status)
+	}
+	return fmt.Sprintf("Github is unavailable (%s)", e.Status)
+}
+
+// idempotent returns true if sending the request again can't repeat its effect, as a
+// POST that failed with a server error may still have been made
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// retryUnavailable runs the request with do, retrying with increasing waits, or the wait
+// Github asks for, while it is under maintenance or returning server errors. It gives up
+// once the next retry would be more than maxWait after the first attempt, or if the
+// request body can't be sent again. Requests that aren't idempotent are never retried.
+func retryUnavailable(req *http.Request, do func(*http.Request) ([]byte, error)) ([]byte, error) {
+	deadline := time.Now().Add(maxWait)
+	delay := unavailableRetryDelay
+
+	for {
+		body, err := do(req)
+		e, ok := unavailable(err)
+		if !ok || !idempotent(req) {
+			return body, err
+		}
+
+		wait := delay
+		if e.RetryAfter > 0 {
+			wait = e.RetryAfter
+		}
+
+		remaining := time.Until(deadline)
+		if wait > remaining || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+			return nil, err
+		}
+
+		attemptsLeft := 1 + retriesWithin(remaining-wait, nextRetryDelay(delay))
+		fmt.Printf("%s; next retry in %s, %d attempts left\n", describeUnavailable(e), wait.Round(time.Second), attemptsLeft)
+		sleep(wait)
+
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		delay = nextRetryDelay(delay)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestRetryUnavailable checks requests are retried while Github is unavailable, within maxWait
+func TestRetryUnavailable(t *testing.T) {
+	defer func(w, d time.Duration, s func(time.Duration)) {
+		maxWait, unavailableRetryDelay, sleep = w, d, s
+	}(maxWait, unavailableRetryDelay, sleep)
+
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	unavailableRetryDelay = time.Second
+	maxWait = time.Hour
+
+	retryTests := []struct {
+		name     string
+		method   string
+		failures []*httpError
+		maxWait  time.Duration
+		waits    []time.Duration
+		err      bool
+	}{
+		{
+			name:     "recovers",
+			failures: []*httpError{{StatusCode: 503, Status: "503"}, {StatusCode: 502, Status: "502"}},
+			maxWait:  time.Hour,
+			waits:    []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:     "retry after",
+			failures: []*httpError{{StatusCode: 503, Status: "503", RetryAfter: 30 * time.Second}},
+			maxWait:  time.Hour,
+			waits:    []time.Duration{30 * time.Second},
+		},
+		{
+			name:     "gives up",
+			failures: []*httpError{{StatusCode: 503, Status: "503", RetryAfter: 30 * time.Second}},
+			maxWait:  10 * time.Second,
+			err:      true,
+		},
+		{
+			name:     "client errors are not retried",
+			failures: []*httpError{{StatusCode: 404, Status: "404"}},
+			maxWait:  time.Hour,
+			err:      true,
+		},
+		{
+			name:     "posts are not retried",
+			method:   "POST",
+			failures: []*httpError{{StatusCode: 502, Status: "502"}},
+			maxWait:  time.Hour,
+			err:      true,
+		},
+	}
+
+	for _, testSpec := range retryTests {
+		t.Run(
+			testSpec.name,
+			func(t *testing.T) {
+				waits = nil
+				maxWait = testSpec.maxWait
+
+				calls := 0
+				do := func(*http.Request) ([]byte, error) {
+					calls++
+					if calls <= len(testSpec.failures) {
+						return nil, testSpec.failures[calls-1]
+					}
+					return []byte("ok"), nil
+				}
+
+				req, err := newGetRequest("https://api.github.com/repos/o/r/releases", nil)
+				Nil(t, err)
+				if testSpec.method != "" {
+					req.Method = testSpec.method
+				}
+
+				body, err := retryUnavailable(req, do)
+				if testSpec.err {
+					Error(t, err)
+				} else {
+					Nil(t, err)
+					Equal(t, "ok", string(body))
+				}
+				Equal(t, testSpec.waits, waits)
+			},
+		)
+	}
+}
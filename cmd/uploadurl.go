@@ -0,0 +1,131 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// uploadURLTemplate renders the URL assets are uploaded to, in place of the upload_url the
+// API returns, eg: through an artifact proxy:
+// https://proxy.example.com/github/{{ .Owner }}/{{ .Repo }}/releases/{{ .ReleaseID }}/assets
+var uploadURLTemplate string
+
+// uploadURLExpression matches the upload_url of a release, without its {?name,label} template
+var uploadURLExpression = regexp.MustCompile(`^(?P<base>.*)/repos/(?P<owner>[^/]+)/(?P<repo>[^/]+)/releases/(?P<id>\d+)/assets$`)
+
+// uploadURLVars are the values available to the upload URL template
+type uploadURLVars struct {
+	// UploadURL is the upload_url the API returned, without its {?name,label} template
+	UploadURL string
+	// Host is the host of the upload_url, eg: uploads.github.com
+	Host      string
+	Owner     string
+	Repo      string
+	ReleaseID string
+}
+
+// newUploadURLVars splits the upload_url into the template's values
+func newUploadURLVars(uploadURL string) (uploadURLVars, error) {
+	matches := uploadURLExpression.FindStringSubmatch(uploadURL)
+	if matches == nil {
+		return uploadURLVars{}, fmt.Errorf("cannot parse upload url %s", uploadURL)
+	}
+
+	u, err := url.Parse(uploadURL)
+	if err != nil {
+		return uploadURLVars{}, err
+	}
+
+	re := uploadURLExpression
+	return uploadURLVars{
+		UploadURL: uploadURL,
+		Host:      u.Host,
+		Owner:     matches[re.SubexpIndex("owner")],
+		Repo:      matches[re.SubexpIndex("repo")],
+		ReleaseID: matches[re.SubexpIndex("id")],
+	}, nil
+}
+
+// parseUploadURLTemplate parses the template, and checks it renders an http(s) URL
+func parseUploadURLTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("uploadURL").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := newUploadURLVars("https://uploads.github.com/repos/o/r/releases/1/assets")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = renderUploadURL(tmpl, vars); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// renderUploadURL renders the template with the vars into an upload URL
+func renderUploadURL(tmpl *template.Template, vars uploadURLVars) (string, error) {
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", err
+	}
+
+	u := strings.TrimSpace(rendered.String())
+	if !validEndpointURL(u) {
+		return "", fmt.Errorf("rendered %q, which is not an http or https URL", u)
+	}
+	if strings.ContainsAny(u, "{}?") {
+		return "", fmt.Errorf("rendered %q, which must not have a query or URL template; the asset name is added to it", u)
+	}
+
+	return u, nil
+}
+
+// rewriteUploadURL returns where to upload the release's assets instead of its upload_url,
+// per --uploadURLTemplate or --uploadURL, or the upload_url if neither is set
+func rewriteUploadURL(uploadURL string) (string, error) {
+	switch {
+	case uploadURLTemplate != "":
+		tmpl, err := parseUploadURLTemplate(uploadURLTemplate)
+		if err != nil {
+			return "", fmt.Errorf("invalid upload URL template: %w", err)
+		}
+		vars, err := newUploadURLVars(uploadURL)
+		if err != nil {
+			return "", err
+		}
+		return renderUploadURL(tmpl, vars)
+
+	case githubEndpoint.UploadURL != "":
+		// Uploads may go through another host than the API says, eg: an upload proxy
+		i := strings.Index(uploadURL, "/repos/")
+		if i < 0 {
+			return "", fmt.Errorf("cannot rebase upload url %s onto %s", uploadURL, githubEndpoint.UploadURL)
+		}
+		return strings.TrimSuffix(githubEndpoint.UploadURL, "/") + uploadURL[i:], nil
+	}
+
+	return uploadURL, nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestRewriteUploadURL checks the upload_url is replaced by the template, or rebased onto the upload URL
+func TestRewriteUploadURL(t *testing.T) {
+	defer func(e endpoint, text string) { githubEndpoint, uploadURLTemplate = e, text }(githubEndpoint, uploadURLTemplate)
+	apiURL := "https://uploads.github.com/repos/o/r/releases/12/assets"
+
+	u, err := rewriteUploadURL(apiURL)
+	Nil(t, err)
+	Equal(t, apiURL, u)
+
+	githubEndpoint.UploadURL = "https://uploads.example.com/github"
+	u, err = rewriteUploadURL(apiURL)
+	Nil(t, err)
+	Equal(t, "https://uploads.example.com/github/repos/o/r/releases/12/assets", u)
+
+	githubEndpoint.UploadURL = ""
+	uploadURLTemplate = `https://{{ if eq .Owner "o" }}eu{{ else }}us{{ end }}.proxy.example.com/{{ .Host }}/{{ .Owner }}/{{ .Repo }}/{{ .ReleaseID }}`
+	u, err = rewriteUploadURL(apiURL)
+	Nil(t, err)
+	Equal(t, "https://eu.proxy.example.com/uploads.github.com/o/r/12", u)
+
+	_, err = rewriteUploadURL("https://uploads.github.com/assets")
+	NotNil(t, err)
+}
+
+// TestParseUploadURLTemplate checks templates that can't render a usable upload URL are rejected
+func TestParseUploadURLTemplate(t *testing.T) {
+	_, err := parseUploadURLTemplate("{{ .UploadURL }}")
+	Nil(t, err)
+
+	for _, text := range []string{
+		"{{ .Upload",
+		"{{ .Region }}",
+		"proxy.example.com/{{ .Repo }}",
+		"{{ .UploadURL }}{?name,label}",
+		"{{ .UploadURL }}?token=secret",
+	} {
+		_, err := parseUploadURLTemplate(text)
+		NotNil(t, err, text)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// GitCommit is the commit the tool was built from, set at build time, eg:
+// -ldflags "-X github.com/clcollins/go-git-release/cmd.GitCommit=<commit>"
+var GitCommit string
+
+// toolVersion is the version of the tool, set at build time
+var toolVersion = "dev"
+
+// userAgent identifies the tool in every API and upload request; the tool, its version and
+// commit if not set, eg: go-git-release/v1.2.0 (abc1234)
+var userAgent string
+
+// correlationIDHeader carries the ID of the run in each request, so the requests of a run
+// can be found in the forge's and proxies' logs
+const correlationIDHeader = "X-Correlation-ID"
+
+// correlationID identifies the run in its requests, output and audit events
+var correlationID = newCorrelationID()
+
+// newCorrelationID returns a random ID for a run
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// defaultUserAgent returns the User-Agent of the tool's version and commit
+func defaultUserAgent() string {
+	agent := "go-git-release/" + toolVersion
+	if GitCommit != "" {
+		agent += fmt.Sprintf(" (%s)", GitCommit)
+	}
+	return agent
+}
+
+// identifyingTransport is an http.RoundTripper that sets the User-Agent and correlation ID
+// of each request
+type identifyingTransport struct {
+	next http.RoundTripper
+}
+
+// enableRequestIdentification makes httpClient identify the tool and run in every request.
+// It wraps the trace, so the headers are in it.
+func enableRequestIdentification() {
+	httpClient.Transport = &identifyingTransport{next: httpClient.Transport}
+}
+
+// RoundTrip executes a copy of the request with the identifying headers set
+func (t *identifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	// RoundTrippers mustn't modify the request
+	identified := req.Clone(req.Context())
+	agent := userAgent
+	if agent == "" {
+		agent = defaultUserAgent()
+	}
+	identified.Header.Set("User-Agent", agent)
+	identified.Header.Set(correlationIDHeader, correlationID)
+
+	return next.RoundTrip(identified)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestIdentifyingTransport checks every request has the User-Agent and correlation ID of the run
+func TestIdentifyingTransport(t *testing.T) {
+	defer gock.Off()
+	defer func(agent, commit, id string) { userAgent, GitCommit, correlationID = agent, commit, id }(userAgent, GitCommit, correlationID)
+	userAgent, GitCommit, correlationID = "", "abc1234", "0123456789abcdef"
+
+	gock.New("https://api.github.com").
+		Get("/repos/o/r").
+		MatchHeader("User-Agent", "^go-git-release/dev \\(abc1234\\)$").
+		MatchHeader(http.CanonicalHeaderKey(correlationIDHeader), "0123456789abcdef").
+		Reply(200)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r").
+		MatchHeader("User-Agent", "^release-bot/1.0$").
+		Reply(200)
+
+	// gock intercepts the default transport, which is the one wrapped
+	client := &http.Client{Transport: &identifyingTransport{}}
+	req, err := http.NewRequest("GET", "https://api.github.com/repos/o/r", nil)
+	Nil(t, err)
+	_, err = client.Do(req)
+	Nil(t, err)
+	Equal(t, "", req.Header.Get(correlationIDHeader), "the caller's request isn't modified")
+
+	userAgent = "release-bot/1.0"
+	req, err = http.NewRequest("GET", "https://api.github.com/repos/o/r", nil)
+	Nil(t, err)
+	_, err = client.Do(req)
+	Nil(t, err)
+	True(t, gock.IsDone())
+}
+
+// TestNewCorrelationID checks each run gets a different ID
+func TestNewCorrelationID(t *testing.T) {
+	id := newCorrelationID()
+	Len(t, id, 16)
+	NotEqual(t, id, newCorrelationID())
+}
@@ -0,0 +1,202 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyMirrors bool
+var verifySample int
+var manifestFile string
+var manifestAsset string
+
+// verifyCmd checks the published copies of the release assets against the checksum manifest
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the checksums of published release assets",
+	Long: `verify downloads the assets of an existing release and confirms their checksums match the
+canonical checksum manifest. With --mirrors, the copies on each configured mirror are verified too.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return verify()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().BoolVar(&verifyMirrors, "mirrors", false, "also verify the copies on the mirrors configured in the config file")
+	verifyCmd.Flags().IntVar(&verifySample, "sample", 0, "number of randomly selected assets to verify per mirror (default is all)")
+	verifyCmd.Flags().StringVar(&manifestFile, "manifest", "", "(optional) local checksum manifest to verify against, instead of the release's manifest asset")
+	verifyCmd.Flags().StringVar(&manifestAsset, "manifestAsset", "SHA256SUMS", "name of the release asset containing the checksum manifest")
+}
+
+func verify() error {
+	gURL, err := parseGitURL(repositoryURL)
+	if err != nil {
+		return err
+	}
+
+	tag, err = resolveTag(gURL)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := loadManifest(gURL)
+	if err != nil {
+		return fmt.Errorf("failed loading checksum manifest: %w", err)
+	}
+
+	// Github is always verified, as the canonical location of the assets
+	targets := []mirror{{
+		Name: "github",
+		URL:  githubWebURL(fmt.Sprintf("%s/%s/releases/download/{{ .Tag }}/{{ .Name }}", gURL.organization, gURL.repository)),
+	}}
+
+	if verifyMirrors {
+		if len(mirrors) == 0 {
+			return fmt.Errorf("no mirrors configured")
+		}
+		targets = append(targets, mirrors...)
+	}
+
+	failed := 0
+	for _, m := range targets {
+		for _, result := range verifyMirror(m, tag, manifest, verifySample) {
+			fmt.Println(result)
+			if result.err != nil {
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d asset(s) failed verification", failed)
+	}
+
+	return nil
+}
+
+// loadManifest reads the local manifest file if one was provided, otherwise downloads
+// the manifest asset from the release for the tag
+func loadManifest(gURL *gitURL) (checksumManifest, error) {
+	if manifestFile != "" {
+		data, err := ioutil.ReadFile(manifestFile)
+		if err != nil {
+			return nil, err
+		}
+		return parseChecksumManifest(string(data))
+	}
+
+	releasesList, err := getReleases(gURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range *releasesList {
+		if r.TagName == nil || *r.TagName != tag {
+			continue
+		}
+
+		for _, a := range r.Assets {
+			if a.Name == nil || *a.Name != manifestAsset || a.BrowserDownloadURL == nil {
+				continue
+			}
+
+			req, err := http.NewRequest("GET", *a.BrowserDownloadURL, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			body, err := makeHTTPRequest(req)
+			if err != nil {
+				return nil, err
+			}
+
+			manifest, err := parseChecksumManifest(string(body))
+			if err != nil {
+				return nil, err
+			}
+
+			// The manifest does not list itself
+			delete(manifest, manifestAsset)
+			return manifest, nil
+		}
+
+		return nil, fmt.Errorf("release %s has no %s asset", tag, manifestAsset)
+	}
+
+	return nil, fmt.Errorf("no release found for tag %s", tag)
+}
+
+// verifyUploadedAssets downloads the uploaded assets again, or a random sample of them,
+// through their public download URLs, and compares them to the local artifacts, to catch
+// corruption on the way to or from Github, eg: by a proxy or the CDN
+func verifyUploadedAssets(artifacts []*artifact, uploaded []*asset, sample int) error {
+	urls := make(map[string]string)
+	for _, u := range uploaded {
+		if u.Name != nil && u.BrowserDownloadURL != nil {
+			urls[*u.Name] = *u.BrowserDownloadURL
+		}
+	}
+
+	manifest := make(checksumManifest)
+	for _, a := range artifacts {
+		if _, ok := urls[a.name]; !ok {
+			continue
+		}
+		digest, err := fileDigest(a.path)
+		if err != nil {
+			return err
+		}
+		manifest[a.name] = digest
+	}
+
+	failed := 0
+	for _, name := range sampleAssets(manifest, sample) {
+		result := mirrorResult{mirror: "github", asset: name, url: urls[name]}
+
+		if verbose {
+			noteInfo(fmt.Sprintf("Downloading %s", result.url))
+		}
+
+		var digest string
+		digest, result.err = downloadDigest(result.url, manifest[name])
+		if result.err == nil && digest != manifest[name] {
+			result.err = fmt.Errorf("checksum mismatch: expected %s, got %s", manifest[name], digest)
+		}
+
+		if result.err != nil {
+			failed++
+		}
+		if result.err != nil || verbose {
+			fmt.Println(result)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d uploaded asset(s) failed verification", failed)
+	}
+
+	return nil
+}
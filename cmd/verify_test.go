@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestVerifyUploadedAssets checks the downloaded assets are compared to the local artifacts
+func TestVerifyUploadedAssets(t *testing.T) {
+	defer gock.Off()
+
+	dir, err := ioutil.TempDir("", "verify")
+	Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var artifacts []*artifact
+	var uploaded []*asset
+	for _, name := range []string{"app", "other"} {
+		path := filepath.Join(dir, name)
+		Nil(t, ioutil.WriteFile(path, []byte(name), 0644))
+		artifacts = append(artifacts, &artifact{path: path, name: name})
+
+		n, u := name, "https://github.com/o/r/releases/download/v1.0.0/"+name
+		uploaded = append(uploaded, &asset{Name: &n, BrowserDownloadURL: &u})
+	}
+
+	gock.New("https://github.com").
+		Get("/o/r/releases/download/v1.0.0/app").
+		Reply(200).
+		BodyString("app")
+	gock.New("https://github.com").
+		Get("/o/r/releases/download/v1.0.0/other").
+		Reply(200).
+		BodyString("corrupted")
+
+	err = verifyUploadedAssets(artifacts, uploaded, 0)
+	NotNil(t, err)
+	Contains(t, err.Error(), "1 uploaded asset(s)")
+	True(t, gock.IsDone())
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version parsed from a tag, such as "v1.2.3-rc.1+build.5"
+type version struct {
+	prefix     string
+	major      int
+	minor      int
+	patch      int
+	prerelease string
+	build      string
+}
+
+var versionExpression = regexp.MustCompile(
+	`^(?P<prefix>v?)(?P<major>0|[1-9][0-9]*)\.(?P<minor>0|[1-9][0-9]*)\.(?P<patch>0|[1-9][0-9]*)` +
+		`(?:-(?P<prerelease>[0-9A-Za-z\-\.]+))?(?:\+(?P<build>[0-9A-Za-z\-\.]+))?$`,
+)
+
+// parseVersion parses a tag into a semantic version
+func parseVersion(tag string) (*version, error) {
+	re := versionExpression
+	matches := re.FindStringSubmatch(tag)
+	if matches == nil {
+		return nil, fmt.Errorf("%q is not a semantic version", tag)
+	}
+
+	v := &version{
+		prefix:     matches[re.SubexpIndex("prefix")],
+		prerelease: matches[re.SubexpIndex("prerelease")],
+		build:      matches[re.SubexpIndex("build")],
+	}
+
+	// The expression only matches digits, so these cannot fail
+	v.major, _ = strconv.Atoi(matches[re.SubexpIndex("major")])
+	v.minor, _ = strconv.Atoi(matches[re.SubexpIndex("minor")])
+	v.patch, _ = strconv.Atoi(matches[re.SubexpIndex("patch")])
+
+	return v, nil
+}
+
+// String returns the version formatted as a tag
+func (v *version) String() string {
+	s := fmt.Sprintf("%s%s", v.prefix, v.core())
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	if v.build != "" {
+		s += "+" + v.build
+	}
+	return s
+}
+
+// core returns the "major.minor.patch" portion of the version
+func (v *version) core() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// isPrerelease returns true if the version has a prerelease component, eg: "-rc.1"
+func (v *version) isPrerelease() bool {
+	return v.prerelease != ""
+}
+
+// sameCore returns true if both versions have the same major, minor and patch numbers
+func (v *version) sameCore(other *version) bool {
+	return v.major == other.major && v.minor == other.minor && v.patch == other.patch
+}
+
+// compare returns -1, 0 or 1 as v has lower, equal or higher precedence than other, by
+// the semantic versioning rules: prereleases come before their final release, and build
+// metadata is ignored
+func (v *version) compare(other *version) int {
+	for _, d := range []int{v.major - other.major, v.minor - other.minor, v.patch - other.patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+
+	a, b := strings.Split(v.prerelease, "."), strings.Split(other.prerelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePrereleaseIdentifiers(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// comparePrereleaseIdentifiers compares dot separated prerelease identifiers: numeric
+// ones numerically, and lower than alphanumeric ones, which are compared in ASCII order
+func comparePrereleaseIdentifiers(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		if na < nb {
+			return -1
+		}
+		if na > nb {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestParseVersion checks tags are parsed into semantic versions and formatted back
+func TestParseVersion(t *testing.T) {
+	versionTests := []struct {
+		tag        string
+		core       string
+		prerelease string
+		build      string
+		err        bool
+	}{
+		{tag: "v1.2.3", core: "1.2.3"},
+		{tag: "1.2.3", core: "1.2.3"},
+		{tag: "v1.2.3-rc.1", core: "1.2.3", prerelease: "rc.1"},
+		{tag: "v0.10.0-beta+build.5", core: "0.10.0", prerelease: "beta", build: "build.5"},
+		{tag: "v1.2", err: true},
+		{tag: "latest", err: true},
+		{tag: "v01.2.3", err: true},
+	}
+
+	for _, testSpec := range versionTests {
+		t.Run(
+			testSpec.tag,
+			func(t *testing.T) {
+				v, err := parseVersion(testSpec.tag)
+				if testSpec.err {
+					Error(t, err)
+					return
+				}
+				Nil(t, err)
+				Equal(t, testSpec.core, v.core())
+				Equal(t, testSpec.prerelease, v.prerelease)
+				Equal(t, testSpec.build, v.build)
+				Equal(t, testSpec.prerelease != "", v.isPrerelease())
+				Equal(t, testSpec.tag, v.String())
+			},
+		)
+	}
+}
+
+// TestCompareVersions checks versions are ordered by semantic versioning precedence
+func TestCompareVersions(t *testing.T) {
+	// In ascending order, from the semantic versioning spec
+	ordered := []string{
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.2.0",
+		"v2.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, err := parseVersion(ordered[i])
+			Nil(t, err)
+			b, err := parseVersion(ordered[j])
+			Nil(t, err)
+
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			Equal(t, want, a.compare(b), "%s compared to %s", ordered[i], ordered[j])
+		}
+	}
+
+	a, _ := parseVersion("v1.0.0+build.1")
+	b, _ := parseVersion("1.0.0+build.2")
+	Equal(t, 0, a.compare(b))
+}
@@ -0,0 +1,508 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var watchRepos []string
+var watchTagPattern string
+var watchInterval time.Duration
+var webhookAddr string
+var webhookSecretEnv string
+var publishScheduled bool
+
+// watchCmd runs the release pipeline for new tags pushed by other tooling
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Release new tags as they are pushed",
+	Long: `watch runs as a release daemon. It polls the repository, and any others listed with --watchRepos, for
+new tags matching --tagPattern, and runs the build and release pipeline for each one. With --webhookAddr,
+Github "create" webhooks for new tags trigger a release immediately, and polling is only a fallback. With
+--publishScheduled, drafts scheduled with --publishAt are published once their time has come. With
+--statusAddr, /healthz and /metrics report the release queue, the last release and the failures, and
+/queue lists the queued releases. The releases of a repository run one at a time, each in a process of its own,
+and those of different repositories side by side.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return watch()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringSliceVar(&watchRepos, "watchRepos", []string{}, "(optional) additional repositories to watch, as owner/name")
+	watchCmd.Flags().StringVar(&watchTagPattern, "tagPattern", "v*", "glob pattern of the tags to release")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Minute, "how often to poll for new tags")
+	watchCmd.Flags().StringVar(&webhookAddr, "webhookAddr", "", "(optional) address to receive Github webhooks on, eg: :8080")
+	watchCmd.Flags().StringVar(&statusAddr, "statusAddr", "", "(optional) address to serve /healthz and /metrics on, eg: :9090; may be the webhookAddr")
+	watchCmd.Flags().StringVar(&adminTokenEnv, "adminTokenEnv", "", "(optional) environment variable containing the bearer token that lists and cancels queued releases on the statusAddr")
+	watchCmd.Flags().StringVar(&webhookSecretEnv, "webhookSecretEnv", "", "environment variable containing the webhook secret used to verify deliveries; required with --webhookAddr")
+	watchCmd.Flags().BoolVar(&publishScheduled, "publishScheduled", false, "publish the draft releases scheduled with --publishAt on the watched repositories, at each poll")
+}
+
+// tagEvent is a new tag to release
+type tagEvent struct {
+	repositoryURL string
+	tag           string
+}
+
+// repoTag is the subset of a Github tag listing we use
+type repoTag struct {
+	Name string `json:"name"`
+}
+
+// watcher tracks the tags already seen on each watched repository
+type watcher struct {
+	pattern string
+	// seen maps repository URLs to the tags found on them
+	seen map[string]map[string]bool
+	// repos maps the "owner/name" of each watched repository to its URL
+	repos map[string]*gitURL
+	mu    sync.Mutex
+	// stats are reported on --statusAddr
+	stats watchStats
+}
+
+// newWatcher creates a watcher for the repository URLs
+func newWatcher(repositoryURLs []string, pattern string) (*watcher, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+	}
+
+	w := &watcher{
+		pattern: pattern,
+		seen:    make(map[string]map[string]bool),
+		repos:   make(map[string]*gitURL),
+	}
+
+	for _, u := range repositoryURLs {
+		gURL, err := parseGitURL(u)
+		if err != nil {
+			return nil, err
+		}
+		// Tags are polled, and scheduled releases published, with the Github API
+		if _, ok := providerFor(gURL).(*githubProvider); !ok {
+			return nil, fmt.Errorf("watch only supports Github repositories, not %s", u)
+		}
+		w.repos[strings.ToLower(gURL.organization+"/"+gURL.repository)] = gURL
+	}
+
+	return w, nil
+}
+
+// matches returns true if the tag should be released
+func (w *watcher) matches(tag string) bool {
+	ok, _ := path.Match(w.pattern, tag)
+	return ok
+}
+
+// observe records the tags on a repository and returns those not seen before that
+// match the pattern. The first observation of a repository only records its tags, so
+// existing tags are not released.
+func (w *watcher) observe(gURL *gitURL, tags []string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seen, known := w.seen[gURL.raw]
+	if !known {
+		seen = make(map[string]bool)
+		w.seen[gURL.raw] = seen
+	}
+
+	var found []string
+	for _, t := range tags {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if known && w.matches(t) {
+			found = append(found, t)
+		}
+	}
+
+	return oldestTagsFirst(found)
+}
+
+// oldestTagsFirst orders the tags by version, oldest first, as Github doesn't list tags
+// in the order they were created. Tags that aren't all versions are left as they are.
+func oldestTagsFirst(tags []string) []string {
+	versions := make(map[string]*version, len(tags))
+	for _, t := range tags {
+		v, err := parseVersion(t)
+		if err != nil {
+			return tags
+		}
+		versions[t] = v
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return versions[tags[i]].compare(versions[tags[j]]) < 0
+	})
+	return tags
+}
+
+// poll lists the tags of each watched repository and queues any new ones
+func (w *watcher) poll(q *releaseQueue) {
+	failed := false
+	defer func() { w.stats.polled(time.Now(), failed) }()
+
+	for _, gURL := range w.repos {
+		tags, err := listTags(gURL)
+		if err != nil {
+			reportErr(fmt.Sprintf("failed listing tags for %s: %s", gURL.raw, err))
+			failed = true
+			continue
+		}
+
+		for _, t := range w.observe(gURL, tags) {
+			q.push(tagEvent{repositoryURL: gURL.raw, tag: t}, time.Now())
+		}
+	}
+}
+
+// publishDue publishes the scheduled drafts that are due on each watched repository
+func (w *watcher) publishDue(auth *UserAuth, now time.Time) {
+	for _, gURL := range w.repos {
+		published, err := publishDueReleases(auth, gURL, now)
+		for _, t := range published {
+			noteInfo(fmt.Sprintf("Published scheduled release %s on %s", t, gURL.raw))
+		}
+		if err != nil {
+			reportErr(fmt.Sprintf("failed publishing scheduled releases on %s: %s", gURL.raw, err))
+		}
+	}
+}
+
+// tagsPerPage is the most tags Github lists in a page
+const tagsPerPage = 100
+
+// listTags retrieves the names of every tag on the repository, a page at a time until the last
+func listTags(gURL *gitURL) ([]string, error) {
+	var names []string
+	for page := 1; ; page++ {
+		query := url.Values{"per_page": {strconv.Itoa(tagsPerPage)}, "page": {strconv.Itoa(page)}}
+		req, err := newGetRequest(githubRepoURL(gURL, "tags")+"?"+query.Encode(), url.Values{})
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := makeHTTPRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var tags []repoTag
+		if err = json.Unmarshal(body, &tags); err != nil {
+			return nil, err
+		}
+		for _, t := range tags {
+			names = append(names, t.Name)
+		}
+
+		if len(tags) < tagsPerPage {
+			return names, nil
+		}
+	}
+}
+
+// createEvent is the subset of a Github "create" webhook payload we use
+type createEvent struct {
+	Ref        string `json:"ref"`
+	RefType    string `json:"ref_type"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// webhookHandler returns a handler that sends tags created on the watched repositories
+// to the queue. Deliveries are verified against the secret, and refused without one.
+func (w *watcher) webhookHandler(secret string, q *releaseQueue) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(rw, "failed reading body", http.StatusBadRequest)
+			return
+		}
+
+		if secret == "" || !validWebhookSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+			http.Error(rw, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if r.Header.Get("X-GitHub-Event") != "create" {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var event createEvent
+		if err = json.Unmarshal(body, &event); err != nil {
+			http.Error(rw, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		gURL, ok := w.repos[strings.ToLower(event.Repository.FullName)]
+		if event.RefType != "tag" || !ok || !w.matches(event.Ref) {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Record the tag, so polling doesn't release it again, and ignore redeliveries
+		w.mu.Lock()
+		seen := w.seen[gURL.raw]
+		released := seen[event.Ref]
+		if seen != nil {
+			seen[event.Ref] = true
+		}
+		w.mu.Unlock()
+
+		if released {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !q.push(tagEvent{repositoryURL: gURL.raw, tag: event.Ref}, time.Now()) {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// validWebhookSignature checks the sha256 HMAC Github signs webhook deliveries with
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(webhookSignature(secret, body)), []byte(signature))
+}
+
+// webhookSignature returns the sha256 HMAC of the body, in the form Github sends in the
+// X-Hub-Signature-256 header
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// releaseProcess runs a release process of this binary with the arguments; it is replaced
+// in tests
+var releaseProcess = func(args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// releaseArgs returns the settings given on the command line among the flags, as
+// arguments, so the release processes have them too. The repository, tag and force are
+// set for each release instead.
+func releaseArgs(flags *pflag.FlagSet) []string {
+	var args []string
+	flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		switch f.Name {
+		case "repositoryURL", "repo", "tag", "force":
+			return
+		}
+
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range s.GetSlice() {
+				args = append(args, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		value := f.Value.String()
+		if f.Value.Type() == "stringToString" {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, value))
+	})
+
+	return args
+}
+
+// releaseTag runs the release pipeline for an existing tag on the repository. Each
+// release runs in a process of its own, as the pipeline uses the global settings, so the
+// releases of different repositories can run side by side. The tag already exists, so
+// it is used without prompting.
+func releaseTag(event tagEvent) error {
+	// The repo shorthand is cleared, as it may be set in the config file
+	args := append(releaseArgs(rootCmd.PersistentFlags()), "--repo=", "--repositoryURL", event.repositoryURL, "--tag", event.tag, "--force")
+	return releaseProcess(args...)
+}
+
+// releaseQueued starts the release of each queued tag whose repository has no release
+// running, oldest first, without waiting for them. A finished release wakes the daemon
+// again, to start the next one of its repository.
+func (w *watcher) releaseQueued(q *releaseQueue) {
+	for {
+		event, ok := q.next()
+		if !ok {
+			return
+		}
+
+		noteInfo(fmt.Sprintf("Releasing %s from %s", event.tag, event.repositoryURL))
+		w.stats.started()
+		go func() {
+			err := releaseTag(event)
+			w.stats.finished(time.Now(), err)
+			q.done(event.repositoryURL)
+			if err != nil {
+				reportErr(fmt.Sprintf("failed releasing %s from %s: %s", event.tag, event.repositoryURL, err))
+			}
+		}()
+	}
+}
+
+func watch() error {
+	// Without a secret, anyone who can reach the webhook address could start releases
+	if webhookAddr != "" && webhookSecretEnv == "" {
+		return errors.New("--webhookAddr needs --webhookSecretEnv, to verify the deliveries")
+	}
+
+	urls := []string{repositoryURL}
+	for _, r := range watchRepos {
+		u, err := repositoryURLFromShorthand(r)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, u)
+	}
+
+	w, err := newWatcher(urls, watchTagPattern)
+	if err != nil {
+		return err
+	}
+
+	q := newReleaseQueue()
+
+	// Record the existing tags, so only tags pushed from now on are released
+	w.poll(q)
+
+	// Drafts are only listed for authenticated users, so authorize before polling for them
+	if publishScheduled {
+		auth, err := authenticate()
+		if err != nil {
+			return stageFailed(errAuth, err)
+		}
+		w.publishDue(auth, time.Now())
+	}
+
+	// The webhooks and status endpoints share a server if they're on the same address
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	// Webhooks report new tags on the same channel as polling
+	if webhookAddr != "" {
+		secret, err := secretFromEnv(webhookSecretEnv)
+		if err != nil {
+			return err
+		}
+
+		muxFor(webhookAddr).Handle("/", w.webhookHandler(secret, q))
+		noteInfo(fmt.Sprintf("Listening for webhooks on %s", webhookAddr))
+	}
+
+	if statusAddr != "" {
+		adminToken := ""
+		if adminTokenEnv != "" {
+			adminToken, err = secretFromEnv(adminTokenEnv)
+			if err != nil {
+				return err
+			}
+		}
+
+		mux := muxFor(statusAddr)
+		mux.Handle("/healthz", w.healthHandler(q, watchInterval))
+		mux.Handle("/metrics", w.metricsHandler(q))
+		mux.Handle("/queue", w.queueHandler(q, adminToken))
+		noteInfo(fmt.Sprintf("Serving /healthz, /metrics and /queue on %s", statusAddr))
+	}
+
+	for addr, mux := range muxes {
+		server := &http.Server{Addr: addr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				reportErr(fmt.Sprintf("server on %s failed: %s", server.Addr, err))
+			}
+		}()
+	}
+
+	noteInfo(fmt.Sprintf("Watching %d repositories for tags matching %s", len(w.repos), watchTagPattern))
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	// Releases run in the background, so the polls go on while a release runs and
+	// /healthz doesn't go stale
+	for {
+		select {
+		case <-ticker.C:
+			go w.poll(q)
+			// Tokens expire during a long watch, so authenticate refreshes them
+			if publishScheduled {
+				auth, err := authenticate()
+				if err != nil {
+					reportErr(fmt.Sprintf("failed authenticating to publish scheduled releases: %s", err))
+					continue
+				}
+				go w.publishDue(auth, time.Now())
+			}
+		case <-q.ready:
+			w.releaseQueued(q)
+		}
+	}
+}
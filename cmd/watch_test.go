@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	. "github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// TestWatcherObserve checks only tags pushed after the first observation are released
+func TestWatcherObserve(t *testing.T) {
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	gURL := w.repos["clcollins/go-git-release"]
+
+	Empty(t, w.observe(gURL, []string{"v0.2.0", "v0.1.0"}))
+	Equal(t, []string{"v0.3.0", "v0.4.0"}, w.observe(gURL, []string{"v0.4.0", "nightly", "v0.3.0", "v0.2.0", "v0.1.0"}))
+	Empty(t, w.observe(gURL, []string{"v0.4.0", "nightly", "v0.3.0", "v0.2.0", "v0.1.0"}))
+	Equal(t, []string{"v0.9.0", "v0.10.0"}, w.observe(gURL, []string{"v0.10.0", "v0.4.0", "v0.9.0"}), "tags are released in version order, not Github's")
+
+	_, err = newWatcher(nil, "v[")
+	Error(t, err)
+}
+
+// TestWebhookHandler checks create events for matching tags are queued once, and signatures are verified
+func TestWebhookHandler(t *testing.T) {
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	w.observe(w.repos["clcollins/go-git-release"], []string{"v0.1.0"})
+
+	secret := "s3cret"
+	q := newReleaseQueue()
+	handler := w.webhookHandler(secret, q)
+
+	deliver := func(event, payload, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", event)
+		if signature == "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write([]byte(payload))
+			signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		}
+		req.Header.Set("X-Hub-Signature-256", signature)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	payload := `{"ref":"v0.2.0","ref_type":"tag","repository":{"full_name":"clcollins/go-git-release"}}`
+
+	Equal(t, http.StatusUnauthorized, deliver("create", payload, "sha256=00"))
+	rec := httptest.NewRecorder()
+	w.webhookHandler("", q).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload)))
+	Equal(t, http.StatusUnauthorized, rec.Code, "deliveries are refused without a secret")
+	Equal(t, http.StatusNoContent, deliver("push", payload, ""))
+	Equal(t, http.StatusNoContent, deliver("create", `{"ref":"main","ref_type":"branch","repository":{"full_name":"clcollins/go-git-release"}}`, ""))
+	Equal(t, http.StatusNoContent, deliver("create", `{"ref":"v0.2.0","ref_type":"tag","repository":{"full_name":"someone/else"}}`, ""))
+	Equal(t, http.StatusAccepted, deliver("create", payload, ""))
+	Equal(t, http.StatusNoContent, deliver("create", payload, ""), "redeliveries are ignored")
+	Equal(t, http.StatusAccepted, deliver("create", strings.Replace(payload, "v0.2.0", "v0.3.0", 1), ""), "deliveries don't wait for the queue to be taken from")
+
+	Equal(t, 2, q.depth())
+	event, ok := q.next()
+	True(t, ok)
+	Equal(t, tagEvent{repositoryURL: "git@github.com:clcollins/go-git-release.git", tag: "v0.2.0"}, event)
+
+	// Polling doesn't release the tag again
+	Empty(t, w.observe(w.repos["clcollins/go-git-release"], []string{"v0.2.0", "v0.1.0"}))
+}
+
+// TestListTagsPages checks every page of tags is listed, until one isn't full
+func TestListTagsPages(t *testing.T) {
+	defer gock.Off()
+
+	full := make([]map[string]string, tagsPerPage)
+	for i := range full {
+		full[i] = map[string]string{"name": fmt.Sprintf("v0.0.%d", i)}
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/tags").
+		MatchParam("page", "1").
+		MatchParam("per_page", "100").
+		Reply(200).
+		JSON(full)
+	gock.New("https://api.github.com").
+		Get("/repos/o/r/tags").
+		MatchParam("page", "2").
+		Reply(200).
+		JSON([]map[string]string{{"name": "v1.0.0"}})
+
+	tags, err := listTags(&gitURL{organization: "o", repository: "r"})
+	if !Nil(t, err, "%v", err) {
+		return
+	}
+	Len(t, tags, tagsPerPage+1)
+	Equal(t, "v1.0.0", tags[tagsPerPage])
+	True(t, gock.IsDone())
+}
+
+// TestReleaseArgs checks the settings given on the command line are passed on to the
+// release processes, other than those set for each release
+func TestReleaseArgs(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Bool("draft", false, "")
+	flags.String("tag", "", "")
+	flags.String("releaseName", "", "")
+	flags.StringSlice("alias", nil, "")
+	flags.StringToString("assetMetadata", nil, "")
+	Nil(t, flags.Parse([]string{"--draft", "--tag", "v1.0.0", "--alias", "v1,latest", "--assetMetadata", "os={{ .OS }}"}))
+
+	Equal(t, []string{"--alias=v1", "--alias=latest", "--assetMetadata=os={{ .OS }}", "--draft=true"}, releaseArgs(flags))
+}
+
+// TestReleaseQueued checks each queued tag is released in its own process, and the
+// repository's next release waits for it
+func TestReleaseQueued(t *testing.T) {
+	defer func(p func(...string) error) { releaseProcess = p }(releaseProcess)
+	released := make(chan []string, 2)
+	block := make(chan struct{})
+	releaseProcess = func(args ...string) error {
+		released <- args
+		<-block
+		return nil
+	}
+
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	q := newReleaseQueue()
+	repoURL := "git@github.com:clcollins/go-git-release.git"
+	q.push(tagEvent{repositoryURL: repoURL, tag: "v0.2.0"}, time.Now())
+	q.push(tagEvent{repositoryURL: repoURL, tag: "v0.3.0"}, time.Now())
+
+	<-q.ready
+	w.releaseQueued(q)
+	args := <-released
+	Equal(t, []string{"--repo=", "--repositoryURL", repoURL, "--tag", "v0.2.0", "--force"}, args[len(args)-6:])
+	Equal(t, 1, q.depth(), "the next release of the repository waits")
+
+	close(block)
+	<-q.ready
+	w.releaseQueued(q)
+	args = <-released
+	Equal(t, "v0.3.0", args[len(args)-2])
+}
+
+// TestNewWatcherGithubOnly checks repositories on other forges are refused, as tags are polled with the Github API
+func TestNewWatcherGithubOnly(t *testing.T) {
+	_, err := newWatcher([]string{"git@gitlab.com:o/r.git"}, "v*")
+	EqualError(t, err, "watch only supports Github repositories, not git@gitlab.com:o/r.git")
+}
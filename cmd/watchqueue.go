@@ -0,0 +1,204 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adminTokenEnv names the environment variable with the token cancelling queued releases
+var adminTokenEnv string
+
+// queuedRelease is a tag waiting to be released by the watch daemon
+type queuedRelease struct {
+	RepositoryURL string    `json:"repository_url"`
+	Tag           string    `json:"tag"`
+	QueuedAt      time.Time `json:"queued_at"`
+}
+
+// releaseQueue holds the tags the watch daemon is to release, in the order they were found.
+// A tag is only queued once, however many polls and webhook deliveries report it. The
+// releases of a repository run one at a time, and those of different repositories side
+// by side.
+type releaseQueue struct {
+	mu      sync.Mutex
+	pending []*queuedRelease
+	// running maps repository URLs to the release running for them
+	running map[string]*queuedRelease
+	// ready is signalled when a tag is queued, or a release finishes
+	ready chan struct{}
+}
+
+// newReleaseQueue creates an empty queue
+func newReleaseQueue() *releaseQueue {
+	return &releaseQueue{running: make(map[string]*queuedRelease), ready: make(chan struct{}, 1)}
+}
+
+// signal wakes the daemon to start the releases that are ready
+func (q *releaseQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// push queues the tag, returning false if it is already queued or being released
+func (q *releaseQueue) push(event tagEvent, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if r := q.running[event.repositoryURL]; r != nil && r.Tag == event.tag {
+		return false
+	}
+	if q.indexOf(event.repositoryURL, event.tag) >= 0 {
+		return false
+	}
+	q.pending = append(q.pending, &queuedRelease{RepositoryURL: event.repositoryURL, Tag: event.tag, QueuedAt: now})
+
+	q.signal()
+	return true
+}
+
+// next takes the oldest tag off the queue whose repository has no release running,
+// returning false if there is none. The tag is running until done is called.
+func (q *releaseQueue) next() (tagEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, r := range q.pending {
+		if q.running[r.RepositoryURL] != nil {
+			continue
+		}
+		q.running[r.RepositoryURL] = r
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		return tagEvent{repositoryURL: r.RepositoryURL, tag: r.Tag}, true
+	}
+	return tagEvent{}, false
+}
+
+// done records the release running for the repository has finished, so its next one
+// can start
+func (q *releaseQueue) done(repositoryURL string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, repositoryURL)
+	q.signal()
+}
+
+// cancel removes the tag from the queue, returning false if it isn't queued. The release
+// that is running can't be cancelled.
+func (q *releaseQueue) cancel(repositoryURL, tag string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexOf(repositoryURL, tag)
+	if i < 0 {
+		return false
+	}
+	q.pending = append(q.pending[:i], q.pending[i+1:]...)
+	return true
+}
+
+// indexOf returns the position of the tag in the queue, or -1. The lock must be held.
+func (q *releaseQueue) indexOf(repositoryURL, tag string) int {
+	for i, r := range q.pending {
+		if r.RepositoryURL == repositoryURL && r.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// depth returns the number of tags waiting, not counting those being released
+func (q *releaseQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// queueListing is the GET /queue response
+type queueListing struct {
+	Running []*queuedRelease `json:"running"`
+	Queued  []*queuedRelease `json:"queued"`
+}
+
+// list returns the releases running, and those queued, oldest first
+func (q *releaseQueue) list() *queueListing {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	listing := &queueListing{Running: make([]*queuedRelease, 0, len(q.running)), Queued: make([]*queuedRelease, 0, len(q.pending))}
+	for _, r := range q.running {
+		running := *r
+		listing.Running = append(listing.Running, &running)
+	}
+	sort.Slice(listing.Running, func(i, j int) bool {
+		return listing.Running[i].QueuedAt.Before(listing.Running[j].QueuedAt)
+	})
+	for _, r := range q.pending {
+		queued := *r
+		listing.Queued = append(listing.Queued, &queued)
+	}
+	return listing
+}
+
+// queueHandler lists the queued releases, and with DELETE, cancels the one of
+// ?repository=owner/name&tag=<tag>. Both need the admin token.
+func (w *watcher) queueHandler(q *releaseQueue, adminToken string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if adminToken == "" || !validAdminToken(adminToken, r.Header.Get("Authorization")) {
+			http.Error(rw, "the queue needs the admin token", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			rw.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(rw).Encode(q.list())
+
+		case http.MethodDelete:
+
+			gURL, ok := w.repos[strings.ToLower(r.URL.Query().Get("repository"))]
+			if !ok {
+				http.Error(rw, "repository is not watched", http.StatusNotFound)
+				return
+			}
+			if !q.cancel(gURL.raw, r.URL.Query().Get("tag")) {
+				http.Error(rw, "tag is not queued", http.StatusNotFound)
+				return
+			}
+			noteInfo(fmt.Sprintf("Cancelled the release of %s from %s", r.URL.Query().Get("tag"), gURL.raw))
+			rw.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// validAdminToken checks the Authorization header has the bearer token
+func validAdminToken(token, authorization string) bool {
+	return hmac.Equal([]byte("Bearer "+token), []byte(authorization))
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestReleaseQueue checks tags are released in order, once, and can be cancelled until they run
+func TestReleaseQueue(t *testing.T) {
+	q := newReleaseQueue()
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	repoURL := "git@github.com:clcollins/go-git-release.git"
+
+	True(t, q.push(tagEvent{repositoryURL: repoURL, tag: "v0.2.0"}, now))
+	True(t, q.push(tagEvent{repositoryURL: repoURL, tag: "v0.3.0"}, now))
+	True(t, q.push(tagEvent{repositoryURL: repoURL, tag: "v0.4.0"}, now))
+	False(t, q.push(tagEvent{repositoryURL: repoURL, tag: "v0.2.0"}, now), "duplicates are dropped")
+	Len(t, q.ready, 1)
+
+	event, ok := q.next()
+	True(t, ok)
+	Equal(t, "v0.2.0", event.tag)
+	False(t, q.push(tagEvent{repositoryURL: repoURL, tag: "v0.2.0"}, now), "the running release isn't queued again")
+	False(t, q.cancel(repoURL, "v0.2.0"), "the running release can't be cancelled")
+	True(t, q.cancel(repoURL, "v0.3.0"))
+	q.done(repoURL)
+
+	listing := q.list()
+	Empty(t, listing.Running)
+	Equal(t, []*queuedRelease{{RepositoryURL: repoURL, Tag: "v0.4.0", QueuedAt: now}}, listing.Queued)
+
+	event, ok = q.next()
+	True(t, ok)
+	Equal(t, "v0.4.0", event.tag)
+	q.done(repoURL)
+	_, ok = q.next()
+	False(t, ok)
+}
+
+// TestReleaseQueueRepositories checks the releases of a repository run one at a time, and
+// those of others alongside
+func TestReleaseQueueRepositories(t *testing.T) {
+	q := newReleaseQueue()
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	first, second := "git@github.com:o/first.git", "git@github.com:o/second.git"
+
+	True(t, q.push(tagEvent{repositoryURL: first, tag: "v1.0.0"}, now))
+	True(t, q.push(tagEvent{repositoryURL: first, tag: "v1.1.0"}, now.Add(time.Second)))
+	True(t, q.push(tagEvent{repositoryURL: second, tag: "v2.0.0"}, now.Add(2*time.Second)))
+
+	event, ok := q.next()
+	True(t, ok)
+	Equal(t, tagEvent{repositoryURL: first, tag: "v1.0.0"}, event)
+	event, ok = q.next()
+	True(t, ok)
+	Equal(t, tagEvent{repositoryURL: second, tag: "v2.0.0"}, event, "another repository's release runs alongside")
+	_, ok = q.next()
+	False(t, ok, "the repository's next release waits for the running one")
+
+	listing := q.list()
+	if Len(t, listing.Running, 2) {
+		Equal(t, "v1.0.0", listing.Running[0].Tag)
+		Equal(t, "v2.0.0", listing.Running[1].Tag)
+	}
+
+	<-q.ready
+	q.done(first)
+	Len(t, q.ready, 1, "a finished release wakes the daemon")
+	event, ok = q.next()
+	True(t, ok)
+	Equal(t, tagEvent{repositoryURL: first, tag: "v1.1.0"}, event)
+}
+
+// TestQueueHandler checks the queue is only listed, and releases cancelled, with the admin token
+func TestQueueHandler(t *testing.T) {
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	q := newReleaseQueue()
+	q.push(tagEvent{repositoryURL: "git@github.com:clcollins/go-git-release.git", tag: "v0.2.0"}, time.Now())
+	handler := w.queueHandler(q, "admin")
+
+	serve := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/queue", "").Code)
+	rec := serve(http.MethodGet, "/queue", "admin")
+	Equal(t, http.StatusOK, rec.Code)
+	var listing queueListing
+	Nil(t, json.Unmarshal(rec.Body.Bytes(), &listing))
+	Len(t, listing.Queued, 1)
+
+	cancel := "/queue?repository=clcollins/go-git-release&tag=v0.2.0"
+	Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, cancel, "").Code)
+	Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, cancel, "wrong").Code)
+	Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/queue?repository=someone/else&tag=v0.2.0", "admin").Code)
+	Equal(t, http.StatusNoContent, serve(http.MethodDelete, cancel, "admin").Code)
+	Equal(t, http.StatusNotFound, serve(http.MethodDelete, cancel, "admin").Code)
+	Equal(t, 0, q.depth())
+
+	// Without an admin token, the queue is neither listed nor cancelled
+	handler = w.queueHandler(q, "")
+	Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/queue", "admin").Code)
+	Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, cancel, "admin").Code)
+}
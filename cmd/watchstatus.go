@@ -0,0 +1,165 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmd is the main cobra command package
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statusAddr is the address the watch daemon serves /healthz and /metrics on
+var statusAddr string
+
+// staleFactor is how many poll intervals may pass without a poll before the daemon is unhealthy
+const staleFactor = 3
+
+// watchStats are the counters of the watch daemon, reported by /healthz and /metrics
+type watchStats struct {
+	mu sync.Mutex
+	// releasing is the number of releases running, which count towards the queue
+	releasing    int
+	published    int
+	failed       int
+	pollFailures int
+	lastPoll     time.Time
+	// lastRelease is when the last release was published; failures don't count
+	lastRelease time.Time
+	lastFailure time.Time
+}
+
+// polled records a poll of the watched repositories, and whether any of them failed
+func (s *watchStats) polled(now time.Time, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPoll = now
+	if failed {
+		s.pollFailures++
+	}
+}
+
+// started records a release has started
+func (s *watchStats) started() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releasing++
+}
+
+// finished records the outcome of a release that started
+func (s *watchStats) finished(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releasing--
+	if err != nil {
+		s.failed++
+		s.lastFailure = now
+		return
+	}
+	s.published++
+	s.lastRelease = now
+}
+
+// watchStatus is the /healthz response
+type watchStatus struct {
+	Status       string     `json:"status"`
+	QueueDepth   int        `json:"queue_depth"`
+	Published    int        `json:"published"`
+	Failed       int        `json:"failed"`
+	PollFailures int        `json:"poll_failures"`
+	LastPoll     *time.Time `json:"last_poll,omitempty"`
+	LastRelease  *time.Time `json:"last_release,omitempty"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+}
+
+// status returns the state of the daemon with the tags queued. It is "stale" if the
+// repositories haven't been polled for staleFactor intervals.
+func (s *watchStats) status(queued int, interval time.Duration, now time.Time) *watchStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := &watchStatus{
+		Status:       "ok",
+		QueueDepth:   queued,
+		Published:    s.published,
+		Failed:       s.failed,
+		PollFailures: s.pollFailures,
+		LastPoll:     optionalTime(s.lastPoll),
+		LastRelease:  optionalTime(s.lastRelease),
+		LastFailure:  optionalTime(s.lastFailure),
+	}
+	status.QueueDepth += s.releasing
+	if interval > 0 && now.Sub(s.lastPoll) > staleFactor*interval {
+		status.Status = "stale"
+	}
+
+	return status
+}
+
+// optionalTime returns nil for the zero time, so it is left out of the JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// healthHandler responds with the daemon's status, as 503 if it is stale
+func (w *watcher) healthHandler(q *releaseQueue, interval time.Duration) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		status := w.stats.status(q.depth(), interval, time.Now())
+
+		rw.Header().Set("Content-Type", "application/json")
+		if status.Status != "ok" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(rw).Encode(status)
+	})
+}
+
+// metricsHandler responds with the daemon's status in the Prometheus text format
+func (w *watcher) metricsHandler(q *releaseQueue) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		status := w.stats.status(q.depth(), 0, time.Now())
+
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetric(rw, "go_git_release_queue_depth", "gauge", "Tags waiting to be released, including the one being released.", float64(status.QueueDepth))
+		fmt.Fprintf(rw, "# HELP go_git_release_releases_total Releases run by the daemon, by outcome.\n# TYPE go_git_release_releases_total counter\n")
+		fmt.Fprintf(rw, "go_git_release_releases_total{outcome=\"published\"} %d\n", status.Published)
+		fmt.Fprintf(rw, "go_git_release_releases_total{outcome=\"failed\"} %d\n", status.Failed)
+		writeMetric(rw, "go_git_release_poll_failures_total", "counter", "Polls that failed to list the tags of a watched repository.", float64(status.PollFailures))
+		writeMetric(rw, "go_git_release_last_poll_timestamp_seconds", "gauge", "Time of the last poll of the watched repositories.", unixSeconds(status.LastPoll))
+		writeMetric(rw, "go_git_release_last_release_timestamp_seconds", "gauge", "Time the last release was published.", unixSeconds(status.LastRelease))
+		writeMetric(rw, "go_git_release_last_failure_timestamp_seconds", "gauge", "Time the last release failed.", unixSeconds(status.LastFailure))
+	})
+}
+
+// writeMetric writes a metric without labels, with its help and type
+func writeMetric(rw http.ResponseWriter, name, kind, help string, value float64) {
+	fmt.Fprintf(rw, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// unixSeconds returns the time in seconds since the epoch, or 0 if there isn't one
+func unixSeconds(t *time.Time) float64 {
+	if t == nil {
+		return 0
+	}
+	return float64(t.Unix())
+}
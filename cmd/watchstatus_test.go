@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/stretchr/testify/assert"
+)
+
+// TestWatchStatus checks the queue counts the release running, and stale daemons are unhealthy
+func TestWatchStatus(t *testing.T) {
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	w.stats.polled(now, false)
+	w.stats.started()
+	w.stats.finished(now, errors.New("build failed"))
+	w.stats.started()
+
+	status := w.stats.status(2, time.Minute, now.Add(time.Minute))
+	Equal(t, "ok", status.Status)
+	Equal(t, 3, status.QueueDepth)
+	Equal(t, 1, status.Failed)
+	Nil(t, status.LastRelease)
+
+	w.stats.finished(now.Add(time.Minute), nil)
+	status = w.stats.status(0, time.Minute, now.Add(4*time.Minute))
+	Equal(t, "stale", status.Status)
+	Equal(t, 0, status.QueueDepth)
+	Equal(t, 1, status.Published)
+	Equal(t, now.Add(time.Minute), *status.LastRelease)
+}
+
+// TestHealthHandler checks /healthz reports the status, with 503 once polling has stalled
+func TestHealthHandler(t *testing.T) {
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	q := newReleaseQueue()
+	q.push(tagEvent{repositoryURL: "git@github.com:clcollins/go-git-release.git", tag: "v0.2.0"}, time.Now())
+
+	w.stats.polled(time.Now(), true)
+	rec := httptest.NewRecorder()
+	w.healthHandler(q, time.Minute).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	Equal(t, http.StatusOK, rec.Code)
+
+	var status watchStatus
+	Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	Equal(t, 1, status.QueueDepth)
+	Equal(t, 1, status.PollFailures)
+
+	w.stats.polled(time.Now().Add(-time.Hour), false)
+	rec = httptest.NewRecorder()
+	w.healthHandler(q, time.Minute).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+// TestMetricsHandler checks /metrics has the counters in the Prometheus text format
+func TestMetricsHandler(t *testing.T) {
+	w, err := newWatcher([]string{"git@github.com:clcollins/go-git-release.git"}, "v*")
+	Nil(t, err)
+	w.stats.started()
+	w.stats.finished(time.Unix(1591012800, 0), nil)
+
+	rec := httptest.NewRecorder()
+	w.metricsHandler(newReleaseQueue()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	Equal(t, http.StatusOK, rec.Code)
+	Contains(t, rec.Body.String(), "# TYPE go_git_release_queue_depth gauge\ngo_git_release_queue_depth 0\n")
+	Contains(t, rec.Body.String(), "go_git_release_releases_total{outcome=\"published\"} 1\n")
+	Contains(t, rec.Body.String(), "go_git_release_releases_total{outcome=\"failed\"} 0\n")
+	Contains(t, rec.Body.String(), "go_git_release_last_release_timestamp_seconds 1591012800\n")
+}
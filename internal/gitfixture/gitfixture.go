@@ -0,0 +1,165 @@
+/*
+Copyright © 2020 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gitfixture creates throwaway git repositories with commits and tags for tests,
+// in memory, and bare repositories on disk to push them to
+package gitfixture
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Repo is an in memory repository with a worktree. Its commits and tags are made a minute
+// apart, from 2021-01-01, so their hashes are the same every run.
+type Repo struct {
+	*git.Repository
+	t    testing.TB
+	tree *git.Worktree
+	when time.Time
+}
+
+// New returns an empty in memory repository, failing the test if it can't be created
+func New(t testing.TB) *Repo {
+	t.Helper()
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatalf("cannot create repository: %s", err)
+	}
+	tree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("cannot open worktree: %s", err)
+	}
+
+	return &Repo{Repository: repo, t: t, tree: tree, when: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Signature returns the author of the next commit or tag
+func (r *Repo) Signature() *object.Signature {
+	r.when = r.when.Add(time.Minute)
+	return &object.Signature{Name: "test", Email: "test@example.com", When: r.when}
+}
+
+// Commit writes the files, by path, to the worktree and commits them on the current branch
+func (r *Repo) Commit(message string, files map[string]string) *object.Commit {
+	r.t.Helper()
+
+	for path, content := range files {
+		if err := util.WriteFile(r.tree.Filesystem, path, []byte(content), 0644); err != nil {
+			r.t.Fatalf("cannot write %s: %s", path, err)
+		}
+		if _, err := r.tree.Add(path); err != nil {
+			r.t.Fatalf("cannot add %s: %s", path, err)
+		}
+	}
+
+	return r.commit(message, r.Signature(), nil)
+}
+
+// CommitWith commits the worktree as it is with the parents, rather than on HEAD, eg: to
+// build branches and merges. Without parents, it is committed on HEAD.
+func (r *Repo) CommitWith(message string, parents ...*object.Commit) *object.Commit {
+	r.t.Helper()
+
+	return r.commit(message, r.Signature(), parents)
+}
+
+// CommitAt commits the worktree as it is with the parents, authored and committed at the
+// time, eg: on a clock running behind. The commits after it are still a minute apart.
+func (r *Repo) CommitAt(message string, when time.Time, parents ...*object.Commit) *object.Commit {
+	r.t.Helper()
+
+	return r.commit(message, &object.Signature{Name: "test", Email: "test@example.com", When: when}, parents)
+}
+
+// commit commits the worktree by the signature, with the parents or on HEAD
+func (r *Repo) commit(message string, sig *object.Signature, parents []*object.Commit) *object.Commit {
+	r.t.Helper()
+
+	var hashes []plumbing.Hash
+	for _, p := range parents {
+		hashes = append(hashes, p.Hash)
+	}
+	h, err := r.tree.Commit(message, &git.CommitOptions{Author: sig, Committer: sig, Parents: hashes})
+	if err != nil {
+		r.t.Fatalf("cannot commit %q: %s", message, err)
+	}
+	c, err := r.CommitObject(h)
+	if err != nil {
+		r.t.Fatalf("cannot read commit %s: %s", h, err)
+	}
+
+	return c
+}
+
+// Tag creates a lightweight tag of the commit
+func (r *Repo) Tag(name string, c *object.Commit) *plumbing.Reference {
+	r.t.Helper()
+
+	ref, err := r.CreateTag(name, c.Hash, nil)
+	if err != nil {
+		r.t.Fatalf("cannot tag %s: %s", name, err)
+	}
+
+	return ref
+}
+
+// AnnotatedTag creates an annotated tag of the commit, with the message
+func (r *Repo) AnnotatedTag(name string, c *object.Commit, message string) *object.Tag {
+	r.t.Helper()
+
+	ref, err := r.CreateTag(name, c.Hash, &git.CreateTagOptions{Tagger: r.Signature(), Message: message})
+	if err != nil {
+		r.t.Fatalf("cannot tag %s: %s", name, err)
+	}
+	tag, err := r.TagObject(ref.Hash())
+	if err != nil {
+		r.t.Fatalf("cannot read tag %s: %s", name, err)
+	}
+
+	return tag
+}
+
+// AddRemote adds a remote with the URL, eg: the path of a Bare repository
+func (r *Repo) AddRemote(name, url string) {
+	r.t.Helper()
+
+	if _, err := r.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{url}}); err != nil {
+		r.t.Fatalf("cannot add remote %s: %s", name, err)
+	}
+}
+
+// Bare creates a bare repository in a temporary directory, removed with the test, and
+// returns its path to push to
+func Bare(t testing.TB) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatalf("cannot create bare repository: %s", err)
+	}
+
+	return dir
+}